@@ -2,8 +2,12 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/rendyspratama/digital-discovery/api/versioning"
 )
 
 type Config struct {
@@ -14,6 +18,37 @@ type Config struct {
 	DBPass    string
 	DBName    string
 	DBSSLMode string
+
+	// DBQueryTimeout bounds each repository call made by a handler
+	DBQueryTimeout time.Duration
+
+	// RequestTimeout bounds how long a handler may run before the client
+	// gets a 503; zero disables it
+	RequestTimeout time.Duration
+
+	// RequiredMigration is the schema version /ready waits for
+	RequiredMigration int
+
+	// CategoryCacheTTL is how long category reads are cached; zero disables
+	CategoryCacheTTL time.Duration
+
+	// TrailingSlash is the router's trailing slash policy: strip, redirect
+	// or off
+	TrailingSlash string
+
+	// ShutdownTimeout is how long in-flight requests get to finish on
+	// SIGTERM before the server is closed
+	ShutdownTimeout time.Duration
+
+	// AppVersion is reported by /version; empty uses the version set at
+	// build time
+	AppVersion string
+
+	// DeprecatedBelow marks API versions older than it as deprecated; the
+	// zero version deprecates none. Sunset, if set, is announced as the
+	// date they stop being served.
+	DeprecatedBelow versioning.Version
+	Sunset          time.Time
 }
 
 func LoadConfig() *Config {
@@ -28,6 +63,16 @@ func LoadConfig() *Config {
 		DBPass:    getEnvOrDefault("POSTGRES_PASSWORD", "password"),
 		DBName:    getEnvOrDefault("POSTGRES_DB", "digital_discovery"),
 		DBSSLMode: getEnvOrDefault("POSTGRES_SSL_MODE", "disable"),
+
+		DBQueryTimeout:    getEnvDurationOrDefault("DB_QUERY_TIMEOUT", 5*time.Second),
+		RequestTimeout:    getEnvDurationOrDefault("REQUEST_TIMEOUT", 30*time.Second),
+		RequiredMigration: getEnvIntOrDefault("DB_REQUIRED_MIGRATION", 4),
+		CategoryCacheTTL:  getEnvDurationOrDefault("CATEGORY_CACHE_TTL", 30*time.Second),
+		TrailingSlash:     getEnvOrDefault("TRAILING_SLASH", "strip"),
+		ShutdownTimeout:   getEnvDurationOrDefault("SHUTDOWN_TIMEOUT", 15*time.Second),
+		AppVersion:        getEnvOrDefault("APP_VERSION", ""),
+		DeprecatedBelow:   getEnvVersionOrDefault("API_DEPRECATED_BELOW", versioning.Version{}),
+		Sunset:            getEnvDateOrDefault("API_SUNSET", time.Time{}),
 	}
 
 	return cfg
@@ -40,6 +85,72 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvDurationOrDefault parses a duration such as "5s", falling back to
+// the default when unset or unparsable
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvVersionOrDefault parses an API version such as "v2", falling back
+// to the default when unset or unparsable
+func getEnvVersionOrDefault(key string, defaultValue versioning.Version) versioning.Version {
+	if value := os.Getenv(key); value != "" {
+		if v, err := versioning.ParseVersion(value); err == nil {
+			return v
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDateOrDefault parses a date such as "2025-06-30", falling back to
+// the default when unset or unparsable
+func getEnvDateOrDefault(key string, defaultValue time.Time) time.Time {
+	if value := os.Getenv(key); value != "" {
+		if t, err := time.Parse(time.DateOnly, value); err == nil {
+			return t
+		}
+	}
+	return defaultValue
+}
+
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// getEnvListOrDefault splits a comma separated list, dropping blank items
+func getEnvListOrDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
 // GetDSN returns the database connection string
 func (c *Config) GetDSN() string {
 	return "host=" + c.DBHost +
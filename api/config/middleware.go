@@ -4,15 +4,22 @@ import "time"
 
 type MiddlewareConfig struct {
 	CORS struct {
-		AllowedOrigins []string
-		AllowedMethods []string
-		AllowedHeaders []string
-		MaxAge         int
+		// AllowedOrigins may contain "*", which never matches credentialed
+		// requests: those are only allowed for origins listed explicitly
+		AllowedOrigins   []string
+		AllowedMethods   []string
+		AllowedHeaders   []string
+		AllowCredentials bool
+		MaxAge           int
 	}
 	Logger struct {
 		Format     string
 		TimeFormat string
 		Level      string
+		// LogBodies adds request and response bodies to the access log,
+		// with the values of RedactFields replaced at any depth
+		LogBodies    bool
+		RedactFields []string
 	}
 	Validation struct {
 		MaxBodySize int64
@@ -33,10 +40,11 @@ type ValidationRule struct {
 func LoadMiddlewareConfig() MiddlewareConfig {
 	cfg := MiddlewareConfig{}
 
-	// CORS Configuration
-	cfg.CORS.AllowedOrigins = []string{"*"}
-	cfg.CORS.AllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	cfg.CORS.AllowedHeaders = []string{
+	// CORS Configuration, overridable per environment
+	cfg.CORS.AllowedOrigins = getEnvListOrDefault("CORS_ALLOWED_ORIGINS", []string{"*"})
+	cfg.CORS.AllowedMethods = getEnvListOrDefault("CORS_ALLOWED_METHODS",
+		[]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	cfg.CORS.AllowedHeaders = getEnvListOrDefault("CORS_ALLOWED_HEADERS", []string{
 		"Accept",
 		"Content-Type",
 		"Content-Length",
@@ -44,13 +52,17 @@ func LoadMiddlewareConfig() MiddlewareConfig {
 		"X-CSRF-Token",
 		"Authorization",
 		"X-Request-ID",
-	}
-	cfg.CORS.MaxAge = 86400 // 24 hours
+	})
+	cfg.CORS.AllowCredentials = getEnvBoolOrDefault("CORS_ALLOW_CREDENTIALS", false)
+	cfg.CORS.MaxAge = getEnvIntOrDefault("CORS_MAX_AGE", 86400) // 24 hours
 
 	// Logger Configuration
 	cfg.Logger.Format = "[%s] %s %s %d %s %s %s"
 	cfg.Logger.TimeFormat = time.RFC3339
 	cfg.Logger.Level = "info"
+	cfg.Logger.LogBodies = getEnvBoolOrDefault("LOG_BODIES", false)
+	cfg.Logger.RedactFields = getEnvListOrDefault("LOG_REDACT_FIELDS",
+		[]string{"password", "token", "secret", "authorization", "api_key"})
 
 	// Validation Configuration
 	cfg.Validation.MaxBodySize = 1024 * 1024 // 1MB
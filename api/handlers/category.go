@@ -1,85 +1,154 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rendyspratama/digital-discovery/api/models"
 	"github.com/rendyspratama/digital-discovery/api/repositories"
 	"github.com/rendyspratama/digital-discovery/api/utils"
+	"github.com/rendyspratama/digital-discovery/api/versioning"
 )
 
 type CategoryHandler struct {
-	repo repositories.CategoryRepository
+	repo         repositories.CategoryRepository
+	queryTimeout time.Duration
 }
 
-func NewCategoryHandler(repo repositories.CategoryRepository) *CategoryHandler {
-	return &CategoryHandler{repo: repo}
+func NewCategoryHandler(repo repositories.CategoryRepository, queryTimeout time.Duration) *CategoryHandler {
+	return &CategoryHandler{repo: repo, queryTimeout: queryTimeout}
 }
 
-// V1 Handlers
+// queryContext derives the context for repository calls from the request,
+// so queries stop when the client goes away, and bounds it by the
+// configured statement timeout
+func (h *CategoryHandler) queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if h.queryTimeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), h.queryTimeout)
+}
 
+// GetCategories lists categories in the shape of the request's API
+// version: whole for V1, a page at a time for V2
 func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
 	requestID := r.Context().Value("requestID").(string)
-	categories, err := h.repo.GetAllCategories()
+	transformer := transformerFor(versioning.FromContext(r.Context()))
+
+	if !transformer.Paginated() {
+		ctx, cancel := h.queryContext(r)
+		defer cancel()
+
+		categories, err := h.repo.GetAllCategories(ctx)
+		if err != nil {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError,
+				fmt.Sprintf("Failed to fetch categories: %v", err), requestID)
+			return
+		}
+		utils.WriteSuccessWithRequestID(w, transformer.CategoryList(categories, nil), requestID)
+		return
+	}
+
+	// Parse pagination parameters
+	page, err := utils.QueryInt(r, "page", 1, 1)
+	if err != nil {
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeInvalidQuery, err.Error(), requestID)
+		return
+	}
+
+	perPage, err := utils.QueryInt(r, "per_page", 10, 1)
+	if err != nil {
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeInvalidQuery, err.Error(), requestID)
+		return
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+
+	ctx, cancel := h.queryContext(r)
+	defer cancel()
+
+	categories, total, err := h.repo.GetCategoriesWithPagination(ctx, page, perPage)
 	if err != nil {
-		utils.WriteErrorWithRequestID(w, http.StatusInternalServerError,
-			fmt.Sprintf("Failed to fetch categories: %v", err), requestID)
+		utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError,
+			"Failed to fetch categories", requestID)
 		return
 	}
-	utils.WriteSuccessWithRequestID(w, categories, requestID)
+
+	utils.WriteSuccessWithRequestID(w, transformer.CategoryList(categories, newPageInfo(total, page, perPage)), requestID)
 }
 
 func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
 	requestID := r.Context().Value("requestID").(string)
 	idStr := chi.URLParam(r, "id")
 	if idStr == "" {
-		utils.WriteErrorWithRequestID(w, http.StatusBadRequest,
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeMissingID,
 			"Category ID is required", requestID)
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		utils.WriteErrorWithRequestID(w, http.StatusBadRequest,
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeInvalidID,
 			"Invalid category ID format", requestID)
 		return
 	}
 
-	category, err := h.repo.GetCategoryByID(id)
+	ctx, cancel := h.queryContext(r)
+	defer cancel()
+
+	category, err := h.repo.GetCategoryByID(ctx, id)
 	if err != nil {
-		utils.WriteErrorWithRequestID(w, http.StatusInternalServerError,
+		utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError,
 			"Failed to fetch category", requestID)
 		return
 	}
 	if category == nil {
-		utils.WriteErrorWithRequestID(w, http.StatusNotFound,
+		utils.WriteErrorCodeWithRequestID(w, http.StatusNotFound, utils.CodeCategoryNotFound,
 			"Category not found", requestID)
 		return
 	}
-	utils.WriteSuccessWithRequestID(w, category, requestID)
+
+	etag := utils.ETag(category.ID, category.UpdatedAt)
+	if utils.NotModified(w, r, etag) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	transformer := transformerFor(versioning.FromContext(r.Context()))
+	utils.WriteSuccessWithRequestID(w, transformer.Category(*category), requestID)
 }
 
 func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	requestID := r.Context().Value("requestID").(string)
 	var category models.Category
 	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
-		utils.WriteErrorWithRequestID(w, http.StatusBadRequest,
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeInvalidBody,
 			fmt.Sprintln("Invalid request body", err), requestID)
 		return
 	}
 
 	if err := category.Validate(); err != nil {
-		utils.WriteErrorWithRequestID(w, http.StatusBadRequest,
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeValidationFailed,
 			err.Error(), requestID)
 		return
 	}
 
-	if err := h.repo.CreateCategory(&category); err != nil {
-		utils.WriteErrorWithRequestID(w, http.StatusInternalServerError,
+	ctx, cancel := h.queryContext(r)
+	defer cancel()
+
+	if err := h.repo.CreateCategory(ctx, &category); err != nil {
+		if errors.Is(err, repositories.ErrDuplicateCategory) {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusConflict, utils.CodeDuplicateCategory,
+				fmt.Sprintf("Category %q already exists", category.Name), requestID)
+			return
+		}
+		utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError,
 			"Failed to create category", requestID)
 		return
 	}
@@ -87,110 +156,209 @@ func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request)
 	utils.WriteSuccessWithRequestID(w, category, requestID)
 }
 
+// maxBatchSize bounds a batch create, so one request can't hold a
+// transaction open for an arbitrarily long import
+const maxBatchSize = 500
+
+// batchItemResult is the outcome of one item of a batch create, with the
+// status and error code it would have had as a single create
+type batchItemResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	ID     int    `json:"id,omitempty"`
+	Code   string `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CreateCategoriesBatch creates every valid category in the body's array in
+// one transaction. Items fail independently, so the response is always 207
+// Multi-Status listing each item's result in request order.
+func (h *CategoryHandler) CreateCategoriesBatch(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value("requestID").(string)
+	var categories []*models.Category
+	if err := json.NewDecoder(r.Body).Decode(&categories); err != nil {
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeInvalidBody,
+			"Request body must be a JSON array of categories", requestID)
+		return
+	}
+	if len(categories) == 0 {
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeValidationFailed,
+			"At least one category is required", requestID)
+		return
+	}
+	if len(categories) > maxBatchSize {
+		utils.WriteErrorCodeWithRequestID(w, http.StatusRequestEntityTooLarge, utils.CodePayloadTooLarge,
+			fmt.Sprintf("A batch holds at most %d categories", maxBatchSize), requestID)
+		return
+	}
+
+	results := make([]batchItemResult, len(categories))
+	valid := make([]*models.Category, 0, len(categories))
+	indexes := make([]int, 0, len(categories))
+	for i, category := range categories {
+		if category == nil {
+			results[i] = batchItemResult{Index: i, Status: http.StatusBadRequest, Code: utils.CodeInvalidBody, Error: "category is null"}
+			continue
+		}
+		if err := category.Validate(); err != nil {
+			results[i] = batchItemResult{Index: i, Status: http.StatusBadRequest, Code: utils.CodeValidationFailed, Error: err.Error()}
+			continue
+		}
+		valid = append(valid, category)
+		indexes = append(indexes, i)
+	}
+
+	created := 0
+	if len(valid) > 0 {
+		ctx, cancel := h.queryContext(r)
+		defer cancel()
+
+		itemErrs, err := h.repo.CreateCategoriesBatch(ctx, valid)
+		if err != nil {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError,
+				"Failed to create categories", requestID)
+			return
+		}
+		for j, category := range valid {
+			i := indexes[j]
+			switch err := itemErrs[j]; {
+			case err == nil:
+				results[i] = batchItemResult{Index: i, Status: http.StatusCreated, ID: category.ID}
+				created++
+			case errors.Is(err, repositories.ErrDuplicateCategory):
+				results[i] = batchItemResult{Index: i, Status: http.StatusConflict, Code: utils.CodeDuplicateCategory,
+					Error: fmt.Sprintf("Category %q already exists", category.Name)}
+			default:
+				results[i] = batchItemResult{Index: i, Status: http.StatusInternalServerError, Code: utils.CodeInternalError,
+					Error: "Failed to create category"}
+			}
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusMultiStatus, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"created": created,
+			"failed":  len(categories) - created,
+			"results": results,
+		},
+		"request_id": requestID,
+	})
+}
+
 func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value("requestID").(string)
 	idStr := chi.URLParam(r, "id")
 	if idStr == "" {
-		utils.WriteError(w, http.StatusBadRequest, "Category ID is required")
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeMissingID,
+			"Category ID is required", requestID)
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "Invalid category ID format")
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeInvalidID,
+			"Invalid category ID format", requestID)
 		return
 	}
 
 	var category models.Category
 	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeInvalidBody,
+			"Invalid request body", requestID)
 		return
 	}
 
 	if err := category.Validate(); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeValidationFailed,
+			err.Error(), requestID)
 		return
 	}
 
 	category.ID = id
-	if err := h.repo.UpdateCategory(&category); err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, "Failed to update category")
+	ctx, cancel := h.queryContext(r)
+	defer cancel()
+
+	// The expected version comes from the body or, with If-Match, from the
+	// row the ETag was checked against. Either way the repository only
+	// writes if the row is still at that version, so an update landing
+	// between the check and the write is caught rather than overwritten.
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		current, err := h.repo.GetCategoryByID(ctx, id)
+		if err != nil {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError,
+				"Failed to fetch category", requestID)
+			return
+		}
+		if current == nil {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusNotFound, utils.CodeCategoryNotFound,
+				"Category not found", requestID)
+			return
+		}
+		if !utils.MatchesETag(ifMatch, utils.ETag(current.ID, current.UpdatedAt)) {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusPreconditionFailed, utils.CodePreconditionFailed,
+				"Category has been modified since it was read", requestID)
+			return
+		}
+		if category.Version == 0 {
+			category.Version = current.Version
+		}
+	}
+
+	if err := h.repo.UpdateCategory(ctx, &category); err != nil {
+		if errors.Is(err, repositories.ErrDuplicateCategory) {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusConflict, utils.CodeDuplicateCategory,
+				fmt.Sprintf("Category %q already exists", category.Name), requestID)
+			return
+		}
+		if errors.Is(err, repositories.ErrCategoryNotFound) {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusNotFound, utils.CodeCategoryNotFound,
+				"Category not found", requestID)
+			return
+		}
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusConflict, utils.CodeVersionConflict,
+				"Category has been modified by another update; fetch it and retry", requestID)
+			return
+		}
+		utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError,
+			"Failed to update category", requestID)
 		return
 	}
 
-	utils.WriteSuccess(w, category)
+	w.Header().Set("ETag", utils.ETag(category.ID, category.UpdatedAt))
+	utils.WriteSuccessWithRequestID(w, category, requestID)
 }
 
 func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value("requestID").(string)
 	idStr := chi.URLParam(r, "id")
 	if idStr == "" {
-		utils.WriteError(w, http.StatusBadRequest, "Category ID is required")
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeMissingID,
+			"Category ID is required", requestID)
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "Invalid category ID format")
-		return
-	}
-
-	if err := h.repo.DeleteCategory(id); err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete category")
+		utils.WriteErrorCodeWithRequestID(w, http.StatusBadRequest, utils.CodeInvalidID,
+			"Invalid category ID format", requestID)
 		return
 	}
 
-	utils.WriteSuccess(w, map[string]string{"message": "Category deleted successfully"})
-}
-
-// V2 Handlers
-
-type PaginatedResponse struct {
-	Data       interface{} `json:"data"`
-	Pagination struct {
-		Total       int  `json:"total"`
-		Page        int  `json:"page"`
-		PerPage     int  `json:"per_page"`
-		TotalPages  int  `json:"total_pages"`
-		HasNextPage bool `json:"has_next_page"`
-	} `json:"pagination"`
-}
-
-func (h *CategoryHandler) GetCategoriesV2(w http.ResponseWriter, r *http.Request) {
-	// Parse pagination parameters
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-
-	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
-	if perPage < 1 {
-		perPage = 10
-	}
-	if perPage > 100 {
-		perPage = 100
-	}
+	ctx, cancel := h.queryContext(r)
+	defer cancel()
 
-	// Get categories with pagination
-	categories, total, err := h.repo.GetCategoriesWithPagination(page, perPage)
-	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch categories")
+	if err := h.repo.DeleteCategory(ctx, id); err != nil {
+		if errors.Is(err, repositories.ErrCategoryNotFound) {
+			utils.WriteErrorCodeWithRequestID(w, http.StatusNotFound, utils.CodeCategoryNotFound,
+				"Category not found", requestID)
+			return
+		}
+		utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError,
+			"Failed to delete category", requestID)
 		return
 	}
 
-	// Calculate pagination metadata
-	totalPages := (total + perPage - 1) / perPage
-	if totalPages < 1 {
-		totalPages = 1
-	}
-
-	// Prepare response
-	response := PaginatedResponse{
-		Data: categories,
-	}
-	response.Pagination.Total = total
-	response.Pagination.Page = page
-	response.Pagination.PerPage = perPage
-	response.Pagination.TotalPages = totalPages
-	response.Pagination.HasNextPage = page < totalPages
-
-	utils.WriteSuccess(w, response)
+	utils.WriteSuccessWithRequestID(w, map[string]string{"message": "Category deleted successfully"}, requestID)
 }
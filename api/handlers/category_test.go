@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rendyspratama/digital-discovery/api/models"
+	"github.com/rendyspratama/digital-discovery/api/repositories"
+	"github.com/rendyspratama/digital-discovery/api/utils"
+	"github.com/rendyspratama/digital-discovery/api/versioning"
+)
+
+const testRequestID = "req-123"
+
+// stubRepository is a CategoryRepository whose methods run the matching
+// function field; calling a method without one panics
+type stubRepository struct {
+	repositories.CategoryRepository
+
+	getByID     func(ctx context.Context, id int) (*models.Category, error)
+	update      func(ctx context.Context, category *models.Category) error
+	delete      func(ctx context.Context, id int) error
+	createBatch func(ctx context.Context, categories []*models.Category) ([]error, error)
+}
+
+func (s *stubRepository) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
+	return s.getByID(ctx, id)
+}
+
+func (s *stubRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	return s.update(ctx, category)
+}
+
+func (s *stubRepository) DeleteCategory(ctx context.Context, id int) error {
+	return s.delete(ctx, id)
+}
+
+func (s *stubRepository) CreateCategoriesBatch(ctx context.Context, categories []*models.Category) ([]error, error) {
+	return s.createBatch(ctx, categories)
+}
+
+// serve routes a request for target through handler mounted at pattern, as
+// the RequestID middleware would have left it
+func serve(handler http.HandlerFunc, method, pattern, target, body string, header http.Header) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.MethodFunc(method, pattern, handler)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	ctx := context.WithValue(req.Context(), "requestID", testRequestID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req.WithContext(ctx))
+	return rec
+}
+
+// errorBody is the envelope of an error response
+type errorBody struct {
+	Status    string `json:"status"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+	var body errorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+func TestWritesToMissingCategoryReturnNotFound(t *testing.T) {
+	repo := &stubRepository{
+		update: func(ctx context.Context, category *models.Category) error { return repositories.ErrCategoryNotFound },
+		delete: func(ctx context.Context, id int) error { return repositories.ErrCategoryNotFound },
+	}
+	h := NewCategoryHandler(repo, 0)
+
+	tests := []struct {
+		name string
+		rec  *httptest.ResponseRecorder
+	}{
+		{"update", serve(h.UpdateCategory, http.MethodPut, "/categories/{id}", "/categories/42", `{"name":"Books"}`, nil)},
+		{"delete", serve(h.DeleteCategory, http.MethodDelete, "/categories/{id}", "/categories/42", "", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.rec.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want %d", tt.rec.Code, http.StatusNotFound)
+			}
+			body := decodeError(t, tt.rec)
+			if body.Code != "CATEGORY_NOT_FOUND" || body.RequestID != testRequestID {
+				t.Errorf("body = %+v, want code CATEGORY_NOT_FOUND and request ID %s", body, testRequestID)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	current := models.Category{ID: 42, Name: "Books", Version: 3, UpdatedAt: updatedAt}
+	etag := utils.ETag(current.ID, current.UpdatedAt)
+	stale := utils.ETag(current.ID, updatedAt.Add(-time.Minute))
+
+	updated := false
+	repo := &stubRepository{
+		getByID: func(ctx context.Context, id int) (*models.Category, error) {
+			category := current
+			return &category, nil
+		},
+		update: func(ctx context.Context, category *models.Category) error {
+			updated = true
+			return nil
+		},
+	}
+	h := NewCategoryHandler(repo, 0)
+
+	t.Run("get with current etag", func(t *testing.T) {
+		rec := serve(h.GetCategory, http.MethodGet, "/categories/{id}", "/categories/42", "",
+			http.Header{"If-None-Match": {etag}})
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("status = %d with %d body bytes, want %d and none", rec.Code, rec.Body.Len(), http.StatusNotModified)
+		}
+		if rec.Header().Get("ETag") != etag {
+			t.Errorf("ETag = %q, want %q", rec.Header().Get("ETag"), etag)
+		}
+	})
+
+	t.Run("get with stale etag", func(t *testing.T) {
+		rec := serve(h.GetCategory, http.MethodGet, "/categories/{id}", "/categories/42", "",
+			http.Header{"If-None-Match": {stale}})
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("update with stale etag", func(t *testing.T) {
+		rec := serve(h.UpdateCategory, http.MethodPut, "/categories/{id}", "/categories/42", `{"name":"Comics"}`,
+			http.Header{"If-Match": {stale}})
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusPreconditionFailed)
+		}
+		if body := decodeError(t, rec); body.Code != utils.CodePreconditionFailed {
+			t.Errorf("code = %s, want %s", body.Code, utils.CodePreconditionFailed)
+		}
+		if updated {
+			t.Error("category updated despite the failed precondition")
+		}
+	})
+}
+
+func TestMalformedPaginationReturnsBadRequest(t *testing.T) {
+	h := NewCategoryHandler(&stubRepository{}, 0)
+	// Only V2 paginates
+	v2 := func(w http.ResponseWriter, r *http.Request) {
+		h.GetCategories(w, r.WithContext(versioning.WithVersion(r.Context(), versioning.Version{Major: 2})))
+	}
+
+	for target, param := range map[string]string{
+		"/categories?page=abc":   "page",
+		"/categories?page=0":     "page",
+		"/categories?per_page=x": "per_page",
+	} {
+		t.Run(target, func(t *testing.T) {
+			rec := serve(v2, http.MethodGet, "/categories", target, "", nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			body := decodeError(t, rec)
+			if body.Code != utils.CodeInvalidQuery || !strings.Contains(body.Message, `"`+param+`"`) {
+				t.Errorf("body = %+v, want code %s naming %q", body, utils.CodeInvalidQuery, param)
+			}
+		})
+	}
+}
+
+func TestCreateCategoriesBatchReportsEachItem(t *testing.T) {
+	var received []string
+	repo := &stubRepository{
+		createBatch: func(ctx context.Context, categories []*models.Category) ([]error, error) {
+			itemErrs := make([]error, len(categories))
+			for i, category := range categories {
+				received = append(received, category.Name)
+				if category.Name == "Games" {
+					itemErrs[i] = repositories.ErrDuplicateCategory
+					continue
+				}
+				category.ID = 100 + i
+			}
+			return itemErrs, nil
+		},
+	}
+	h := NewCategoryHandler(repo, 0)
+
+	rec := serve(h.CreateCategoriesBatch, http.MethodPost, "/categories/batch", "/categories/batch",
+		`[{"name":"Books","status":1},{"name":""},null,{"name":"Games","status":1}]`, nil)
+
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMultiStatus)
+	}
+	var body struct {
+		Data struct {
+			Created int               `json:"created"`
+			Failed  int               `json:"failed"`
+			Results []batchItemResult `json:"results"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+
+	// Invalid items never reach the repository
+	if strings.Join(received, ",") != "Books,Games" {
+		t.Errorf("repository received %v, want Books and Games", received)
+	}
+	if body.Data.Created != 1 || body.Data.Failed != 3 {
+		t.Errorf("created %d and failed %d, want 1 and 3", body.Data.Created, body.Data.Failed)
+	}
+	want := []batchItemResult{
+		{Index: 0, Status: http.StatusCreated, ID: 100},
+		{Index: 1, Status: http.StatusBadRequest, Code: utils.CodeValidationFailed},
+		{Index: 2, Status: http.StatusBadRequest, Code: utils.CodeInvalidBody},
+		{Index: 3, Status: http.StatusConflict, Code: utils.CodeDuplicateCategory},
+	}
+	if len(body.Data.Results) != len(want) {
+		t.Fatalf("results = %+v, want %d", body.Data.Results, len(want))
+	}
+	for i, result := range body.Data.Results {
+		result.Error = ""
+		if result != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, result, want[i])
+		}
+	}
+}
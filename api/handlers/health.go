@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/rendyspratama/digital-discovery/api/utils"
+	"github.com/rendyspratama/digital-discovery/buildinfo"
 )
 
 type HealthResponse struct {
@@ -19,3 +23,79 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	}
 	utils.WriteSuccess(w, response)
 }
+
+// VersionHandler reports which build is running
+func VersionHandler(version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteJSON(w, http.StatusOK, buildinfo.Get(version))
+	}
+}
+
+// ReadinessHandler reports whether the API can serve traffic: the database
+// answers and the schema is migrated to at least the required version.
+type ReadinessHandler struct {
+	db                *sql.DB
+	requiredMigration int
+	timeout           time.Duration
+}
+
+func NewReadinessHandler(db *sql.DB, requiredMigration int, timeout time.Duration) *ReadinessHandler {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &ReadinessHandler{
+		db:                db,
+		requiredMigration: requiredMigration,
+		timeout:           timeout,
+	}
+}
+
+func (h *ReadinessHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	status := map[string]interface{}{
+		"status":     "UP",
+		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+		"database":   "UP",
+		"migrations": "UP",
+	}
+
+	if err := h.db.PingContext(ctx); err != nil {
+		status["database"] = "DOWN"
+		status["migrations"] = "UNKNOWN"
+		status["status"] = "DOWN"
+		status["error"] = err.Error()
+	} else if err := h.checkMigrations(ctx); err != nil {
+		status["migrations"] = "DOWN"
+		status["status"] = "DOWN"
+		status["error"] = err.Error()
+	}
+
+	code := http.StatusOK
+	if status["status"] == "DOWN" {
+		code = http.StatusServiceUnavailable
+	}
+	utils.WriteJSON(w, code, status)
+}
+
+// checkMigrations reads golang-migrate's bookkeeping table; a dirty flag
+// means a migration failed halfway and the schema can't be trusted
+func (h *ReadinessHandler) checkMigrations(ctx context.Context) error {
+	var version int
+	var dirty bool
+	err := h.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no migrations applied")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read migration state: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < h.requiredMigration {
+		return fmt.Errorf("schema at migration %d, need %d", version, h.requiredMigration)
+	}
+	return nil
+}
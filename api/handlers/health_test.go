@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionHandlerReportsBuild(t *testing.T) {
+	rec := httptest.NewRecorder()
+	VersionHandler("1.4.0")(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	for _, key := range []string{"version", "commit", "build_date", "go_version"} {
+		if body[key] == "" {
+			t.Errorf("%s missing from %v", key, body)
+		}
+	}
+	if body["version"] != "1.4.0" {
+		t.Errorf("version = %q, want the configured 1.4.0", body["version"])
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/api/models"
+	"github.com/rendyspratama/digital-discovery/api/versioning"
+)
+
+// ResponseTransformer shapes canonical categories into the response body of
+// one API version, so every version is served by the same handlers
+type ResponseTransformer interface {
+	// Paginated reports whether listings are served a page at a time
+	Paginated() bool
+	Category(category models.Category) interface{}
+	// CategoryList shapes a listing; page is nil when it isn't paginated
+	CategoryList(categories []models.Category, page *PageInfo) interface{}
+}
+
+// PageInfo describes the page a paginated listing holds
+type PageInfo struct {
+	Total       int  `json:"total"`
+	Page        int  `json:"page"`
+	PerPage     int  `json:"per_page"`
+	TotalPages  int  `json:"total_pages"`
+	HasNextPage bool `json:"has_next_page"`
+}
+
+// newPageInfo computes the pagination metadata for a page of a listing
+func newPageInfo(total, page, perPage int) *PageInfo {
+	totalPages := (total + perPage - 1) / perPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	return &PageInfo{
+		Total:       total,
+		Page:        page,
+		PerPage:     perPage,
+		TotalPages:  totalPages,
+		HasNextPage: page < totalPages,
+	}
+}
+
+// transformerFor returns the transformer of an API version. Versions
+// without a shape of their own get the newest older one.
+func transformerFor(version versioning.Version) ResponseTransformer {
+	if version.Major >= 2 {
+		return v2Transformer{}
+	}
+	return v1Transformer{}
+}
+
+// v1Transformer serves categories as they are stored, and listings whole
+type v1Transformer struct{}
+
+func (v1Transformer) Paginated() bool { return false }
+
+func (v1Transformer) Category(category models.Category) interface{} {
+	return category
+}
+
+func (v1Transformer) CategoryList(categories []models.Category, _ *PageInfo) interface{} {
+	return categories
+}
+
+// v2Transformer moves bookkeeping fields under metadata and pages listings
+type v2Transformer struct{}
+
+type categoryV2 struct {
+	ID          int                `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Status      int                `json:"status"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	Metadata    categoryMetadataV2 `json:"metadata"`
+}
+
+type categoryMetadataV2 struct {
+	Version int `json:"version"`
+}
+
+// PaginatedResponse is a page of a V2 listing
+type PaginatedResponse struct {
+	Data       interface{} `json:"data"`
+	Pagination *PageInfo   `json:"pagination"`
+}
+
+func (v2Transformer) Paginated() bool { return true }
+
+func (v2Transformer) Category(category models.Category) interface{} {
+	return categoryV2{
+		ID:          category.ID,
+		Name:        category.Name,
+		Description: category.Description,
+		Status:      category.Status,
+		CreatedAt:   category.CreatedAt,
+		UpdatedAt:   category.UpdatedAt,
+		Metadata:    categoryMetadataV2{Version: category.Version},
+	}
+}
+
+func (t v2Transformer) CategoryList(categories []models.Category, page *PageInfo) interface{} {
+	data := make([]interface{}, len(categories))
+	for i, category := range categories {
+		data[i] = t.Category(category)
+	}
+	return PaginatedResponse{Data: data, Pagination: page}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/api/models"
+	"github.com/rendyspratama/digital-discovery/api/versioning"
+)
+
+func TestVersionsShapeTheSameCategory(t *testing.T) {
+	category := models.Category{ID: 7, Name: "Books", Status: 1, Version: 4,
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), UpdatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	repo := &stubRepository{
+		getByID: func(ctx context.Context, id int) (*models.Category, error) {
+			c := category
+			return &c, nil
+		},
+	}
+	h := NewCategoryHandler(repo, 0)
+
+	// get returns the data of the response to GET /categories/7 under major
+	get := func(major int) map[string]interface{} {
+		t.Helper()
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			h.GetCategory(w, r.WithContext(versioning.WithVersion(r.Context(), versioning.Version{Major: major})))
+		}
+		rec := serve(handler, http.MethodGet, "/categories/{id}", "/categories/7", "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("v%d status = %d, want %d", major, rec.Code, http.StatusOK)
+		}
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode %q: %v", rec.Body.String(), err)
+		}
+		return body.Data
+	}
+
+	v1, v2 := get(1), get(2)
+
+	// V1 serves the version at the top level; V2 moves it under metadata
+	if v1["version"] != float64(4) || v1["metadata"] != nil {
+		t.Errorf("v1 = %v, want version 4 at the top level", v1)
+	}
+	metadata, _ := v2["metadata"].(map[string]interface{})
+	if _, ok := v2["version"]; ok || metadata["version"] != float64(4) {
+		t.Errorf("v2 = %v, want version 4 under metadata only", v2)
+	}
+	for _, key := range []string{"id", "name", "status", "created_at", "updated_at"} {
+		if v1[key] != v2[key] {
+			t.Errorf("%s = %v in v1 and %v in v2, want the same", key, v1[key], v2[key])
+		}
+	}
+}
@@ -25,7 +25,7 @@ func main() {
 	cfg := config.LoadConfig()
 
 	// Setup router
-	router := routes.SetupRouter()
+	router := routes.SetupRouter(cfg)
 
 	// Create server
 	srv := &http.Server{
@@ -52,7 +52,7 @@ func main() {
 
 	// Graceful shutdown
 	fmt.Printf("\n%s⏹ Shutting down server...%s\n", blue, reset)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
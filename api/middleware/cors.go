@@ -18,35 +18,81 @@ func NewCORSMiddleware(cfg config.MiddlewareConfig) *CORSMiddleware {
 
 func (c *CORSMiddleware) CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set allowed origins
+		// The response depends on the Origin, so caches must key on it
+		w.Header().Add("Vary", "Origin")
+
 		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
 		if origin != "" {
-			allowed := false
-			for _, allowedOrigin := range c.config.CORS.AllowedOrigins {
-				if allowedOrigin == "*" || allowedOrigin == origin {
-					allowed = true
-					break
+			if allowOrigin, ok := c.allowOrigin(origin); ok {
+				w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+				if c.config.CORS.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				if preflight {
+					c.writePreflight(w, r)
 				}
-			}
-			if allowed {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
 			}
 		}
 
-		// Set allowed methods
-		w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.config.CORS.AllowedMethods, ", "))
+		// A preflight is answered here, so it never reaches the handlers or
+		// the metrics they record; any other OPTIONS request is routed
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
 
-		// Set allowed headers
-		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.config.CORS.AllowedHeaders, ", "))
+		next.ServeHTTP(w, r)
+	})
+}
 
-		// Set max age for preflight requests
-		w.Header().Set("Access-Control-Max-Age", fmt.Sprintf("%d", c.config.CORS.MaxAge))
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, and
+// whether it is allowed. Browsers reject "*" on credentialed responses, and
+// echoing any origin with credentials would let every site act as the
+// user, so with credentials only explicitly listed origins are allowed and
+// they are echoed back.
+func (c *CORSMiddleware) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range c.config.CORS.AllowedOrigins {
+		switch {
+		case strings.EqualFold(allowed, origin):
+			return origin, true
+		case allowed == "*" && !c.config.CORS.AllowCredentials:
+			return "*", true
+		}
+	}
+	return "", false
+}
+
+// writePreflight answers a preflight request for an allowed origin. The
+// requested headers are granted only if every one of them is allowed;
+// otherwise Access-Control-Allow-Headers is left out and the browser
+// blocks the request.
+func (c *CORSMiddleware) writePreflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.config.CORS.AllowedMethods, ", "))
+	w.Header().Set("Access-Control-Max-Age", fmt.Sprintf("%d", c.config.CORS.MaxAge))
+
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if requested == "" {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.config.CORS.AllowedHeaders, ", "))
+		return
+	}
+	for _, header := range strings.Split(requested, ",") {
+		if header = strings.TrimSpace(header); header != "" && !c.headerAllowed(header) {
 			return
 		}
+	}
+	w.Header().Set("Access-Control-Allow-Headers", requested)
+}
 
-		next.ServeHTTP(w, r)
-	})
+func (c *CORSMiddleware) headerAllowed(header string) bool {
+	for _, allowed := range c.config.CORS.AllowedHeaders {
+		if allowed == "*" || strings.EqualFold(allowed, header) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/api/config"
+)
+
+// corsConfig allows origins, with credentials if credentials is set
+func corsConfig(credentials bool, origins ...string) config.MiddlewareConfig {
+	cfg := config.MiddlewareConfig{}
+	cfg.CORS.AllowedOrigins = origins
+	cfg.CORS.AllowedMethods = []string{http.MethodGet, http.MethodPost}
+	cfg.CORS.AllowedHeaders = []string{"Content-Type"}
+	cfg.CORS.AllowCredentials = credentials
+	cfg.CORS.MaxAge = 600
+	return cfg
+}
+
+func TestCORSAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.MiddlewareConfig
+		origin      string
+		allow       string
+		credentials string
+	}{
+		{"listed origin", corsConfig(false, "https://shop.example"), "https://shop.example", "https://shop.example", ""},
+		{"unlisted origin", corsConfig(false, "https://shop.example"), "https://evil.example", "", ""},
+		{"wildcard", corsConfig(false, "*"), "https://evil.example", "*", ""},
+		{"credentialed listed origin", corsConfig(true, "https://shop.example"), "https://shop.example", "https://shop.example", "true"},
+		{"credentialed wildcard", corsConfig(true, "*"), "https://evil.example", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewCORSMiddleware(tt.cfg).CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/categories", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allow)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.credentials)
+			}
+		})
+	}
+}
+
+func TestCORSPreflightIsAnsweredByTheMiddleware(t *testing.T) {
+	called := false
+	handler := NewCORSMiddleware(corsConfig(false, "https://shop.example")).CORS(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/categories", nil)
+	req.Header.Set("Origin", "https://shop.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if called {
+		t.Error("preflight reached the downstream handler")
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	vary := rec.Header().Values("Vary")
+	for _, want := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+		found := false
+		for _, v := range vary {
+			found = found || v == want
+		}
+		if !found {
+			t.Errorf("Vary = %v, missing %s", vary, want)
+		}
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "content-type" {
+		t.Errorf("Access-Control-Allow-Headers = %q, want the requested content-type", got)
+	}
+}
+
+func TestCORSVariesOnOriginForEveryResponse(t *testing.T) {
+	handler := NewCORSMiddleware(corsConfig(false, "https://shop.example")).CORS(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Even a response without CORS headers depends on the Origin
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/categories", nil))
+
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+}
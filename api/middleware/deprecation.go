@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/api/versioning"
+)
+
+// Deprecation warns clients of API versions older than minimum: their
+// responses carry "Deprecation: true" and, when sunset is set, a Sunset
+// header with the date the version stops being served (RFC 8594). The zero
+// minimum deprecates nothing.
+func Deprecation(minimum versioning.Version, sunset time.Time) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if minimum == (versioning.Version{}) {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// An unparsable version is left for the router to reject
+			if version, err := versioning.VersionFromRequest(r); err == nil && version.Less(minimum) {
+				w.Header().Set("Deprecation", "true")
+				if !sunset.IsZero() {
+					w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/api/versioning"
+)
+
+func TestDeprecationMarksOlderVersions(t *testing.T) {
+	sunset := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+	handler := Deprecation(versioning.Version{Major: 2}, sunset)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		path        string
+		deprecation string
+		sunset      string
+	}{
+		{"/api/v1/categories", "true", "Wed, 30 Jun 2027 00:00:00 GMT"},
+		{"/api/v2/categories", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if got := rec.Header().Get("Deprecation"); got != tt.deprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.deprecation)
+			}
+			if got := rec.Header().Get("Sunset"); got != tt.sunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.sunset)
+			}
+		})
+	}
+}
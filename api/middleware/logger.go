@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,27 +22,59 @@ func NewLoggerMiddleware(cfg config.MiddlewareConfig) *LoggerMiddleware {
 	return &LoggerMiddleware{config: cfg}
 }
 
+// LogEntry is one access log line. ContentLength is the request body's
+// declared size, -1 if unknown; ResponseSize counts the body bytes written
+// to the client.
 type LogEntry struct {
-	RequestID    string      `json:"request_id"`
-	Timestamp    string      `json:"timestamp"`
-	Method       string      `json:"method"`
-	Path         string      `json:"path"`
-	Status       int         `json:"status"`
-	Duration     string      `json:"duration"`
-	IP           string      `json:"ip"`
-	UserAgent    string      `json:"user_agent"`
-	QueryParams  string      `json:"query_params,omitempty"`
-	RequestBody  interface{} `json:"request_body,omitempty"`
-	ResponseBody interface{} `json:"response_body,omitempty"`
+	RequestID     string      `json:"request_id"`
+	Timestamp     string      `json:"timestamp"`
+	Method        string      `json:"method"`
+	Path          string      `json:"path"`
+	Status        int         `json:"status"`
+	Duration      string      `json:"duration"`
+	IP            string      `json:"ip"`
+	UserAgent     string      `json:"user_agent"`
+	Referer       string      `json:"referer,omitempty"`
+	QueryParams   string      `json:"query_params,omitempty"`
+	ContentLength int64       `json:"content_length"`
+	ResponseSize  int         `json:"response_size"`
+	RequestBody   interface{} `json:"request_body,omitempty"`
+	ResponseBody  interface{} `json:"response_body,omitempty"`
 }
 
+// maxLoggedBody caps how much of a body that isn't JSON is logged
+const maxLoggedBody = 1024
+
+// maxCapturedBody caps how much of a request or response body is held for
+// the log; a longer body is logged truncated, as text
+const maxCapturedBody = 64 << 10
+
 func (l *LoggerMiddleware) Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		requestID := uuid.New().String()
 
-		// Create new response writer to capture status and body
+		// Reuse the ID RequestID put in the context, so the log matches
+		// the response; only a request it didn't see gets a new one
+		requestID, _ := r.Context().Value("requestID").(string)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+
+		// Create new response writer to capture status and size, and the
+		// body when bodies are logged
 		rw := NewResponseWriter(w)
+		rw.captureBody = l.config.Logger.LogBodies
+
+		// Only the start of the request body is read for the log; the
+		// handler still reads all of it
+		var requestBody []byte
+		if l.config.Logger.LogBodies && r.Body != nil {
+			requestBody, _ = io.ReadAll(io.LimitReader(r.Body, maxCapturedBody))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(requestBody), r.Body), r.Body}
+		}
 
 		// Store request ID in context
 		ctx := r.Context()
@@ -51,19 +86,26 @@ func (l *LoggerMiddleware) Logger(next http.Handler) http.Handler {
 
 		// Create log entry
 		entry := LogEntry{
-			RequestID: requestID,
-			Timestamp: time.Now().Format("2006-01-02 15:04:05.000"),
-			Method:    r.Method,
-			Path:      r.URL.Path,
-			Status:    rw.status,
-			Duration:  fmt.Sprintf("%.3fms", float64(time.Since(start).Microseconds())/1000),
-			IP:        r.RemoteAddr,
-			UserAgent: r.UserAgent(),
+			RequestID:     requestID,
+			Timestamp:     time.Now().Format("2006-01-02 15:04:05.000"),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Status:        rw.Status(),
+			Duration:      fmt.Sprintf("%.3fms", float64(time.Since(start).Microseconds())/1000),
+			IP:            r.RemoteAddr,
+			UserAgent:     r.UserAgent(),
+			Referer:       r.Referer(),
+			ContentLength: r.ContentLength,
+			ResponseSize:  rw.bytes,
 		}
 
 		if r.URL.RawQuery != "" {
 			entry.QueryParams = r.URL.RawQuery
 		}
+		if l.config.Logger.LogBodies {
+			entry.RequestBody = loggableBody(requestBody, l.config.Logger.RedactFields)
+			entry.ResponseBody = loggableBody(rw.body, l.config.Logger.RedactFields)
+		}
 
 		// Pretty print the log entry
 		logJSON, _ := json.MarshalIndent(entry, "", "  ")
@@ -91,3 +133,49 @@ func (l *LoggerMiddleware) Logger(next http.Handler) http.Handler {
 		fmt.Printf("\n%s%s%s\n", color, string(logJSON), reset)
 	})
 }
+
+// loggableBody returns a body for the access log: JSON with the redacted
+// fields masked, or the body as text, truncated, when it isn't JSON
+func loggableBody(body []byte, redact []string) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		if len(body) > maxLoggedBody {
+			return string(body[:maxLoggedBody]) + "...(truncated)"
+		}
+		return string(body)
+	}
+	return redactFields(data, redact)
+}
+
+// redactFields masks the value of every object key named in fields,
+// compared case-insensitively, in nested objects and arrays too
+func redactFields(data interface{}, fields []string) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if containsFold(fields, key) {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redactFields(value, fields)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactFields(value, fields)
+		}
+	}
+	return data
+}
+
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+	return false
+}
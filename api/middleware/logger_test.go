@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/api/config"
+)
+
+// captureEntry runs fn and decodes the access log entry it prints
+func captureEntry(t *testing.T, fn func()) LogEntry {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The entry is wrapped in color codes
+	text := string(out)
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		t.Fatalf("no log entry in %q", text)
+	}
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(text[start:end+1]), &entry); err != nil {
+		t.Fatalf("decode log entry: %v", err)
+	}
+	return entry
+}
+
+func TestLoggerReusesRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(NewLoggerMiddleware(config.MiddlewareConfig{}).Logger(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen, _ = r.Context().Value("requestID").(string)
+		})))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/categories", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	entry := captureEntry(t, func() { handler.ServeHTTP(httptest.NewRecorder(), req) })
+
+	if seen != "req-123" || entry.RequestID != "req-123" {
+		t.Errorf("handler saw %q and log has %q, want req-123 for both", seen, entry.RequestID)
+	}
+}
+
+func TestLoggerCapsCapturedBodies(t *testing.T) {
+	cfg := config.MiddlewareConfig{}
+	cfg.Logger.LogBodies = true
+	size := maxCapturedBody + 100
+
+	var received int
+	handler := NewLoggerMiddleware(cfg).Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = len(body)
+		chunk := []byte(strings.Repeat("b", 1000))
+		for written := 0; written < size; written += len(chunk) {
+			w.Write(chunk[:min(len(chunk), size-written)])
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/categories", strings.NewReader(strings.Repeat("a", size)))
+	rec := httptest.NewRecorder()
+	entry := captureEntry(t, func() { handler.ServeHTTP(rec, req) })
+
+	// The handler and client see whole bodies; the log only their start
+	if received != size {
+		t.Errorf("handler read %d request bytes, want %d", received, size)
+	}
+	if rec.Body.Len() != size || entry.ResponseSize != size {
+		t.Errorf("client got %d bytes and log counts %d, want %d", rec.Body.Len(), entry.ResponseSize, size)
+	}
+	for name, body := range map[string]interface{}{"request": entry.RequestBody, "response": entry.ResponseBody} {
+		if text, _ := body.(string); !strings.HasSuffix(text, "...(truncated)") {
+			t.Errorf("%s body logged as %.40q..., want it truncated", name, text)
+		}
+	}
+}
@@ -3,8 +3,13 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // MetricType represents the type of metric being tracked
@@ -15,6 +20,8 @@ const (
 	MetricErrors    MetricType = "errors"
 	MetricRequests  MetricType = "requests"
 	MetricResponses MetricType = "responses"
+	MetricCacheHit  MetricType = "cache_hits"
+	MetricCacheMiss MetricType = "cache_misses"
 )
 
 // MetricValue represents a metric value with timestamp
@@ -23,17 +30,101 @@ type MetricValue struct {
 	Timestamp time.Time
 }
 
-// MiddlewareMetrics tracks metrics for middleware
+// Defaults for the in-memory samples behind the human-readable summary
+const (
+	DefaultMetricsWindow   = 5 * time.Minute
+	DefaultMetricsCapacity = 2048
+)
+
+// MiddlewareMetrics tracks metrics for middleware. Samples are kept in
+// fixed-size ring buffers and aggregated over a sliding time window for the
+// human-readable summary, and mirrored into Prometheus collectors on a
+// registry owned by this tracker.
 type MiddlewareMetrics struct {
-	mu      sync.RWMutex
-	metrics map[string]map[MetricType][]MetricValue
+	mu       sync.RWMutex
+	metrics  map[string]map[MetricType]*sampleRing
+	window   time.Duration
+	capacity int
+
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+	requestsTotal   *prometheus.CounterVec
+	errorsTotal     *prometheus.CounterVec
+	eventsTotal     *prometheus.CounterVec
 }
 
-// NewMiddlewareMetrics creates a new middleware metrics tracker
+// NewMiddlewareMetrics creates a new middleware metrics tracker using the
+// default window and capacity
 func NewMiddlewareMetrics() *MiddlewareMetrics {
-	return &MiddlewareMetrics{
-		metrics: make(map[string]map[MetricType][]MetricValue),
+	return NewWindowedMiddlewareMetrics(DefaultMetricsWindow, DefaultMetricsCapacity)
+}
+
+// NewWindowedMiddlewareMetrics creates a tracker that aggregates samples
+// from the last window, keeping at most capacity samples per metric
+func NewWindowedMiddlewareMetrics(window time.Duration, capacity int) *MiddlewareMetrics {
+	if window <= 0 {
+		window = DefaultMetricsWindow
+	}
+	if capacity <= 0 {
+		capacity = DefaultMetricsCapacity
 	}
+
+	mm := &MiddlewareMetrics{
+		metrics:  make(map[string]map[MetricType]*sampleRing),
+		window:   window,
+		capacity: capacity,
+		registry: prometheus.NewRegistry(),
+	}
+
+	mm.requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
+	mm.requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "api",
+			Name:      "requests_total",
+			Help:      "Total number of HTTP requests",
+		},
+		[]string{"route", "method", "status"},
+	)
+	mm.errorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "api",
+			Name:      "request_errors_total",
+			Help:      "Total number of HTTP requests answered with a 4xx or 5xx",
+		},
+		[]string{"route"},
+	)
+	mm.eventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "api",
+			Name:      "events_total",
+			Help:      "Events recorded outside the request path, such as cache hits",
+		},
+		[]string{"name", "type"},
+	)
+
+	mm.registry.MustRegister(
+		mm.requestDuration,
+		mm.requestsTotal,
+		mm.errorsTotal,
+		mm.eventsTotal,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return mm
+}
+
+// Handler serves the collected metrics in the Prometheus exposition format
+func (mm *MiddlewareMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(mm.registry, promhttp.HandlerOpts{})
 }
 
 // Track creates a middleware that tracks metrics
@@ -42,7 +133,7 @@ func (mm *MiddlewareMetrics) Track(name string, next http.Handler) http.Handler
 		start := time.Now()
 
 		// Create response writer wrapper to capture status code
-		rw := newResponseWriter(w)
+		rw := NewResponseWriter(w)
 
 		// Track the request
 		mm.recordMetric(name, MetricRequests, 1)
@@ -53,11 +144,18 @@ func (mm *MiddlewareMetrics) Track(name string, next http.Handler) http.Handler
 		// Record metrics
 		duration := time.Since(start)
 		mm.recordMetric(name, MetricLatency, float64(duration.Milliseconds()))
-		mm.recordMetric(name, MetricResponses, float64(rw.status))
+		mm.recordMetric(name, MetricResponses, float64(rw.Status()))
 
-		if rw.status >= 400 {
+		if rw.Status() >= 400 {
 			mm.recordMetric(name, MetricErrors, 1)
 		}
+
+		status := strconv.Itoa(rw.Status())
+		mm.requestDuration.WithLabelValues(name, r.Method, status).Observe(duration.Seconds())
+		mm.requestsTotal.WithLabelValues(name, r.Method, status).Inc()
+		if rw.Status() >= 400 {
+			mm.errorsTotal.WithLabelValues(name).Inc()
+		}
 	})
 }
 
@@ -67,71 +165,98 @@ func (mm *MiddlewareMetrics) recordMetric(middleware string, metricType MetricTy
 	defer mm.mu.Unlock()
 
 	if _, exists := mm.metrics[middleware]; !exists {
-		mm.metrics[middleware] = make(map[MetricType][]MetricValue)
+		mm.metrics[middleware] = make(map[MetricType]*sampleRing)
+	}
+	ring, exists := mm.metrics[middleware][metricType]
+	if !exists {
+		ring = newSampleRing(mm.capacity)
+		mm.metrics[middleware][metricType] = ring
 	}
 
-	mm.metrics[middleware][metricType] = append(
-		mm.metrics[middleware][metricType],
-		MetricValue{Value: value, Timestamp: time.Now()},
-	)
+	ring.add(MetricValue{Value: value, Timestamp: time.Now()})
+}
+
+// windowed returns the samples of metricType under name inside the window.
+// Callers must hold mm.mu.
+func (mm *MiddlewareMetrics) windowed(name string, metricType MetricType) []MetricValue {
+	ring, exists := mm.metrics[name][metricType]
+	if !exists {
+		return nil
+	}
+	return ring.since(time.Now().Add(-mm.window))
+}
 
-	// Keep only last 1000 values
-	if len(mm.metrics[middleware][metricType]) > 1000 {
-		mm.metrics[middleware][metricType] = mm.metrics[middleware][metricType][1:]
+func sumValues(values []MetricValue) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v.Value
 	}
+	return sum
 }
 
-// GetMetrics returns metrics for a middleware
+// Increment records a single occurrence of metricType under name, for
+// counters recorded outside the request path such as cache lookups
+func (mm *MiddlewareMetrics) Increment(name string, metricType MetricType) {
+	mm.recordMetric(name, metricType, 1)
+	mm.eventsTotal.WithLabelValues(name, string(metricType)).Inc()
+}
+
+// GetCount returns the sum of metricType under name within the window
+func (mm *MiddlewareMetrics) GetCount(name string, metricType MetricType) float64 {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	return sumValues(mm.windowed(name, metricType))
+}
+
+// GetMetrics returns the samples for a middleware within the window
 func (mm *MiddlewareMetrics) GetMetrics(middleware string) map[MetricType][]MetricValue {
 	mm.mu.RLock()
 	defer mm.mu.RUnlock()
 
-	if metrics, exists := mm.metrics[middleware]; exists {
-		return metrics
+	types, exists := mm.metrics[middleware]
+	if !exists {
+		return nil
+	}
+	result := make(map[MetricType][]MetricValue, len(types))
+	for metricType := range types {
+		result[metricType] = mm.windowed(middleware, metricType)
 	}
-	return nil
+	return result
 }
 
-// GetAverageLatency returns the average latency for a middleware
+// GetAverageLatency returns the average latency for a middleware over the
+// window
 func (mm *MiddlewareMetrics) GetAverageLatency(middleware string) float64 {
 	mm.mu.RLock()
 	defer mm.mu.RUnlock()
 
-	if metrics, exists := mm.metrics[middleware]; exists {
-		if latencies, exists := metrics[MetricLatency]; exists {
-			var sum float64
-			for _, v := range latencies {
-				sum += v.Value
-			}
-			return sum / float64(len(latencies))
-		}
+	return mm.averageLatency(middleware)
+}
+
+func (mm *MiddlewareMetrics) averageLatency(middleware string) float64 {
+	latencies := mm.windowed(middleware, MetricLatency)
+	if len(latencies) == 0 {
+		return 0
 	}
-	return 0
+	return sumValues(latencies) / float64(len(latencies))
 }
 
-// GetErrorRate returns the error rate for a middleware
+// GetErrorRate returns the percentage of requests for a middleware that
+// failed within the window
 func (mm *MiddlewareMetrics) GetErrorRate(middleware string) float64 {
 	mm.mu.RLock()
 	defer mm.mu.RUnlock()
 
-	if metrics, exists := mm.metrics[middleware]; exists {
-		if requests, hasReqs := metrics[MetricRequests]; hasReqs {
-			if errors, hasErrs := metrics[MetricErrors]; hasErrs {
-				totalReqs := 0.0
-				totalErrs := 0.0
-				for _, v := range requests {
-					totalReqs += v.Value
-				}
-				for _, v := range errors {
-					totalErrs += v.Value
-				}
-				if totalReqs > 0 {
-					return (totalErrs / totalReqs) * 100
-				}
-			}
-		}
+	return mm.errorRate(middleware)
+}
+
+func (mm *MiddlewareMetrics) errorRate(middleware string) float64 {
+	totalReqs := sumValues(mm.windowed(middleware, MetricRequests))
+	if totalReqs == 0 {
+		return 0
 	}
-	return 0
+	return sumValues(mm.windowed(middleware, MetricErrors)) / totalReqs * 100
 }
 
 // String returns a string representation of middleware metrics
@@ -142,8 +267,8 @@ func (mm *MiddlewareMetrics) String() string {
 	result := "Middleware Metrics:\n"
 	for middleware := range mm.metrics {
 		result += fmt.Sprintf("\n%s:\n", middleware)
-		result += fmt.Sprintf("  Average Latency: %.2fms\n", mm.GetAverageLatency(middleware))
-		result += fmt.Sprintf("  Error Rate: %.2f%%\n", mm.GetErrorRate(middleware))
+		result += fmt.Sprintf("  Average Latency: %.2fms\n", mm.averageLatency(middleware))
+		result += fmt.Sprintf("  Error Rate: %.2f%%\n", mm.errorRate(middleware))
 	}
 	return result
 }
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpointExposesTrackedRequests(t *testing.T) {
+	mm := NewMiddlewareMetrics()
+	handler := mm.Track("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/categories/42", nil))
+
+	rec := httptest.NewRecorder()
+	mm.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	for _, want := range []string{
+		"# TYPE api_request_duration_seconds histogram",
+		`api_request_duration_seconds_count{method="GET",route="api",status="404"} 1`,
+		`api_requests_total{method="GET",route="api",status="404"} 1`,
+		`api_request_errors_total{route="api"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("scrape is missing %q", want)
+		}
+	}
+}
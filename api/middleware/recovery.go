@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
 	"runtime/debug"
 	"time"
+
+	"github.com/rendyspratama/digital-discovery/api/utils"
 )
 
 // RecoveryConfig configures the recovery middleware
@@ -28,7 +33,7 @@ func DefaultRecoveryConfig() *RecoveryConfig {
 	return &RecoveryConfig{
 		DisableStackTrace:    false,
 		DisableResponseWrite: false,
-		ErrorHandler:         defaultErrorHandler,
+		ErrorHandler:         panicErrorHandler(os.Getenv("GO_ENV") != "production"),
 		LogHandler:           defaultLogHandler,
 	}
 }
@@ -66,11 +71,21 @@ func Recovery(config *RecoveryConfig) func(http.Handler) http.Handler {
 	}
 }
 
-// defaultErrorHandler is the default error handler
-func defaultErrorHandler(err interface{}, w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
-	fmt.Fprintf(w, `{"error": "Internal Server Error", "message": "%v"}`, err)
+// panicErrorHandler writes the standard error envelope for a recovered
+// panic. The panic value is only included when exposeDetail is set; in
+// production it stays in the log and the client gets the request ID to
+// correlate with it.
+func panicErrorHandler(exposeDetail bool) func(interface{}, http.ResponseWriter, *http.Request) {
+	return func(err interface{}, w http.ResponseWriter, r *http.Request) {
+		requestID, _ := r.Context().Value("requestID").(string)
+
+		message := "Internal server error"
+		if exposeDetail {
+			message = fmt.Sprintf("Internal server error: %v", err)
+		}
+
+		utils.WriteErrorCodeWithRequestID(w, http.StatusInternalServerError, utils.CodeInternalError, message, requestID)
+	}
 }
 
 // defaultLogHandler is the default log handler
@@ -114,7 +129,7 @@ func WithCircuitBreaker(cb *CircuitBreaker, next http.Handler) http.Handler {
 		next.ServeHTTP(rw, r)
 
 		// Update circuit breaker state
-		if rw.status >= 500 {
+		if rw.Status() >= 500 {
 			cb.failures++
 			cb.lastError = time.Now()
 		} else {
@@ -131,30 +146,45 @@ type RetryConfig struct {
 	ShouldRetry func(r *http.Request, status int) bool
 }
 
-// WithRetry adds retry functionality to a handler
+// WithRetry adds retry functionality to a handler. Each attempt is
+// buffered and replayed the request body, and only the response kept in
+// the end is sent to the client.
 func WithRetry(config RetryConfig, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var lastStatus int
-		var lastBody []byte
+		// Every request gets at least one attempt
+		attempts := max(config.MaxAttempts, 1)
+		var last *ResponseWriter
+
+		// Read the body once so every attempt can be given all of it
+		var body []byte
+		if r.Body != nil && attempts > 1 {
+			var err error
+			if body, err = io.ReadAll(r.Body); err != nil {
+				utils.WriteErrorCode(w, http.StatusBadRequest, utils.CodeInvalidBody, "Error reading request body")
+				return
+			}
+			r.Body.Close()
+		}
 
-		for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
-			// Create response writer wrapper to capture status and body
-			rw := NewResponseWriter(w)
+		for attempt := 1; attempt <= attempts; attempt++ {
+			if body != nil {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
 
-			next.ServeHTTP(rw, r)
-			lastStatus = rw.status
-			lastBody = rw.body
+			// Buffer the attempt, so only the response that is kept
+			// reaches the client
+			last = newBufferedResponseWriter(w)
+			next.ServeHTTP(last, r)
 
 			// Check if should retry
-			if !config.ShouldRetry(r, lastStatus) {
+			if !config.ShouldRetry(r, last.Status()) {
 				// Write the successful response
-				w.WriteHeader(lastStatus)
-				w.Write(lastBody)
+				last.flush()
 				return
 			}
 
 			// Don't retry on last attempt
-			if attempt == config.MaxAttempts {
+			if attempt == attempts {
 				break
 			}
 
@@ -163,7 +193,6 @@ func WithRetry(config RetryConfig, next http.Handler) http.Handler {
 		}
 
 		// If all retries failed, return last response
-		w.WriteHeader(lastStatus)
-		w.Write(lastBody)
+		last.flush()
 	})
 }
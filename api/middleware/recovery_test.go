@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryWritesErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name         string
+		exposeDetail bool
+	}{
+		{"development", true},
+		{"production", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Recovery(&RecoveryConfig{
+				DisableStackTrace: true,
+				ErrorHandler:      panicErrorHandler(tt.exposeDetail),
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic("db password leaked")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/categories", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "requestID", "req-123"))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+			}
+			var body struct {
+				Code      string `json:"code"`
+				Message   string `json:"message"`
+				RequestID string `json:"request_id"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %q: %v", rec.Body.String(), err)
+			}
+			if body.Code != "INTERNAL_ERROR" || body.RequestID != "req-123" {
+				t.Errorf("body = %+v, want code INTERNAL_ERROR and request ID req-123", body)
+			}
+			if leaked := strings.Contains(body.Message, "db password leaked"); leaked != tt.exposeDetail {
+				t.Errorf("message = %q, panic value exposed = %v, want %v", body.Message, leaked, tt.exposeDetail)
+			}
+		})
+	}
+}
+
+func TestWithRetrySendsOnlyTheKeptResponse(t *testing.T) {
+	attempts := 0
+	var bodies []string
+	handler := WithRetry(RetryConfig{
+		MaxAttempts: 3,
+		ShouldRetry: func(r *http.Request, status int) bool { return status >= 500 },
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if attempts == 1 {
+			w.Header().Set("X-Attempt", "failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("first attempt failed"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/categories", strings.NewReader(`{"name":"Books"}`)))
+
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" || rec.Header().Get("X-Attempt") != "" {
+		t.Errorf("client got %d %q with X-Attempt %q, want only the 200", rec.Code, rec.Body.String(), rec.Header().Get("X-Attempt"))
+	}
+	// Every attempt is given the whole body
+	for i, body := range bodies {
+		if body != `{"name":"Books"}` {
+			t.Errorf("attempt %d read %q", i+1, body)
+		}
+	}
+}
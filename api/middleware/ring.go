@@ -0,0 +1,41 @@
+package middleware
+
+import "time"
+
+// sampleRing is a fixed-size ring buffer of metric samples. Once full, each
+// new sample overwrites the oldest, so memory stays constant no matter how
+// much traffic is recorded.
+type sampleRing struct {
+	buf  []MetricValue
+	next int
+	full bool
+}
+
+func newSampleRing(capacity int) *sampleRing {
+	return &sampleRing{buf: make([]MetricValue, capacity)}
+}
+
+func (r *sampleRing) add(v MetricValue) {
+	r.buf[r.next] = v
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// since returns the samples recorded at or after cutoff, oldest first
+func (r *sampleRing) since(cutoff time.Time) []MetricValue {
+	var ordered []MetricValue
+	if r.full {
+		ordered = append(ordered, r.buf[r.next:]...)
+	}
+	ordered = append(ordered, r.buf[:r.next]...)
+
+	// Samples are appended in time order, so skip the expired prefix
+	for i, v := range ordered {
+		if !v.Timestamp.Before(cutoff) {
+			return ordered[i:]
+		}
+	}
+	return nil
+}
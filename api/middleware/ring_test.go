@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampleRingAgesOutSamples(t *testing.T) {
+	ring := newSampleRing(3)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		ring.add(MetricValue{Value: float64(i), Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	tests := []struct {
+		name   string
+		cutoff time.Time
+		want   []float64
+	}{
+		// Only the newest three samples survive the overwrite
+		{"all kept", start, []float64{2, 3, 4}},
+		{"expired prefix", start.Add(3 * time.Second), []float64{3, 4}},
+		{"all expired", start.Add(time.Minute), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ring.since(tt.cutoff)
+			if len(got) != len(tt.want) {
+				t.Fatalf("since = %v, want values %v", got, tt.want)
+			}
+			for i, v := range got {
+				if v.Value != tt.want[i] {
+					t.Errorf("since = %v, want values %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMetricsRecord(b *testing.B) {
+	mm := NewMiddlewareMetrics()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mm.recordMetric("api", MetricLatency, float64(i))
+	}
+}
@@ -69,6 +69,16 @@ func (r *Route) Use(middleware func(http.Handler) http.Handler) *Route {
 	return r
 }
 
+// allows reports whether the route serves method
+func (r *Route) allows(method string) bool {
+	for _, allowed := range r.Methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
 // Router handles route registration and middleware
 type Router struct {
 	routes          map[string]*Route
@@ -106,33 +116,33 @@ func (r *Router) Handler() http.Handler {
 			return
 		}
 
-		// Get the appropriate handler
+		// Get the appropriate handler. A path needs both a versioned
+		// handler and a route carrying its methods and middleware; with
+		// either missing it isn't served.
 		handler, err := r.versionedRoutes.GetHandler(req.URL.Path, version)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-
-		// Create route-specific middleware wrapper
-		routeHandler := NewRouteMiddleware(http.HandlerFunc(handler))
-		for _, middleware := range r.routes[req.URL.Path].Middleware {
-			routeHandler.Use(middleware)
+		route, ok := r.routes[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
 		}
 
 		// Check if method is allowed
-		methodAllowed := false
-		for _, method := range r.routes[req.URL.Path].Methods {
-			if method == req.Method {
-				methodAllowed = true
-				break
-			}
-		}
-
-		if !methodAllowed {
+		if !route.allows(req.Method) {
+			w.Header().Set("Allow", strings.Join(route.Methods, ", "))
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
+		// Create route-specific middleware wrapper
+		routeHandler := NewRouteMiddleware(http.HandlerFunc(handler))
+		for _, middleware := range route.Middleware {
+			routeHandler.Use(middleware)
+		}
+
 		routeHandler.Handler().ServeHTTP(w, req)
 	})
 
@@ -148,15 +158,8 @@ func (r *Router) Handler() http.Handler {
 			// Register the route with its middleware
 			mux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 				// Check if method is allowed
-				methodAllowed := false
-				for _, method := range route.Methods {
-					if method == req.Method {
-						methodAllowed = true
-						break
-					}
-				}
-
-				if !methodAllowed {
+				if !route.allows(req.Method) {
+					w.Header().Set("Allow", strings.Join(route.Methods, ", "))
 					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 					return
 				}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/api/versioning"
+)
+
+func TestRouterHandler(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router := NewRouter()
+	router.Register(NewRoute("/api/v1/categories", []string{http.MethodGet}, ok))
+	// Versioned without a route carrying its methods and middleware
+	router.versionedRoutes.AddRoute("/api/v1/orphan", versioning.Version{Major: 1}, ok)
+	handler := router.Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"registered", http.MethodGet, "/api/v1/categories", http.StatusOK},
+		{"method not allowed", http.MethodPost, "/api/v1/categories", http.StatusMethodNotAllowed},
+		{"unregistered", http.MethodGet, "/api/v1/unknown", http.StatusNotFound},
+		{"only versioned", http.MethodGet, "/api/v1/orphan", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/api/utils"
+)
+
+// Timeout bounds how long a handler may run. The request context is
+// cancelled at the deadline, so repository calls made with it abort their
+// query, and the client gets a 503 in the standard error envelope instead
+// of whatever the handler writes afterwards. The response is buffered until
+// the handler returns, as http.TimeoutHandler does. A zero d disables it.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				// Re-raised here so the recovery middleware sees it
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.flushTo(w)
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				if ctx.Err() == context.DeadlineExceeded {
+					requestID, _ := r.Context().Value("requestID").(string)
+					utils.WriteErrorCodeWithRequestID(w, http.StatusServiceUnavailable, utils.CodeRequestTimeout, "Request timed out", requestID)
+				}
+			}
+		})
+	}
+}
+
+// timeoutWriter buffers a handler's response so it can be dropped if the
+// deadline passes first
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+// flushTo copies the buffered response to w; the caller holds mu
+func (tw *timeoutWriter) flushTo(w http.ResponseWriter) {
+	dst := w.Header()
+	for k, vv := range tw.header {
+		dst[k] = vv
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	w.WriteHeader(tw.code)
+	w.Write(tw.buf.Bytes())
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutAnswersWithRequestTimeout(t *testing.T) {
+	handler := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A query stopping with the request context
+		<-r.Context().Done()
+		w.Write([]byte("too late"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/categories", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "requestID", "req-123"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	var body struct {
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if body.Code != "REQUEST_TIMEOUT" || body.RequestID != "req-123" {
+		t.Errorf("body = %+v, want code REQUEST_TIMEOUT and request ID req-123", body)
+	}
+}
+
+func TestTimeoutPassesResponsesInTime(t *testing.T) {
+	handler := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/categories", nil))
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != "created" || rec.Header().Get("ETag") != `"abc"` {
+		t.Errorf("response = %d %q with ETag %q, want the handler's", rec.Code, rec.Body.String(), rec.Header().Get("ETag"))
+	}
+}
@@ -2,12 +2,22 @@ package middleware
 
 import "net/http"
 
-// ResponseWriter is a wrapper around http.ResponseWriter that captures status and body
+// ResponseWriter is a wrapper around http.ResponseWriter that captures the
+// status and the number of body bytes written, and optionally the body
 type ResponseWriter struct {
 	http.ResponseWriter
 	status      int
 	wroteHeader bool
+	// bytes is the size of the body written so far
+	bytes int
+	// body accumulates every Write while buffered is set, or the first
+	// maxCapturedBody bytes while captureBody is
 	body        []byte
+	captureBody bool
+	// buffered holds the headers, status and body back from the client
+	// until flush
+	buffered bool
+	header   http.Header
 }
 
 // NewResponseWriter creates a new response writer
@@ -15,14 +25,40 @@ func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
 	return &ResponseWriter{ResponseWriter: w}
 }
 
+// newBufferedResponseWriter creates a response writer that keeps the
+// response to itself, like httptest.ResponseRecorder, for a middleware that
+// decides afterwards whether to send it
+func newBufferedResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w, buffered: true, header: make(http.Header)}
+}
+
+// Header implements http.ResponseWriter
+func (rw *ResponseWriter) Header() http.Header {
+	if rw.buffered {
+		return rw.header
+	}
+	return rw.ResponseWriter.Header()
+}
+
+// Status returns the response status, which is 200 if the handler wrote
+// nothing
+func (rw *ResponseWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
 // WriteHeader implements http.ResponseWriter
 func (rw *ResponseWriter) WriteHeader(code int) {
 	if rw.wroteHeader {
 		return
 	}
 	rw.status = code
-	rw.ResponseWriter.WriteHeader(code)
 	rw.wroteHeader = true
+	if !rw.buffered {
+		rw.ResponseWriter.WriteHeader(code)
+	}
 }
 
 // Write implements http.ResponseWriter
@@ -30,6 +66,31 @@ func (rw *ResponseWriter) Write(b []byte) (int, error) {
 	if !rw.wroteHeader {
 		rw.WriteHeader(http.StatusOK)
 	}
-	rw.body = b
-	return rw.ResponseWriter.Write(b)
+
+	n := len(b)
+	var err error
+	if !rw.buffered {
+		n, err = rw.ResponseWriter.Write(b)
+	}
+	rw.bytes += n
+	switch {
+	case rw.buffered:
+		rw.body = append(rw.body, b[:n]...)
+	case rw.captureBody:
+		// Captured only for the log, so only the start is kept
+		if room := maxCapturedBody - len(rw.body); room > 0 {
+			rw.body = append(rw.body, b[:min(n, room)]...)
+		}
+	}
+	return n, err
+}
+
+// flush sends a buffered response to the client
+func (rw *ResponseWriter) flush() {
+	dst := rw.ResponseWriter.Header()
+	for k, vv := range rw.header {
+		dst[k] = vv
+	}
+	rw.ResponseWriter.WriteHeader(rw.Status())
+	rw.ResponseWriter.Write(rw.body)
 }
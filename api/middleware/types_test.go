@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriterCapturesEveryChunk(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec)
+	rw.captureBody = true
+
+	for _, chunk := range []string{`{"status":`, `"success",`, `"data":[]}`} {
+		if _, err := rw.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write(%q): %v", chunk, err)
+		}
+	}
+
+	const want = `{"status":"success","data":[]}`
+	if string(rw.body) != want || rec.Body.String() != want {
+		t.Errorf("captured %q and sent %q, want %q for both", rw.body, rec.Body.String(), want)
+	}
+	if rw.bytes != len(want) {
+		t.Errorf("bytes = %d, want %d", rw.bytes, len(want))
+	}
+	if rw.Status() != http.StatusOK {
+		t.Errorf("status = %d, want an implicit %d", rw.Status(), http.StatusOK)
+	}
+}
+
+func TestBufferedResponseWriterHoldsResponseUntilFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newBufferedResponseWriter(rec)
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte(`{"id":`))
+	rw.Write([]byte(`7}`))
+
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Fatalf("client got %q before flush", rec.Body.String())
+	}
+	rw.flush()
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"id":7}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("flushed %d %q with Content-Type %q", rec.Code, rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+}
@@ -36,21 +36,21 @@ func (v *ValidationMiddleware) Validate(next http.Handler) http.Handler {
 
 		// Check content length
 		if r.ContentLength > v.config.Validation.MaxBodySize {
-			utils.WriteError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			utils.WriteErrorCode(w, http.StatusRequestEntityTooLarge, utils.CodePayloadTooLarge, "Request body too large")
 			return
 		}
 
 		// Get the request body from context
 		body, ok := r.Context().Value("requestBody").([]byte)
 		if !ok {
-			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			utils.WriteErrorCode(w, http.StatusBadRequest, utils.CodeInvalidBody, "Invalid request body")
 			return
 		}
 
 		// Try to unmarshal into a map first to check if it's valid JSON
 		var data map[string]interface{}
 		if err := json.Unmarshal(body, &data); err != nil {
-			utils.WriteError(w, http.StatusBadRequest, "Invalid JSON format")
+			utils.WriteErrorCode(w, http.StatusBadRequest, utils.CodeInvalidBody, "Invalid JSON format")
 			return
 		}
 
@@ -61,13 +61,13 @@ func (v *ValidationMiddleware) Validate(next http.Handler) http.Handler {
 		// Get validation rules for the resource
 		rules, ok := v.config.Validation.Rules[resourceType]
 		if !ok {
-			utils.WriteError(w, http.StatusBadRequest, "Unknown resource type")
+			utils.WriteErrorCode(w, http.StatusBadRequest, utils.CodeUnknownResource, "Unknown resource type")
 			return
 		}
 
 		// Validate the data against rules
 		if err := v.validateData(data, rules); err != nil {
-			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			utils.WriteErrorCode(w, http.StatusBadRequest, utils.CodeValidationFailed, err.Error())
 			return
 		}
 
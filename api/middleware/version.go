@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/rendyspratama/digital-discovery/api/utils"
+	"github.com/rendyspratama/digital-discovery/api/versioning"
+)
+
+// APIVersion resolves the request's API version and stores it in the
+// context, where handlers pick the response shape from it
+func APIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, err := versioning.VersionFromRequest(r)
+		if err != nil {
+			utils.WriteErrorCode(w, http.StatusBadRequest, utils.CodeInvalidVersion, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(versioning.WithVersion(r.Context(), version)))
+	})
+}
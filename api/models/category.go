@@ -12,6 +12,10 @@ type Category struct {
 	Status      int       `json:"status"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Version is bumped by every update; sending it back on an update makes
+	// the update fail if someone else changed the category in between
+	Version int `json:"version"`
 }
 
 // Validate checks if the category data is valid
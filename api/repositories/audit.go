@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/rendyspratama/digital-discovery/api/models"
+)
+
+// Audit actions recorded in audit_log
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// actorKey is the context key the authenticated user is stored under
+const actorKey = "auditActor"
+
+// WithActor returns a context whose writes are audited as made by actor.
+// Authentication middleware sets it from the token's subject; writes
+// without one are recorded with no actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// writeAudit records a category mutation in tx, so the entry commits or
+// rolls back with the change itself. before is nil for a create and after
+// is nil for a delete.
+func writeAudit(ctx context.Context, tx *sql.Tx, action string, id int, before, after *models.Category) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (entity, entity_id, action, before, after, request_id, actor)
+		VALUES ('category', $1, $2, $3, $4, $5, $6)
+	`, id, action, beforeJSON, afterJSON, contextString(ctx, "requestID"), contextString(ctx, actorKey))
+	return err
+}
+
+// snapshot encodes a category for audit_log, or NULL for none
+func snapshot(category *models.Category) (interface{}, error) {
+	if category == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(category)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// contextString returns the string stored under key, or NULL when unset
+func contextString(ctx context.Context, key string) interface{} {
+	if value, ok := ctx.Value(key).(string); ok && value != "" {
+		return value
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/api/models"
+)
+
+// CacheObserver is told about every cache lookup, for hit/miss metrics
+type CacheObserver func(hit bool)
+
+type cachedList struct {
+	categories []models.Category
+	expires    time.Time
+}
+
+type cachedCategory struct {
+	category models.Category
+	expires  time.Time
+}
+
+// cachedCategoryRepository wraps a CategoryRepository with a TTL cache for
+// GetAllCategories and GetCategoryByID. Any write clears the whole cache, so
+// readers never see data older than the write they just made.
+type cachedCategoryRepository struct {
+	CategoryRepository
+	ttl      time.Duration
+	observer CacheObserver
+
+	mu   sync.RWMutex
+	all  *cachedList
+	byID map[int]cachedCategory
+	// gen is bumped on every invalidation so a read that raced a write
+	// doesn't store what it fetched before the write landed
+	gen uint64
+}
+
+// NewCachedCategoryRepository decorates repo with a read cache. A ttl of
+// zero or less disables caching and returns repo unchanged.
+func NewCachedCategoryRepository(repo CategoryRepository, ttl time.Duration, observer CacheObserver) CategoryRepository {
+	if ttl <= 0 {
+		return repo
+	}
+	if observer == nil {
+		observer = func(bool) {}
+	}
+	return &cachedCategoryRepository{
+		CategoryRepository: repo,
+		ttl:                ttl,
+		observer:           observer,
+		byID:               make(map[int]cachedCategory),
+	}
+}
+
+func (r *cachedCategoryRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	r.mu.RLock()
+	entry, gen := r.all, r.gen
+	r.mu.RUnlock()
+
+	if entry != nil && time.Now().Before(entry.expires) {
+		r.observer(true)
+		return copyCategories(entry.categories), nil
+	}
+	r.observer(false)
+
+	categories, err := r.CategoryRepository.GetAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if r.gen == gen {
+		r.all = &cachedList{categories: copyCategories(categories), expires: time.Now().Add(r.ttl)}
+	}
+	r.mu.Unlock()
+
+	return categories, nil
+}
+
+func (r *cachedCategoryRepository) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
+	r.mu.RLock()
+	entry, ok := r.byID[id]
+	gen := r.gen
+	r.mu.RUnlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		r.observer(true)
+		category := entry.category
+		return &category, nil
+	}
+	r.observer(false)
+
+	category, err := r.CategoryRepository.GetCategoryByID(ctx, id)
+	if err != nil || category == nil {
+		// Misses aren't cached, so a category created elsewhere shows up
+		// without waiting for the TTL
+		return category, err
+	}
+
+	r.mu.Lock()
+	if r.gen == gen {
+		r.byID[id] = cachedCategory{category: *category, expires: time.Now().Add(r.ttl)}
+	}
+	r.mu.Unlock()
+
+	return category, nil
+}
+
+func (r *cachedCategoryRepository) CreateCategory(ctx context.Context, category *models.Category) error {
+	defer r.invalidate()
+	return r.CategoryRepository.CreateCategory(ctx, category)
+}
+
+func (r *cachedCategoryRepository) CreateCategoriesBatch(ctx context.Context, categories []*models.Category) ([]error, error) {
+	defer r.invalidate()
+	return r.CategoryRepository.CreateCategoriesBatch(ctx, categories)
+}
+
+func (r *cachedCategoryRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	defer r.invalidate()
+	return r.CategoryRepository.UpdateCategory(ctx, category)
+}
+
+func (r *cachedCategoryRepository) DeleteCategory(ctx context.Context, id int) error {
+	defer r.invalidate()
+	return r.CategoryRepository.DeleteCategory(ctx, id)
+}
+
+// invalidate drops every cached entry. It runs even when the write fails,
+// since a failed write may still have partially applied.
+func (r *cachedCategoryRepository) invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.all = nil
+	r.byID = make(map[int]cachedCategory)
+	r.gen++
+}
+
+// copyCategories keeps callers from mutating the cached slice
+func copyCategories(categories []models.Category) []models.Category {
+	if categories == nil {
+		return nil
+	}
+	out := make([]models.Category, len(categories))
+	copy(out, categories)
+	return out
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/api/models"
+)
+
+// countingRepository serves one category and counts the reads reaching it
+type countingRepository struct {
+	CategoryRepository
+	name  string
+	reads int
+}
+
+func (r *countingRepository) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
+	r.reads++
+	if id != 1 {
+		return nil, nil
+	}
+	return &models.Category{ID: 1, Name: r.name}, nil
+}
+
+func (r *countingRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	r.name = category.Name
+	return nil
+}
+
+func TestCachedCategoryRepository(t *testing.T) {
+	ctx := context.Background()
+	backing := &countingRepository{name: "Books"}
+	var hits, misses int
+	repo := NewCachedCategoryRepository(backing, time.Minute, func(hit bool) {
+		if hit {
+			hits++
+		} else {
+			misses++
+		}
+	})
+
+	read := func(id int) *models.Category {
+		t.Helper()
+		category, err := repo.GetCategoryByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetCategoryByID(%d): %v", id, err)
+		}
+		return category
+	}
+
+	// A miss loads the category, and the next read is served from cache
+	read(1)
+	if category := read(1); category.Name != "Books" || backing.reads != 1 {
+		t.Errorf("second read = %+v after %d backing reads, want Books from cache", category, backing.reads)
+	}
+
+	// A missing category isn't cached
+	read(2)
+	read(2)
+	if backing.reads != 3 {
+		t.Errorf("backing reads = %d, want both reads of a missing category to reach it", backing.reads)
+	}
+
+	// A write invalidates, so the next read sees it
+	if err := repo.UpdateCategory(ctx, &models.Category{ID: 1, Name: "Comics"}); err != nil {
+		t.Fatalf("UpdateCategory: %v", err)
+	}
+	if category := read(1); category.Name != "Comics" || backing.reads != 4 {
+		t.Errorf("read after update = %+v after %d backing reads, want Comics from the repository", category, backing.reads)
+	}
+
+	if hits != 1 || misses != 4 {
+		t.Errorf("observed %d hits and %d misses, want 1 and 4", hits, misses)
+	}
+}
+
+func TestCachedCategoryRepositoryDisabledWithoutTTL(t *testing.T) {
+	backing := &countingRepository{}
+	if repo := NewCachedCategoryRepository(backing, 0, nil); repo != CategoryRepository(backing) {
+		t.Errorf("NewCachedCategoryRepository with no TTL = %T, want the repository unchanged", repo)
+	}
+}
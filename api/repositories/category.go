@@ -1,21 +1,48 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/rendyspratama/digital-discovery/api/config"
 	"github.com/rendyspratama/digital-discovery/api/models"
 )
 
+// ErrDuplicateCategory is returned when a write would give a category the
+// name of another one
+var ErrDuplicateCategory = errors.New("a category with this name already exists")
+
+// ErrCategoryNotFound is returned when a write targets a category that
+// doesn't exist
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrVersionConflict is returned when an update names a version the
+// category has already moved past
+var ErrVersionConflict = errors.New("category has been modified by another update")
+
+// uniqueViolation is Postgres' SQLSTATE for a unique constraint violation
+const uniqueViolation = "23505"
+
+// translateError maps driver errors callers act on to repository errors
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrDuplicateCategory
+	}
+	return err
+}
+
 type CategoryRepository interface {
-	GetAllCategories() ([]models.Category, error)
-	GetCategoryByID(id int) (*models.Category, error)
-	CreateCategory(category *models.Category) error
-	UpdateCategory(category *models.Category) error
-	DeleteCategory(id int) error
-	GetCategoriesWithPagination(page, perPage int) ([]models.Category, int, error)
+	GetAllCategories(ctx context.Context) ([]models.Category, error)
+	GetCategoryByID(ctx context.Context, id int) (*models.Category, error)
+	CreateCategory(ctx context.Context, category *models.Category) error
+	CreateCategoriesBatch(ctx context.Context, categories []*models.Category) ([]error, error)
+	UpdateCategory(ctx context.Context, category *models.Category) error
+	DeleteCategory(ctx context.Context, id int) error
+	GetCategoriesWithPagination(ctx context.Context, page, perPage int) ([]models.Category, int, error)
 }
 
 type categoryRepository struct {
@@ -28,9 +55,9 @@ func NewCategoryRepository() CategoryRepository {
 	}
 }
 
-func (r *categoryRepository) GetAllCategories() ([]models.Category, error) {
-	rows, err := r.db.Query(`
-		SELECT id, name, status, created_at, updated_at 
+func (r *categoryRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, status, version, created_at, updated_at 
 		FROM categories 
 		ORDER BY created_at DESC
 	`)
@@ -42,7 +69,7 @@ func (r *categoryRepository) GetAllCategories() ([]models.Category, error) {
 	var categories []models.Category
 	for rows.Next() {
 		var c models.Category
-		err := rows.Scan(&c.ID, &c.Name, &c.Status, &c.CreatedAt, &c.UpdatedAt)
+		err := rows.Scan(&c.ID, &c.Name, &c.Status, &c.Version, &c.CreatedAt, &c.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -51,13 +78,13 @@ func (r *categoryRepository) GetAllCategories() ([]models.Category, error) {
 	return categories, nil
 }
 
-func (r *categoryRepository) GetCategoryByID(id int) (*models.Category, error) {
+func (r *categoryRepository) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
 	var c models.Category
-	err := r.db.QueryRow(`
-		SELECT id, name, status, created_at, updated_at 
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, status, version, created_at, updated_at 
 		FROM categories 
 		WHERE id = $1
-	`, id).Scan(&c.ID, &c.Name, &c.Status, &c.CreatedAt, &c.UpdatedAt)
+	`, id).Scan(&c.ID, &c.Name, &c.Status, &c.Version, &c.CreatedAt, &c.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -68,7 +95,45 @@ func (r *categoryRepository) GetCategoryByID(id int) (*models.Category, error) {
 	return &c, nil
 }
 
-func (r *categoryRepository) CreateCategory(category *models.Category) error {
+// lockCategory reads a category within tx and locks its row until the
+// transaction ends, so the snapshot audited as "before" is the one the
+// write replaces. It returns nil if there is no such category.
+func lockCategory(ctx context.Context, tx *sql.Tx, id int) (*models.Category, error) {
+	var c models.Category
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, name, status, version, created_at, updated_at 
+		FROM categories 
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&c.ID, &c.Name, &c.Status, &c.Version, &c.CreatedAt, &c.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// WithTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise, including when fn panics. Writes that span statements or
+// tables go through it so they apply together or not at all.
+func (r *categoryRepository) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// A no-op once committed
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *categoryRepository) CreateCategory(ctx context.Context, category *models.Category) error {
 	if err := category.Validate(); err != nil {
 		return err
 	}
@@ -77,78 +142,144 @@ func (r *categoryRepository) CreateCategory(category *models.Category) error {
 	category.CreatedAt = now
 	category.UpdatedAt = now
 
-	err := r.db.QueryRow(`
-		INSERT INTO categories (name, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
-	`, category.Name, category.Status, category.CreatedAt, category.UpdatedAt).Scan(&category.ID)
+	return r.WithTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO categories (name, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, version
+		`, category.Name, category.Status, category.CreatedAt, category.UpdatedAt).Scan(&category.ID, &category.Version)
+
+		if err != nil {
+			return translateError(err)
+		}
+		return writeAudit(ctx, tx, AuditCreate, category.ID, nil, category)
+	})
+}
+
+// CreateCategoriesBatch inserts categories in one transaction. Each insert
+// runs under a savepoint, so an item that fails, e.g. on a duplicate name,
+// is rolled back alone and reported at its index in the returned slice;
+// the rest are still committed. The error is for the batch as a whole: when
+// it is set nothing was inserted.
+func (r *categoryRepository) CreateCategoriesBatch(ctx context.Context, categories []*models.Category) ([]error, error) {
+	itemErrs := make([]error, len(categories))
+	err := r.WithTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO categories (name, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, version
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		now := time.Now()
+		for i, category := range categories {
+			if err := category.Validate(); err != nil {
+				itemErrs[i] = err
+				continue
+			}
+			category.CreatedAt = now
+			category.UpdatedAt = now
 
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_item"); err != nil {
+				return err
+			}
+			err := stmt.QueryRowContext(ctx, category.Name, category.Status, category.CreatedAt, category.UpdatedAt).
+				Scan(&category.ID, &category.Version)
+			if err == nil {
+				err = writeAudit(ctx, tx, AuditCreate, category.ID, nil, category)
+			}
+			if err != nil {
+				itemErrs[i] = translateError(err)
+				if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_item"); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_item"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return itemErrs, nil
 }
 
-func (r *categoryRepository) UpdateCategory(category *models.Category) error {
+// UpdateCategory writes the category and bumps its version. A non-zero
+// category.Version makes the write conditional on the stored version still
+// being that one, returning ErrVersionConflict otherwise; zero updates
+// unconditionally.
+func (r *categoryRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
 	if err := category.Validate(); err != nil {
 		return err
 	}
 
 	category.UpdatedAt = time.Now()
 
-	result, err := r.db.Exec(`
-		UPDATE categories 
-		SET name = $1, status = $2, updated_at = $3
-		WHERE id = $4
-	`, category.Name, category.Status, category.UpdatedAt, category.ID)
-
-	if err != nil {
-		return err
-	}
+	return r.WithTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockCategory(ctx, tx, category.ID)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return ErrCategoryNotFound
+		}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
+		err = tx.QueryRowContext(ctx, `
+			UPDATE categories 
+			SET name = $1, status = $2, updated_at = $3, version = version + 1
+			WHERE id = $4 AND ($5 = 0 OR version = $5)
+			RETURNING version
+		`, category.Name, category.Status, category.UpdatedAt, category.ID, category.Version).Scan(&category.Version)
 
-	if rows == 0 {
-		return errors.New("category not found")
-	}
+		if err == sql.ErrNoRows {
+			// The row exists, so its version moved on
+			return ErrVersionConflict
+		}
+		if err != nil {
+			return translateError(err)
+		}
 
-	return nil
+		category.CreatedAt = before.CreatedAt
+		return writeAudit(ctx, tx, AuditUpdate, category.ID, before, category)
+	})
 }
 
-func (r *categoryRepository) DeleteCategory(id int) error {
-	result, err := r.db.Exec("DELETE FROM categories WHERE id = $1", id)
-	if err != nil {
-		return err
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if rows == 0 {
-		return errors.New("category not found")
-	}
+func (r *categoryRepository) DeleteCategory(ctx context.Context, id int) error {
+	return r.WithTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockCategory(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return ErrCategoryNotFound
+		}
 
-	return nil
+		if _, err := tx.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, AuditDelete, id, before, nil)
+	})
 }
 
-func (r *categoryRepository) GetCategoriesWithPagination(page, perPage int) ([]models.Category, int, error) {
+func (r *categoryRepository) GetCategoriesWithPagination(ctx context.Context, page, perPage int) ([]models.Category, int, error) {
 	offset := (page - 1) * perPage
 
 	// Get total count
 	var total int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM categories").Scan(&total)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM categories").Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated results
-	rows, err := r.db.Query(`
-		SELECT id, name, status, created_at, updated_at 
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, status, version, created_at, updated_at 
 		FROM categories 
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -161,7 +292,7 @@ func (r *categoryRepository) GetCategoriesWithPagination(page, perPage int) ([]m
 	var categories []models.Category
 	for rows.Next() {
 		var c models.Category
-		err := rows.Scan(&c.ID, &c.Name, &c.Status, &c.CreatedAt, &c.UpdatedAt)
+		err := rows.Scan(&c.ID, &c.Name, &c.Status, &c.Version, &c.CreatedAt, &c.UpdatedAt)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -0,0 +1,266 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/rendyspratama/digital-discovery/api/models"
+)
+
+// newMockRepository returns a categoryRepository over a sqlmock database
+// and the mock to set expectations on
+func newMockRepository(t *testing.T) (*categoryRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &categoryRepository{db: db}, mock
+}
+
+func assertExpectations(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateCategoryReportsDuplicateName(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO categories").
+		WillReturnError(&pq.Error{Code: uniqueViolation, Message: "duplicate key value violates unique constraint"})
+	mock.ExpectRollback()
+
+	err := repo.CreateCategory(context.Background(), &models.Category{Name: "Books", Status: 1})
+
+	if !errors.Is(err, ErrDuplicateCategory) {
+		t.Errorf("CreateCategory error = %v, want ErrDuplicateCategory", err)
+	}
+	assertExpectations(t, mock)
+}
+
+func TestCreateCategoriesBatchReportsDuplicateItem(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectBegin()
+	insert := mock.ExpectPrepare("INSERT INTO categories")
+	mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+	insert.ExpectQuery().WillReturnError(&pq.Error{Code: uniqueViolation})
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+	insert.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(2, 1))
+	mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	itemErrs, err := repo.CreateCategoriesBatch(context.Background(), []*models.Category{
+		{Name: "Books", Status: 1},
+		{Name: "Games", Status: 1},
+	})
+
+	if err != nil {
+		t.Fatalf("CreateCategoriesBatch: %v", err)
+	}
+	if !errors.Is(itemErrs[0], ErrDuplicateCategory) {
+		t.Errorf("item 0 error = %v, want ErrDuplicateCategory", itemErrs[0])
+	}
+	if itemErrs[1] != nil {
+		t.Errorf("item 1 error = %v, want nil", itemErrs[1])
+	}
+	assertExpectations(t, mock)
+}
+
+func TestWritesToMissingCategoryReportNotFound(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(repo *categoryRepository) error
+	}{
+		{"update", func(repo *categoryRepository) error {
+			return repo.UpdateCategory(context.Background(), &models.Category{ID: 42, Name: "Books"})
+		}},
+		{"delete", func(repo *categoryRepository) error {
+			return repo.DeleteCategory(context.Background(), 42)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT (.+) FROM categories WHERE id = \\$1 FOR UPDATE").
+				WithArgs(42).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status", "version", "created_at", "updated_at"}))
+			mock.ExpectRollback()
+
+			if err := tt.write(repo); !errors.Is(err, ErrCategoryNotFound) {
+				t.Errorf("error = %v, want ErrCategoryNotFound", err)
+			}
+			assertExpectations(t, mock)
+		})
+	}
+}
+
+func TestQueriesStopWithTheirContext(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timedOut, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	for name, ctx := range map[string]context.Context{"cancelled": cancelled, "timed out": timedOut} {
+		t.Run(name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectQuery("SELECT (.+) FROM categories WHERE id = \\$1").
+				WithArgs(42).
+				WillDelayFor(time.Second).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status", "version", "created_at", "updated_at"}))
+
+			start := time.Now()
+			_, err := repo.GetCategoryByID(ctx, 42)
+
+			// The driver reports its own cancellation error, not ctx's
+			if err == nil {
+				t.Error("GetCategoryByID succeeded past its context")
+			}
+			if elapsed := time.Since(start); elapsed >= time.Second {
+				t.Errorf("GetCategoryByID returned after %v, want it to stop with the context", elapsed)
+			}
+		})
+	}
+}
+
+// categoryColumns are the columns lockCategory and the reads scan
+var categoryColumns = []string{"id", "name", "status", "version", "created_at", "updated_at"}
+
+func TestUpdateWithStaleVersionReportsConflict(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM categories WHERE id = \\$1 FOR UPDATE").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(42, "Books", 1, 5, now, now))
+	// The row is at version 5, so the write conditional on version 3
+	// matches nothing
+	mock.ExpectQuery("UPDATE categories").
+		WithArgs("Comics", 1, sqlmock.AnyArg(), 42, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
+
+	err := repo.UpdateCategory(context.Background(), &models.Category{ID: 42, Name: "Comics", Status: 1, Version: 3})
+
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("UpdateCategory error = %v, want ErrVersionConflict", err)
+	}
+	assertExpectations(t, mock)
+}
+
+func TestCreateCategoriesBatchReportsInvalidItems(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectBegin()
+	insert := mock.ExpectPrepare("INSERT INTO categories")
+	// Only the valid items reach the database
+	for _, id := range []int{1, 2} {
+		mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+		insert.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(id, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RELEASE SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectCommit()
+
+	categories := []*models.Category{
+		{Name: "Books", Status: 1},
+		{Name: "", Status: 1},
+		{Name: "Games", Status: 1},
+		{Name: "Music", Status: -1},
+	}
+	itemErrs, err := repo.CreateCategoriesBatch(context.Background(), categories)
+
+	if err != nil {
+		t.Fatalf("CreateCategoriesBatch: %v", err)
+	}
+	for i, wantErr := range []bool{false, true, false, true} {
+		if (itemErrs[i] != nil) != wantErr {
+			t.Errorf("item %d error = %v, want error %v", i, itemErrs[i], wantErr)
+		}
+	}
+	if categories[0].ID != 1 || categories[2].ID != 2 {
+		t.Errorf("inserted IDs = %d and %d, want 1 and 2", categories[0].ID, categories[2].ID)
+	}
+	assertExpectations(t, mock)
+}
+
+func TestWithTxRollsBackOnFailure(t *testing.T) {
+	failed := errors.New("audit insert failed")
+	tests := []struct {
+		name string
+		fn   func(tx *sql.Tx) error
+	}{
+		{"error", func(tx *sql.Tx) error { return failed }},
+		{"panic", func(tx *sql.Tx) error { panic(failed) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			func() {
+				defer func() {
+					if p := recover(); p != nil && p != failed {
+						panic(p)
+					}
+				}()
+				if err := repo.WithTx(context.Background(), tt.fn); !errors.Is(err, failed) {
+					t.Errorf("WithTx error = %v, want %v", err, failed)
+				}
+			}()
+			assertExpectations(t, mock)
+		})
+	}
+}
+
+// jsonArg matches a JSON snapshot argument holding the given name and
+// version
+type jsonArg struct {
+	name    string
+	version int
+}
+
+func (a jsonArg) Match(v driver.Value) bool {
+	data, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var category models.Category
+	if err := json.Unmarshal([]byte(data), &category); err != nil {
+		return false
+	}
+	return category.Name == a.name && category.Version == a.version
+}
+
+func TestUpdateCategoryAuditsBeforeAndAfter(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM categories WHERE id = \\$1 FOR UPDATE").
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(42, "Books", 1, 5, now, now))
+	mock.ExpectQuery("UPDATE categories").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(6))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(42, AuditUpdate, jsonArg{"Books", 5}, jsonArg{"Comics", 6}, "req-123", "alice").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ctx := WithActor(context.WithValue(context.Background(), "requestID", "req-123"), "alice")
+	if err := repo.UpdateCategory(ctx, &models.Category{ID: 42, Name: "Comics", Status: 1}); err != nil {
+		t.Fatalf("UpdateCategory: %v", err)
+	}
+	assertExpectations(t, mock)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/rendyspratama/digital-discovery/api/config"
 	"github.com/rendyspratama/digital-discovery/api/handlers"
 	"github.com/rendyspratama/digital-discovery/api/middleware"
@@ -24,6 +25,12 @@ Authentication
 All API endpoints require authentication via Bearer token in the Authorization header.
 Example: Authorization: Bearer <your-token>
 
+Trailing Slashes
+---------------
+A trailing slash is ignored: /api/v1/categories/ is served the same as
+/api/v1/categories. Set TRAILING_SLASH=redirect to answer with a 301 to the
+canonical path instead, or TRAILING_SLASH=off to return 404.
+
 Health Check
 -----------
 GET /health
@@ -34,6 +41,28 @@ GET /health
     "timestamp": "2024-03-21T15:04:05Z"
   }
 
+GET /ready
+- Description: Check if the API can serve traffic (database reachable and
+  migrations applied up to DB_REQUIRED_MIGRATION)
+- Response: 200 OK, or 503 Service Unavailable while not ready
+  {
+    "status": "UP",
+    "timestamp": "2024-03-21T15:04:05Z",
+    "database": "UP",
+    "migrations": "UP"
+  }
+
+GET /version
+- Description: Identify the running build. version is APP_VERSION, or the
+  version set at build time when that is unset.
+- Response: 200 OK
+  {
+    "version": "1.4.0",
+    "commit": "<git sha>",
+    "build_date": "2024-03-21T15:04:05Z",
+    "go_version": "go1.22.0"
+  }
+
 Categories API v1
 ----------------
 Base path: /api/v1/categories
@@ -99,13 +128,16 @@ GET /api/v1/categories/{id}
 
 4. Update Category
 PUT /api/v1/categories/{id}
-- Description: Update category details
+- Description: Update category details. Send the version last read (or
+  the ETag in If-Match) to update only if nobody changed the category since;
+  otherwise the response is 409 Conflict with code VERSION_CONFLICT.
 - Parameters:
   * id: Category UUID
 - Request Body:
   {
     "name": "string",
-    "description": "string"
+    "description": "string",
+    "version": 3
   }
 - Response: 200 OK
   {
@@ -113,6 +145,7 @@ PUT /api/v1/categories/{id}
       "id": "uuid",
       "name": "string",
       "description": "string",
+      "version": 4,
       "created_at": "timestamp",
       "updated_at": "timestamp"
     }
@@ -125,43 +158,89 @@ DELETE /api/v1/categories/{id}
   * id: Category UUID
 - Response: 204 No Content
 
+6. Batch Create Categories
+POST /api/v1/categories/batch
+- Description: Create up to 500 categories in one transaction. Items
+  succeed or fail independently; each result carries the status and code a
+  single create would have returned.
+- Request Body:
+  [
+    {"name": "string", "description": "string"},
+    ...
+  ]
+- Response: 207 Multi-Status
+  {
+    "status": "success",
+    "data": {
+      "created": 1,
+      "failed": 1,
+      "results": [
+        {"index": 0, "status": 201, "id": 42},
+        {"index": 1, "status": 400, "code": "VALIDATION_FAILED", "error": "name is required"}
+      ]
+    },
+    "request_id": "string"
+  }
+
 Categories API v2
 ----------------
 Base path: /api/v2/categories
 
-1. List Categories (Enhanced)
+V2 is served by the same handlers as V1; only the response shape differs.
+The version can also be chosen with Accept:
+application/vnd.digital-discovery.v2+json or the X-API-Version header.
+
+1. List Categories (Paginated)
 GET /api/v2/categories
-- Description: Get all categories with enhanced features
+- Description: Get a page of categories
 - Query Parameters:
-  * limit (optional): Number of items per page (default: 10)
-  * offset (optional): Starting position (default: 0)
-  * sort (optional): Sort field (name, created_at)
-  * order (optional): Sort order (asc, desc)
+  * page (optional): Page number (default: 1)
+  * per_page (optional): Items per page, at most 100 (default: 10)
 - Response: 200 OK
   {
-    "data": [
-      {
-        "id": "uuid",
-        "name": "string",
-        "description": "string",
-        "created_at": "timestamp",
-        "updated_at": "timestamp",
-        "metadata": {
-          "items_count": integer
+    "status": "success",
+    "data": {
+      "data": [
+        {
+          "id": 1,
+          "name": "string",
+          "description": "string",
+          "status": 1,
+          "created_at": "timestamp",
+          "updated_at": "timestamp",
+          "metadata": {
+            "version": 3
+          }
         }
+      ],
+      "pagination": {
+        "total": 42,
+        "page": 1,
+        "per_page": 10,
+        "total_pages": 5,
+        "has_next_page": true
       }
-    ],
-    "metadata": {
-      "total": integer,
-      "limit": integer,
-      "offset": integer
-    }
+    },
+    "request_id": "string"
   }
 
+2. Get Category by ID
+GET /api/v2/categories/{id}
+- Description: Get a category, shaped as in the listing
+- Response: 200 OK, 304 Not Modified on a matching If-None-Match
+
 Metrics
 -------
 GET /metrics
-- Description: Get API performance metrics
+- Description: Prometheus scrape endpoint
+- Response: 200 OK
+  Prometheus text exposition format, including:
+  * api_request_duration_seconds (histogram, by route/method/status)
+  * api_requests_total and api_request_errors_total
+  * api_events_total (cache hits and misses)
+
+GET /metrics/summary
+- Description: Get a human-readable API performance summary
 - Response: 200 OK
   Content-Type: text/plain
   Shows:
@@ -178,21 +257,32 @@ GET /docs/middleware
   Returns this documentation in HTML format
 `
 
-func SetupRouter() http.Handler {
+func SetupRouter(cfg *config.Config) http.Handler {
 	// Load configurations
 	middlewareConfig := config.LoadMiddlewareConfig()
 
-	// Initialize repositories
-	categoryRepo := repositories.NewCategoryRepository()
-
-	// Initialize handlers
-	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
-
 	// Initialize middleware components
 	logger := middleware.NewLoggerMiddleware(middlewareConfig)
 	cors := middleware.NewCORSMiddleware(middlewareConfig)
 	// validator := middleware.NewValidationMiddleware(middlewareConfig)
 	metrics := middleware.NewMiddlewareMetrics()
+
+	// Initialize repositories, caching category reads
+	categoryRepo := repositories.NewCachedCategoryRepository(
+		repositories.NewCategoryRepository(),
+		cfg.CategoryCacheTTL,
+		func(hit bool) {
+			if hit {
+				metrics.Increment("categories.cache", middleware.MetricCacheHit)
+			} else {
+				metrics.Increment("categories.cache", middleware.MetricCacheMiss)
+			}
+		},
+	)
+
+	// Initialize handlers
+	categoryHandler := handlers.NewCategoryHandler(categoryRepo, cfg.DBQueryTimeout)
+	readiness := handlers.NewReadinessHandler(config.GetDB(), cfg.RequiredMigration, cfg.DBQueryTimeout)
 	// docs := middleware.NewMiddlewareDocs()
 	recovery := middleware.Recovery(middleware.DefaultRecoveryConfig())
 
@@ -200,14 +290,20 @@ func SetupRouter() http.Handler {
 	r := chi.NewRouter()
 
 	// Add global middleware in correct order
+	if slashes := trailingSlashMiddleware(cfg.TrailingSlash); slashes != nil {
+		r.Use(slashes)
+	}
 	r.Use(middleware.RequestID)
 	r.Use(logger.Logger)
 	r.Use(recovery)
 	r.Use(cors.CORS)
 	r.Use(middleware.ResponseMetadata)
+	r.Use(middleware.Timeout(cfg.RequestTimeout))
 
 	// Health check route
 	r.Get("/health", handlers.HealthCheck)
+	r.Get("/ready", readiness.Ready)
+	r.Get("/version", handlers.VersionHandler(cfg.AppVersion))
 
 	// API routes
 	r.Route("/api", func(r chi.Router) {
@@ -215,6 +311,8 @@ func SetupRouter() http.Handler {
 		r.Use(func(next http.Handler) http.Handler {
 			return metrics.Track("api", next)
 		})
+		r.Use(middleware.APIVersion)
+		r.Use(middleware.Deprecation(cfg.DeprecatedBelow, cfg.Sunset))
 
 		// V1 routes
 		r.Route("/v1", func(r chi.Router) {
@@ -229,6 +327,7 @@ func SetupRouter() http.Handler {
 				// r.With(validator.Validate, middleware.BodyParser).
 				// 	Post("/", categoryHandler.CreateCategory)
 				r.Post("/", categoryHandler.CreateCategory)
+				r.Post("/batch", categoryHandler.CreateCategoriesBatch)
 				r.Get("/{id}", categoryHandler.GetCategory)
 				// r.With(validator.Validate, middleware.BodyParser).
 				// 	Put("/{id}", categoryHandler.UpdateCategory)
@@ -244,13 +343,19 @@ func SetupRouter() http.Handler {
 				r.Use(func(next http.Handler) http.Handler {
 					return metrics.Track("v2.categories", next)
 				})
-				r.Get("/", categoryHandler.GetCategoriesV2)
+				// Same handlers as V1, shaped for V2 by the version in
+				// the context
+				r.Get("/", categoryHandler.GetCategories)
+				r.Get("/{id}", categoryHandler.GetCategory)
 			})
 		})
 	})
 
-	// Metrics endpoint
-	r.Get("/metrics", func(w http.ResponseWriter, r *http.Request) {
+	// Prometheus metrics endpoint
+	r.Handle("/metrics", metrics.Handler())
+
+	// Human-readable metrics summary
+	r.Get("/metrics/summary", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 
 		// Get metrics data
@@ -276,6 +381,10 @@ func SetupRouter() http.Handler {
 			fmt.Fprintf(w, "Latency: %.2fms\n", metrics.GetAverageLatency("v2.categories"))
 			fmt.Fprintf(w, "Error Rate: %.2f%%\n", metrics.GetErrorRate("v2.categories"))
 		}
+
+		fmt.Fprintf(w, "\n=== Categories Cache ===\n")
+		fmt.Fprintf(w, "Hits: %.0f\n", metrics.GetCount("categories.cache", middleware.MetricCacheHit))
+		fmt.Fprintf(w, "Misses: %.0f\n", metrics.GetCount("categories.cache", middleware.MetricCacheMiss))
 	})
 
 	// Documentation endpoint
@@ -288,3 +397,17 @@ func SetupRouter() http.Handler {
 
 	return r
 }
+
+// trailingSlashMiddleware returns the middleware for the configured trailing
+// slash policy: "strip" serves /path/ as /path, "redirect" answers it with
+// a 301 to /path, and "off" leaves chi's default of a 404
+func trailingSlashMiddleware(policy string) func(http.Handler) http.Handler {
+	switch policy {
+	case "redirect":
+		return chimiddleware.RedirectSlashes
+	case "off":
+		return nil
+	default:
+		return chimiddleware.StripSlashes
+	}
+}
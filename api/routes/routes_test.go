@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTrailingSlashPolicies(t *testing.T) {
+	tests := []struct {
+		policy   string
+		slash    int
+		location string
+	}{
+		{policy: "strip", slash: http.StatusOK},
+		{policy: "redirect", slash: http.StatusMovedPermanently, location: "/api/v1/categories"},
+		{policy: "off", slash: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			r := chi.NewRouter()
+			if slashes := trailingSlashMiddleware(tt.policy); slashes != nil {
+				r.Use(slashes)
+			}
+			r.Get("/api/v1/categories", func(w http.ResponseWriter, r *http.Request) {})
+
+			for path, want := range map[string]int{"/api/v1/categories": http.StatusOK, "/api/v1/categories/": tt.slash} {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				if rec.Code != want {
+					t.Errorf("GET %s = %d, want %d", path, rec.Code, want)
+				}
+				// chi redirects to a scheme-relative URL on the request's host
+				if location := rec.Header().Get("Location"); want == http.StatusMovedPermanently &&
+					!strings.HasSuffix(location, tt.location) {
+					t.Errorf("GET %s redirects to %q, want %q", path, location, tt.location)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag builds a strong entity tag from a resource's id and last update
+// time. The time is truncated to microseconds, Postgres' resolution, so the
+// tag computed right after a write matches the one computed after reading
+// the row back.
+func ETag(id int, updatedAt time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", id,
+		updatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano))))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// MatchesETag reports whether an If-Match or If-None-Match header value
+// names etag, either directly, in a list, or via the "*" wildcard. Weak
+// validators compare by their opaque tag.
+func MatchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// NotModified writes a 304 carrying the ETag when the request's
+// If-None-Match names it, and reports whether it did so
+func NotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !MatchesETag(header, etag) {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -1,5 +1,11 @@
 package utils
 
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
 type Pagination struct {
 	Limit  int
 	Offset int
@@ -14,3 +20,22 @@ func NewPagination(page, size int) Pagination {
 		Offset: (page - 1) * size,
 	}
 }
+
+// QueryInt reads an integer query parameter. An absent parameter yields def;
+// a present one that isn't an integer of at least min is an error naming the
+// parameter, so handlers can report it instead of silently defaulting.
+func QueryInt(r *http.Request, name string, def, min int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("query parameter %q must be an integer, got %q", name, raw)
+	}
+	if value < min {
+		return 0, fmt.Errorf("query parameter %q must be at least %d, got %d", name, min, value)
+	}
+	return value, nil
+}
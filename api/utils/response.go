@@ -7,11 +7,31 @@ import (
 
 type Response struct {
 	Status  string      `json:"status"`
+	Code    string      `json:"code,omitempty"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
 }
 
+// Error codes returned in the "code" field of error responses. They are
+// part of the API contract: clients branch on them, so never rename one.
+const (
+	CodeInvalidID          = "INVALID_ID"
+	CodeMissingID          = "MISSING_ID"
+	CodeInvalidBody        = "INVALID_REQUEST_BODY"
+	CodeInvalidQuery       = "INVALID_QUERY_PARAMETER"
+	CodeInvalidVersion     = "INVALID_API_VERSION"
+	CodeValidationFailed   = "VALIDATION_FAILED"
+	CodeCategoryNotFound   = "CATEGORY_NOT_FOUND"
+	CodeDuplicateCategory  = "DUPLICATE_CATEGORY"
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+	CodeVersionConflict    = "VERSION_CONFLICT"
+	CodePayloadTooLarge    = "PAYLOAD_TOO_LARGE"
+	CodeUnknownResource    = "UNKNOWN_RESOURCE"
+	CodeRequestTimeout     = "REQUEST_TIMEOUT"
+	CodeInternalError      = "INTERNAL_ERROR"
+)
+
 func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -26,6 +46,16 @@ func WriteError(w http.ResponseWriter, status int, message string) {
 	WriteJSON(w, status, response)
 }
 
+// WriteErrorCode writes an error response carrying a machine-readable code
+func WriteErrorCode(w http.ResponseWriter, status int, code, message string) {
+	response := Response{
+		Status: "error",
+		Code:   code,
+		Error:  message,
+	}
+	WriteJSON(w, status, response)
+}
+
 func WriteSuccess(w http.ResponseWriter, data interface{}) {
 	response := Response{
 		Status: "success",
@@ -54,3 +84,16 @@ func WriteErrorWithRequestID(w http.ResponseWriter, status int, message string,
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(response)
 }
+
+// WriteErrorCodeWithRequestID is WriteErrorWithRequestID with an error code
+func WriteErrorCodeWithRequestID(w http.ResponseWriter, status int, code, message string, requestID string) {
+	response := map[string]interface{}{
+		"status":     "error",
+		"code":       code,
+		"message":    message,
+		"request_id": requestID,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,20 @@
+package versioning
+
+import "context"
+
+// versionKey is the context key the request's API version is stored under
+const versionKey = "apiVersion"
+
+// WithVersion returns a context carrying the request's API version
+func WithVersion(ctx context.Context, version Version) context.Context {
+	return context.WithValue(ctx, versionKey, version)
+}
+
+// FromContext returns the API version stored by WithVersion, or v1.0 when
+// there is none
+func FromContext(ctx context.Context) Version {
+	if version, ok := ctx.Value(versionKey).(Version); ok {
+		return version
+	}
+	return Version{Major: 1, Minor: 0}
+}
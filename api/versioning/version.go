@@ -17,14 +17,32 @@ func (v Version) String() string {
 	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
 }
 
-// ParseVersion parses a version string into a Version struct
+// Less reports whether v is an older version than other
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	return v.Minor < other.Minor
+}
+
+// vendorMediaType is the prefix of the media types that select a version in
+// Accept, e.g. application/vnd.digital-discovery.v2+json
+const vendorMediaType = "application/vnd.digital-discovery."
+
+// ParseVersion parses a version string such as "v1.0" into a Version
+// struct. The minor version may be left out: "v2" is v2.0.
 func ParseVersion(version string) (Version, error) {
 	if !strings.HasPrefix(version, "v") {
 		return Version{}, fmt.Errorf("invalid version format: %s", version)
 	}
 
 	var major, minor int
-	_, err := fmt.Sscanf(version[1:], "%d.%d", &major, &minor)
+	var err error
+	if strings.Contains(version, ".") {
+		_, err = fmt.Sscanf(version[1:], "%d.%d", &major, &minor)
+	} else {
+		_, err = fmt.Sscanf(version[1:], "%d", &major)
+	}
 	if err != nil {
 		return Version{}, fmt.Errorf("invalid version format: %s", version)
 	}
@@ -32,7 +50,26 @@ func ParseVersion(version string) (Version, error) {
 	return Version{Major: major, Minor: minor}, nil
 }
 
-// VersionFromRequest extracts version from request path or header
+// versionFromAccept returns the version named by a vendor media type in an
+// Accept header, and whether there was one
+func versionFromAccept(accept string) (Version, bool, error) {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if !strings.HasPrefix(mediaType, vendorMediaType) {
+			continue
+		}
+
+		version := strings.TrimSuffix(strings.TrimPrefix(mediaType, vendorMediaType), "+json")
+		v, err := ParseVersion(version)
+		return v, true, err
+	}
+	return Version{}, false, nil
+}
+
+// VersionFromRequest extracts the version from the request. The path wins
+// over a vendor media type in Accept, which wins over the X-API-Version
+// header; with none of them the request gets v1.0.
 func VersionFromRequest(r *http.Request) (Version, error) {
 	// First try to get version from path
 	path := r.URL.Path
@@ -45,6 +82,11 @@ func VersionFromRequest(r *http.Request) (Version, error) {
 		}
 	}
 
+	// Then from the media type the client accepts
+	if version, ok, err := versionFromAccept(r.Header.Get("Accept")); ok {
+		return version, err
+	}
+
 	// Then try to get version from header
 	version := r.Header.Get("X-API-Version")
 	if version != "" {
@@ -0,0 +1,55 @@
+package versioning
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		header string
+		want   Version
+	}{
+		{name: "default", path: "/categories", want: Version{Major: 1}},
+		{name: "vendor media type", path: "/categories", accept: "application/vnd.digital-discovery.v2+json", want: Version{Major: 2}},
+		{name: "vendor media type with minor and params", path: "/categories",
+			accept: "text/html, application/vnd.digital-discovery.v2.1+json; q=0.9", want: Version{Major: 2, Minor: 1}},
+		{name: "header", path: "/categories", header: "v2", want: Version{Major: 2}},
+		{name: "media type over header", path: "/categories",
+			accept: "application/vnd.digital-discovery.v2+json", header: "v1", want: Version{Major: 2}},
+		{name: "path over media type", path: "/api/v1/categories",
+			accept: "application/vnd.digital-discovery.v2+json", header: "v2", want: Version{Major: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if tt.header != "" {
+				req.Header.Set("X-API-Version", tt.header)
+			}
+
+			got, err := VersionFromRequest(req)
+			if err != nil {
+				t.Fatalf("VersionFromRequest: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("VersionFromRequest = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionFromRequestRejectsMalformedMediaType(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/categories", nil)
+	req.Header.Set("Accept", "application/vnd.digital-discovery.latest+json")
+
+	if _, err := VersionFromRequest(req); err == nil {
+		t.Error("VersionFromRequest accepted a media type naming no version")
+	}
+}
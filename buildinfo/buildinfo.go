@@ -0,0 +1,60 @@
+// Package buildinfo identifies the running build. The variables are set at
+// build time with -ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/rendyspratama/digital-discovery/buildinfo.Commit=$(git rev-parse HEAD)"
+//
+// A build that doesn't set Commit falls back to the revision the Go
+// toolchain stamps into binaries built from a git checkout.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// Version is the release version, used when the service config sets none
+	Version = "dev"
+	// Commit is the git SHA the binary was built from
+	Commit = ""
+	// BuildDate is when the binary was built, in RFC 3339
+	BuildDate = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the running build's info. version is the version from the
+// service's config; empty uses the one set at build time.
+func Get(version string) Info {
+	if version == "" {
+		version = Version
+	}
+	info := Info{
+		Version:   version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok && info.Commit == "" {
+		for _, setting := range bi.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
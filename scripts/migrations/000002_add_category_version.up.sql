@@ -0,0 +1,3 @@
+-- Version categories for optimistic locking: every update bumps it, and an
+-- update can be made conditional on the version the client last read
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
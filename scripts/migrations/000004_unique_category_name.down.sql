@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_categories_name_unique;
@@ -0,0 +1,3 @@
+-- Category names are unique; the API answers a duplicate with 409. Existing
+-- duplicates must be renamed before this applies.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name_unique ON categories (name);
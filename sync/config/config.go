@@ -1,10 +1,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
 	"github.com/spf13/viper"
 )
 
@@ -15,6 +19,12 @@ type Config struct {
 	Sync           SyncConfig           `yaml:"sync"`
 	Monitoring     MonitoringConfig     `yaml:"monitoring"`
 	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	Startup        StartupConfig        `yaml:"startup"`
+	Shutdown       ShutdownConfig       `yaml:"shutdown"`
+
+	// Source is the config file LoadConfig read, empty when it found none
+	// and used defaults and environment variables only
+	Source string `yaml:"-"`
 }
 
 type AppConfig struct {
@@ -28,57 +38,299 @@ type KafkaConfig struct {
 	Brokers         []string `yaml:"brokers"`
 	GroupID         string   `yaml:"group_id"`
 	TopicPrefix     string   `yaml:"topic_prefix"`
-	AutoOffsetReset string   `yaml:"auto_offset_reset"`
+	AutoOffsetReset string   `yaml:"auto_offset_reset"` // where a group with no committed offset starts
 	SecurityEnabled bool     `yaml:"security_enabled"`
 	SASL            struct {
 		Username string `yaml:"username"`
 		Password string `yaml:"password"`
 	} `yaml:"sasl"`
 	// Security configs to be added later
+
+	// Consumer restart limiting: at most MaxRestarts restarts within
+	// RestartWindow, backing off exponentially from RestartBackoff
+	MaxRestarts    int           `yaml:"max_restarts"`
+	RestartWindow  time.Duration `yaml:"restart_window"`
+	RestartBackoff time.Duration `yaml:"restart_backoff"`
+
+	// Offset audit: log every Nth offset marked for commit per partition
+	// (0 disables) and optionally export the marked offset and lag as
+	// metrics. sarama commits marked offsets on its auto-commit interval
+	OffsetCommitLogEvery int  `yaml:"offset_commit_log_every"`
+	OffsetCommitMetrics  bool `yaml:"offset_commit_metrics"`
+
+	// DeserializeErrorPolicy handles messages that can't be decoded:
+	// "skip" dead-letters and commits them, "retry" reprocesses them until
+	// they succeed, "halt" pauses the partition
+	DeserializeErrorPolicy string `yaml:"deserialize_error_policy"`
+
+	// ValueFormat is how message values are serialized: "json", or "avro"
+	// framed for Confluent Schema Registry, which is then required
+	ValueFormat    string               `yaml:"value_format"`
+	SchemaRegistry SchemaRegistryConfig `yaml:"schema_registry"`
+
+	// KeyFields are the primary key columns in message keys, joined in
+	// this order into the document ID for tombstones and rows without an
+	// id
+	KeyFields []string `yaml:"key_fields"`
+
+	// ExpectedTables lists the "schema.table" sources the consumer accepts;
+	// events from any other table fail validation. Empty accepts all.
+	ExpectedTables []string `yaml:"expected_tables"`
+
+	// RequireSnapshotComplete keeps /ready failing until the initial
+	// Debezium snapshot has been consumed
+	RequireSnapshotComplete bool `yaml:"require_snapshot_complete"`
+
+	// Topics maps the topics consumed, <topic_prefix>.<suffix>, to the
+	// entity whose index their operations are written to
+	Topics []TopicMapping `yaml:"topics"`
+
+	// HeartbeatTopic is Debezium's heartbeat topic, usually
+	// __debezium-heartbeat.<topic.prefix>. Its messages are acknowledged
+	// without a write and update the source freshness metric; empty
+	// doesn't subscribe to it.
+	HeartbeatTopic string `yaml:"heartbeat_topic"`
+
+	// Compression is the codec the dead letter producer compresses with:
+	// "none", "gzip", "snappy", "lz4" or "zstd". Consumers decompress
+	// whatever codec a batch was written with.
+	Compression string `yaml:"compression"`
+	// FetchMaxBytes caps how much one fetch request may return; 0 leaves
+	// it to the broker
+	FetchMaxBytes int32 `yaml:"fetch_max_bytes"`
+
+	// SessionTimeout is how long the group waits for a heartbeat before
+	// removing the consumer and rebalancing; HeartbeatInterval must be well
+	// below it
+	SessionTimeout    time.Duration `yaml:"session_timeout"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	// MaxProcessingTime is how long a message may take before its
+	// partition stops fetching. It must exceed the slowest Elasticsearch
+	// operation, or slow writes stall fetching and the consumer is
+	// rebalanced out of the group.
+	MaxProcessingTime time.Duration `yaml:"max_processing_time"`
+}
+
+type TopicMapping struct {
+	Suffix string `yaml:"suffix"`
+	Entity string `yaml:"entity"`
+}
+
+// Entities returns the entities the topics map to, each once, in the order
+// they are first mapped
+func (k KafkaConfig) Entities() []string {
+	var entities []string
+	seen := make(map[string]bool, len(k.Topics))
+	for _, topic := range k.Topics {
+		if !seen[topic.Entity] {
+			seen[topic.Entity] = true
+			entities = append(entities, topic.Entity)
+		}
+	}
+	return entities
+}
+
+// Message value formats accepted by kafka.value_format
+const (
+	ValueFormatJSON = "json"
+	ValueFormatAvro = "avro"
+)
+
+type SchemaRegistryConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
 }
 
 type ElasticsearchConfig struct {
-	Hosts       []string      `yaml:"hosts"`
-	IndexPrefix string        `yaml:"index_prefix"`
-	Username    string        `yaml:"username"`
-	Password    string        `yaml:"password"`
-	MaxRetries  int           `yaml:"max_retries"`
-	Timeout     time.Duration `yaml:"timeout"`
+	Hosts       []string `yaml:"hosts"`
+	IndexPrefix string   `yaml:"index_prefix"`
+	// IndexSeparator joins the parts of index, alias and lifecycle policy
+	// names, e.g. the environment, IndexPrefix and entity
+	IndexSeparator string `yaml:"index_separator"`
+
+	Username   string        `yaml:"username"`
+	Password   string        `yaml:"password"`
+	MaxRetries int           `yaml:"max_retries"`
+	Timeout    time.Duration `yaml:"timeout"`
 	// Add more ES-specific configs
 	MaxConns       int           `yaml:"max_conns"`
 	MaxIdleConns   int           `yaml:"max_idle_conns"`
 	ConnectTimeout time.Duration `yaml:"connect_timeout"`
 	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// BulkTimeout and SearchTimeout override RequestTimeout for bulk and
+	// search requests; unset uses RequestTimeout
+	BulkTimeout   time.Duration `yaml:"bulk_timeout"`
+	SearchTimeout time.Duration `yaml:"search_timeout"`
+	// IndexExistsTTL is how long index existence checks are cached; 0
+	// checks with the cluster every time
+	IndexExistsTTL time.Duration `yaml:"index_exists_ttl"`
+	// Refresh and BulkRefresh are the refresh policy for single document
+	// writes and for bulk requests: "true", "false" or "wait_for"
+	Refresh        string        `yaml:"refresh"`
+	BulkRefresh    string        `yaml:"bulk_refresh"`
 	RetryBackoff   time.Duration `yaml:"retry_backoff"`
 	EnableRetry    bool          `yaml:"enable_retry"`
 	EnableMetrics  bool          `yaml:"enable_metrics"`
 	SnifferEnabled bool          `yaml:"sniffer_enabled"`
 	GzipEnabled    bool          `yaml:"gzip_enabled"`
 
-	// Index naming strategy
+	// Index setup: IndexTemplate is the path to the index template JSON,
+	// empty to use the embedded default
 	IndexTemplate  string `yaml:"index_template"`
 	IndexLifecycle string `yaml:"index_lifecycle"`
 	ShardCount     int    `yaml:"shard_count"`
 	ReplicaCount   int    `yaml:"replica_count"`
 }
 
+// Sync modes accepted by sync.mode
+const (
+	ModeCustom       = "custom"
+	ModeKafkaConnect = "kafka-connect"
+)
+
 type SyncConfig struct {
 	Mode         string             `yaml:"mode"`
 	KafkaConnect KafkaConnectConfig `yaml:"kafka_connect"`
 	Custom       CustomConfig       `yaml:"custom"`
+	Debezium     DebeziumConfig     `yaml:"debezium"`
+	FieldMapping FieldMappingConfig `yaml:"field_mapping"`
+
+	// UpdateConflict controls REST updates whose version is stale:
+	// UpdateConflictReject or UpdateConflictOverwrite
+	UpdateConflict string `yaml:"update_conflict"`
+
+	// ListLimit is the page size when a list request sets no limit, and
+	// ListMaxLimit caps what a request may ask for
+	ListLimit    int `yaml:"list_limit"`
+	ListMaxLimit int `yaml:"list_max_limit"`
+
+	// StaleReadTTL is how old a last good read may be and still be served,
+	// flagged stale, while Elasticsearch is failing; 0 answers with the
+	// error instead. StaleReadEntries bounds how many reads are kept.
+	StaleReadTTL     time.Duration `yaml:"stale_read_ttl"`
+	StaleReadEntries int           `yaml:"stale_read_entries"`
+}
+
+// Debezium message formats accepted by sync.debezium.format
+const (
+	// DebeziumFormatEnvelope is Debezium's full change event, with the row
+	// under payload.before and payload.after
+	DebeziumFormatEnvelope = "envelope"
+	// DebeziumFormatFlattened is the row as written by the
+	// ExtractNewRecordState transform
+	DebeziumFormatFlattened = "flattened"
+)
+
+type DebeziumConfig struct {
+	// Format is the message format the connector produces. With
+	// "flattened", messages that still carry the full envelope are
+	// detected and decoded as such.
+	Format string `yaml:"format"`
+}
+
+// FieldMappingConfig maps Postgres columns onto Elasticsearch fields.
+// Drop removes columns, then Rename renames columns to fields.
+type FieldMappingConfig struct {
+	Rename map[string]string `yaml:"rename"`
+	Drop   []string          `yaml:"drop"`
+
+	// StatusLabels maps status codes to the label stored alongside them in
+	// status_label, e.g. "1": active. A code without a label is stored as
+	// is; an empty map leaves status_label out.
+	StatusLabels map[string]string `yaml:"status_labels"`
 }
 
 type KafkaConnectConfig struct {
 	Enabled       bool                `yaml:"enabled"`
 	SinkConnector SinkConnectorConfig `yaml:"sink_connector"`
+
+	// Connect REST client: per-request timeout and retries on failure
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+
+	// A failed connector is restarted after RestartBackoff, doubling after
+	// each restart that doesn't bring it back up to MaxRestartBackoff
+	RestartBackoff    time.Duration `yaml:"restart_backoff"`
+	MaxRestartBackoff time.Duration `yaml:"max_restart_backoff"`
 }
 
 type SinkConnectorConfig struct {
 	URL         string `yaml:"url"`
 	Name        string `yaml:"name"`
 	TopicPrefix string `yaml:"topic_prefix"`
+
+	// Config, when set, creates the connector with this configuration if
+	// Kafka Connect doesn't have it
+	Config map[string]string `yaml:"config"`
 }
 
+// Conflict modes accepted by sync.custom.conflict_mode
+const (
+	// ConflictTimestamp keeps the write with the newer source timestamp
+	ConflictTimestamp = "timestamp"
+	// ConflictVersion keeps the write with the higher version
+	ConflictVersion = "version"
+	// ConflictLastWriteWins always overwrites
+	ConflictLastWriteWins = "last-write-wins"
+)
+
+// Policies for stale REST updates accepted by sync.update_conflict
+const (
+	// UpdateConflictReject answers 409 with the current version
+	UpdateConflictReject = "reject"
+	// UpdateConflictOverwrite keeps last-write-wins
+	UpdateConflictOverwrite = "overwrite"
+)
+
+// Policies for operations whose retries are exhausted, accepted by
+// sync.custom.exhausted_policy
+const (
+	// ExhaustedSkip records the failure and moves on
+	ExhaustedSkip = "skip"
+	// ExhaustedDLQ publishes the operation to the failure queue, from
+	// which it can be replayed
+	ExhaustedDLQ = "dlq"
+	// ExhaustedHalt pauses the consumer until an operator intervenes
+	ExhaustedHalt = "halt"
+)
+
+// Starting positions accepted by kafka.auto_offset_reset, used when the
+// consumer group has no committed offset for a partition
+const (
+	// OffsetResetEarliest reads the topic from its oldest retained message
+	OffsetResetEarliest = "earliest"
+	// OffsetResetLatest skips what is already on the topic and reads only
+	// messages produced from now on
+	OffsetResetLatest = "latest"
+)
+
+// Policies accepted by kafka.deserialize_error_policy
+const (
+	DeserializeErrorSkip  = "skip"
+	DeserializeErrorRetry = "retry"
+	DeserializeErrorHalt  = "halt"
+)
+
+// Elasticsearch checks accepted by monitoring.readiness_check
+const (
+	ReadinessCheckClusterHealth = "cluster_health"
+	ReadinessCheckPing          = "ping"
+)
+
+// Retry jitter strategies accepted by sync.custom.jitter
+const (
+	// JitterNone uses the exponential delay as is
+	JitterNone = "none"
+	// JitterEqual keeps half the exponential delay and randomizes the rest
+	JitterEqual = "equal"
+	// JitterFull picks a delay between zero and the exponential delay
+	JitterFull = "full"
+	// JitterDecorrelated picks a delay between the base delay and three
+	// times the previous one
+	JitterDecorrelated = "decorrelated"
+)
+
 type CustomConfig struct {
 	Enabled       bool          `yaml:"enabled"`
 	BatchSize     int           `yaml:"batch_size"`
@@ -86,8 +338,48 @@ type CustomConfig struct {
 	RetryDelay    time.Duration `yaml:"retry_delay"`
 	MaxRetryDelay time.Duration `yaml:"max_retry_delay"`
 	BackoffFactor float64       `yaml:"backoff_factor"`
-	FailureQueue  string        `yaml:"failure_queue"`
-	ConflictMode  string        `yaml:"conflict_mode"`
+	// RetryPollInterval is how often the retry queue is checked for
+	// operations that are due
+	RetryPollInterval time.Duration `yaml:"retry_poll_interval"`
+	// RetryStorePath is the local file the retry queue is kept in. It
+	// must not depend on Elasticsearch, whose failures fill the queue.
+	RetryStorePath string `yaml:"retry_store_path"`
+	// Jitter is how retry delays are randomized so failed operations
+	// don't retry in lockstep: "none", "equal", "full" or "decorrelated"
+	Jitter       string `yaml:"jitter"`
+	FailureQueue string `yaml:"failure_queue"`
+	// ExhaustedPolicy is what happens to an operation that fails all its
+	// retries: "skip", "dlq" or "halt"
+	ExhaustedPolicy string `yaml:"exhausted_policy"`
+	// RetryableCodes are the SyncError codes worth retrying, e.g.
+	// SYNC_ES_001; errors with any other code fail at once
+	RetryableCodes []string `yaml:"retryable_codes"`
+	// ConflictMode decides whether a CDC update older than the stored
+	// document is dropped: "timestamp", "version" or "last-write-wins"
+	ConflictMode string `yaml:"conflict_mode"`
+
+	// DryRun validates and transforms operations but skips every
+	// Elasticsearch write, while still committing offsets
+	DryRun bool `yaml:"dry_run"`
+
+	// PartialUpdates sends only the columns a CDC update changed, diffed
+	// from its before and after images, instead of the whole document.
+	// Updates without a before image, such as from tables whose replica
+	// identity isn't FULL, still send the whole document.
+	PartialUpdates bool `yaml:"partial_updates"`
+
+	// ApplyTruncates deletes every document of an entity when its source
+	// table is truncated. It's off by default since it's destructive; a
+	// truncate is then only logged.
+	ApplyTruncates bool `yaml:"apply_truncates"`
+
+	// MaxPayloadBytes rejects operations whose JSON payload is larger,
+	// sending them to the dead letter topic; 0 disables the limit
+	MaxPayloadBytes int `yaml:"max_payload_bytes"`
+
+	// Workers is how many messages of a partition are processed at once.
+	// Messages are sharded by key, so changes to one row stay in order.
+	Workers int `yaml:"workers"`
 }
 
 type MonitoringConfig struct {
@@ -100,9 +392,19 @@ type MonitoringConfig struct {
 	PrometheusPath string `yaml:"prometheus_path"`
 	// Health check configuration
 	HealthCheckPort int `yaml:"health_check_port"`
+	// LivenessTimeout is how long a message may process without any
+	// progress before /health reports the consumer stuck; 0 disables
+	LivenessTimeout time.Duration `yaml:"liveness_timeout"`
+	// ReadinessCheck is how /ready checks Elasticsearch: "cluster_health"
+	// asks for cluster health, which needs the monitor privilege, and
+	// "ping" only checks the cluster answers
+	ReadinessCheck string `yaml:"readiness_check"`
 	// Logging
 	LogFormat string `yaml:"log_format"`
 	LogOutput string `yaml:"log_output"`
+	// SourceTables lists the "schema.table" sources that get their own
+	// metric labels; anything else is reported as "other"
+	SourceTables []string `yaml:"source_tables"`
 }
 
 type CircuitBreakerConfig struct {
@@ -115,127 +417,416 @@ type CircuitBreakerConfig struct {
 	RateLimitPeriod time.Duration `yaml:"rate_limit_period"`
 }
 
-func fileExists(path string) bool {
-	if _, err := os.Stat(path); err == nil {
-		return true
-	}
-	return false
+// StartupConfig controls how long startup waits for Elasticsearch and
+// Kafka to become reachable: up to MaxAttempts tries, backing off
+// exponentially from Backoff to at most MaxBackoff
+type StartupConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
 }
 
-func verifyConfigPaths() {
-	paths := []string{
-		"./sync/config/config.yaml",
-		// "../config/config.yaml",
-		// "../../config/config.yaml",
-		// "/etc/digital-discovery/config.yaml",
-	}
-
-	fmt.Println("Checking config file locations:")
-	for _, path := range paths {
-		if fileExists(path) {
-			fmt.Printf("✅ Found config at: %s\n", path)
-		} else {
-			fmt.Printf("❌ No config at: %s\n", path)
-		}
-	}
+// ShutdownConfig bounds graceful shutdown: Stop gets Timeout to stop
+// consuming, flush the bulk buffer and close its clients
+type ShutdownConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
 }
 
 // LoadConfig loads configuration from both file and environment variables
 func LoadConfig() (*Config, error) {
-	verifyConfigPaths()
+	return loadConfig("./sync/config")
+}
 
+// loadConfig reads config.yaml from dir over the defaults, with environment
+// variables taking precedence
+func loadConfig(dir string) (*Config, error) {
 	v := viper.New()
 
 	// Set defaults
 	setDefaults(v)
 
-	// Add debug logging to verify defaults were set
-	fmt.Printf("After defaults - healthCheckPort: %v\n", v.GetInt("monitoring.healthCheckPort"))
-
 	v.SetConfigName("config")
 	v.SetConfigType("yaml")
-	v.AddConfigPath("./sync/config")
+	v.AddConfigPath(dir)
 
-	// Enable environment variables
-	v.AutomaticEnv()
+	// Enable environment variables: es.request_timeout is read from
+	// DD_ES_REQUEST_TIMEOUT
 	v.SetEnvPrefix("DD")
-
-	// Add debug logging after env vars
-	fmt.Printf("After env setup - healthCheckPort: %v\n", v.GetInt("monitoring.healthCheckPort"))
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	bindEnv(v, reflect.TypeOf(Config{}), "")
 
 	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, fmt.Errorf("error reading config file: %w", err)
 		}
-		// Add debug logging when no config file found
-		fmt.Println("No config file found, using defaults")
 	}
 
-	// Add debug logging after config read
-	fmt.Printf("After config read - healthCheckPort: %v\n", v.GetInt("monitoring.healthCheckPort"))
-
+	// Keys are the yaml tags, as written in config.yaml
 	config := &Config{}
-	if err := v.Unmarshal(config); err != nil {
+	if err := v.Unmarshal(config, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+	}); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
+	config.Source = v.ConfigFileUsed()
 
-	// Add debug logging after unmarshal
-	fmt.Printf("Final config - healthCheckPort: %v\n", config.Monitoring.HealthCheckPort)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
 
+// bindEnv binds every setting of t to its environment variable. Automatic
+// env lookup only covers keys viper already knows about, which leaves out
+// settings without a default. Maps and lists of structs can't be set from a
+// single variable and are skipped.
+func bindEnv(v *viper.Viper, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("yaml")
+		if name == "-" || name == "" {
+			continue
+		}
+
+		key := prefix + name
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			bindEnv(v, field.Type, key+".")
+		case reflect.Map:
+		case reflect.Slice:
+			if field.Type.Elem().Kind() != reflect.Struct {
+				v.BindEnv(key)
+			}
+		default:
+			v.BindEnv(key)
+		}
+	}
+}
+
+// Summary returns where the config came from and its key settings, for
+// logging at startup. Credentials are left out.
+func (c *Config) Summary() map[string]interface{} {
+	source := c.Source
+	if source == "" {
+		source = "defaults and environment"
+	}
+	return map[string]interface{}{
+		"source":            source,
+		"environment":       c.App.Environment,
+		"log_level":         c.App.LogLevel,
+		"sync_mode":         c.Sync.Mode,
+		"dry_run":           c.Sync.Custom.DryRun,
+		"kafka_brokers":     c.Kafka.Brokers,
+		"kafka_group_id":    c.Kafka.GroupID,
+		"kafka_topic":       c.Kafka.TopicPrefix,
+		"value_format":      c.Kafka.ValueFormat,
+		"debezium_format":   c.Sync.Debezium.Format,
+		"es_hosts":          c.ES.Hosts,
+		"health_check_port": c.Monitoring.HealthCheckPort,
+	}
+}
+
+// Validate checks the settings the service can't start without and
+// returns every problem found, one per line, rather than only the first
+func (c *Config) Validate() error {
+	var errs []error
+	if err := validateSyncMode(c.Sync); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(c.Kafka.Brokers) == 0 {
+		errs = append(errs, fmt.Errorf("kafka.brokers is empty; list at least one broker address"))
+	}
+	if len(c.ES.Hosts) == 0 {
+		errs = append(errs, fmt.Errorf("es.hosts is empty; list at least one Elasticsearch URL"))
+	}
+
+	if c.ES.IndexPrefix == "" {
+		errs = append(errs, fmt.Errorf("es.index_prefix is empty"))
+	}
+	if c.ES.IndexSeparator == "" || strings.ContainsAny(c.ES.IndexSeparator, `\/*?"<>| ,#:`) {
+		errs = append(errs, fmt.Errorf("es.index_separator %q must be non-empty and valid in an index name, e.g. - or _ or .",
+			c.ES.IndexSeparator))
+	}
+
+	for _, refresh := range []struct{ key, policy string }{
+		{"es.refresh", c.ES.Refresh},
+		{"es.bulk_refresh", c.ES.BulkRefresh},
+	} {
+		switch refresh.policy {
+		case "true", "false", "wait_for":
+		default:
+			errs = append(errs, fmt.Errorf("invalid %s %q: must be true, false or wait_for", refresh.key, refresh.policy))
+		}
+	}
+
+	switch c.Monitoring.ReadinessCheck {
+	case ReadinessCheckClusterHealth, ReadinessCheckPing:
+	default:
+		errs = append(errs, fmt.Errorf("invalid monitoring.readiness_check %q: must be %q or %q",
+			c.Monitoring.ReadinessCheck, ReadinessCheckClusterHealth, ReadinessCheckPing))
+	}
+
+	if len(c.Kafka.Topics) == 0 {
+		errs = append(errs, fmt.Errorf("kafka.topics is empty; map at least one topic suffix to an entity"))
+	}
+	suffixes := make(map[string]bool, len(c.Kafka.Topics))
+	for i, topic := range c.Kafka.Topics {
+		if topic.Suffix == "" || topic.Entity == "" {
+			errs = append(errs, fmt.Errorf("kafka.topics[%d] needs both a suffix and an entity", i))
+			continue
+		}
+		if suffixes[topic.Suffix] {
+			errs = append(errs, fmt.Errorf("kafka.topics maps suffix %q more than once", topic.Suffix))
+		}
+		suffixes[topic.Suffix] = true
+	}
+
+	if c.Shutdown.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown.timeout must be positive, got %s", c.Shutdown.Timeout))
+	}
+
+	switch c.Kafka.AutoOffsetReset {
+	case OffsetResetEarliest, OffsetResetLatest:
+	default:
+		errs = append(errs, fmt.Errorf("invalid kafka.auto_offset_reset %q: must be %q or %q",
+			c.Kafka.AutoOffsetReset, OffsetResetEarliest, OffsetResetLatest))
+	}
+
+	switch c.Kafka.Compression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		errs = append(errs, fmt.Errorf("invalid kafka.compression %q: must be none, gzip, snappy, lz4 or zstd",
+			c.Kafka.Compression))
+	}
+	if c.Kafka.FetchMaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("kafka.fetch_max_bytes is %d; it must be 0 (broker default) or positive",
+			c.Kafka.FetchMaxBytes))
+	}
+	if c.Kafka.SessionTimeout <= 0 || c.Kafka.HeartbeatInterval <= 0 || c.Kafka.HeartbeatInterval >= c.Kafka.SessionTimeout {
+		errs = append(errs, fmt.Errorf("kafka.heartbeat_interval (%s) must be positive and below kafka.session_timeout (%s)",
+			c.Kafka.HeartbeatInterval, c.Kafka.SessionTimeout))
+	}
+	if c.Kafka.MaxProcessingTime <= 0 {
+		errs = append(errs, fmt.Errorf("kafka.max_processing_time is %s; it must be positive",
+			c.Kafka.MaxProcessingTime))
+	}
+
+	switch c.Kafka.DeserializeErrorPolicy {
+	case DeserializeErrorSkip, DeserializeErrorRetry, DeserializeErrorHalt:
+	default:
+		errs = append(errs, fmt.Errorf("invalid kafka.deserialize_error_policy %q: must be %q, %q or %q",
+			c.Kafka.DeserializeErrorPolicy, DeserializeErrorSkip, DeserializeErrorRetry, DeserializeErrorHalt))
+	}
+
+	switch c.Kafka.ValueFormat {
+	case ValueFormatJSON:
+	case ValueFormatAvro:
+		if c.Kafka.SchemaRegistry.URL == "" {
+			errs = append(errs, fmt.Errorf("kafka.value_format is %q but kafka.schema_registry.url is not set", ValueFormatAvro))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid kafka.value_format %q: must be %q or %q",
+			c.Kafka.ValueFormat, ValueFormatJSON, ValueFormatAvro))
+	}
+
+	if c.Sync.Custom.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("sync.custom.batch_size is %d; it must be greater than 0", c.Sync.Custom.BatchSize))
+	}
+	if c.Sync.Custom.Workers < 1 {
+		errs = append(errs, fmt.Errorf("sync.custom.workers is %d; it must be at least 1", c.Sync.Custom.Workers))
+	}
+	if c.Sync.Custom.BackoffFactor < 1 {
+		errs = append(errs, fmt.Errorf("sync.custom.backoff_factor is %v; it must be at least 1 so retry delays don't shrink",
+			c.Sync.Custom.BackoffFactor))
+	}
+	switch c.Sync.Custom.Jitter {
+	case JitterNone, JitterEqual, JitterFull, JitterDecorrelated:
+	default:
+		errs = append(errs, fmt.Errorf("invalid sync.custom.jitter %q: must be %q, %q, %q or %q",
+			c.Sync.Custom.Jitter, JitterNone, JitterEqual, JitterFull, JitterDecorrelated))
+	}
+	if c.Sync.Custom.MaxPayloadBytes < 0 {
+		errs = append(errs, fmt.Errorf("sync.custom.max_payload_bytes is %d; it must be 0 (no limit) or positive",
+			c.Sync.Custom.MaxPayloadBytes))
+	}
+	switch c.Sync.Custom.ExhaustedPolicy {
+	case ExhaustedSkip, ExhaustedHalt:
+	case ExhaustedDLQ:
+		if c.Sync.Custom.FailureQueue == "" {
+			errs = append(errs, fmt.Errorf("sync.custom.exhausted_policy %q needs sync.custom.failure_queue to be set", ExhaustedDLQ))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid sync.custom.exhausted_policy %q: must be %q, %q or %q",
+			c.Sync.Custom.ExhaustedPolicy, ExhaustedSkip, ExhaustedDLQ, ExhaustedHalt))
+	}
+	if c.Sync.Custom.RetryStorePath == "" {
+		errs = append(errs, fmt.Errorf("sync.custom.retry_store_path is empty; set a local file for the retry queue"))
+	}
+	for _, code := range c.Sync.Custom.RetryableCodes {
+		if !strings.HasPrefix(code, "SYNC_") {
+			errs = append(errs, fmt.Errorf("invalid sync.custom.retryable_codes entry %q: must be a SYNC_ error code", code))
+		}
+	}
+	switch c.Sync.Custom.ConflictMode {
+	case ConflictTimestamp, ConflictVersion, ConflictLastWriteWins:
+	default:
+		errs = append(errs, fmt.Errorf("invalid sync.custom.conflict_mode %q: must be %q, %q or %q",
+			c.Sync.Custom.ConflictMode, ConflictTimestamp, ConflictVersion, ConflictLastWriteWins))
+	}
+	switch c.Sync.UpdateConflict {
+	case UpdateConflictReject, UpdateConflictOverwrite:
+	default:
+		errs = append(errs, fmt.Errorf("invalid sync.update_conflict %q: must be %q or %q",
+			c.Sync.UpdateConflict, UpdateConflictReject, UpdateConflictOverwrite))
+	}
+	if connect := c.Sync.KafkaConnect; connect.RestartBackoff <= 0 || connect.MaxRestartBackoff < connect.RestartBackoff {
+		errs = append(errs, fmt.Errorf("sync.kafka_connect.restart_backoff is %v and max_restart_backoff is %v; the backoff must be positive and not exceed the maximum",
+			connect.RestartBackoff, connect.MaxRestartBackoff))
+	}
+	switch c.Sync.Debezium.Format {
+	case DebeziumFormatEnvelope, DebeziumFormatFlattened:
+	default:
+		errs = append(errs, fmt.Errorf("invalid sync.debezium.format %q: must be %q or %q",
+			c.Sync.Debezium.Format, DebeziumFormatEnvelope, DebeziumFormatFlattened))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateSyncMode rejects a mode App.Start would refuse, so a bad or
+// missing setting fails at load time with a hint instead of after startup
+func validateSyncMode(sync SyncConfig) error {
+	switch sync.Mode {
+	case ModeCustom:
+		if !sync.Custom.Enabled {
+			return fmt.Errorf("sync.mode is %q but sync.custom.enabled is false; enable it or choose another sync.mode", ModeCustom)
+		}
+	case ModeKafkaConnect:
+		if !sync.KafkaConnect.Enabled {
+			return fmt.Errorf("sync.mode is %q but sync.kafka_connect.enabled is false; enable it or choose another sync.mode", ModeKafkaConnect)
+		}
+	default:
+		return fmt.Errorf("invalid sync.mode %q: must be %q or %q", sync.Mode, ModeCustom, ModeKafkaConnect)
+	}
+	return nil
+}
+
 func setDefaults(v *viper.Viper) {
 	// App defaults
 	v.SetDefault("app.environment", "development")
-	v.SetDefault("app.logLevel", "info")
-	v.SetDefault("app.serviceName", "digital-discovery-sync")
+	v.SetDefault("app.log_level", "info")
+	v.SetDefault("app.service_name", "digital-discovery-sync")
 	v.SetDefault("app.version", "1.0.0")
 
 	// Kafka defaults
 	v.SetDefault("kafka.brokers", []string{"localhost:9092"})
-	v.SetDefault("kafka.groupId", "digital-discovery-sync")
-	v.SetDefault("kafka.topicPrefix", "postgres.digital_discovery.public")
-	v.SetDefault("kafka.autoOffsetReset", "earliest")
-	v.SetDefault("kafka.securityEnabled", false)
+	v.SetDefault("kafka.group_id", "digital-discovery-sync")
+	v.SetDefault("kafka.topic_prefix", "postgres.digital_discovery.public")
+	v.SetDefault("kafka.auto_offset_reset", OffsetResetEarliest)
+	v.SetDefault("kafka.security_enabled", false)
+	v.SetDefault("kafka.max_restarts", 5)
+	v.SetDefault("kafka.restart_window", "10m")
+	v.SetDefault("kafka.restart_backoff", "5s")
+	v.SetDefault("kafka.offset_commit_log_every", 100)
+	v.SetDefault("kafka.offset_commit_metrics", true)
+	v.SetDefault("kafka.deserialize_error_policy", DeserializeErrorSkip)
+	v.SetDefault("kafka.value_format", ValueFormatJSON)
+	v.SetDefault("kafka.schema_registry.url", "")
+	v.SetDefault("kafka.schema_registry.timeout", "10s")
+	v.SetDefault("kafka.key_fields", []string{"id"})
+	v.SetDefault("kafka.expected_tables", []string{"public.categories"})
+	v.SetDefault("kafka.require_snapshot_complete", false)
+	v.SetDefault("kafka.heartbeat_topic", "")
+	v.SetDefault("kafka.compression", "snappy")
+	v.SetDefault("kafka.fetch_max_bytes", 0)
+	v.SetDefault("kafka.session_timeout", "30s")
+	v.SetDefault("kafka.heartbeat_interval", "3s")
+	v.SetDefault("kafka.max_processing_time", "15s")
+	v.SetDefault("kafka.topics", []map[string]interface{}{
+		{"suffix": "categories", "entity": "categories"},
+	})
 
 	// Elasticsearch defaults
 	v.SetDefault("es.hosts", []string{"http://localhost:9200"})
-	v.SetDefault("es.indexPrefix", "digital-discovery")
-	v.SetDefault("es.maxRetries", 3)
+	v.SetDefault("es.index_prefix", "digital-discovery")
+	v.SetDefault("es.index_separator", "-")
+	v.SetDefault("es.max_retries", 3)
 	v.SetDefault("es.timeout", "30s")
 	v.SetDefault("es.username", "")
 	v.SetDefault("es.password", "")
+	v.SetDefault("es.shard_count", 1)
+	v.SetDefault("es.replica_count", 1)
+	v.SetDefault("es.index_exists_ttl", "10s")
+	v.SetDefault("es.refresh", "wait_for")
+	v.SetDefault("es.bulk_refresh", "false")
 
 	// Sync defaults
-	v.SetDefault("sync.mode", "kafka")
-	v.SetDefault("sync.kafkaConnect.enabled", false)
-	v.SetDefault("sync.kafkaConnect.url", "")
-	v.SetDefault("sync.kafkaConnect.name", "")
-	v.SetDefault("sync.custom.enabled", false)
-	v.SetDefault("sync.custom.batchSize", 100)
-	v.SetDefault("sync.custom.maxRetries", 3)
-	v.SetDefault("sync.custom.retryDelay", "5s")
-	v.SetDefault("sync.custom.maxRetryDelay", "1h")
-	v.SetDefault("sync.custom.backoffFactor", 2.0)
-	v.SetDefault("sync.custom.failureQueue", "failed-syncs")
-	v.SetDefault("sync.custom.conflictMode", "timestamp")
+	v.SetDefault("sync.mode", ModeCustom)
+	v.SetDefault("sync.kafka_connect.enabled", false)
+	v.SetDefault("sync.kafka_connect.sink_connector.url", "")
+	v.SetDefault("sync.kafka_connect.sink_connector.name", "")
+	v.SetDefault("sync.kafka_connect.timeout", "10s")
+	v.SetDefault("sync.kafka_connect.max_retries", 2)
+	v.SetDefault("sync.kafka_connect.retry_backoff", "1s")
+	v.SetDefault("sync.kafka_connect.restart_backoff", "30s")
+	v.SetDefault("sync.kafka_connect.max_restart_backoff", "10m")
+	v.SetDefault("sync.custom.enabled", true)
+	v.SetDefault("sync.custom.batch_size", 100)
+	v.SetDefault("sync.custom.max_retries", 3)
+	v.SetDefault("sync.custom.retry_delay", "5s")
+	v.SetDefault("sync.custom.max_retry_delay", "1h")
+	v.SetDefault("sync.custom.backoff_factor", 2.0)
+	v.SetDefault("sync.custom.retry_poll_interval", "1s")
+	v.SetDefault("sync.custom.retry_store_path", "./data/sync-retries.json")
+	v.SetDefault("sync.custom.jitter", JitterEqual)
+	v.SetDefault("sync.custom.failure_queue", "failed-syncs")
+	v.SetDefault("sync.custom.exhausted_policy", ExhaustedSkip)
+	v.SetDefault("sync.custom.retryable_codes", utils.DefaultRetryableCodes)
+	v.SetDefault("sync.custom.conflict_mode", "timestamp")
+	v.SetDefault("sync.custom.workers", 1)
+	v.SetDefault("sync.custom.dry_run", false)
+	v.SetDefault("sync.custom.partial_updates", false)
+	v.SetDefault("sync.custom.apply_truncates", false)
+	v.SetDefault("sync.custom.max_payload_bytes", 1<<20)
+	v.SetDefault("sync.debezium.format", DebeziumFormatEnvelope)
+	v.SetDefault("sync.update_conflict", UpdateConflictReject)
+	v.SetDefault("sync.list_limit", 50)
+	v.SetDefault("sync.list_max_limit", 500)
+	v.SetDefault("sync.stale_read_ttl", "5m")
+	v.SetDefault("sync.stale_read_entries", 1000)
 
 	// Monitoring defaults
 	v.SetDefault("monitoring.enabled", true)
-	v.SetDefault("monitoring.metricsPort", 8085)
-	v.SetDefault("monitoring.tracingEnabled", true)
-	v.SetDefault("monitoring.otelCollector", "localhost:4317")
-	v.SetDefault("monitoring.prometheusPath", "/metrics")
-	v.SetDefault("monitoring.healthCheckPort", 8082)
-	v.SetDefault("monitoring.logFormat", "json")
-	v.SetDefault("monitoring.logOutput", "stdout")
+	v.SetDefault("monitoring.metrics_port", 8085)
+	v.SetDefault("monitoring.tracing_enabled", true)
+	v.SetDefault("monitoring.otel_collector", "localhost:4317")
+	v.SetDefault("monitoring.prometheus_path", "/metrics")
+	v.SetDefault("monitoring.health_check_port", 8082)
+	v.SetDefault("monitoring.liveness_timeout", "2m")
+	v.SetDefault("monitoring.readiness_check", ReadinessCheckClusterHealth)
+	v.SetDefault("monitoring.source_tables", []string{"public.categories"})
+	v.SetDefault("monitoring.log_format", "json")
+	v.SetDefault("monitoring.log_output", "stdout")
 
 	// CircuitBreaker defaults
-	v.SetDefault("circuitBreaker.enabled", true)
-	v.SetDefault("circuitBreaker.maxRequests", 10)
-	v.SetDefault("circuitBreaker.interval", "1m")
-	v.SetDefault("circuitBreaker.timeout", "10s")
-	v.SetDefault("circuitBreaker.rateLimit", 10)
-	v.SetDefault("circuitBreaker.rateLimitPeriod", "1m")
+	v.SetDefault("circuit_breaker.enabled", true)
+	v.SetDefault("circuit_breaker.max_requests", 10)
+	v.SetDefault("circuit_breaker.interval", "1m")
+	v.SetDefault("circuit_breaker.timeout", "10s")
+	v.SetDefault("circuit_breaker.rate_limit", 10)
+	v.SetDefault("circuit_breaker.rate_limit_period", "1m")
+
+	// Startup defaults
+	v.SetDefault("startup.max_attempts", 10)
+	v.SetDefault("startup.backoff", "2s")
+	v.SetDefault("startup.max_backoff", "30s")
+
+	// Shutdown defaults
+	v.SetDefault("shutdown.timeout", "30s")
 }
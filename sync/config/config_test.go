@@ -0,0 +1,305 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadConfigReadsYAML(t *testing.T) {
+	cfg, err := loadConfig(".")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"app.log_level", cfg.App.LogLevel, "debug"},
+		{"es.bulk_timeout", cfg.ES.BulkTimeout, 2 * time.Minute},
+		{"es.search_timeout", cfg.ES.SearchTimeout, 10 * time.Second},
+		{"monitoring.metrics_port", cfg.Monitoring.MetricsPort, 9090},
+		{"monitoring.enabled", cfg.Monitoring.Enabled, false},
+		{"monitoring.readiness_check", cfg.Monitoring.ReadinessCheck, ReadinessCheckClusterHealth},
+		{"kafka.group_id", cfg.Kafka.GroupID, "digital-discovery-sync"},
+		{"kafka.session_timeout", cfg.Kafka.SessionTimeout, 30 * time.Second},
+		{"kafka.topics", cfg.Kafka.Topics, []TopicMapping{{Suffix: "categories", Entity: "categories"}}},
+		{"sync.custom.workers", cfg.Sync.Custom.Workers, 1},
+		{"sync.custom.retryable_codes", cfg.Sync.Custom.RetryableCodes, []string{"SYNC_ES_001", "SYNC_ES_002"}},
+		{"sync.kafka_connect.sink_connector.name", cfg.Sync.KafkaConnect.SinkConnector.Name, "elasticsearch-sink"},
+		{"circuit_breaker.max_requests", cfg.CircuitBreaker.MaxRequests, 100},
+		{"shutdown.timeout", cfg.Shutdown.Timeout, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if !strings.HasSuffix(cfg.Source, "config.yaml") {
+		t.Errorf("Source = %q, want config.yaml", cfg.Source)
+	}
+}
+
+func TestLoadConfigEnvOverridesYAML(t *testing.T) {
+	t.Setenv("DD_ES_REQUEST_TIMEOUT", "45s")
+	t.Setenv("DD_KAFKA_GROUP_ID", "from-env")
+
+	cfg, err := loadConfig(".")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.ES.RequestTimeout != 45*time.Second {
+		t.Errorf("es.request_timeout = %s, want 45s", cfg.ES.RequestTimeout)
+	}
+	if cfg.Kafka.GroupID != "from-env" {
+		t.Errorf("kafka.group_id = %q, want from-env", cfg.Kafka.GroupID)
+	}
+}
+
+// Every default must be set under a key config.yaml could use, or it is
+// never applied
+func TestDefaultsMatchYAMLKeys(t *testing.T) {
+	keys := make(map[string]bool)
+	yamlKeys(reflect.TypeOf(Config{}), "", keys)
+
+	v := viper.New()
+	setDefaults(v)
+	for _, key := range v.AllKeys() {
+		if !keys[key] {
+			t.Errorf("default %q has no matching yaml key", key)
+		}
+	}
+}
+
+// yamlKeys collects the dotted yaml path of every setting of t
+func yamlKeys(t reflect.Type, prefix string, keys map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("yaml")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		keys[key] = true
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			yamlKeys(t.Field(i).Type, key+".", keys)
+		}
+	}
+}
+
+func TestKafkaEntitiesListsEachOnce(t *testing.T) {
+	kafka := KafkaConfig{Topics: []TopicMapping{
+		{Suffix: "categories", Entity: "categories"},
+		{Suffix: "products", Entity: "products"},
+		{Suffix: "categories_archive", Entity: "categories"},
+	}}
+
+	if got, want := kafka.Entities(), []string{"categories", "products"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Entities() = %v, want %v", got, want)
+	}
+}
+
+// validConfig returns the config.yaml configuration, which passes Validate
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := loadConfig(".")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	return cfg
+}
+
+func TestValidateUpdateConflict(t *testing.T) {
+	for _, policy := range []string{UpdateConflictReject, UpdateConflictOverwrite} {
+		cfg := validConfig(t)
+		cfg.Sync.UpdateConflict = policy
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with update_conflict %q = %v, want nil", policy, err)
+		}
+	}
+
+	cfg := validConfig(t)
+	cfg.Sync.UpdateConflict = "overwite"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "sync.update_conflict") {
+		t.Errorf("Validate() with update_conflict overwite = %v, want it rejected", err)
+	}
+}
+
+func TestReplicaCountZeroIsKept(t *testing.T) {
+	t.Setenv("DD_ES_REPLICA_COUNT", "0")
+
+	cfg, err := loadConfig(".")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	// Single-node clusters need 0; the default of 1 must not replace it
+	if cfg.ES.ReplicaCount != 0 {
+		t.Errorf("es.replica_count = %d, want 0", cfg.ES.ReplicaCount)
+	}
+}
+
+func TestLoadConfigWithoutFile(t *testing.T) {
+	cfg, err := loadConfig(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadConfig() without config.yaml error = %v, want the defaults to validate", err)
+	}
+	if cfg.Sync.Mode != ModeCustom || !cfg.Sync.Custom.Enabled {
+		t.Errorf("default sync mode = %q (custom enabled %v), want %q enabled", cfg.Sync.Mode, cfg.Sync.Custom.Enabled, ModeCustom)
+	}
+	if cfg.Source != "" {
+		t.Errorf("Source = %q, want empty without a config file", cfg.Source)
+	}
+	if got := cfg.Summary()["source"]; got != "defaults and environment" {
+		t.Errorf("Summary source = %v, want defaults and environment", got)
+	}
+}
+
+func TestLoadConfigRejectsInvalidModeWithHint(t *testing.T) {
+	t.Setenv("DD_SYNC_MODE", "kafka")
+
+	_, err := loadConfig(t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), `invalid sync.mode "kafka"`) {
+		t.Errorf("loadConfig() with sync.mode kafka = %v, want it rejected at load time", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		// want are substrings the error must contain, one per problem
+		want []string
+	}{
+		{"valid", func(cfg *Config) {}, nil},
+		{"unknown mode", func(cfg *Config) { cfg.Sync.Mode = "kafka" }, []string{`invalid sync.mode "kafka"`}},
+		{"mode not enabled", func(cfg *Config) { cfg.Sync.Custom.Enabled = false }, []string{"sync.custom.enabled is false"}},
+		{"no brokers", func(cfg *Config) { cfg.Kafka.Brokers = nil }, []string{"kafka.brokers is empty"}},
+		{"no hosts", func(cfg *Config) { cfg.ES.Hosts = nil }, []string{"es.hosts is empty"}},
+		{"zero batch size", func(cfg *Config) { cfg.Sync.Custom.BatchSize = 0 }, []string{"sync.custom.batch_size is 0"}},
+		{"shrinking backoff", func(cfg *Config) { cfg.Sync.Custom.BackoffFactor = 0.5 }, []string{"sync.custom.backoff_factor is 0.5"}},
+		{"deserialize error policy", func(cfg *Config) { cfg.Kafka.DeserializeErrorPolicy = "ignore" }, []string{`invalid kafka.deserialize_error_policy "ignore"`}},
+		{"no workers", func(cfg *Config) { cfg.Sync.Custom.Workers = 0 }, []string{"sync.custom.workers is 0"}},
+		{"negative workers", func(cfg *Config) { cfg.Sync.Custom.Workers = -2 }, []string{"sync.custom.workers is -2"}},
+		{
+			name: "every problem at once",
+			modify: func(cfg *Config) {
+				cfg.Kafka.Brokers = nil
+				cfg.Sync.Custom.BatchSize = -1
+				cfg.Sync.Custom.Workers = 0
+			},
+			want: []string{"kafka.brokers is empty", "sync.custom.batch_size is -1", "sync.custom.workers is 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want it to report %q", err, want)
+				}
+			}
+			// One line per problem after the heading
+			if lines := strings.Count(err.Error(), "\n"); lines != len(tt.want) {
+				t.Errorf("Validate() reported %d problems, want %d:\n%v", lines, len(tt.want), err)
+			}
+		})
+	}
+}
+
+func TestAutoOffsetReset(t *testing.T) {
+	t.Setenv("DD_KAFKA_AUTO_OFFSET_RESET", OffsetResetLatest)
+	cfg, err := loadConfig(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.Kafka.AutoOffsetReset != OffsetResetLatest {
+		t.Errorf("kafka.auto_offset_reset = %q, want %q", cfg.Kafka.AutoOffsetReset, OffsetResetLatest)
+	}
+
+	cfg.Kafka.AutoOffsetReset = "smallest"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `invalid kafka.auto_offset_reset "smallest"`) {
+		t.Errorf("Validate() with auto_offset_reset smallest = %v, want it rejected", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	cfg, err := loadConfig(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.Shutdown.Timeout != 30*time.Second {
+		t.Errorf("shutdown.timeout = %s, want the 30s default", cfg.Shutdown.Timeout)
+	}
+
+	t.Setenv("DD_SHUTDOWN_TIMEOUT", "2m")
+	if cfg, err = loadConfig(t.TempDir()); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.Shutdown.Timeout != 2*time.Minute {
+		t.Errorf("shutdown.timeout = %s, want 2m from the environment", cfg.Shutdown.Timeout)
+	}
+
+	cfg.Shutdown.Timeout = 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "shutdown.timeout must be positive") {
+		t.Errorf("Validate() with no shutdown timeout = %v, want it rejected", err)
+	}
+}
+
+func TestRefreshPolicy(t *testing.T) {
+	cfg, err := loadConfig(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.ES.Refresh != "wait_for" || cfg.ES.BulkRefresh != "false" {
+		t.Errorf("es.refresh = %q and es.bulk_refresh = %q, want wait_for and false", cfg.ES.Refresh, cfg.ES.BulkRefresh)
+	}
+
+	cfg.ES.Refresh = "always"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `invalid es.refresh "always"`) {
+		t.Errorf("Validate() with es.refresh always = %v, want it rejected", err)
+	}
+}
+
+func TestConsumerSessionTuning(t *testing.T) {
+	cfg := validConfig(t)
+	if cfg.Kafka.SessionTimeout != 30*time.Second || cfg.Kafka.HeartbeatInterval != 3*time.Second || cfg.Kafka.MaxProcessingTime != 15*time.Second {
+		t.Errorf("session %s, heartbeat %s, processing %s; want the 30s, 3s and 15s defaults",
+			cfg.Kafka.SessionTimeout, cfg.Kafka.HeartbeatInterval, cfg.Kafka.MaxProcessingTime)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   string
+	}{
+		{"heartbeat not below session", func(cfg *Config) { cfg.Kafka.HeartbeatInterval = cfg.Kafka.SessionTimeout }, "kafka.heartbeat_interval (30s) must be positive and below kafka.session_timeout (30s)"},
+		{"no heartbeat", func(cfg *Config) { cfg.Kafka.HeartbeatInterval = 0 }, "kafka.heartbeat_interval (0s)"},
+		{"no processing time", func(cfg *Config) { cfg.Kafka.MaxProcessingTime = 0 }, "kafka.max_processing_time is 0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
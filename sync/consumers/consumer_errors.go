@@ -0,0 +1,49 @@
+package consumers
+
+import (
+	"errors"
+	"net"
+
+	"github.com/Shopify/sarama"
+)
+
+// Categories of the errors the consumer group reports, used as the type
+// label of kafka_consumer_errors_total
+const (
+	consumerErrorRebalance = "rebalance"
+	consumerErrorBroker    = "broker_unreachable"
+	consumerErrorAuth      = "auth"
+	consumerErrorOther     = "other"
+)
+
+// classifyConsumerError returns the category of an error from the consumer
+// group's Errors channel
+func classifyConsumerError(err error) string {
+	var kerr sarama.KError
+	if errors.As(err, &kerr) {
+		switch kerr {
+		case sarama.ErrRebalanceInProgress, sarama.ErrIllegalGeneration, sarama.ErrUnknownMemberId,
+			sarama.ErrNotCoordinatorForConsumer, sarama.ErrConsumerCoordinatorNotAvailable:
+			return consumerErrorRebalance
+		case sarama.ErrBrokerNotAvailable, sarama.ErrLeaderNotAvailable, sarama.ErrNotLeaderForPartition:
+			return consumerErrorBroker
+		case sarama.ErrSASLAuthenticationFailed, sarama.ErrUnsupportedSASLMechanism, sarama.ErrIllegalSASLState,
+			sarama.ErrTopicAuthorizationFailed, sarama.ErrGroupAuthorizationFailed, sarama.ErrClusterAuthorizationFailed:
+			return consumerErrorAuth
+		}
+		return consumerErrorOther
+	}
+
+	var netErr net.Error
+	if errors.Is(err, sarama.ErrOutOfBrokers) || errors.Is(err, sarama.ErrNotConnected) || errors.As(err, &netErr) {
+		return consumerErrorBroker
+	}
+	return consumerErrorOther
+}
+
+// isFatalConsumerError reports whether errors of category need an operator.
+// Sarama recovers from rebalances and unreachable brokers on its own, so
+// only failed authentication or authorization marks the consumer unhealthy.
+func isFatalConsumerError(category string) bool {
+	return category == consumerErrorAuth
+}
@@ -0,0 +1,91 @@
+package consumers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+func TestClassifyConsumerError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"rebalance", sarama.ErrRebalanceInProgress, consumerErrorRebalance},
+		{"wrapped rebalance", &sarama.ConsumerError{Topic: testTopic, Err: sarama.ErrIllegalGeneration}, consumerErrorRebalance},
+		{"coordinator moved", fmt.Errorf("commit: %w", sarama.ErrNotCoordinatorForConsumer), consumerErrorRebalance},
+		{"leader unavailable", sarama.ErrLeaderNotAvailable, consumerErrorBroker},
+		{"out of brokers", sarama.ErrOutOfBrokers, consumerErrorBroker},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, consumerErrorBroker},
+		{"sasl", sarama.ErrSASLAuthenticationFailed, consumerErrorAuth},
+		{"topic authorization", sarama.ErrTopicAuthorizationFailed, consumerErrorAuth},
+		{"other kafka error", sarama.ErrMessageSizeTooLarge, consumerErrorOther},
+		{"plain error", errors.New("boom"), consumerErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyConsumerError(tt.err); got != tt.want {
+				t.Errorf("classifyConsumerError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// consumerErrors returns kafka_consumer_errors_total for errorType on the
+// default registry, where SyncService registers its metrics
+func consumerErrors(t *testing.T, errorType string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "sync_kafka_consumer_errors_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "type" && label.GetValue() == errorType {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestHandleErrorCountsAndFailsOnlyOnAuth(t *testing.T) {
+	tests := []struct {
+		err        error
+		errorType  string
+		wantStatus string
+	}{
+		{sarama.ErrRebalanceInProgress, consumerErrorRebalance, "running"},
+		{sarama.ErrOutOfBrokers, consumerErrorBroker, "running"},
+		{sarama.ErrGroupAuthorizationFailed, consumerErrorAuth, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.errorType, func(t *testing.T) {
+			h := newTestHandler(mocks.NewRepository(), 1)
+			c := &KafkaConsumer{syncService: h.syncService, logger: logger.NewLogger("json"), status: "running"}
+			before := consumerErrors(t, tt.errorType)
+
+			c.handleError(context.Background(), tt.err)
+
+			if got := consumerErrors(t, tt.errorType) - before; got != 1 {
+				t.Errorf("kafka_consumer_errors_total{type=%q} rose by %v, want 1", tt.errorType, got)
+			}
+			if status := c.getStatus(); status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package consumers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Connect logical type names Debezium uses for Postgres temporal and
+// numeric columns
+const (
+	typeDebeziumTimestamp      = "io.debezium.time.Timestamp"
+	typeDebeziumMicroTimestamp = "io.debezium.time.MicroTimestamp"
+	typeDebeziumNanoTimestamp  = "io.debezium.time.NanoTimestamp"
+	typeDebeziumZonedTimestamp = "io.debezium.time.ZonedTimestamp"
+	typeDebeziumDate           = "io.debezium.time.Date"
+	typeDebeziumVariableScale  = "io.debezium.data.VariableScaleDecimal"
+	typeConnectTimestamp       = "org.apache.kafka.connect.data.Timestamp"
+	typeConnectDate            = "org.apache.kafka.connect.data.Date"
+	typeConnectDecimal         = "org.apache.kafka.connect.data.Decimal"
+)
+
+// connectSchema is the Kafka Connect schema the JSON converter writes next
+// to the payload when schemas are enabled
+type connectSchema struct {
+	Type       string            `json:"type"`
+	Name       string            `json:"name"`
+	Field      string            `json:"field"`
+	Fields     []connectSchema   `json:"fields"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+// logicalTypeDecoder converts a field encoded as a Connect logical type
+// into a value that unmarshals into the model's Go type. params are the
+// schema parameters of the field, such as a decimal's scale.
+type logicalTypeDecoder func(value json.RawMessage, params map[string]string) (interface{}, error)
+
+// logicalTypes maps logical type names to their decoders; add an entry to
+// support another column type
+var logicalTypes = map[string]logicalTypeDecoder{
+	typeDebeziumTimestamp:      epochDecoder(time.Millisecond),
+	typeDebeziumMicroTimestamp: epochDecoder(time.Microsecond),
+	typeDebeziumNanoTimestamp:  epochDecoder(time.Nanosecond),
+	typeDebeziumZonedTimestamp: decodeZonedTimestamp,
+	typeDebeziumDate:           epochDecoder(24 * time.Hour),
+	typeDebeziumVariableScale:  decodeVariableScaleDecimal,
+	typeConnectTimestamp:       epochDecoder(time.Millisecond),
+	typeConnectDate:            epochDecoder(24 * time.Hour),
+	typeConnectDecimal:         decodeDecimal,
+}
+
+// categoryFieldTypes are the logical types of the categories columns, used
+// when a message carries no schema
+var categoryFieldTypes = map[string]string{
+	"created_at": typeDebeziumMicroTimestamp,
+	"updated_at": typeDebeziumMicroTimestamp,
+}
+
+// rowSchema returns the field schemas of the before or after row, keyed by
+// column name, or nil when the message carried no schema
+func (e *DebeziumEvent) rowSchema(state string) map[string]connectSchema {
+	if e.Schema == nil {
+		return nil
+	}
+
+	fields := e.Schema.Fields
+	if !e.flattened {
+		fields = nil
+		for _, f := range e.Schema.Fields {
+			if f.Field == state {
+				fields = f.Fields
+				break
+			}
+		}
+	}
+	if fields == nil {
+		return nil
+	}
+
+	schema := make(map[string]connectSchema, len(fields))
+	for _, f := range fields {
+		schema[f.Field] = f
+	}
+	return schema
+}
+
+// decodeRow unmarshals a Debezium row into v, first converting fields
+// encoded as logical types and then applying mapper. Types come from
+// schema, or from fallback when the message has no schema.
+func decodeRow(raw json.RawMessage, schema map[string]connectSchema, fallback map[string]string,
+	mapper *fieldMapper, v interface{}) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+
+	for name, value := range fields {
+		typeName := fallback[name]
+		var params map[string]string
+		if schema != nil {
+			typeName = schema[name].Name
+			params = schema[name].Parameters
+		}
+
+		decode, ok := logicalTypes[typeName]
+		if !ok || isNullJSON(value) {
+			continue
+		}
+		decoded, err := decode(value, params)
+		if err != nil {
+			return fmt.Errorf("field %s (%s): %w", name, typeName, err)
+		}
+		if fields[name], err = json.Marshal(decoded); err != nil {
+			return fmt.Errorf("field %s (%s): %w", name, typeName, err)
+		}
+	}
+
+	mapper.Map(fields)
+
+	normalized, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(normalized, v)
+}
+
+// epochDecoder decodes an integer count of unit since the Unix epoch.
+// Values that aren't numbers are left as they are, so a column already
+// rendered as a string still decodes.
+func epochDecoder(unit time.Duration) logicalTypeDecoder {
+	return func(value json.RawMessage, _ map[string]string) (interface{}, error) {
+		var n int64
+		if err := json.Unmarshal(value, &n); err != nil {
+			return value, nil
+		}
+		if unit >= time.Second {
+			return time.Unix(n*int64(unit/time.Second), 0).UTC(), nil
+		}
+		return time.Unix(0, 0).Add(time.Duration(n) * unit).UTC(), nil
+	}
+}
+
+func decodeZonedTimestamp(value json.RawMessage, _ map[string]string) (interface{}, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return nil, err
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// decodeDecimal decodes a Connect Decimal: the unscaled value as a
+// base64-encoded big-endian two's complement integer, with the scale in
+// the schema parameters
+func decodeDecimal(value json.RawMessage, params map[string]string) (interface{}, error) {
+	var encoded string
+	if err := json.Unmarshal(value, &encoded); err != nil {
+		// decimal.handling.mode double or string
+		return value, nil
+	}
+
+	scale := 0
+	if s, ok := params["scale"]; ok {
+		if _, err := fmt.Sscan(s, &scale); err != nil {
+			return nil, fmt.Errorf("invalid scale %q", s)
+		}
+	}
+	return unscaledDecimal(encoded, scale)
+}
+
+// decodeVariableScaleDecimal decodes Debezium's VariableScaleDecimal, a
+// struct carrying its own scale next to the unscaled value
+func decodeVariableScaleDecimal(value json.RawMessage, _ map[string]string) (interface{}, error) {
+	var d struct {
+		Scale int    `json:"scale"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(value, &d); err != nil {
+		return nil, err
+	}
+	return unscaledDecimal(d.Value, d.Scale)
+}
+
+// unscaledDecimal renders the base64 unscaled integer encoded with scale
+// as a JSON number
+func unscaledDecimal(encoded string, scale int) (json.Number, error) {
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+
+	digits := new(big.Int).Abs(n).String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if n.Sign() < 0 {
+		digits = "-" + digits
+	}
+	return json.Number(digits), nil
+}
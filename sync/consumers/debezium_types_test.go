@@ -0,0 +1,93 @@
+package consumers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+)
+
+func TestLogicalTypeDecoders(t *testing.T) {
+	tests := []struct {
+		name     string
+		typeName string
+		value    string
+		params   map[string]string
+		want     string
+	}{
+		{"micro timestamp", typeDebeziumMicroTimestamp, `1700000000123456`, nil, `"2023-11-14T22:13:20.123456Z"`},
+		{"milli timestamp", typeDebeziumTimestamp, `1700000000123`, nil, `"2023-11-14T22:13:20.123Z"`},
+		{"nano timestamp", typeDebeziumNanoTimestamp, `1700000000123456789`, nil, `"2023-11-14T22:13:20.123456789Z"`},
+		{"connect timestamp", typeConnectTimestamp, `1700000000123`, nil, `"2023-11-14T22:13:20.123Z"`},
+		{"zoned timestamp", typeDebeziumZonedTimestamp, `"2023-11-15T05:13:20.5+07:00"`, nil, `"2023-11-15T05:13:20.5+07:00"`},
+		{"date", typeDebeziumDate, `19675`, nil, `"2023-11-14T00:00:00Z"`},
+		{"timestamp already a string", typeDebeziumMicroTimestamp, `"2023-11-14T22:13:20Z"`, nil, `"2023-11-14T22:13:20Z"`},
+		// 12345 is 0x3039
+		{"decimal", typeConnectDecimal, `"MDk="`, map[string]string{"scale": "2"}, `123.45`},
+		{"negative decimal", typeConnectDecimal, `"z8c="`, map[string]string{"scale": "2"}, `-123.45`},
+		{"decimal below one", typeConnectDecimal, `"MDk="`, map[string]string{"scale": "6"}, `0.012345`},
+		{"decimal without scale", typeConnectDecimal, `"MDk="`, nil, `12345`},
+		{"decimal handled as double", typeConnectDecimal, `123.45`, map[string]string{"scale": "2"}, `123.45`},
+		{"variable scale decimal", typeDebeziumVariableScale, `{"scale":3,"value":"MDk="}`, nil, `12.345`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := logicalTypes[tt.typeName](json.RawMessage(tt.value), tt.params)
+			if err != nil {
+				t.Fatalf("decode %s: %v", tt.value, err)
+			}
+			got, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatalf("marshal %v: %v", decoded, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decode %s = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRowConvertsDebeziumTypes(t *testing.T) {
+	// A categories row as the Postgres connector writes it, timestamps in
+	// epoch microseconds
+	row := json.RawMessage(`{"id":"7","name":"Books","created_at":1700000000123456,"updated_at":1700000001000000}`)
+	want := models.Category{
+		ID:        "7",
+		Name:      "Books",
+		CreatedAt: time.Date(2023, 11, 14, 22, 13, 20, 123456000, time.UTC),
+		UpdatedAt: time.Date(2023, 11, 14, 22, 13, 21, 0, time.UTC),
+	}
+
+	t.Run("without schema", func(t *testing.T) {
+		var got models.Category
+		if err := decodeRow(row, nil, categoryFieldTypes, newFieldMapper(config.FieldMappingConfig{}), &got); err != nil {
+			t.Fatalf("decodeRow: %v", err)
+		}
+		if got.ID != want.ID || !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+			t.Errorf("decodeRow = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("with schema", func(t *testing.T) {
+		schema := map[string]connectSchema{
+			"created_at": {Field: "created_at", Name: typeDebeziumMicroTimestamp},
+			"updated_at": {Field: "updated_at", Name: typeDebeziumMicroTimestamp},
+			"price":      {Field: "price", Name: typeConnectDecimal, Parameters: map[string]string{"scale": "2"}},
+		}
+		priced := json.RawMessage(`{"id":"7","name":"Books","created_at":1700000000123456,"updated_at":1700000001000000,"price":"MDk="}`)
+
+		var got struct {
+			models.Category
+			Price float64 `json:"price"`
+		}
+		if err := decodeRow(priced, schema, nil, newFieldMapper(config.FieldMappingConfig{}), &got); err != nil {
+			t.Fatalf("decodeRow: %v", err)
+		}
+		if !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) || got.Price != 123.45 {
+			t.Errorf("decodeRow = %+v, want %+v priced 123.45", got, want)
+		}
+	})
+}
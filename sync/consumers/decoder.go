@@ -0,0 +1,218 @@
+package consumers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+)
+
+// eventDecoder turns Kafka messages into Debezium events in the configured
+// format and validates them. values reads the serialized message values,
+// keys derives document IDs from message keys, mapper is applied when the
+// event's rows are decoded, and router names the entity of each topic.
+type eventDecoder struct {
+	format    string
+	values    Deserializer
+	keys      *keyDecoder
+	validator *envelopeValidator
+	mapper    *fieldMapper
+	router    *topicRouter
+}
+
+func newEventDecoder(format string, values Deserializer, keys *keyDecoder, validator *envelopeValidator,
+	mapper *fieldMapper, router *topicRouter) *eventDecoder {
+	return &eventDecoder{format: format, values: values, keys: keys, validator: validator, mapper: mapper, router: router}
+}
+
+// Decode parses message and validates the resulting event. A tombstone is
+// decoded as a delete of the document its key identifies.
+func (d *eventDecoder) Decode(message *sarama.ConsumerMessage) (*DebeziumEvent, error) {
+	if len(message.Value) == 0 {
+		return d.decodeTombstone(message)
+	}
+
+	var event *DebeziumEvent
+	var err error
+	if d.format == config.DebeziumFormatFlattened {
+		event, err = d.decodeFlattened(message)
+	} else {
+		event, err = d.values.Deserialize(message.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The key is only a fallback for rows without an id, so a key that
+	// can't be read doesn't fail a message whose row is fine
+	event.key, _ = d.keys.DocumentID(message.Key)
+
+	if err := d.validator.Validate(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (d *eventDecoder) decodeTombstone(message *sarama.ConsumerMessage) (*DebeziumEvent, error) {
+	id, err := d.keys.DocumentID(message.Key)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, utils.NewSyncError(
+			utils.ErrCodeKafkaDeserialize,
+			"Tombstone without a key",
+			nil,
+			"DESERIALIZE",
+			"message",
+		)
+	}
+
+	event := &DebeziumEvent{tombstone: true, key: id}
+	event.Payload.Op = "d"
+	event.Payload.Before = json.RawMessage("{}")
+	event.Payload.Source.Timestamp = kafkaTimestamp(message)
+	event.Payload.Source.Schema, event.Payload.Source.Table = topicSource(message.Topic)
+
+	if err := d.validator.Validate(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// decodeFlattened decodes a flattened message whatever its value format
+func (d *eventDecoder) decodeFlattened(message *sarama.ConsumerMessage) (*DebeziumEvent, error) {
+	value, err := d.values.JSON(message.Value)
+	if err != nil {
+		return nil, err
+	}
+	decoded := *message
+	decoded.Value = value
+	return decodeFlattened(&decoded)
+}
+
+// decodeEnvelope parses a full Debezium change event
+func decodeEnvelope(value []byte) (*DebeziumEvent, error) {
+	var event DebeziumEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return nil, deserializeError(err)
+	}
+	return &event, nil
+}
+
+// decodeFlattened rebuilds an event from ExtractNewRecordState output. The
+// operation comes from __op, or __deleted when deletes are rewritten; rows
+// with neither are treated as updates, which upsert. Messages that still
+// carry the full envelope are decoded as such.
+func decodeFlattened(message *sarama.ConsumerMessage) (*DebeziumEvent, error) {
+	event := &DebeziumEvent{flattened: true}
+	event.Payload.Source.Timestamp = kafkaTimestamp(message)
+	event.Payload.Source.Schema, event.Payload.Source.Table = topicSource(message.Topic)
+
+	row, fields, err := unwrapSchema(message.Value)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := fields["op"]; ok {
+		return decodeEnvelope(message.Value)
+	}
+
+	var wrapper struct {
+		Schema *connectSchema `json:"schema"`
+	}
+	if err := json.Unmarshal(message.Value, &wrapper); err != nil {
+		return nil, deserializeError(err)
+	}
+	event.Schema = wrapper.Schema
+
+	// Metadata ExtractNewRecordState adds to the row
+	var meta struct {
+		Op       string         `json:"__op"`
+		Deleted  string         `json:"__deleted"`
+		Schema   string         `json:"__schema"`
+		Table    string         `json:"__table"`
+		TsMs     int64          `json:"__source_ts_ms"`
+		Snapshot snapshotMarker `json:"__source_snapshot"`
+	}
+	if err := json.Unmarshal(row, &meta); err != nil {
+		return nil, deserializeError(err)
+	}
+
+	switch {
+	case meta.Deleted == "true":
+		event.Payload.Op = "d"
+	case meta.Op != "":
+		event.Payload.Op = meta.Op
+	default:
+		event.Payload.Op = "u"
+	}
+	if event.Payload.Op == "d" {
+		event.Payload.Before = row
+	} else {
+		event.Payload.After = row
+	}
+
+	if meta.Schema != "" && meta.Table != "" {
+		event.Payload.Source.Schema = meta.Schema
+		event.Payload.Source.Table = meta.Table
+	}
+	if meta.TsMs != 0 {
+		event.Payload.Source.Timestamp = meta.TsMs
+	}
+	event.Payload.Source.Snapshot = meta.Snapshot
+	return event, nil
+}
+
+// unwrapSchema returns the object in value, taking it out of the payload
+// field when the converter wrapped it with a schema, along with its fields
+func unwrapSchema(value []byte) (json.RawMessage, map[string]json.RawMessage, error) {
+	if len(value) == 0 {
+		return nil, nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, nil, deserializeError(err)
+	}
+	payload, ok := fields["payload"]
+	if !ok || isNullJSON(payload) {
+		return value, fields, nil
+	}
+
+	var inner map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &inner); err != nil {
+		return nil, nil, deserializeError(err)
+	}
+	return payload, inner, nil
+}
+
+// topicSource derives the source schema and table from a Debezium topic
+// name, <prefix>.<schema>.<table>
+func topicSource(topic string) (string, string) {
+	parts := strings.Split(topic, ".")
+	if len(parts) < 2 {
+		return "", topic
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+func deserializeError(err error) error {
+	return utils.NewSyncError(
+		utils.ErrCodeKafkaDeserialize,
+		"Invalid message format",
+		err,
+		"DESERIALIZE",
+		"message",
+	)
+}
+
+// kafkaTimestamp returns the message's Kafka timestamp in milliseconds, or 0
+// if it has none, as with messages written before Kafka 0.10
+func kafkaTimestamp(message *sarama.ConsumerMessage) int64 {
+	if message.Timestamp.IsZero() {
+		return 0
+	}
+	return message.Timestamp.UnixMilli()
+}
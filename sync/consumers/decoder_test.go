@@ -0,0 +1,101 @@
+package consumers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+)
+
+// newTestDecoder returns a decoder of JSON values in format
+func newTestDecoder(format string) *eventDecoder {
+	values := jsonDeserializer{}
+	router := newTopicRouter("dbserver1.public", []config.TopicMapping{{Suffix: "categories", Entity: "categories"}})
+	return newEventDecoder(format, values, newKeyDecoder(nil, values), newEnvelopeValidator(nil),
+		newFieldMapper(config.FieldMappingConfig{}), router)
+}
+
+// decodeOperation decodes message in format into the operation it applies
+func decodeOperation(t *testing.T, format string, message *sarama.ConsumerMessage) *models.CategoryOperation {
+	t.Helper()
+	d := newTestDecoder(format)
+	event, err := d.Decode(message)
+	if err != nil {
+		t.Fatalf("Decode(%s): %v", format, err)
+	}
+	operation, err := toCategoryOperation(event, d.mapper)
+	if err != nil {
+		t.Fatalf("toCategoryOperation(%s): %v", format, err)
+	}
+	return operation
+}
+
+func flattenedMessage(value string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Topic:     testTopic,
+		Key:       []byte(`{"id":"7"}`),
+		Value:     []byte(value),
+		Timestamp: time.UnixMilli(1700000000000),
+	}
+}
+
+func TestFlattenedAndEnvelopeDecodeAlike(t *testing.T) {
+	tests := []struct {
+		name      string
+		envelope  *sarama.ConsumerMessage
+		flattened *sarama.ConsumerMessage
+	}{
+		{
+			name:      "create",
+			envelope:  changeMessage(0, "c", "7", "Books"),
+			flattened: flattenedMessage(`{"id":"7","name":"Books","description":"Printed books","__op":"c","__source_ts_ms":1700000000000}`),
+		},
+		{
+			name:     "create with schema",
+			envelope: changeMessage(0, "c", "7", "Books"),
+			flattened: flattenedMessage(`{"schema":{"type":"struct","fields":[]},` +
+				`"payload":{"id":"7","name":"Books","description":"Printed books","__op":"c"}}`),
+		},
+		{
+			name:      "delete rewritten",
+			envelope:  changeMessage(0, "d", "7", "Books"),
+			flattened: flattenedMessage(`{"id":"7","name":"Books","description":"Printed books","__deleted":"true"}`),
+		},
+		{
+			// Without __op an upsert is the only safe reading
+			name:      "no metadata",
+			envelope:  changeMessage(0, "u", "7", "Books"),
+			flattened: flattenedMessage(`{"id":"7","name":"Books","description":"Printed books"}`),
+		},
+		{
+			name:      "envelope sent to a flattened consumer",
+			envelope:  changeMessage(0, "u", "7", "Books"),
+			flattened: changeMessage(0, "u", "7", "Books"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := decodeOperation(t, config.DebeziumFormatEnvelope, tt.envelope)
+			got := decodeOperation(t, config.DebeziumFormatFlattened, tt.flattened)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("flattened operation = %+v\nenvelope operation = %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestTombstoneDecodesAsDelete(t *testing.T) {
+	for _, format := range []string{config.DebeziumFormatEnvelope, config.DebeziumFormatFlattened} {
+		message := flattenedMessage("")
+		message.Value = nil
+
+		operation := decodeOperation(t, format, message)
+		if operation.Operation != models.OperationDelete || operation.Payload.ID != "7" {
+			t.Errorf("%s tombstone = %s of %q, want DELETE of 7", format, operation.Operation, operation.Payload.ID)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package consumers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+)
+
+// Deserializer decodes message values in the format they were produced in
+type Deserializer interface {
+	// Deserialize decodes a Debezium change event envelope
+	Deserialize(value []byte) (*DebeziumEvent, error)
+	// JSON returns the value as JSON, for decoders that work on the row
+	// itself rather than the envelope
+	JSON(value []byte) ([]byte, error)
+}
+
+func newDeserializer(cfg config.KafkaConfig) Deserializer {
+	if cfg.ValueFormat == config.ValueFormatAvro {
+		return newAvroDeserializer(cfg.SchemaRegistry)
+	}
+	return jsonDeserializer{}
+}
+
+// jsonDeserializer reads values written by Connect's JSON converter
+type jsonDeserializer struct{}
+
+func (jsonDeserializer) Deserialize(value []byte) (*DebeziumEvent, error) {
+	return decodeEnvelope(value)
+}
+
+func (jsonDeserializer) JSON(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+// avroMagicByte starts every value framed for Confluent Schema Registry,
+// followed by the 4 byte schema ID
+const avroMagicByte = 0
+
+// avroDeserializer reads Avro values framed for Confluent Schema Registry.
+// Schemas are fetched from the registry by ID on first use and cached;
+// schema IDs are immutable so the cache never needs invalidating.
+type avroDeserializer struct {
+	registryURL string
+	httpClient  *http.Client
+
+	mu     sync.RWMutex
+	codecs map[uint32]*goavro.Codec
+}
+
+func newAvroDeserializer(cfg config.SchemaRegistryConfig) *avroDeserializer {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &avroDeserializer{
+		registryURL: strings.TrimRight(cfg.URL, "/"),
+		httpClient:  &http.Client{Timeout: timeout},
+		codecs:      make(map[uint32]*goavro.Codec),
+	}
+}
+
+// Deserialize decodes an Avro Debezium envelope. Unlike the JSON converter,
+// the Avro converter writes the envelope fields at the top level.
+func (d *avroDeserializer) Deserialize(value []byte) (*DebeziumEvent, error) {
+	payload, err := d.JSON(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var event DebeziumEvent
+	if err := json.Unmarshal(payload, &event.Payload); err != nil {
+		return nil, deserializeError(err)
+	}
+	return &event, nil
+}
+
+// JSON decodes value into plain JSON, with unions unwrapped
+func (d *avroDeserializer) JSON(value []byte) ([]byte, error) {
+	if len(value) < 5 || value[0] != avroMagicByte {
+		return nil, deserializeError(fmt.Errorf("value is not framed for schema registry"))
+	}
+
+	codec, err := d.codec(binary.BigEndian.Uint32(value[1:5]))
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := codec.NativeFromBinary(value[5:])
+	if err != nil {
+		return nil, deserializeError(err)
+	}
+	textual, err := codec.TextualFromNative(nil, native)
+	if err != nil {
+		return nil, deserializeError(err)
+	}
+	return textual, nil
+}
+
+// codec returns the cached codec for id, fetching its schema if needed
+func (d *avroDeserializer) codec(id uint32) (*goavro.Codec, error) {
+	d.mu.RLock()
+	codec, ok := d.codecs[id]
+	d.mu.RUnlock()
+	if ok {
+		return codec, nil
+	}
+
+	schema, err := d.fetchSchema(id)
+	if err != nil {
+		// The registry being unreachable says nothing about the message,
+		// so this isn't a deserialization error
+		return nil, utils.NewSyncError(
+			utils.ErrCodeConnectionFailed,
+			fmt.Sprintf("Failed to fetch schema %d", id),
+			err,
+			"DESERIALIZE",
+			"schema_registry",
+		)
+	}
+
+	codec, err = goavro.NewCodecForStandardJSONFull(schema)
+	if err != nil {
+		return nil, deserializeError(fmt.Errorf("invalid schema %d: %w", id, err))
+	}
+
+	d.mu.Lock()
+	d.codecs[id] = codec
+	d.mu.Unlock()
+	return codec, nil
+}
+
+func (d *avroDeserializer) fetchSchema(id uint32) (string, error) {
+	resp, err := d.httpClient.Get(fmt.Sprintf("%s/schemas/ids/%d", d.registryURL, id))
+	if err != nil {
+		return "", fmt.Errorf("schema registry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("schema registry returned %s: %s", resp.Status, body)
+	}
+
+	var out struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode schema registry response: %w", err)
+	}
+	return out.Schema, nil
+}
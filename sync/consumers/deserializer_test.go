@@ -0,0 +1,103 @@
+package consumers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+)
+
+const envelopeSchema = `{
+	"type": "record",
+	"name": "Envelope",
+	"fields": [
+		{"name": "before", "type": ["null", {"type": "record", "name": "Value", "fields": [
+			{"name": "id", "type": "string"},
+			{"name": "name", "type": "string"}
+		]}], "default": null},
+		{"name": "after", "type": ["null", "Value"], "default": null},
+		{"name": "op", "type": "string"}
+	]
+}`
+
+// avroValue frames the Avro encoding of textual as schema registry does
+func avroValue(t *testing.T, schemaID uint32, textual string) []byte {
+	t.Helper()
+	codec, err := goavro.NewCodecForStandardJSONFull(envelopeSchema)
+	if err != nil {
+		t.Fatalf("NewCodec: %v", err)
+	}
+	native, _, err := codec.NativeFromTextual([]byte(textual))
+	if err != nil {
+		t.Fatalf("NativeFromTextual: %v", err)
+	}
+
+	value := []byte{avroMagicByte, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(value[1:], schemaID)
+	value, err = codec.BinaryFromNative(value, native)
+	if err != nil {
+		t.Fatalf("BinaryFromNative: %v", err)
+	}
+	return value
+}
+
+func TestAvroDeserializer(t *testing.T) {
+	var fetches atomic.Int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schemas/ids/7" {
+			http.NotFound(w, r)
+			return
+		}
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]string{"schema": envelopeSchema})
+	}))
+	defer registry.Close()
+
+	d := newDeserializer(config.KafkaConfig{
+		ValueFormat:    config.ValueFormatAvro,
+		SchemaRegistry: config.SchemaRegistryConfig{URL: registry.URL + "/"},
+	})
+	value := avroValue(t, 7, `{"before": null, "after": {"id": "1", "name": "Books"}, "op": "c"}`)
+
+	for i := 0; i < 2; i++ {
+		event, err := d.Deserialize(value)
+		if err != nil {
+			t.Fatalf("Deserialize: %v", err)
+		}
+		if event.Payload.Op != "c" {
+			t.Errorf("op = %q, want c", event.Payload.Op)
+		}
+		var after struct{ ID, Name string }
+		if err := json.Unmarshal(event.Payload.After, &after); err != nil || after.ID != "1" || after.Name != "Books" {
+			t.Errorf("after = %s (%v), want id 1 named Books", event.Payload.After, err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("registry fetched %d times, want the schema cached after 1", n)
+	}
+
+	t.Run("unframed value", func(t *testing.T) {
+		_, err := d.Deserialize([]byte(`{"payload": {}}`))
+		var syncErr *utils.SyncError
+		if !errors.As(err, &syncErr) || syncErr.Code != utils.ErrCodeKafkaDeserialize {
+			t.Errorf("Deserialize(json) error = %v, want code %s", err, utils.ErrCodeKafkaDeserialize)
+		}
+	})
+
+	t.Run("unknown schema", func(t *testing.T) {
+		// The registry failing isn't the message's fault, so the
+		// deserialize error policy mustn't apply
+		_, err := d.Deserialize(avroValue(t, 8, `{"before": null, "after": null, "op": "d"}`))
+		var syncErr *utils.SyncError
+		if !errors.As(err, &syncErr) || syncErr.Code != utils.ErrCodeConnectionFailed {
+			t.Errorf("Deserialize with unknown schema error = %v, want code %s", err, utils.ErrCodeConnectionFailed)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package consumers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+)
+
+// Headers set on dead-lettered messages so they can be traced back to, and
+// replayed onto, their source
+const (
+	dlqHeaderTopic     = "dlq.original.topic"
+	dlqHeaderPartition = "dlq.original.partition"
+	dlqHeaderOffset    = "dlq.original.offset"
+	dlqHeaderError     = "dlq.error"
+	dlqHeaderTimestamp = "dlq.timestamp"
+	dlqHeaderAttempt   = "dlq.attempt"
+	// dlqHeaderKind marks messages whose value is a CategoryOperation
+	// rather than the source event
+	dlqHeaderKind = "dlq.kind"
+)
+
+// dlqKindOperation is the dlqHeaderKind of an operation dead-lettered by
+// the retry queue, which no longer has the event it was decoded from
+const dlqKindOperation = "operation"
+
+// deadLetterQueue publishes messages the consumer gave up on to the
+// configured failure topic
+type deadLetterQueue struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func newDeadLetterQueue(brokers []string, base *sarama.Config, topic string) (*deadLetterQueue, error) {
+	cfg := *base
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Return.Errors = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Retry.Max = 3
+
+	producer, err := sarama.NewSyncProducer(brokers, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dead letter producer: %w", err)
+	}
+	return &deadLetterQueue{producer: producer, topic: topic}, nil
+}
+
+// Send copies message to the dead letter topic, keeping its key and headers
+// and recording where it came from and why it failed. A message replayed
+// from the dead letter topic keeps its original source and has its attempt
+// count incremented. A requeued operation has no source position to record.
+func (q *deadLetterQueue) Send(message *sarama.ConsumerMessage, cause error) error {
+	topic := []byte(message.Topic)
+	partition := []byte(strconv.Itoa(int(message.Partition)))
+	offset := []byte(strconv.FormatInt(message.Offset, 10))
+	replayed := false
+	operation := false
+
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers)+6)
+	for _, h := range message.Headers {
+		if h == nil {
+			continue
+		}
+		switch string(h.Key) {
+		case dlqHeaderTopic:
+			topic = h.Value
+			replayed = true
+		case dlqHeaderPartition:
+			partition = h.Value
+		case dlqHeaderOffset:
+			offset = h.Value
+		case dlqHeaderKind:
+			operation = string(h.Value) == dlqKindOperation
+			replayed = replayed || operation
+			headers = append(headers, *h)
+		case dlqHeaderError, dlqHeaderTimestamp, dlqHeaderAttempt:
+		default:
+			headers = append(headers, *h)
+		}
+	}
+
+	attempt := 1
+	if replayed {
+		attempt = dlqAttempt(message) + 1
+	}
+	if !operation {
+		headers = append(headers,
+			sarama.RecordHeader{Key: []byte(dlqHeaderTopic), Value: topic},
+			sarama.RecordHeader{Key: []byte(dlqHeaderPartition), Value: partition},
+			sarama.RecordHeader{Key: []byte(dlqHeaderOffset), Value: offset},
+		)
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(dlqHeaderError), Value: []byte(cause.Error())},
+		sarama.RecordHeader{Key: []byte(dlqHeaderTimestamp), Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+		sarama.RecordHeader{Key: []byte(dlqHeaderAttempt), Value: []byte(strconv.Itoa(attempt))},
+	)
+
+	_, _, err := q.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   q.topic,
+		Key:     sarama.ByteEncoder(message.Key),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to dead letter topic %s: %w", q.topic, err)
+	}
+	return nil
+}
+
+// SendOperation publishes an operation the retry queue gave up on. The
+// message is keyed by the row ID, like the source event, and replays
+// straight into SyncService.
+func (q *deadLetterQueue) SendOperation(operation *models.CategoryOperation, attempts int, cause error) error {
+	value, err := json.Marshal(operation)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter operation: %w", err)
+	}
+
+	_, _, err = q.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: q.topic,
+		Key:   sarama.StringEncoder(operation.Payload.ID),
+		Value: sarama.ByteEncoder(value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(dlqHeaderKind), Value: []byte(dlqKindOperation)},
+			{Key: []byte(dlqHeaderError), Value: []byte(cause.Error())},
+			{Key: []byte(dlqHeaderTimestamp), Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+			{Key: []byte(dlqHeaderAttempt), Value: []byte(strconv.Itoa(attempts))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to dead letter topic %s: %w", q.topic, err)
+	}
+	return nil
+}
+
+// dlqHeader returns the value of a dead letter header on message, or ""
+func dlqHeader(message *sarama.ConsumerMessage, key string) string {
+	for _, h := range message.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// dlqAttempt is how many times message has been dead-lettered. Messages
+// dead-lettered before attempts were recorded count as one.
+func dlqAttempt(message *sarama.ConsumerMessage) int {
+	n, err := strconv.Atoi(dlqHeader(message, dlqHeaderAttempt))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+func (q *deadLetterQueue) Close() error {
+	return q.producer.Close()
+}
@@ -0,0 +1,386 @@
+package consumers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+	"github.com/rendyspratama/digital-discovery/sync/services"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// ErrReplayInProgress is returned when a replay is requested while another
+// one is still running
+var ErrReplayInProgress = errors.New("dead letter replay already in progress")
+
+// Statuses of a replayed message
+const (
+	ReplayStatusPending  = "would_replay"
+	ReplayStatusReplayed = "replayed"
+	ReplayStatusRequeued = "requeued"
+)
+
+// dlqFetchTimeout bounds how long a fetch waits on a partition that has
+// fewer messages than its high water mark suggests
+const dlqFetchTimeout = 5 * time.Second
+
+// dlqSource reads dead-lettered messages and records which have been
+// handled, so the next replay starts after them
+type dlqSource interface {
+	// Fetch returns up to limit unhandled messages, in offset order within
+	// each partition
+	Fetch(ctx context.Context, limit int) ([]*sarama.ConsumerMessage, error)
+	// Ack marks messages as handled
+	Ack(messages []*sarama.ConsumerMessage) error
+	Close() error
+}
+
+// ReplayedMessage describes one dead-lettered message and what the replay
+// did with it
+type ReplayedMessage struct {
+	Topic      string `json:"topic"`
+	Partition  string `json:"partition"`
+	Offset     string `json:"offset"`
+	DLQOffset  int64  `json:"dlq_offset"`
+	Operation  string `json:"operation,omitempty"`
+	CategoryID string `json:"category_id,omitempty"`
+	Attempt    int    `json:"attempt"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ReplayReport summarizes a replay
+type ReplayReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Fetched  int               `json:"fetched"`
+	Replayed int               `json:"replayed"`
+	Requeued int               `json:"requeued"`
+	Messages []ReplayedMessage `json:"messages"`
+}
+
+// DLQReplayer moves dead-lettered messages back through SyncService once
+// whatever made them fail has been fixed
+type DLQReplayer struct {
+	source      dlqSource
+	dlq         *deadLetterQueue
+	syncService *services.SyncService
+	decoder     *eventDecoder
+	logger      logger.Logger
+	running     sync.Mutex
+}
+
+func newDLQReplayer(source dlqSource, dlq *deadLetterQueue, syncService *services.SyncService,
+	decoder *eventDecoder, logger logger.Logger) *DLQReplayer {
+	return &DLQReplayer{
+		source:      source,
+		dlq:         dlq,
+		syncService: syncService,
+		decoder:     decoder,
+		logger:      logger,
+	}
+}
+
+// Replay reprocesses up to limit dead-lettered messages. Messages that fail
+// again go back to the dead letter topic with their attempt count
+// incremented. A dry run only reports what would be replayed and leaves
+// the dead letter topic untouched.
+func (r *DLQReplayer) Replay(ctx context.Context, limit int, dryRun bool) (*ReplayReport, error) {
+	if !r.running.TryLock() {
+		return nil, ErrReplayInProgress
+	}
+	defer r.running.Unlock()
+
+	messages, err := r.source.Fetch(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch dead letter messages: %w", err)
+	}
+
+	report := &ReplayReport{
+		DryRun:   dryRun,
+		Fetched:  len(messages),
+		Messages: make([]ReplayedMessage, 0, len(messages)),
+	}
+
+	handled := 0
+	for _, message := range messages {
+		result := ReplayedMessage{
+			Topic:     dlqHeader(message, dlqHeaderTopic),
+			Partition: dlqHeader(message, dlqHeaderPartition),
+			Offset:    dlqHeader(message, dlqHeaderOffset),
+			DLQOffset: message.Offset,
+			Attempt:   dlqAttempt(message),
+		}
+
+		err := r.replay(ctx, message, &result, dryRun)
+		if err != nil {
+			// Never ack past a message that is neither replayed nor
+			// back on the dead letter topic
+			report.Messages = append(report.Messages, result)
+			if ackErr := r.source.Ack(messages[:handled]); ackErr != nil {
+				r.logger.WithError(ctx, ackErr, "Failed to acknowledge replayed messages", nil)
+			}
+			return report, err
+		}
+
+		switch result.Status {
+		case ReplayStatusReplayed:
+			report.Replayed++
+		case ReplayStatusRequeued:
+			report.Requeued++
+		}
+		report.Messages = append(report.Messages, result)
+		handled++
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if err := r.source.Ack(messages); err != nil {
+		return report, fmt.Errorf("failed to acknowledge replayed messages: %w", err)
+	}
+
+	r.logger.Info(ctx, "Dead letter replay completed", map[string]interface{}{
+		"fetched":  report.Fetched,
+		"replayed": report.Replayed,
+		"requeued": report.Requeued,
+	})
+	return report, nil
+}
+
+// replay reprocesses one message and fills in result. It only returns an
+// error when a failed message could not be put back on the dead letter
+// topic.
+func (r *DLQReplayer) replay(ctx context.Context, message *sarama.ConsumerMessage, result *ReplayedMessage, dryRun bool) error {
+	err := func() error {
+		operation, err := r.operation(message, result)
+		if err != nil {
+			return err
+		}
+		result.Operation = operation.Operation
+		result.CategoryID = operation.Payload.ID
+		if dryRun {
+			return nil
+		}
+		return r.syncService.ProcessCategoryOperation(ctx, operation)
+	}()
+
+	if dryRun {
+		result.Status = ReplayStatusPending
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return nil
+	}
+	if err == nil {
+		result.Status = ReplayStatusReplayed
+		return nil
+	}
+
+	result.Error = err.Error()
+	if sendErr := r.dlq.Send(message, err); sendErr != nil {
+		r.logger.WithError(ctx, sendErr, "Failed to requeue replayed message", map[string]interface{}{
+			"dlq_offset": message.Offset,
+		})
+		return sendErr
+	}
+	result.Status = ReplayStatusRequeued
+	result.Attempt++
+	return nil
+}
+
+func (r *DLQReplayer) Close() error {
+	return r.source.Close()
+}
+
+// kafkaDLQSource reads the dead letter topic directly, tracking progress
+// in its own consumer group so replays don't disturb the main consumer
+type kafkaDLQSource struct {
+	client   sarama.Client
+	consumer sarama.Consumer
+	offsets  sarama.OffsetManager
+	topic    string
+
+	mu         sync.Mutex
+	partitions map[int32]sarama.PartitionOffsetManager
+}
+
+func newKafkaDLQSource(brokers []string, base *sarama.Config, group, topic string) (*kafkaDLQSource, error) {
+	cfg := *base
+	cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	cfg.Consumer.Offsets.AutoCommit.Enable = false
+
+	client, err := sarama.NewClient(brokers, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dead letter client: %w", err)
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create dead letter consumer: %w", err)
+	}
+	offsets, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		consumer.Close()
+		client.Close()
+		return nil, fmt.Errorf("failed to create dead letter offset manager: %w", err)
+	}
+
+	return &kafkaDLQSource{
+		client:     client,
+		consumer:   consumer,
+		offsets:    offsets,
+		topic:      topic,
+		partitions: make(map[int32]sarama.PartitionOffsetManager),
+	}, nil
+}
+
+func (s *kafkaDLQSource) Fetch(ctx context.Context, limit int) ([]*sarama.ConsumerMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	partitions, err := s.client.Partitions(s.topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", s.topic, err)
+	}
+
+	var messages []*sarama.ConsumerMessage
+	for _, partition := range partitions {
+		if len(messages) >= limit {
+			break
+		}
+		fetched, err := s.fetchPartition(ctx, partition, limit-len(messages))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, fetched...)
+	}
+	return messages, nil
+}
+
+// fetchPartition reads up to limit messages from partition, starting after
+// the last acknowledged one and stopping at the high water mark
+func (s *kafkaDLQSource) fetchPartition(ctx context.Context, partition int32, limit int) ([]*sarama.ConsumerMessage, error) {
+	pom, err := s.partitionOffsets(partition)
+	if err != nil {
+		return nil, err
+	}
+
+	next, _ := pom.NextOffset()
+	if next < 0 {
+		if next, err = s.client.GetOffset(s.topic, partition, sarama.OffsetOldest); err != nil {
+			return nil, fmt.Errorf("failed to get oldest offset of %s/%d: %w", s.topic, partition, err)
+		}
+	}
+	newest, err := s.client.GetOffset(s.topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get newest offset of %s/%d: %w", s.topic, partition, err)
+	}
+	if next >= newest {
+		return nil, nil
+	}
+
+	pc, err := s.consumer.ConsumePartition(s.topic, partition, next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume %s/%d: %w", s.topic, partition, err)
+	}
+	defer pc.Close()
+
+	timeout := time.NewTimer(dlqFetchTimeout)
+	defer timeout.Stop()
+
+	var messages []*sarama.ConsumerMessage
+	for len(messages) < limit {
+		select {
+		case message := <-pc.Messages():
+			messages = append(messages, message)
+			if message.Offset >= newest-1 {
+				return messages, nil
+			}
+		case <-timeout.C:
+			return messages, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return messages, nil
+}
+
+func (s *kafkaDLQSource) partitionOffsets(partition int32) (sarama.PartitionOffsetManager, error) {
+	if pom, ok := s.partitions[partition]; ok {
+		return pom, nil
+	}
+	pom, err := s.offsets.ManagePartition(s.topic, partition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to manage offsets of %s/%d: %w", s.topic, partition, err)
+	}
+	s.partitions[partition] = pom
+	return pom, nil
+}
+
+func (s *kafkaDLQSource) Ack(messages []*sarama.ConsumerMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, message := range messages {
+		pom, err := s.partitionOffsets(message.Partition)
+		if err != nil {
+			return err
+		}
+		pom.MarkOffset(message.Offset+1, "")
+	}
+	s.offsets.Commit()
+	return nil
+}
+
+func (s *kafkaDLQSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pom := range s.partitions {
+		pom.AsyncClose()
+	}
+	if err := s.offsets.Close(); err != nil {
+		return err
+	}
+	if err := s.consumer.Close(); err != nil {
+		return err
+	}
+	return s.client.Close()
+}
+
+// operation recovers the operation a dead-lettered message holds: decoded
+// from the source event, or as it was when the retry queue gave up on it
+func (r *DLQReplayer) operation(message *sarama.ConsumerMessage, result *ReplayedMessage) (*models.CategoryOperation, error) {
+	if dlqHeader(message, dlqHeaderKind) == dlqKindOperation {
+		var operation models.CategoryOperation
+		if err := json.Unmarshal(message.Value, &operation); err != nil {
+			return nil, fmt.Errorf("failed to decode dead-lettered operation: %w", err)
+		}
+		return &operation, nil
+	}
+
+	// Route by the topic the message was dead-lettered from
+	entity, err := r.decoder.router.Entity(result.Topic)
+	if err != nil {
+		return nil, err
+	}
+	event, err := r.decoder.Decode(message)
+	if err != nil {
+		return nil, err
+	}
+	operation, err := toCategoryOperation(event, r.decoder.mapper)
+	if err != nil {
+		return nil, err
+	}
+	operation.Entity = entity
+	return operation, nil
+}
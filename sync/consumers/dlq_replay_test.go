@@ -0,0 +1,180 @@
+package consumers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// fakeDLQSource serves a fixed set of dead-lettered messages
+type fakeDLQSource struct {
+	messages []*sarama.ConsumerMessage
+	acked    []int64
+}
+
+func (s *fakeDLQSource) Fetch(ctx context.Context, limit int) ([]*sarama.ConsumerMessage, error) {
+	if limit < len(s.messages) {
+		return s.messages[:limit], nil
+	}
+	return s.messages, nil
+}
+
+func (s *fakeDLQSource) Ack(messages []*sarama.ConsumerMessage) error {
+	for _, message := range messages {
+		s.acked = append(s.acked, message.Offset)
+	}
+	return nil
+}
+
+func (s *fakeDLQSource) Close() error { return nil }
+
+// sendRecorder records the messages sent to the dead letter topic
+type sendRecorder struct {
+	sarama.SyncProducer
+	sent []*sarama.ProducerMessage
+}
+
+func (p *sendRecorder) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.sent = append(p.sent, msg)
+	return 0, int64(len(p.sent) - 1), nil
+}
+
+// deadLettered returns message as the dead letter topic holds it at offset
+func deadLettered(offset int64, message *sarama.ConsumerMessage, attempt int) *sarama.ConsumerMessage {
+	message.Headers = []*sarama.RecordHeader{
+		{Key: []byte(dlqHeaderTopic), Value: []byte(message.Topic)},
+		{Key: []byte(dlqHeaderPartition), Value: []byte("0")},
+		{Key: []byte(dlqHeaderOffset), Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		{Key: []byte(dlqHeaderError), Value: []byte("connection reset")},
+		{Key: []byte(dlqHeaderAttempt), Value: []byte(strconv.Itoa(attempt))},
+	}
+	message.Topic = "digital-discovery-dlq"
+	message.Offset = offset
+	return message
+}
+
+// newTestReplayer returns a replayer over source that writes to repo
+func newTestReplayer(repo *mocks.Repository, source dlqSource, producer *sendRecorder) *DLQReplayer {
+	h := newTestHandler(repo, 1)
+	dlq := &deadLetterQueue{producer: producer, topic: "digital-discovery-dlq"}
+	return newDLQReplayer(source, dlq, h.syncService, h.decoder, logger.NewLogger("json"))
+}
+
+func TestReplayRequeuesMessagesThatFailAgain(t *testing.T) {
+	repo := mocks.NewRepository()
+	repo.Errors["Delete"] = errors.New("connection reset")
+	source := &fakeDLQSource{messages: []*sarama.ConsumerMessage{
+		deadLettered(0, changeMessage(40, "c", "7", "Books"), 1),
+		deadLettered(1, changeMessage(41, "d", "8", "Comics"), 2),
+	}}
+	producer := &sendRecorder{}
+
+	report, err := newTestReplayer(repo, source, producer).Replay(context.Background(), 10, false)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	if report.Fetched != 2 || report.Replayed != 1 || report.Requeued != 1 {
+		t.Errorf("report = %+v, want 2 fetched, 1 replayed and 1 requeued", report)
+	}
+	if got := report.Messages[0]; got.Status != ReplayStatusReplayed || got.CategoryID != "7" || got.Offset != "40" {
+		t.Errorf("first message = %+v, want category 7 from offset 40 replayed", got)
+	}
+	if got := report.Messages[1]; got.Status != ReplayStatusRequeued || got.Attempt != 3 {
+		t.Errorf("second message = %+v, want requeued as attempt 3", got)
+	}
+	if len(repo.CallsTo("Index")) != 1 {
+		t.Errorf("Index calls = %+v, want the replayed create", repo.CallsTo("Index"))
+	}
+
+	// The requeued message keeps pointing at its source
+	if len(producer.sent) != 1 {
+		t.Fatalf("%d messages requeued, want 1", len(producer.sent))
+	}
+	headers := map[string]string{}
+	for _, h := range producer.sent[0].Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	if headers[dlqHeaderTopic] != testTopic || headers[dlqHeaderOffset] != "41" || headers[dlqHeaderAttempt] != "3" {
+		t.Errorf("requeued headers = %v, want %s offset 41 attempt 3", headers, testTopic)
+	}
+
+	if len(source.acked) != 2 {
+		t.Errorf("acked offsets %v, want both handled messages", source.acked)
+	}
+}
+
+func TestReplayDryRunChangesNothing(t *testing.T) {
+	repo := mocks.NewRepository()
+	source := &fakeDLQSource{messages: []*sarama.ConsumerMessage{
+		deadLettered(0, changeMessage(40, "c", "7", "Books"), 1),
+		deadLettered(1, changeMessage(41, "u", "8", "Comics"), 1),
+		deadLettered(2, changeMessage(42, "u", "9", "Maps"), 1),
+	}}
+	producer := &sendRecorder{}
+
+	report, err := newTestReplayer(repo, source, producer).Replay(context.Background(), 2, true)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	if !report.DryRun || report.Fetched != 2 || len(report.Messages) != 2 {
+		t.Fatalf("report = %+v, want a dry run over the first 2 messages", report)
+	}
+	for i, message := range report.Messages {
+		if message.Status != ReplayStatusPending || message.Operation == "" {
+			t.Errorf("message %d = %+v, want a decoded operation that would replay", i, message)
+		}
+	}
+	if calls := repo.Calls(); len(calls) != 0 {
+		t.Errorf("dry run called Elasticsearch: %+v", calls)
+	}
+	if len(producer.sent) != 0 || len(source.acked) != 0 {
+		t.Errorf("dry run requeued %d and acked %v, want the dead letter topic untouched", len(producer.sent), source.acked)
+	}
+}
+
+func TestReplayExhaustedOperation(t *testing.T) {
+	producer := &sendRecorder{}
+	dlq := &deadLetterQueue{producer: producer, topic: "digital-discovery-dlq"}
+	operation := &models.CategoryOperation{
+		Operation: models.OperationCreate,
+		Payload:   models.Category{ID: "7", Name: "Books", Description: "Printed books"},
+	}
+	if err := dlq.SendOperation(operation, 5, errors.New("connection reset")); err != nil {
+		t.Fatalf("SendOperation: %v", err)
+	}
+
+	// Read the dead-lettered operation back as the replayer would
+	sent := producer.sent[0]
+	value, _ := sent.Value.Encode()
+	message := &sarama.ConsumerMessage{Topic: sent.Topic, Value: value}
+	for _, h := range sent.Headers {
+		message.Headers = append(message.Headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	if key, _ := sent.Key.Encode(); string(key) != "7" {
+		t.Errorf("key = %q, want the row ID", key)
+	}
+	if dlqHeader(message, dlqHeaderTopic) != "" {
+		t.Error("a dead-lettered operation recorded a source topic")
+	}
+
+	repo := mocks.NewRepository()
+	report, err := newTestReplayer(repo, &fakeDLQSource{messages: []*sarama.ConsumerMessage{message}}, &sendRecorder{}).
+		Replay(context.Background(), 10, false)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if report.Replayed != 1 || report.Messages[0].CategoryID != "7" {
+		t.Errorf("report = %+v, want category 7 replayed", report)
+	}
+	if len(repo.CallsTo("Index")) != 1 {
+		t.Errorf("calls = %+v, want the operation indexed", repo.Calls())
+	}
+}
@@ -0,0 +1,95 @@
+package consumers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+)
+
+// debeziumConnector is the source.connector value of the Postgres connector
+const debeziumConnector = "postgresql"
+
+// envelopeValidator checks the shape of a Debezium event before it is
+// processed, so bad events fail with ErrCodeSchemaInvalid up front rather
+// than deep in unmarshaling
+type envelopeValidator struct {
+	// tables holds the accepted "schema.table" sources; empty accepts any
+	tables map[string]bool
+}
+
+func newEnvelopeValidator(tables []string) *envelopeValidator {
+	v := &envelopeValidator{tables: make(map[string]bool, len(tables))}
+	for _, t := range tables {
+		v.tables[t] = true
+	}
+	return v
+}
+
+func (v *envelopeValidator) Validate(event *DebeziumEvent) error {
+	// Tombstones and flattened rows take the Kafka timestamp, which old
+	// messages lack; only a full envelope must carry ts_ms
+	full := !event.flattened && !event.tombstone
+	if full && event.Payload.Source.Timestamp == 0 {
+		return utils.NewSyncError(
+			utils.ErrCodeInvalidPayload,
+			"Missing timestamp in event",
+			nil,
+			"VALIDATE",
+			"message",
+		)
+	}
+
+	if event.Payload.Op == "" {
+		return utils.NewSyncError(
+			utils.ErrCodeInvalidPayload,
+			"Missing operation in event",
+			nil,
+			"VALIDATE",
+			"message",
+		)
+	}
+
+	// Logical decoding messages aren't tied to a table and carry no row
+	if event.Payload.Op == "m" {
+		return nil
+	}
+
+	source := event.Payload.Source
+	if full && source.Connector != debeziumConnector {
+		return schemaError(fmt.Sprintf("Unexpected connector %q", source.Connector))
+	}
+
+	table := source.Schema + "." + source.Table
+	if len(v.tables) > 0 && !v.tables[table] {
+		return schemaError(fmt.Sprintf("Unexpected source table %q", table))
+	}
+
+	switch event.Payload.Op {
+	case "c", "u", "r":
+		if isNullJSON(event.Payload.After) {
+			return schemaError(fmt.Sprintf("Missing after state for operation %q", event.Payload.Op))
+		}
+	case "d":
+		if isNullJSON(event.Payload.Before) {
+			return schemaError("Missing before state for delete")
+		}
+	}
+
+	return nil
+}
+
+func schemaError(msg string) error {
+	return utils.NewSyncError(
+		utils.ErrCodeSchemaInvalid,
+		msg,
+		nil,
+		"VALIDATE",
+		"message",
+	)
+}
+
+// isNullJSON reports whether raw is absent or a JSON null
+func isNullJSON(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
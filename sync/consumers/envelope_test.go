@@ -0,0 +1,71 @@
+package consumers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+)
+
+func TestEnvelopeValidator(t *testing.T) {
+	const row = `{"id":"7","name":"Books"}`
+	source := func(connector, table string) string {
+		return `"source":{"connector":"` + connector + `","schema":"public","table":"` + table + `","ts_ms":1700000000000}`
+	}
+
+	tests := []struct {
+		name     string
+		envelope string
+		wantCode string
+	}{
+		{"create", `{"after":` + row + `,` + source("postgresql", "categories") + `,"op":"c"}`, ""},
+		{"delete", `{"before":` + row + `,` + source("postgresql", "categories") + `,"op":"d"}`, ""},
+		{"message", `{` + source("postgresql", "") + `,"op":"m"}`, ""},
+		{"missing timestamp", `{"after":` + row + `,"source":{"connector":"postgresql","schema":"public","table":"categories"},"op":"c"}`, utils.ErrCodeInvalidPayload},
+		{"missing op", `{"after":` + row + `,` + source("postgresql", "categories") + `}`, utils.ErrCodeInvalidPayload},
+		{"other connector", `{"after":` + row + `,` + source("mysql", "categories") + `,"op":"c"}`, utils.ErrCodeSchemaInvalid},
+		{"unexpected table", `{"after":` + row + `,` + source("postgresql", "orders") + `,"op":"c"}`, utils.ErrCodeSchemaInvalid},
+		{"create without after", `{"after":null,` + source("postgresql", "categories") + `,"op":"c"}`, utils.ErrCodeSchemaInvalid},
+		{"update without after", `{"before":` + row + `,` + source("postgresql", "categories") + `,"op":"u"}`, utils.ErrCodeSchemaInvalid},
+		{"read without after", `{` + source("postgresql", "categories") + `,"op":"r"}`, utils.ErrCodeSchemaInvalid},
+		{"delete without before", `{"before":null,` + source("postgresql", "categories") + `,"op":"d"}`, utils.ErrCodeSchemaInvalid},
+	}
+
+	v := newEnvelopeValidator([]string{"public.categories"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var event DebeziumEvent
+			if err := json.Unmarshal([]byte(`{"payload":`+tt.envelope+`}`), &event); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			err := v.Validate(&event)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			var syncErr *utils.SyncError
+			if !errors.As(err, &syncErr) || syncErr.Code != tt.wantCode {
+				t.Fatalf("Validate error = %v, want code %s", err, tt.wantCode)
+			}
+			// Invalid events go to the dead letter topic, not the retry queue
+			if utils.IsRetryableError(err) {
+				t.Errorf("%s error retryable", tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestEnvelopeValidatorWithoutTablesAcceptsAny(t *testing.T) {
+	var event DebeziumEvent
+	data := `{"payload":{"after":{"id":"7"},"source":{"connector":"postgresql","schema":"inventory","table":"orders","ts_ms":1},"op":"c"}}`
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := newEnvelopeValidator(nil).Validate(&event); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package consumers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// Policies for messages that can't be deserialized
+const (
+	// DeserializePolicySkip dead-letters the message and commits past it
+	DeserializePolicySkip = config.DeserializeErrorSkip
+	// DeserializePolicyRetry reprocesses the message until it succeeds,
+	// blocking its partition
+	DeserializePolicyRetry = config.DeserializeErrorRetry
+	// DeserializePolicyHalt pauses the partition and leaves the offset
+	// uncommitted so the message can be investigated
+	DeserializePolicyHalt = config.DeserializeErrorHalt
+)
+
+// malformedPolicy decides what happens to a message that failed to
+// deserialize. Such failures are data problems, not transient ones, so
+// retrying is opt-in rather than the default.
+type malformedPolicy struct {
+	mode         string
+	dlq          *deadLetterQueue
+	retryBackoff time.Duration
+	halt         func(topic string, partition int32)
+	logger       logger.Logger
+}
+
+// isRetryLost reports whether err means an operation failed and couldn't be
+// queued for a retry either
+func isRetryLost(err error) bool {
+	var syncErr *utils.SyncError
+	return errors.As(err, &syncErr) && syncErr.Code == utils.ErrCodeRetryPersist
+}
+
+// isMalformed reports whether err means the message itself is unreadable,
+// or too large to ever be written
+func isMalformed(err error) bool {
+	if isOversized(err) {
+		return true
+	}
+	syncErr, ok := err.(*utils.SyncError)
+	if !ok {
+		return false
+	}
+	switch syncErr.Code {
+	case utils.ErrCodeKafkaDeserialize, utils.ErrCodeDataTransform, utils.ErrCodeSchemaInvalid:
+		return true
+	default:
+		return false
+	}
+}
+
+// isSchemaInvalid reports whether err is a failed envelope validation
+func isSchemaInvalid(err error) bool {
+	syncErr, ok := err.(*utils.SyncError)
+	return ok && syncErr.Code == utils.ErrCodeSchemaInvalid
+}
+
+// isOversized reports whether err is a payload over the size limit
+func isOversized(err error) bool {
+	_, ok := err.(*utils.PayloadTooLargeError)
+	return ok
+}
+
+// Handle applies the policy to message and reports whether its offset may
+// be committed. reprocess is called by the retry policy.
+func (p *malformedPolicy) Handle(ctx context.Context, message *sarama.ConsumerMessage, cause error,
+	reprocess func() error) bool {
+	// An event with the wrong shape will never pass validation, nor will
+	// an oversized one shrink, so they are dead-lettered instead of retried
+	mode := p.mode
+	if mode == DeserializePolicyRetry && (isSchemaInvalid(cause) || isOversized(cause)) {
+		mode = DeserializePolicySkip
+	}
+
+	fields := map[string]interface{}{
+		"topic":     message.Topic,
+		"partition": message.Partition,
+		"offset":    message.Offset,
+		"policy":    mode,
+	}
+
+	switch mode {
+	case DeserializePolicyRetry:
+		for {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(p.retryBackoff):
+			}
+			err := reprocess()
+			if err == nil {
+				return true
+			}
+			if !isMalformed(err) {
+				return false
+			}
+			p.logger.WithError(ctx, err, "Malformed message still failing, retrying", fields)
+		}
+
+	case DeserializePolicyHalt:
+		p.logger.WithError(ctx, cause, "Halting partition on malformed message", fields)
+		if p.halt != nil {
+			p.halt(message.Topic, message.Partition)
+		}
+		return false
+
+	default:
+		if p.dlq == nil {
+			p.logger.WithError(ctx, cause, "Skipping malformed message, no dead letter topic configured", fields)
+			return true
+		}
+		if err := p.dlq.Send(message, cause); err != nil {
+			// Leave the offset uncommitted rather than lose the message
+			p.logger.WithError(ctx, err, "Failed to dead-letter malformed message", fields)
+			return false
+		}
+		p.logger.Info(ctx, "Malformed message sent to dead letter topic", fields)
+		return true
+	}
+}
@@ -0,0 +1,56 @@
+package consumers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+func TestIsRetryLost(t *testing.T) {
+	lost := utils.NewSyncError(utils.ErrCodeRetryPersist, "Failed to persist retry", errors.New("disk full"), "DELETE", "category")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unpersisted retry", lost, true},
+		{"wrapped unpersisted retry", fmt.Errorf("retry: %w", lost), true},
+		{"other sync error", utils.NewSyncError(utils.ErrCodeESConnection, "down", nil, "DELETE", "category"), false},
+		{"plain error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryLost(tt.err); got != tt.want {
+				t.Errorf("isRetryLost(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOversizedPayloadIsNeverRetried(t *testing.T) {
+	oversized := utils.NewPayloadTooLargeError("CREATE", "category", 2048, 1024)
+	if !isMalformed(oversized) {
+		t.Fatal("isMalformed(oversized payload) = false, want true")
+	}
+
+	policy := &malformedPolicy{mode: DeserializePolicyRetry, logger: logger.NewLogger("json")}
+	message := &sarama.ConsumerMessage{Topic: testTopic, Partition: 0, Offset: 7}
+	reprocessed := 0
+	commit := policy.Handle(context.Background(), message, oversized, func() error {
+		reprocessed++
+		return oversized
+	})
+
+	if !commit {
+		t.Error("Handle kept the offset of an oversized payload, want it skipped")
+	}
+	if reprocessed != 0 {
+		t.Errorf("an oversized payload was reprocessed %d times, want 0", reprocessed)
+	}
+}
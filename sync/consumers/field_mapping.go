@@ -0,0 +1,87 @@
+package consumers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+)
+
+// fieldTransform rewrites the fields of a row in place
+type fieldTransform func(fields map[string]json.RawMessage)
+
+// fieldMapper applies the configured column to field mapping to rows
+// before they are turned into documents. Transforms run in order.
+type fieldMapper struct {
+	transforms []fieldTransform
+}
+
+func newFieldMapper(cfg config.FieldMappingConfig) *fieldMapper {
+	m := &fieldMapper{}
+	if len(cfg.Drop) > 0 {
+		m.transforms = append(m.transforms, dropFields(cfg.Drop))
+	}
+	if len(cfg.Rename) > 0 {
+		m.transforms = append(m.transforms, renameFields(cfg.Rename))
+	}
+	if len(cfg.StatusLabels) > 0 {
+		m.transforms = append(m.transforms, labelStatus(cfg.StatusLabels))
+	}
+	return m
+}
+
+// Map applies the transforms to fields
+func (m *fieldMapper) Map(fields map[string]json.RawMessage) {
+	if m == nil {
+		return
+	}
+	for _, transform := range m.transforms {
+		transform(fields)
+	}
+}
+
+func dropFields(names []string) fieldTransform {
+	return func(fields map[string]json.RawMessage) {
+		for _, name := range names {
+			delete(fields, name)
+		}
+	}
+}
+
+// renameFields moves each column to its new name, replacing a field that
+// already has that name
+func renameFields(renames map[string]string) fieldTransform {
+	return func(fields map[string]json.RawMessage) {
+		renamed := make(map[string]json.RawMessage, len(renames))
+		for from, to := range renames {
+			if value, ok := fields[from]; ok {
+				delete(fields, from)
+				renamed[to] = value
+			}
+		}
+		for name, value := range renamed {
+			fields[name] = value
+		}
+	}
+}
+
+// labelStatus sets status_label to the label of the row's status code, so
+// documents carry a readable status while Postgres keeps the integer. A
+// code without a label passes through as its string form.
+func labelStatus(labels map[string]string) fieldTransform {
+	return func(fields map[string]json.RawMessage) {
+		value, ok := fields["status"]
+		if !ok || isNullJSON(value) {
+			return
+		}
+
+		code := strings.Trim(string(value), `"`)
+		label, ok := labels[code]
+		if !ok {
+			label = code
+		}
+		if encoded, err := json.Marshal(label); err == nil {
+			fields["status_label"] = encoded
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package consumers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+)
+
+func TestFieldMapper(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.FieldMappingConfig
+		row  string
+		want string
+	}{
+		{
+			name: "no mapping",
+			row:  `{"id":"7","name":"Books"}`,
+			want: `{"id":"7","name":"Books"}`,
+		},
+		{
+			name: "rename",
+			cfg:  config.FieldMappingConfig{Rename: map[string]string{"cat_name": "name", "cat_desc": "description"}},
+			row:  `{"id":"7","cat_name":"Books","cat_desc":"Printed books"}`,
+			want: `{"id":"7","name":"Books","description":"Printed books"}`,
+		},
+		{
+			name: "rename replaces the existing field",
+			cfg:  config.FieldMappingConfig{Rename: map[string]string{"cat_name": "name"}},
+			row:  `{"id":"7","name":"old","cat_name":"Books"}`,
+			want: `{"id":"7","name":"Books"}`,
+		},
+		{
+			// Renames read each other's sources, not their results
+			name: "swap",
+			cfg:  config.FieldMappingConfig{Rename: map[string]string{"a": "b", "b": "a"}},
+			row:  `{"a":1,"b":2}`,
+			want: `{"a":2,"b":1}`,
+		},
+		{
+			name: "drop",
+			cfg:  config.FieldMappingConfig{Drop: []string{"internal_notes", "missing"}},
+			row:  `{"id":"7","name":"Books","internal_notes":"secret"}`,
+			want: `{"id":"7","name":"Books"}`,
+		},
+		{
+			// Drops name source columns, before they are renamed
+			name: "drop before rename",
+			cfg:  config.FieldMappingConfig{Drop: []string{"name"}, Rename: map[string]string{"cat_name": "name"}},
+			row:  `{"name":"legacy","cat_name":"Books"}`,
+			want: `{"name":"Books"}`,
+		},
+		{
+			name: "status label",
+			cfg:  config.FieldMappingConfig{StatusLabels: map[string]string{"1": "active"}},
+			row:  `{"id":"7","status":1}`,
+			want: `{"id":"7","status":1,"status_label":"active"}`,
+		},
+		{
+			name: "unlabelled status",
+			cfg:  config.FieldMappingConfig{StatusLabels: map[string]string{"1": "active"}},
+			row:  `{"id":"7","status":9}`,
+			want: `{"id":"7","status":9,"status_label":"9"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.row), &fields); err != nil {
+				t.Fatalf("unmarshal row: %v", err)
+			}
+			newFieldMapper(tt.cfg).Map(fields)
+
+			var got, want map[string]interface{}
+			mapped, _ := json.Marshal(fields)
+			json.Unmarshal(mapped, &got)
+			json.Unmarshal([]byte(tt.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("mapped row = %s, want %s", mapped, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldMappingShapesTheDocument(t *testing.T) {
+	d := newTestDecoder(config.DebeziumFormatEnvelope)
+	d.mapper = newFieldMapper(config.FieldMappingConfig{
+		Rename: map[string]string{"cat_name": "name"},
+		Drop:   []string{"description"},
+	})
+
+	message := &sarama.ConsumerMessage{
+		Topic: testTopic,
+		Value: []byte(`{"payload":{"before":{"id":"7","cat_name":"Books","description":"old"},` +
+			`"after":{"id":"7","cat_name":"Comics","description":"new"},` +
+			`"source":{"connector":"postgresql","schema":"public","table":"categories","ts_ms":1700000000000},"op":"u"}}`),
+	}
+	event, err := d.Decode(message)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	operation, err := toCategoryOperation(event, d.mapper)
+	if err != nil {
+		t.Fatalf("toCategoryOperation: %v", err)
+	}
+
+	if operation.Payload.Name != "Comics" || operation.Payload.Description != "" {
+		t.Errorf("document = %+v, want cat_name renamed and description dropped", operation.Payload)
+	}
+	// The before image is mapped the same way, so a dropped column never
+	// shows up as changed
+	if want := []string{"name"}; operation.Operation != models.OperationUpdate || !reflect.DeepEqual(operation.Changed, want) {
+		t.Errorf("%s changed %v, want UPDATE of %v", operation.Operation, operation.Changed, want)
+	}
+}
+
+func TestLabelStatus(t *testing.T) {
+	labels := map[string]string{"0": "inactive", "1": "active"}
+
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		row       string
+		wantLabel string
+		wantNone  bool
+	}{
+		{name: "numeric code", labels: labels, row: `{"status":0}`, wantLabel: "inactive"},
+		{name: "string code", labels: labels, row: `{"status":"1"}`, wantLabel: "active"},
+		{name: "unlabelled code", labels: labels, row: `{"status":"archived"}`, wantLabel: "archived"},
+		{name: "null status", labels: labels, row: `{"status":null}`, wantNone: true},
+		{name: "no status", labels: labels, row: `{"id":"7"}`, wantNone: true},
+		{name: "no labels configured", row: `{"status":1}`, wantNone: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.row), &fields); err != nil {
+				t.Fatalf("unmarshal row: %v", err)
+			}
+			newFieldMapper(config.FieldMappingConfig{StatusLabels: tt.labels}).Map(fields)
+
+			encoded, ok := fields["status_label"]
+			if tt.wantNone {
+				if ok {
+					t.Errorf("status_label = %s, want none", encoded)
+				}
+				return
+			}
+			var label string
+			if err := json.Unmarshal(encoded, &label); err != nil || label != tt.wantLabel {
+				t.Errorf("status_label = %s, want %q", encoded, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestStatusLabelReachesTheDocument(t *testing.T) {
+	d := newTestDecoder(config.DebeziumFormatEnvelope)
+	d.mapper = newFieldMapper(config.FieldMappingConfig{StatusLabels: map[string]string{"1": "active"}})
+
+	message := &sarama.ConsumerMessage{
+		Topic: testTopic,
+		Value: []byte(`{"payload":{"before":null,"after":{"id":"7","name":"Books","status":1},` +
+			`"source":{"connector":"postgresql","schema":"public","table":"categories","ts_ms":1700000000000},"op":"c"}}`),
+	}
+	event, err := d.Decode(message)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	operation, err := toCategoryOperation(event, d.mapper)
+	if err != nil {
+		t.Fatalf("toCategoryOperation: %v", err)
+	}
+	if operation.Payload.Status != 1 || operation.Payload.StatusLabel != "active" {
+		t.Errorf("document status = %d labelled %q, want 1 labelled active", operation.Payload.Status, operation.Payload.StatusLabel)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -16,62 +18,109 @@ import (
 type ConsumerHandler struct {
 	syncService *services.SyncService
 	logger      logger.Logger
+	offsets     *offsetAuditor
+	malformed   *malformedPolicy
+	snapshot    *snapshotTracker
+	heartbeat   *heartbeat
+	heartbeats  *sourceHeartbeats
+	decoder     *eventDecoder
+	pause       *pauseGate
+	session     *activeSession
+	workers     int
 	ready       chan bool
 }
 
 type DebeziumEvent struct {
+	Schema  *connectSchema `json:"schema"`
 	Payload struct {
 		Before json.RawMessage `json:"before"`
 		After  json.RawMessage `json:"after"`
 		Source struct {
-			Version   string `json:"version"`
-			Connector string `json:"connector"`
-			Database  string `json:"database"`
-			Schema    string `json:"schema"`
-			Table     string `json:"table"`
-			TxId      string `json:"txId"`
-			Lsn       string `json:"lsn"`
-			Timestamp int64  `json:"ts_ms"`
+			Version   string         `json:"version"`
+			Connector string         `json:"connector"`
+			Database  string         `json:"database"`
+			Schema    string         `json:"schema"`
+			Table     string         `json:"table"`
+			TxId      string         `json:"txId"`
+			Lsn       string         `json:"lsn"`
+			Timestamp int64          `json:"ts_ms"`
+			Snapshot  snapshotMarker `json:"snapshot"`
 		} `json:"source"`
 		Op string `json:"op"`
 	} `json:"payload"`
+
+	// flattened is set for events rebuilt from ExtractNewRecordState
+	// output, and tombstone for deletes rebuilt from a tombstone; neither
+	// carries connector information
+	flattened bool
+	tombstone bool
+
+	// key is the document ID derived from the message key
+	key string
 }
 
-func (h *ConsumerHandler) Setup(sarama.ConsumerGroupSession) error {
+func (h *ConsumerHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.session.setup(session)
 	close(h.ready)
 	return nil
 }
 
-func (h *ConsumerHandler) Cleanup(sarama.ConsumerGroupSession) error {
+// Cleanup runs when the session ends, before partitions are handed to
+// another member. Buffered operations are flushed and marked offsets
+// committed now, so the new owner neither loses nor repeats them.
+func (h *ConsumerHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	// The session context is already cancelled here
+	ctx := context.Background()
+	defer h.session.cleanup()
+
+	if pending := h.syncService.BulkBufferSize(); pending > 0 {
+		h.logger.Info(ctx, "Flushing bulk buffer before rebalance", map[string]interface{}{
+			"buffer_size": pending,
+		})
+		if err := h.syncService.FlushBulkBuffer(ctx); err != nil {
+			return fmt.Errorf("failed to flush bulk buffer on rebalance: %w", err)
+		}
+	}
+
+	session.Commit()
 	return nil
 }
 
 func (h *ConsumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	if h.workers > 1 {
+		return h.consumeSharded(session, claim)
+	}
+
+	// Once the partition is halted, messages already fetched are left
+	// uncommitted so consumption resumes from the halting message
+	halted := false
+
 	for {
 		select {
 		case message, ok := <-claim.Messages():
 			if !ok {
 				return nil
 			}
+			if halted {
+				continue
+			}
+			// Held here while paused; the message is left unmarked if the
+			// session ends first
+			if !h.pause.wait(session.Context()) {
+				return nil
+			}
 
-			ctx := context.WithValue(session.Context(), "requestID", session.GenerationID())
-
-			h.logger.Info(ctx, "Processing message", map[string]interface{}{
-				"topic":     message.Topic,
-				"partition": message.Partition,
-				"offset":    message.Offset,
-			})
-
-			if err := h.processMessage(ctx, message); err != nil {
-				h.logger.WithError(ctx, err, "Failed to process message", map[string]interface{}{
-					"topic":     message.Topic,
-					"partition": message.Partition,
-					"offset":    message.Offset,
-				})
+			commit, halt := h.handleMessage(session, message)
+			if halt {
+				halted = true
+				continue
+			}
+			if !commit {
 				continue
 			}
 
 			session.MarkMessage(message, "")
+			h.offsets.Marked(session.Context(), message, claim.HighWaterMarkOffset())
 
 		case <-session.Context().Done():
 			return nil
@@ -79,29 +128,121 @@ func (h *ConsumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 	}
 }
 
+// handleMessage processes one message and applies the malformed message
+// policy on failure. It reports whether the message's offset may be marked,
+// and whether the partition has been halted.
+func (h *ConsumerHandler) handleMessage(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) (commit, halt bool) {
+	ctx := context.WithValue(session.Context(), "requestID", session.GenerationID())
+
+	h.heartbeat.begin()
+	defer h.heartbeat.end()
+
+	h.logger.Info(ctx, "Processing message", map[string]interface{}{
+		"topic":     message.Topic,
+		"partition": message.Partition,
+		"offset":    message.Offset,
+	})
+
+	err := h.processMessage(ctx, message)
+	if err == nil {
+		return true, false
+	}
+
+	h.logger.WithError(ctx, err, "Failed to process message", map[string]interface{}{
+		"topic":     message.Topic,
+		"partition": message.Partition,
+		"offset":    message.Offset,
+	})
+	if isRetryLost(err) {
+		// The operation was neither applied nor queued for a retry, and
+		// marking a later offset would commit past it
+		if h.malformed != nil && h.malformed.halt != nil {
+			h.malformed.halt(message.Topic, message.Partition)
+		}
+		return false, true
+	}
+	if !isMalformed(err) || h.malformed == nil {
+		return false, false
+	}
+
+	commit = h.malformed.Handle(ctx, message, err, func() error {
+		return h.processMessage(ctx, message)
+	})
+	if !commit {
+		return false, h.malformed.mode == DeserializePolicyHalt
+	}
+	return true, false
+}
+
 func (h *ConsumerHandler) processMessage(ctx context.Context, message *sarama.ConsumerMessage) error {
-	var event DebeziumEvent
-	if err := json.Unmarshal(message.Value, &event); err != nil {
-		return utils.NewSyncError(
-			utils.ErrCodeKafkaDeserialize,
-			"Invalid message format",
-			err,
-			"DESERIALIZE",
-			"message",
-		)
+	if h.heartbeats.Matches(message.Topic) {
+		h.heartbeats.Observe(ctx, message)
+		return nil
 	}
 
-	if err := h.validateMessage(&event); err != nil {
+	entity, err := h.decoder.router.Entity(message.Topic)
+	if err != nil {
+		return err
+	}
+
+	event, err := h.decoder.Decode(message)
+	if err != nil {
 		return err
 	}
 
-	operation := h.mapOperation(event.Payload.Op)
+	h.snapshot.Observe(ctx, event.Payload.Source.Snapshot, message.Topic)
+
+	switch event.Payload.Op {
+	case "m":
+		// Logical decoding messages aren't row changes; nothing to sync
+		return nil
+	case "t":
+		return h.truncate(ctx, event, entity)
+	}
+
+	categoryOp, err := toCategoryOperation(event, h.decoder.mapper)
+	if err != nil {
+		return err
+	}
+	categoryOp.Entity = entity
+
+	// Applying a change while an older one for the row waits on a retry
+	// would let the retry overwrite it
+	if h.syncService.RetryPending(entity, categoryOp.Payload.ID) {
+		return h.syncService.RetryOperation(ctx, categoryOp, nil)
+	}
+
+	err = h.syncService.ProcessCategoryOperation(ctx, categoryOp)
+	if err != nil {
+		// If the error is retryable, schedule a retry
+		if h.syncService.IsRetryable(err) {
+			return h.syncService.RetryOperation(ctx, categoryOp, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// truncate applies a truncate of the table behind entity
+func (h *ConsumerHandler) truncate(ctx context.Context, event *DebeziumEvent, entity string) error {
+	source := models.SourceInfo{
+		Schema: event.Payload.Source.Schema,
+		Table:  event.Payload.Source.Table,
+	}
+	_, err := h.syncService.TruncateEntity(ctx, entity, source)
+	return err
+}
+
+// toCategoryOperation builds the operation SyncService applies for event
+func toCategoryOperation(event *DebeziumEvent, mapper *fieldMapper) (*models.CategoryOperation, error) {
+	operation := mapOperation(event.Payload.Op)
 	var category models.Category
 
 	switch operation {
 	case models.OperationCreate, models.OperationUpdate:
-		if err := json.Unmarshal(event.Payload.After, &category); err != nil {
-			return utils.NewSyncError(
+		if err := decodeRow(event.Payload.After, event.rowSchema("after"), categoryFieldTypes, mapper, &category); err != nil {
+			return nil, utils.NewSyncError(
 				utils.ErrCodeDataTransform,
 				"Failed to unmarshal category",
 				err,
@@ -110,8 +251,8 @@ func (h *ConsumerHandler) processMessage(ctx context.Context, message *sarama.Co
 			)
 		}
 	case models.OperationDelete:
-		if err := json.Unmarshal(event.Payload.Before, &category); err != nil {
-			return utils.NewSyncError(
+		if err := decodeRow(event.Payload.Before, event.rowSchema("before"), categoryFieldTypes, mapper, &category); err != nil {
+			return nil, utils.NewSyncError(
 				utils.ErrCodeDataTransform,
 				"Failed to unmarshal category",
 				err,
@@ -120,7 +261,7 @@ func (h *ConsumerHandler) processMessage(ctx context.Context, message *sarama.Co
 			)
 		}
 	default:
-		return utils.NewSyncError(
+		return nil, utils.NewSyncError(
 			utils.ErrCodeInvalidPayload,
 			fmt.Sprintf("Unknown operation: %s", operation),
 			nil,
@@ -129,51 +270,67 @@ func (h *ConsumerHandler) processMessage(ctx context.Context, message *sarama.Co
 		)
 	}
 
-	categoryOp := &models.CategoryOperation{
-		Operation: operation,
-		Payload:   category,
-		Timestamp: time.Unix(0, event.Payload.Source.Timestamp*int64(time.Millisecond)),
+	if category.ID == "" {
+		category.ID = event.key
 	}
 
-	err := h.syncService.ProcessCategoryOperation(ctx, categoryOp)
-	if err != nil {
-		// If the error is retryable, attempt retry
-		if utils.IsRetryableError(err) {
-			return h.syncService.RetryOperation(ctx, categoryOp)
-		}
-		return err
+	var changed []string
+	if operation == models.OperationUpdate {
+		changed = changedFields(event, mapper, category)
 	}
 
-	return nil
+	return &models.CategoryOperation{
+		Operation: operation,
+		Payload:   category,
+		Timestamp: sourceTime(event.Payload.Source.Timestamp),
+		Source: models.SourceInfo{
+			Schema: event.Payload.Source.Schema,
+			Table:  event.Payload.Source.Table,
+		},
+		Changed: changed,
+	}, nil
 }
 
-func (h *ConsumerHandler) validateMessage(event *DebeziumEvent) error {
-	if event.Payload.Source.Timestamp == 0 {
-		return utils.NewSyncError(
-			utils.ErrCodeInvalidPayload,
-			"Missing timestamp in event",
-			nil,
-			"VALIDATE",
-			"message",
-		)
+// changedFields returns the document fields that differ between an update's
+// before image and after, or nil when it has no usable before image
+func changedFields(event *DebeziumEvent, mapper *fieldMapper, after models.Category) []string {
+	if len(event.Payload.Before) == 0 || string(event.Payload.Before) == "null" {
+		return nil
+	}
+	var before models.Category
+	if err := decodeRow(event.Payload.Before, event.rowSchema("before"), categoryFieldTypes, mapper, &before); err != nil {
+		return nil
+	}
+	if before.ID == "" {
+		before.ID = after.ID
 	}
 
-	if event.Payload.Op == "" {
-		return utils.NewSyncError(
-			utils.ErrCodeInvalidPayload,
-			"Missing operation in event",
-			nil,
-			"VALIDATE",
-			"message",
-		)
+	beforeFields, afterFields := fieldsOf(before), fieldsOf(after)
+	changed := make([]string, 0)
+	for field, value := range afterFields {
+		if !reflect.DeepEqual(beforeFields[field], value) {
+			changed = append(changed, field)
+		}
 	}
+	sort.Strings(changed)
+	return changed
+}
 
-	return nil
+// fieldsOf returns category as the document fields it is indexed as
+func fieldsOf(category models.Category) map[string]interface{} {
+	var fields map[string]interface{}
+	data, _ := json.Marshal(category)
+	_ = json.Unmarshal(data, &fields)
+	return fields
 }
 
-func (h *ConsumerHandler) mapOperation(op string) string {
+// mapOperation maps a Debezium op to the operation SyncService applies.
+// Snapshot reads ("r") carry the full row like creates, and CREATE indexes
+// the whole document, so replaying a snapshot over existing documents
+// overwrites them rather than failing.
+func mapOperation(op string) string {
 	switch op {
-	case "c":
+	case "c", "r":
 		return "CREATE"
 	case "u":
 		return "UPDATE"
@@ -184,10 +341,28 @@ func (h *ConsumerHandler) mapOperation(op string) string {
 	}
 }
 
-func NewConsumerHandler(syncService *services.SyncService, logger logger.Logger) *ConsumerHandler {
+func NewConsumerHandler(syncService *services.SyncService, logger logger.Logger, offsets *offsetAuditor, malformed *malformedPolicy, snapshot *snapshotTracker, heartbeat *heartbeat, heartbeats *sourceHeartbeats, decoder *eventDecoder, pause *pauseGate, session *activeSession, workers int) *ConsumerHandler {
 	return &ConsumerHandler{
 		syncService: syncService,
 		logger:      logger,
+		offsets:     offsets,
+		malformed:   malformed,
+		snapshot:    snapshot,
+		heartbeat:   heartbeat,
+		heartbeats:  heartbeats,
+		decoder:     decoder,
+		pause:       pause,
+		session:     session,
+		workers:     workers,
 		ready:       make(chan bool),
 	}
 }
+
+// sourceTime converts a ts_ms to a time, leaving it zero when ts_ms is 0 so
+// a missing timestamp isn't read as the Unix epoch
+func sourceTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
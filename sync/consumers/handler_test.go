@@ -0,0 +1,314 @@
+package consumers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/services"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+const testTopic = "dbserver1.public.categories"
+
+// fakeSession is a consumer group session that records marked offsets
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx context.Context
+
+	mu      sync.Mutex
+	marked  []int64
+	commits int
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+func (s *fakeSession) GenerationID() int32      { return 1 }
+
+func (s *fakeSession) MarkMessage(message *sarama.ConsumerMessage, metadata string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, message.Offset)
+}
+
+func (s *fakeSession) Commit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commits++
+}
+
+// fakeClaim is a claim on testTopic serving messages
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func newFakeClaim(messages ...*sarama.ConsumerMessage) *fakeClaim {
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(messages))}
+	for _, message := range messages {
+		claim.messages <- message
+	}
+	close(claim.messages)
+	return claim
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return int64(cap(c.messages)) }
+
+// newTestHandler returns a handler for testTopic writing through repo with
+// the given number of workers
+func newTestHandler(repo *mocks.Repository, workers int) *ConsumerHandler {
+	cfg := &config.Config{}
+	cfg.ES.IndexPrefix = "digital-discovery"
+	cfg.ES.IndexSeparator = "-"
+	cfg.Sync.Custom.BatchSize = 100
+	cfg.Sync.Custom.ConflictMode = config.ConflictLastWriteWins
+	cfg.Sync.Custom.RetryableCodes = utils.DefaultRetryableCodes
+
+	log := logger.NewLogger("json")
+	syncService := services.NewSyncService(repo, cfg, log)
+	values := jsonDeserializer{}
+	router := newTopicRouter("dbserver1.public", []config.TopicMapping{{Suffix: "categories", Entity: "categories"}})
+	decoder := newEventDecoder(config.DebeziumFormatEnvelope, values, newKeyDecoder(nil, values),
+		newEnvelopeValidator(nil), newFieldMapper(config.FieldMappingConfig{}), router)
+
+	return NewConsumerHandler(syncService, log, newOffsetAuditor(log, syncService.Metrics(), 0, false), nil,
+		newSnapshotTracker(log, syncService.Metrics()), &heartbeat{}, newSourceHeartbeats("", values, log, syncService.Metrics()),
+		decoder, &pauseGate{}, &activeSession{}, workers)
+}
+
+// changeMessage returns a Debezium change event for the category with id at
+// offset, whose name is name
+func changeMessage(offset int64, op, id, name string) *sarama.ConsumerMessage {
+	row := fmt.Sprintf(`{"id":%q,"name":%q,"description":"Printed books"}`, id, name)
+	before, after := "null", row
+	if op == "d" {
+		before, after = row, "null"
+	}
+	value := fmt.Sprintf(`{"payload":{"before":%s,"after":%s,`+
+		`"source":{"connector":"postgresql","schema":"public","table":"categories","ts_ms":1700000000000},"op":%q}}`,
+		before, after, op)
+	return &sarama.ConsumerMessage{
+		Topic:  testTopic,
+		Offset: offset,
+		Key:    []byte(fmt.Sprintf(`{"id":%q}`, id)),
+		Value:  []byte(value),
+	}
+}
+
+// docName returns the category name an Index or Update call wrote
+func docName(t *testing.T, call mocks.Call) string {
+	t.Helper()
+	var body struct {
+		Name string `json:"name"`
+		Doc  struct {
+			Name string `json:"name"`
+		} `json:"doc"`
+	}
+	if err := json.Unmarshal([]byte(call.Body), &body); err != nil {
+		t.Fatalf("decode %s body: %v", call.Method, err)
+	}
+	if body.Doc.Name != "" {
+		return body.Doc.Name
+	}
+	return body.Name
+}
+
+func TestSnapshotReadIndexesDocument(t *testing.T) {
+	repo := mocks.NewRepository()
+	h := newTestHandler(repo, 1)
+	session := &fakeSession{ctx: context.Background()}
+
+	if err := h.ConsumeClaim(session, newFakeClaim(changeMessage(0, "r", "7", "Books"))); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+
+	// A snapshot read replaces the whole document, like a create
+	calls := repo.CallsTo("Index")
+	if len(calls) != 1 || calls[0].ID != "7" || docName(t, calls[0]) != "Books" {
+		t.Fatalf("Index calls = %+v, want one indexing category 7", calls)
+	}
+	if len(session.marked) != 1 || session.marked[0] != 0 {
+		t.Errorf("marked offsets %v, want [0]", session.marked)
+	}
+}
+
+func TestMapOperation(t *testing.T) {
+	tests := map[string]string{
+		"c": models.OperationCreate,
+		"r": models.OperationCreate,
+		"u": models.OperationUpdate,
+		"d": models.OperationDelete,
+		"t": "UNKNOWN",
+	}
+	for op, want := range tests {
+		if got := mapOperation(op); got != want {
+			t.Errorf("mapOperation(%q) = %s, want %s", op, got, want)
+		}
+	}
+}
+
+func TestMissingTimestampsStayZero(t *testing.T) {
+	values := jsonDeserializer{}
+	mapper := newFieldMapper(config.FieldMappingConfig{})
+	envelope := newEventDecoder(config.DebeziumFormatEnvelope, values, newKeyDecoder(nil, values),
+		newEnvelopeValidator(nil), mapper, nil)
+	flattened := newEventDecoder(config.DebeziumFormatFlattened, values, newKeyDecoder(nil, values),
+		newEnvelopeValidator(nil), mapper, nil)
+	kafkaTime := time.UnixMilli(1700000005000)
+
+	tests := []struct {
+		name    string
+		decoder *eventDecoder
+		message *sarama.ConsumerMessage
+		want    time.Time
+	}{
+		{
+			name:    "envelope ts_ms",
+			decoder: envelope,
+			message: changeMessage(0, "u", "7", "Books"),
+			want:    time.UnixMilli(1700000000000),
+		},
+		{
+			name:    "tombstone without Kafka timestamp",
+			decoder: envelope,
+			message: &sarama.ConsumerMessage{Topic: testTopic, Key: []byte(`{"id":"7"}`)},
+		},
+		{
+			name:    "tombstone with Kafka timestamp",
+			decoder: envelope,
+			message: &sarama.ConsumerMessage{Topic: testTopic, Key: []byte(`{"id":"7"}`), Timestamp: kafkaTime},
+			want:    kafkaTime,
+		},
+		{
+			name:    "flattened without Kafka timestamp",
+			decoder: flattened,
+			message: &sarama.ConsumerMessage{Topic: testTopic, Value: []byte(`{"id":"7","name":"Books","__op":"u"}`)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := tt.decoder.Decode(tt.message)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			operation, err := toCategoryOperation(event, mapper)
+			if err != nil {
+				t.Fatalf("toCategoryOperation: %v", err)
+			}
+			if !operation.Timestamp.Equal(tt.want) {
+				t.Errorf("Timestamp = %v, want %v", operation.Timestamp, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanupFlushesBufferBeforeCommit(t *testing.T) {
+	repo := mocks.NewRepository()
+	h := newTestHandler(repo, 1)
+	session := &fakeSession{ctx: context.Background()}
+	if err := h.Setup(session); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	operation := models.CategoryOperation{
+		Operation: models.OperationCreate,
+		Payload:   models.Category{ID: "7", Name: "Books", Description: "Printed books"},
+	}
+	if err := h.syncService.AddToBulkBuffer(operation); err != nil {
+		t.Fatalf("AddToBulkBuffer: %v", err)
+	}
+
+	if err := h.Cleanup(session); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if len(repo.CallsTo("Bulk")) != 1 {
+		t.Errorf("calls = %+v, want the buffer flushed in one bulk request", repo.Calls())
+	}
+	if n := h.syncService.BulkBufferSize(); n != 0 {
+		t.Errorf("%d operations still buffered", n)
+	}
+	if session.commits != 1 {
+		t.Errorf("%d commits, want offsets committed once the buffer was flushed", session.commits)
+	}
+}
+
+func TestCleanupKeepsOffsetsWhenFlushFails(t *testing.T) {
+	repo := mocks.NewRepository()
+	repo.Errors["Bulk"] = fmt.Errorf("connection reset")
+	h := newTestHandler(repo, 1)
+	session := &fakeSession{ctx: context.Background()}
+	h.Setup(session)
+
+	operation := models.CategoryOperation{
+		Operation: models.OperationCreate,
+		Payload:   models.Category{ID: "7", Name: "Books", Description: "Printed books"},
+	}
+	h.syncService.AddToBulkBuffer(operation)
+
+	// The next owner must see the buffered operation's message again
+	if err := h.Cleanup(session); err == nil {
+		t.Error("Cleanup succeeded with a failed flush")
+	}
+	if session.commits != 0 {
+		t.Errorf("%d commits after a failed flush, want none", session.commits)
+	}
+}
+
+func TestTruncateAndMessageEvents(t *testing.T) {
+	truncate := &sarama.ConsumerMessage{
+		Topic: testTopic,
+		Value: []byte(`{"payload":{"before":null,"after":null,` +
+			`"source":{"connector":"postgresql","schema":"public","table":"categories","ts_ms":1700000000000},"op":"t"}}`),
+	}
+	logical := &sarama.ConsumerMessage{
+		Topic: testTopic,
+		Value: []byte(`{"payload":{"op":"m","ts_ms":1700000000000,` +
+			`"source":{"connector":"postgresql","ts_ms":1700000000000},` +
+			`"message":{"prefix":"audit","content":"aGVsbG8="}}}`),
+	}
+
+	tests := []struct {
+		name    string
+		message *sarama.ConsumerMessage
+		apply   bool
+		want    []string
+	}{
+		{"truncate logged only", truncate, false, nil},
+		{"truncate applied", truncate, true, []string{"DeleteByQuery"}},
+		{"logical message", logical, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewRepository()
+			h := newTestHandler(repo, 1)
+			cfg := &config.Config{}
+			cfg.ES.IndexPrefix = "digital-discovery"
+			cfg.ES.IndexSeparator = "-"
+			cfg.Sync.Custom.ApplyTruncates = tt.apply
+			h.syncService = services.NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+			if err := h.processMessage(context.Background(), tt.message); err != nil {
+				t.Fatalf("processMessage: %v", err)
+			}
+
+			var methods []string
+			for _, call := range repo.Calls() {
+				methods = append(methods, call.Method)
+			}
+			if !slices.Equal(methods, tt.want) {
+				t.Errorf("calls = %v, want %v", methods, tt.want)
+			}
+		})
+	}
+}
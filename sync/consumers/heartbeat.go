@@ -0,0 +1,41 @@
+package consumers
+
+import (
+	"sync"
+	"time"
+)
+
+// heartbeat tracks whether message processing is making progress. It beats
+// when a message starts processing with nothing else in flight and whenever
+// one finishes. The consumer is stalled when messages are in flight and it
+// hasn't beaten within the liveness timeout; an idle consumer, or one with
+// no partitions assigned, is never stalled.
+type heartbeat struct {
+	mu       sync.Mutex
+	inFlight int
+	last     time.Time
+}
+
+func (hb *heartbeat) begin() {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.inFlight == 0 {
+		hb.last = time.Now()
+	}
+	hb.inFlight++
+}
+
+func (hb *heartbeat) end() {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	hb.inFlight--
+	hb.last = time.Now()
+}
+
+// stalled reports whether processing has made no progress for longer than
+// timeout as of now, along with when it last did
+func (hb *heartbeat) stalled(now time.Time, timeout time.Duration) (bool, time.Time) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	return hb.inFlight > 0 && now.Sub(hb.last) > timeout, hb.last
+}
@@ -0,0 +1,70 @@
+package consumers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeatStalled(t *testing.T) {
+	const timeout = 30 * time.Second
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		inFlight int
+		last     time.Duration
+		want     bool
+	}{
+		{"idle for long", 0, -time.Hour, false},
+		{"busy and recent", 1, -time.Second, false},
+		{"busy and stale", 1, -time.Minute, true},
+		{"never started", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hb := &heartbeat{inFlight: tt.inFlight, last: now.Add(tt.last)}
+			if got, _ := hb.stalled(now, timeout); got != tt.want {
+				t.Errorf("stalled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeartbeatBeatsOnProgress(t *testing.T) {
+	hb := &heartbeat{}
+	hb.begin()
+	started := hb.last
+
+	// A second message starting doesn't count as progress
+	hb.begin()
+	if !hb.last.Equal(started) {
+		t.Error("begin with a message in flight beat the heartbeat")
+	}
+
+	hb.end()
+	if hb.last.Before(started) {
+		t.Error("end didn't beat the heartbeat")
+	}
+	if stalled, _ := hb.stalled(time.Now().Add(time.Minute), 30*time.Second); !stalled {
+		t.Error("heartbeat with a message still in flight a minute later not stalled")
+	}
+
+	hb.end()
+	if stalled, _ := hb.stalled(time.Now().Add(time.Hour), 30*time.Second); stalled {
+		t.Error("idle consumer reported stalled")
+	}
+}
+
+func TestKafkaConsumerStalled(t *testing.T) {
+	last := time.Now().Add(-time.Minute)
+	c := &KafkaConsumer{heartbeat: &heartbeat{inFlight: 1, last: last}}
+
+	stalled, at := c.Stalled(30 * time.Second)
+	if !stalled || !at.Equal(last) {
+		t.Errorf("Stalled = %v, %v, want stalled since %v", stalled, at, last)
+	}
+	if stalled, _ := c.Stalled(2 * time.Minute); stalled {
+		t.Error("Stalled within the timeout")
+	}
+}
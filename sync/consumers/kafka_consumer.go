@@ -2,26 +2,158 @@ package consumers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
 	"github.com/rendyspratama/digital-discovery/sync/services"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
 	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
 )
 
+// ErrConsumerHalted is returned when resuming a consumer that halted on a
+// failure policy
+var ErrConsumerHalted = errors.New("consumer is halted and must be restarted")
+
 type KafkaConsumer struct {
-	consumer    sarama.ConsumerGroup
-	syncService *services.SyncService
-	logger      logger.Logger
-	topics      []string
-	status      string
-	statusMu    sync.RWMutex
+	consumer     sarama.ConsumerGroup
+	brokers      []string
+	saramaConfig *sarama.Config
+	session      *activeSession
+	syncService  *services.SyncService
+	logger       logger.Logger
+	metrics      *metrics.MetricsCollector
+	topics       []string
+	restarts     *restartLimiter
+	offsets      *offsetAuditor
+	malformed    *malformedPolicy
+	dlq          *deadLetterQueue
+	replayer     *DLQReplayer
+	snapshot     *snapshotTracker
+	heartbeat    *heartbeat
+	heartbeats   *sourceHeartbeats
+	decoder      *eventDecoder
+	pause        *pauseGate
+	workers      int
+	exhausted    string
+	status       string
+	statusMu     sync.RWMutex
+	closeOnce    sync.Once
+	closeErr     error
 }
 
 func NewKafkaConsumer(cfg *config.Config, syncService *services.SyncService, logger logger.Logger) (*KafkaConsumer, error) {
+	policy := cfg.Kafka.DeserializeErrorPolicy
+	switch policy {
+	case DeserializePolicySkip, DeserializePolicyRetry, DeserializePolicyHalt:
+	case "":
+		policy = DeserializePolicySkip
+	default:
+		return nil, fmt.Errorf("invalid kafka.deserialize_error_policy %q", policy)
+	}
+
+	config, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create consumer group, waiting for brokers that are still starting
+	startup := utils.WaitPolicy{
+		MaxAttempts: cfg.Startup.MaxAttempts,
+		Backoff:     cfg.Startup.Backoff,
+		MaxBackoff:  cfg.Startup.MaxBackoff,
+	}
+	var group sarama.ConsumerGroup
+	err = utils.WaitFor(context.Background(), startup, func(context.Context) error {
+		var err error
+		group, err = sarama.NewConsumerGroup(cfg.Kafka.Brokers, cfg.Kafka.GroupID, config)
+		return err
+	}, func(attempt int, wait time.Duration, err error) {
+		logger.WithError(context.Background(), err, "Kafka not reachable, retrying", map[string]interface{}{
+			"attempt":      attempt,
+			"max_attempts": cfg.Startup.MaxAttempts,
+			"backoff":      wait.String(),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	// Dead letter producer for messages the consumer gives up on
+	var dlq *deadLetterQueue
+	if cfg.Sync.Custom.FailureQueue != "" {
+		dlq, err = newDeadLetterQueue(cfg.Kafka.Brokers, config, cfg.Sync.Custom.FailureQueue)
+		if err != nil {
+			group.Close()
+			return nil, err
+		}
+	}
+
+	values := newDeserializer(cfg.Kafka)
+	router := newTopicRouter(cfg.Kafka.TopicPrefix, cfg.Kafka.Topics)
+	decoder := newEventDecoder(cfg.Sync.Debezium.Format, values, newKeyDecoder(cfg.Kafka.KeyFields, values),
+		newEnvelopeValidator(cfg.Kafka.ExpectedTables), newFieldMapper(cfg.Sync.FieldMapping), router)
+
+	// Replays read the dead letter topic under their own group so their
+	// progress is kept apart from the main consumer's
+	var replayer *DLQReplayer
+	if dlq != nil {
+		source, err := newKafkaDLQSource(cfg.Kafka.Brokers, config, cfg.Kafka.GroupID+"-dlq-replay", cfg.Sync.Custom.FailureQueue)
+		if err != nil {
+			dlq.Close()
+			group.Close()
+			return nil, err
+		}
+		replayer = newDLQReplayer(source, dlq, syncService, decoder, logger)
+	}
+
+	topics := router.Topics()
+	if cfg.Kafka.HeartbeatTopic != "" {
+		topics = append(topics, cfg.Kafka.HeartbeatTopic)
+	}
+
+	consumer := &KafkaConsumer{
+		consumer:     group,
+		brokers:      cfg.Kafka.Brokers,
+		saramaConfig: config,
+		session:      &activeSession{},
+		syncService:  syncService,
+		logger:       logger,
+		metrics:      syncService.Metrics(),
+		topics:       topics,
+		restarts:     newRestartLimiter(cfg.Kafka.MaxRestarts, cfg.Kafka.RestartWindow, cfg.Kafka.RestartBackoff),
+		offsets: newOffsetAuditor(logger, syncService.Metrics(),
+			cfg.Kafka.OffsetCommitLogEvery, cfg.Kafka.OffsetCommitMetrics),
+		dlq:        dlq,
+		replayer:   replayer,
+		snapshot:   newSnapshotTracker(logger, syncService.Metrics()),
+		heartbeat:  &heartbeat{},
+		heartbeats: newSourceHeartbeats(cfg.Kafka.HeartbeatTopic, values, logger, syncService.Metrics()),
+		decoder:    decoder,
+		pause:      &pauseGate{},
+		workers:    cfg.Sync.Custom.Workers,
+		exhausted:  cfg.Sync.Custom.ExhaustedPolicy,
+		status:     "initialized",
+	}
+	consumer.malformed = &malformedPolicy{
+		mode:         policy,
+		dlq:          dlq,
+		retryBackoff: consumer.restarts.baseBackoff,
+		halt:         consumer.haltPartition,
+		logger:       logger,
+	}
+
+	return consumer, nil
+}
+
+// newSaramaConfig builds the client config shared by the consumer group,
+// the dead letter producer and the replay and offset lookup clients
+func newSaramaConfig(cfg *config.Config) (*sarama.Config, error) {
 	config := sarama.NewConfig()
 
 	// Version must be greater than 0.10.2.0
@@ -29,7 +161,11 @@ func NewKafkaConsumer(cfg *config.Config, syncService *services.SyncService, log
 
 	// Consumer group settings
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
-	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Offsets.Initial = initialOffset(cfg.Kafka.AutoOffsetReset)
+	config.Consumer.Fetch.Max = cfg.Kafka.FetchMaxBytes
+	config.Consumer.Group.Session.Timeout = cfg.Kafka.SessionTimeout
+	config.Consumer.Group.Heartbeat.Interval = cfg.Kafka.HeartbeatInterval
+	config.Consumer.MaxProcessingTime = cfg.Kafka.MaxProcessingTime
 	config.Consumer.Return.Errors = true
 	config.Consumer.Offsets.AutoCommit.Enable = true
 	config.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
@@ -41,24 +177,111 @@ func NewKafkaConsumer(cfg *config.Config, syncService *services.SyncService, log
 		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
 	}
 
+	// Only the dead letter producer produces
+	if err := config.Producer.Compression.UnmarshalText([]byte(cfg.Kafka.Compression)); err != nil {
+		return nil, fmt.Errorf("invalid kafka.compression %q: %w", cfg.Kafka.Compression, err)
+	}
+
 	// Add additional consumer configurations
 	config.Consumer.Return.Errors = true
 	config.Consumer.Offsets.AutoCommit.Enable = true
 	config.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
 
-	// Create consumer group
-	group, err := sarama.NewConsumerGroup(cfg.Kafka.Brokers, cfg.Kafka.GroupID, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	return config, nil
+}
+
+// initialOffset maps kafka.auto_offset_reset to where sarama starts a
+// partition the group has no committed offset for. Config.Validate rejects
+// anything but earliest and latest.
+func initialOffset(reset string) int64 {
+	if reset == config.OffsetResetLatest {
+		return sarama.OffsetNewest
+	}
+	return sarama.OffsetOldest
+}
+
+// handleError logs and counts an error from the consumer group, marking the
+// consumer unhealthy only if it's fatal
+func (c *KafkaConsumer) handleError(ctx context.Context, err error) {
+	category := classifyConsumerError(err)
+	c.syncService.Metrics().RecordConsumerError(category)
+	c.logger.WithError(ctx, err, "Error from consumer", map[string]interface{}{
+		"type": category,
+	})
+	if isFatalConsumerError(category) {
+		c.setStatus("error")
 	}
+}
 
-	return &KafkaConsumer{
-		consumer:    group,
-		syncService: syncService,
-		logger:      logger,
-		topics:      []string{fmt.Sprintf("%s.categories", cfg.Kafka.TopicPrefix)},
-		status:      "initialized",
-	}, nil
+// haltPartition stops fetching from a partition and marks the consumer as
+// halted so readiness fails until an operator intervenes
+func (c *KafkaConsumer) haltPartition(topic string, partition int32) {
+	c.consumer.Pause(map[string][]int32{topic: {partition}})
+	c.setStatus("halted")
+}
+
+// HandleExhausted applies sync.custom.exhausted_policy to an operation that
+// failed all its retries: skip leaves it recorded as failed, dlq publishes
+// it to the dead letter topic for replay, and halt stops consuming
+// altogether so readiness fails until an operator intervenes.
+func (c *KafkaConsumer) HandleExhausted(ctx context.Context, record *models.SyncRecord, cause error) {
+	fields := map[string]interface{}{
+		"operation_id": record.ID,
+		"operation":    record.Operation,
+		"policy":       c.exhausted,
+	}
+
+	switch c.exhausted {
+	case config.ExhaustedDLQ:
+		if c.dlq == nil || record.Payload == nil {
+			break
+		}
+		if err := c.dlq.SendOperation(record.Payload, record.RetryCount, cause); err != nil {
+			c.logger.WithError(ctx, err, "Failed to dead-letter exhausted operation", fields)
+			return
+		}
+		c.logger.Info(ctx, "Exhausted operation sent to dead letter topic", fields)
+		return
+	case config.ExhaustedHalt:
+		c.consumer.PauseAll()
+		c.setStatus("halted")
+		c.logger.WithError(ctx, cause, "Consumer halted after an operation exhausted its retries", fields)
+		return
+	}
+
+	c.logger.Info(ctx, "Skipping exhausted operation", fields)
+}
+
+// Pause stops consuming for maintenance, without leaving the consumer group.
+// Fetching stops and messages already fetched are held until Resume, so
+// nothing is processed meanwhile. It reports false if already paused.
+func (c *KafkaConsumer) Pause(ctx context.Context) bool {
+	if !c.pause.pause() {
+		return false
+	}
+	c.consumer.PauseAll()
+	c.logger.Info(ctx, "Consumer paused", nil)
+	return true
+}
+
+// Resume undoes Pause. It reports false if the consumer wasn't paused, and
+// fails if the consumer halted meanwhile, since that needs an operator to
+// fix the cause and restart it.
+func (c *KafkaConsumer) Resume(ctx context.Context) (bool, error) {
+	if c.getStatus() == "halted" {
+		return false, ErrConsumerHalted
+	}
+	if !c.pause.resume() {
+		return false, nil
+	}
+	c.consumer.ResumeAll()
+	c.logger.Info(ctx, "Consumer resumed", nil)
+	return true, nil
+}
+
+// Paused reports whether consumption is paused
+func (c *KafkaConsumer) Paused() bool {
+	return c.pause.isPaused()
 }
 
 func (c *KafkaConsumer) Start(ctx context.Context) error {
@@ -67,8 +290,7 @@ func (c *KafkaConsumer) Start(ctx context.Context) error {
 	// Handle errors
 	go func() {
 		for err := range c.consumer.Errors() {
-			c.logger.WithError(ctx, err, "Error from consumer", nil)
-			c.setStatus("error")
+			c.handleError(ctx, err)
 		}
 	}()
 
@@ -76,16 +298,30 @@ func (c *KafkaConsumer) Start(ctx context.Context) error {
 
 	// Consume messages
 	for {
-		handler := NewConsumerHandler(c.syncService, c.logger)
+		handler := NewConsumerHandler(c.syncService, c.logger, c.offsets, c.malformed, c.snapshot, c.heartbeat, c.heartbeats, c.decoder, c.pause, c.session, c.workers)
 
-		err := c.consumer.Consume(ctx, c.topics, handler)
+		// The session's own context lets ResetOffsets end it; the loop
+		// then rejoins from the reset offsets
+		err := c.consumer.Consume(c.session.begin(ctx), c.topics, handler)
 		if err != nil {
 			if err == sarama.ErrClosedConsumerGroup {
 				c.setStatus("closed")
 				return nil
 			}
 			c.setStatus("error")
-			return fmt.Errorf("error from consumer: %w", err)
+
+			if !c.restart(ctx, err) {
+				if ctx.Err() != nil {
+					c.setStatus("stopped")
+					return ctx.Err()
+				}
+				// Stay up but report not ready so the orchestrator stops
+				// routing to us instead of crash-looping the process
+				c.setStatus("failed")
+				return nil
+			}
+			c.setStatus("running")
+			continue
 		}
 
 		// Check if context was cancelled
@@ -96,15 +332,60 @@ func (c *KafkaConsumer) Start(ctx context.Context) error {
 	}
 }
 
-func (c *KafkaConsumer) Close() error {
-	c.setStatus("closing")
-	err := c.consumer.Close()
-	if err != nil {
-		c.setStatus("error")
-		return err
+// restart waits out the restart backoff after a Consume error. It returns
+// false when the restart budget is exhausted or the context is cancelled.
+func (c *KafkaConsumer) restart(ctx context.Context, cause error) bool {
+	allowed, backoff := c.restarts.Allow(time.Now())
+	if !allowed {
+		c.metrics.RecordConsumerRestart("exhausted")
+		c.logger.WithError(ctx, cause, "Consumer restart limit exhausted, marking consumer as failed", map[string]interface{}{
+			"max_restarts": c.restarts.maxRestarts,
+			"window":       c.restarts.window.String(),
+		})
+		return false
+	}
+
+	c.metrics.RecordConsumerRestart("restarted")
+	c.logger.WithError(ctx, cause, "Consumer failed, restarting after backoff", map[string]interface{}{
+		"attempt":      c.restarts.Recent(),
+		"max_restarts": c.restarts.maxRestarts,
+		"backoff":      backoff.String(),
+	})
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(backoff):
+		return true
 	}
-	c.setStatus("closed")
-	return nil
+}
+
+// Close leaves the consumer group, then closes the dead letter replay
+// source and producer, which the group may still send to while its session
+// ends. Only the first call closes anything; later ones return its error.
+func (c *KafkaConsumer) Close() error {
+	c.closeOnce.Do(func() {
+		c.setStatus("closing")
+		c.closeErr = c.consumer.Close()
+
+		if c.replayer != nil {
+			if err := c.replayer.Close(); err != nil {
+				c.logger.WithError(context.Background(), err, "Failed to close dead letter replay source", nil)
+			}
+		}
+		if c.dlq != nil {
+			if err := c.dlq.Close(); err != nil {
+				c.logger.WithError(context.Background(), err, "Failed to close dead letter producer", nil)
+			}
+		}
+
+		if c.closeErr != nil {
+			c.setStatus("error")
+			return
+		}
+		c.setStatus("closed")
+	})
+	return c.closeErr
 }
 
 func (c *KafkaConsumer) HealthCheck() error {
@@ -113,13 +394,31 @@ func (c *KafkaConsumer) HealthCheck() error {
 	}
 
 	status := c.getStatus()
-	if status == "error" || status == "closed" {
+	if status == "error" || status == "closed" || status == "failed" || status == "halted" {
 		return fmt.Errorf("consumer is in %s state", status)
 	}
 
 	return nil
 }
 
+// DLQReplayer returns the dead letter replayer, or nil when no dead letter
+// topic is configured
+func (c *KafkaConsumer) DLQReplayer() *DLQReplayer {
+	return c.replayer
+}
+
+// SnapshotState reports whether the initial Debezium snapshot has been
+// consumed, see snapshotTracker
+func (c *KafkaConsumer) SnapshotState() string {
+	return c.snapshot.State()
+}
+
+// Stalled reports whether a message has been processing without any
+// progress for longer than timeout, and when progress was last made
+func (c *KafkaConsumer) Stalled(timeout time.Duration) (bool, time.Time) {
+	return c.heartbeat.stalled(time.Now(), timeout)
+}
+
 func (c *KafkaConsumer) setStatus(status string) {
 	c.statusMu.Lock()
 	defer c.statusMu.Unlock()
@@ -0,0 +1,183 @@
+package consumers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// closeRecorder appends name to closed whenever it is closed
+type closeRecorder struct {
+	name   string
+	closed *[]string
+}
+
+func (r closeRecorder) Close() error {
+	*r.closed = append(*r.closed, r.name)
+	return nil
+}
+
+type fakeGroup struct {
+	sarama.ConsumerGroup
+	closeRecorder
+}
+
+func (g fakeGroup) Close() error { return g.closeRecorder.Close() }
+
+type fakeProducer struct {
+	sarama.SyncProducer
+	closeRecorder
+}
+
+func (p fakeProducer) Close() error { return p.closeRecorder.Close() }
+
+func TestKafkaConsumerCloseOrderAndOnce(t *testing.T) {
+	var closed []string
+	c := &KafkaConsumer{
+		consumer: fakeGroup{closeRecorder: closeRecorder{"group", &closed}},
+		dlq:      &deadLetterQueue{producer: fakeProducer{closeRecorder: closeRecorder{"dlq", &closed}}},
+		logger:   logger.NewLogger("json"),
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close() #%d = %v", i+1, err)
+		}
+	}
+
+	want := []string{"group", "dlq"}
+	if !slices.Equal(closed, want) {
+		t.Fatalf("closed %v, want %v", closed, want)
+	}
+	if status := c.getStatus(); status != "closed" {
+		t.Errorf("status = %q, want closed", status)
+	}
+}
+
+func TestSaramaConfigCompression(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Kafka.Compression = "zstd"
+	saramaConfig, err := newSaramaConfig(cfg)
+	if err != nil {
+		t.Fatalf("newSaramaConfig: %v", err)
+	}
+	if saramaConfig.Producer.Compression != sarama.CompressionZSTD {
+		t.Errorf("compression = %v, want zstd", saramaConfig.Producer.Compression)
+	}
+
+	cfg.Kafka.Compression = "brotli"
+	if _, err := newSaramaConfig(cfg); err == nil {
+		t.Error("newSaramaConfig accepted compression brotli")
+	}
+}
+
+// pauseRecorder is a consumer group that counts PauseAll and ResumeAll
+// calls
+type pauseRecorder struct {
+	sarama.ConsumerGroup
+	pauses  int
+	resumes int
+}
+
+func (g *pauseRecorder) PauseAll()  { g.pauses++ }
+func (g *pauseRecorder) ResumeAll() { g.resumes++ }
+
+func TestHandleExhaustedAppliesPolicy(t *testing.T) {
+	record := &models.SyncRecord{
+		ID:         "category:7",
+		Operation:  models.OperationUpdate,
+		RetryCount: 5,
+		Payload: &models.CategoryOperation{
+			Operation: models.OperationUpdate,
+			Payload:   models.Category{ID: "7", Name: "Books", Description: "Printed books"},
+		},
+	}
+
+	tests := []struct {
+		policy     string
+		wantSent   int
+		wantPauses int
+		wantStatus string
+	}{
+		{config.ExhaustedSkip, 0, 0, "running"},
+		{config.ExhaustedDLQ, 1, 0, "running"},
+		{config.ExhaustedHalt, 0, 1, "halted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			group := &pauseRecorder{}
+			producer := &sendRecorder{}
+			c := &KafkaConsumer{
+				consumer:  group,
+				dlq:       &deadLetterQueue{producer: producer, topic: "digital-discovery-dlq"},
+				logger:    logger.NewLogger("json"),
+				exhausted: tt.policy,
+				status:    "running",
+			}
+
+			c.HandleExhausted(context.Background(), record, errors.New("connection reset"))
+
+			if len(producer.sent) != tt.wantSent {
+				t.Errorf("sent %d messages to the dead letter topic, want %d", len(producer.sent), tt.wantSent)
+			}
+			if group.pauses != tt.wantPauses {
+				t.Errorf("paused %d times, want %d", group.pauses, tt.wantPauses)
+			}
+			if status := c.getStatus(); status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSaramaConfigInitialOffset(t *testing.T) {
+	tests := map[string]int64{
+		config.OffsetResetEarliest: sarama.OffsetOldest,
+		config.OffsetResetLatest:   sarama.OffsetNewest,
+	}
+	for reset, want := range tests {
+		cfg := &config.Config{}
+		cfg.Kafka.Compression = "none"
+		cfg.Kafka.AutoOffsetReset = reset
+		saramaConfig, err := newSaramaConfig(cfg)
+		if err != nil {
+			t.Fatalf("newSaramaConfig: %v", err)
+		}
+		if got := saramaConfig.Consumer.Offsets.Initial; got != want {
+			t.Errorf("auto_offset_reset %s: initial offset = %d, want %d", reset, got, want)
+		}
+	}
+}
+
+func TestSaramaConfigSessionTuning(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Kafka.Compression = "none"
+	cfg.Kafka.SessionTimeout = 45 * time.Second
+	cfg.Kafka.HeartbeatInterval = 5 * time.Second
+	cfg.Kafka.MaxProcessingTime = time.Minute
+
+	saramaConfig, err := newSaramaConfig(cfg)
+	if err != nil {
+		t.Fatalf("newSaramaConfig: %v", err)
+	}
+	if got := saramaConfig.Consumer.Group.Session.Timeout; got != 45*time.Second {
+		t.Errorf("session timeout = %s, want 45s", got)
+	}
+	if got := saramaConfig.Consumer.Group.Heartbeat.Interval; got != 5*time.Second {
+		t.Errorf("heartbeat interval = %s, want 5s", got)
+	}
+	if got := saramaConfig.Consumer.MaxProcessingTime; got != time.Minute {
+		t.Errorf("max processing time = %s, want 1m", got)
+	}
+	if err := saramaConfig.Validate(); err != nil {
+		t.Errorf("sarama rejected the tuned config: %v", err)
+	}
+}
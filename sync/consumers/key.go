@@ -0,0 +1,76 @@
+package consumers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// keyIDSeparator joins the values of a composite key into a document ID
+const keyIDSeparator = ":"
+
+// keyDecoder derives document IDs from message keys. Debezium keys a
+// message by the primary key columns of its row, as a JSON object read in
+// the same format as values.
+type keyDecoder struct {
+	fields []string
+	values Deserializer
+}
+
+func newKeyDecoder(fields []string, values Deserializer) *keyDecoder {
+	if len(fields) == 0 {
+		fields = []string{"id"}
+	}
+	return &keyDecoder{fields: fields, values: values}
+}
+
+// DocumentID returns the ID of the document key identifies: the values of
+// the configured key fields, in order, joined by keyIDSeparator. It returns
+// "" for a missing key.
+func (k *keyDecoder) DocumentID(key []byte) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	raw, err := k.values.JSON(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Keys reduced to a single value, e.g. by the ExtractField transform
+	var scalar interface{}
+	if err := json.Unmarshal(raw, &scalar); err != nil {
+		return "", deserializeError(err)
+	}
+	if _, ok := scalar.(map[string]interface{}); !ok {
+		if scalar == nil {
+			return "", nil
+		}
+		return keyValue(raw), nil
+	}
+
+	_, fields, err := unwrapSchema(raw)
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(k.fields))
+	for _, name := range k.fields {
+		value, ok := fields[name]
+		if !ok || isNullJSON(value) {
+			return "", deserializeError(fmt.Errorf("key has no %q field", name))
+		}
+		parts = append(parts, keyValue(value))
+	}
+	return strings.Join(parts, keyIDSeparator), nil
+}
+
+// keyValue renders a key column: strings unquoted, anything else as its
+// JSON text
+func keyValue(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	return string(value)
+}
@@ -0,0 +1,64 @@
+package consumers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+)
+
+func TestKeyDecoderDocumentID(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		key    string
+		want   string
+	}{
+		{"default id", nil, `{"id":"7"}`, "7"},
+		{"numeric id", nil, `{"id":7}`, "7"},
+		{"with schema", nil, `{"schema":{"type":"struct"},"payload":{"id":7}}`, "7"},
+		{"renamed key field", []string{"category_id"}, `{"category_id":"7"}`, "7"},
+		// Composite keys join in configured order, not key order, so the
+		// same row always gets the same document
+		{"composite", []string{"tenant", "id"}, `{"id":7,"tenant":"acme"}`, "acme:7"},
+		{"composite reordered", []string{"tenant", "id"}, `{"tenant":"acme","id":7}`, "acme:7"},
+		{"scalar key", nil, `"7"`, "7"},
+		{"missing key", nil, ``, ""},
+		{"null key", nil, `null`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newKeyDecoder(tt.fields, jsonDeserializer{}).DocumentID([]byte(tt.key))
+			if err != nil || got != tt.want {
+				t.Errorf("DocumentID(%s) = %q, %v, want %q", tt.key, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyDecoderRejectsIncompleteKeys(t *testing.T) {
+	for _, key := range []string{`{"tenant":"acme"}`, `{"tenant":"acme","id":null}`, `{not json`} {
+		_, err := newKeyDecoder([]string{"tenant", "id"}, jsonDeserializer{}).DocumentID([]byte(key))
+		var syncErr *utils.SyncError
+		if !errors.As(err, &syncErr) || syncErr.Code != utils.ErrCodeKafkaDeserialize {
+			t.Errorf("DocumentID(%s) error = %v, want code %s", key, err, utils.ErrCodeKafkaDeserialize)
+		}
+	}
+}
+
+func TestRowWithoutIDTakesTheKey(t *testing.T) {
+	message := changeMessage(0, "c", "", "Books")
+	message.Key = []byte(`{"id":"7"}`)
+
+	d := newTestDecoder(config.DebeziumFormatEnvelope)
+	event, err := d.Decode(message)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	operation, err := toCategoryOperation(event, d.mapper)
+	if err != nil || operation.Payload.ID != "7" {
+		t.Errorf("operation = %+v, %v, want the ID 7 from the key", operation, err)
+	}
+}
@@ -0,0 +1,85 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
+)
+
+// offsetAuditor emits a structured event when a message offset is marked
+// for commit. sarama commits marked offsets on its auto-commit interval, so
+// an offset marked here may still be lost to a crash before that; the
+// event and metrics say "marked" for that reason. Logging is sampled per
+// partition so a busy topic doesn't flood the logs; the metrics, when
+// enabled, are updated on every mark.
+type offsetAuditor struct {
+	logger        logger.Logger
+	metrics       *metrics.MetricsCollector
+	logEvery      int
+	recordMetrics bool
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newOffsetAuditor(logger logger.Logger, metrics *metrics.MetricsCollector, logEvery int, recordMetrics bool) *offsetAuditor {
+	return &offsetAuditor{
+		logger:        logger,
+		metrics:       metrics,
+		logEvery:      logEvery,
+		recordMetrics: recordMetrics,
+		counts:        make(map[string]int),
+	}
+}
+
+// Marked records that message was marked for commit; the marked offset is
+// the next one to consume, as Kafka stores it.
+func (a *offsetAuditor) Marked(ctx context.Context, message *sarama.ConsumerMessage, highWaterMark int64) {
+	if a == nil {
+		return
+	}
+
+	marked := message.Offset + 1
+	lag := highWaterMark - marked
+	if lag < 0 {
+		lag = 0
+	}
+
+	if a.recordMetrics && a.metrics != nil {
+		a.metrics.RecordOffsetMarked(message.Topic, message.Partition, marked, lag)
+	}
+
+	if !a.sample(message.Topic, message.Partition) {
+		return
+	}
+
+	a.logger.Info(ctx, "Offset marked for commit", map[string]interface{}{
+		"event":           "offset_marked",
+		"topic":           message.Topic,
+		"partition":       message.Partition,
+		"marked_offset":   marked,
+		"high_water_mark": highWaterMark,
+		"lag":             lag,
+	})
+}
+
+// sample reports whether this mark should be logged: every logEvery-th
+// mark per partition, starting with the first. logEvery <= 0 disables it.
+func (a *offsetAuditor) sample(topic string, partition int32) bool {
+	if a.logEvery <= 0 {
+		return false
+	}
+
+	key := fmt.Sprintf("%s/%d", topic, partition)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := a.counts[key]
+	a.counts[key] = (n + 1) % a.logEvery
+	return n == 0
+}
@@ -0,0 +1,51 @@
+package consumers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// recordingLogger keeps the fields of every Info entry
+type recordingLogger struct {
+	logger.Logger
+
+	mu      sync.Mutex
+	entries []map[string]interface{}
+}
+
+func (l *recordingLogger) Info(ctx context.Context, msg string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fields)
+}
+
+func TestOffsetEventsFollowMarkedMessages(t *testing.T) {
+	repo := mocks.NewRepository()
+	h := newTestHandler(repo, 1)
+	audit := &recordingLogger{Logger: logger.NewLogger("json")}
+	h.offsets = newOffsetAuditor(audit, nil, 1, false)
+	session := &fakeSession{ctx: context.Background()}
+
+	claim := newFakeClaim(changeMessage(0, "c", "7", "Books"), changeMessage(1, "u", "7", "Comics"))
+	if err := h.ConsumeClaim(session, claim); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+
+	// Every event names an offset the session marked, not one sarama has
+	// committed yet
+	if len(audit.entries) != len(session.marked) {
+		t.Fatalf("%d offset events for %d marked messages", len(audit.entries), len(session.marked))
+	}
+	for i, fields := range audit.entries {
+		if fields["event"] != "offset_marked" {
+			t.Errorf("event %d = %v, want offset_marked", i, fields["event"])
+		}
+		if want := session.marked[i] + 1; fields["marked_offset"] != want {
+			t.Errorf("event %d marked_offset = %v, want %d", i, fields["marked_offset"], want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package consumers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	// ErrNotPaused is returned when offsets are reset on a consumer that
+	// is still processing messages
+	ErrNotPaused = errors.New("consumer must be paused before its offsets are reset")
+	// ErrNoSession is returned when offsets are reset while the consumer
+	// holds no partitions, e.g. mid-rebalance
+	ErrNoSession = errors.New("consumer has no active session")
+	// ErrOutsideRetention is returned for a timestamp older than a topic
+	// retains messages, or in the future
+	ErrOutsideRetention = errors.New("timestamp is outside the topics' retention")
+)
+
+// PartitionOffset is where one partition is reset to
+type PartitionOffset struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// offsetLookup finds offsets by time and how long topics keep messages.
+// kafkaOffsetLookup is backed by the cluster.
+type offsetLookup interface {
+	// GetOffset is sarama.Client's: the first offset whose timestamp is at
+	// or after time, or -1 if there is none
+	GetOffset(topic string, partition int32, time int64) (int64, error)
+	// Retention returns the topic's retention.ms, or 0 if it keeps
+	// messages forever
+	Retention(topic string) (time.Duration, error)
+}
+
+// offsetsForTime returns, for each claimed partition, the first offset at
+// or after at. A partition with nothing that recent is reset to its high
+// water mark, so only messages produced from now on are read.
+func offsetsForTime(lookup offsetLookup, claims map[string][]int32, at, now time.Time) ([]PartitionOffset, error) {
+	if at.After(now) {
+		return nil, fmt.Errorf("%w: %s is in the future", ErrOutsideRetention, at.Format(time.RFC3339))
+	}
+
+	var offsets []PartitionOffset
+	for topic, partitions := range claims {
+		retention, err := lookup.Retention(topic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read retention of topic %s: %w", topic, err)
+		}
+		if retention > 0 && at.Before(now.Add(-retention)) {
+			return nil, fmt.Errorf("%w: topic %s keeps messages for %s", ErrOutsideRetention, topic, retention)
+		}
+
+		for _, partition := range partitions {
+			offset, err := lookup.GetOffset(topic, partition, at.UnixMilli())
+			if err == nil && offset < 0 {
+				offset, err = lookup.GetOffset(topic, partition, sarama.OffsetNewest)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up offset of %s/%d: %w", topic, partition, err)
+			}
+			offsets = append(offsets, PartitionOffset{Topic: topic, Partition: partition, Offset: offset})
+		}
+	}
+	return offsets, nil
+}
+
+// kafkaOffsetLookup looks offsets and retention up on the cluster
+type kafkaOffsetLookup struct {
+	sarama.Client
+	admin sarama.ClusterAdmin
+}
+
+func newKafkaOffsetLookup(brokers []string, cfg *sarama.Config) (*kafkaOffsetLookup, error) {
+	client, err := sarama.NewClient(brokers, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offset lookup client: %w", err)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create offset lookup admin: %w", err)
+	}
+	return &kafkaOffsetLookup{Client: client, admin: admin}, nil
+}
+
+func (l *kafkaOffsetLookup) Retention(topic string) (time.Duration, error) {
+	entries, err := l.admin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.TopicResource,
+		Name:        topic,
+		ConfigNames: []string{"retention.ms"},
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if entry.Name != "retention.ms" {
+			continue
+		}
+		ms, err := strconv.ParseInt(entry.Value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid retention.ms %q", entry.Value)
+		}
+		if ms < 0 {
+			return 0, nil
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	return 0, nil
+}
+
+// Close closes the admin, which closes the client it was created from
+func (l *kafkaOffsetLookup) Close() error {
+	return l.admin.Close()
+}
+
+// activeSession tracks the consumer group session in progress, so offsets
+// can be reset through it and the session ended to apply them
+type activeSession struct {
+	mu      sync.Mutex
+	session sarama.ConsumerGroupSession
+	cancel  context.CancelFunc
+}
+
+// begin derives the context for the next session from ctx
+func (s *activeSession) begin(ctx context.Context) context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, s.cancel = context.WithCancel(ctx)
+	return ctx
+}
+
+func (s *activeSession) setup(session sarama.ConsumerGroupSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session = session
+}
+
+func (s *activeSession) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session = nil
+}
+
+// reset moves the session's committed offsets to offsets and ends the
+// session. The claims keep reading from where they were, so the consumer
+// rejoins the group and starts again from the committed offsets.
+func (s *activeSession) reset(offsets []PartitionOffset) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range offsets {
+		s.session.ResetOffset(o.Topic, o.Partition, o.Offset, "")
+	}
+	s.session.Commit()
+	s.cancel()
+}
+
+// claims returns the partitions the session holds, or nil without one
+func (s *activeSession) claims() map[string][]int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session == nil {
+		return nil
+	}
+	return s.session.Claims()
+}
+
+// ResetOffsets moves the group's committed offsets for the partitions this
+// consumer holds back (or forward) to the first message at or after at,
+// e.g. to replay the last hour. The consumer must be paused; it stays
+// paused, and resuming reads from the new offsets. A dry run only returns
+// the offsets. Partitions held by other members of the group are left as
+// they are.
+func (c *KafkaConsumer) ResetOffsets(ctx context.Context, at time.Time, dryRun bool) ([]PartitionOffset, error) {
+	if !c.Paused() {
+		return nil, ErrNotPaused
+	}
+	claims := c.session.claims()
+	if len(claims) == 0 {
+		return nil, ErrNoSession
+	}
+
+	lookup, err := newKafkaOffsetLookup(c.brokers, c.saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer lookup.Close()
+
+	offsets, err := offsetsForTime(lookup, claims, at, time.Now())
+	if err != nil || dryRun {
+		return offsets, err
+	}
+
+	c.session.reset(offsets)
+	c.logger.Info(ctx, "Consumer offsets reset", map[string]interface{}{
+		"timestamp": at.Format(time.RFC3339),
+		"offsets":   offsets,
+	})
+	return offsets, nil
+}
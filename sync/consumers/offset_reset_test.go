@@ -0,0 +1,157 @@
+package consumers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// fakeOffsetLookup serves offsets from a fixed index of partition and time
+type fakeOffsetLookup struct {
+	retention time.Duration
+	// offsets maps "partition@time" to the offset GetOffset returns
+	offsets map[string]int64
+}
+
+func (l *fakeOffsetLookup) GetOffset(topic string, partition int32, at int64) (int64, error) {
+	offset, ok := l.offsets[fmt.Sprintf("%d@%d", partition, at)]
+	if !ok {
+		return 0, fmt.Errorf("unexpected lookup of %s/%d at %d", topic, partition, at)
+	}
+	return offset, nil
+}
+
+func (l *fakeOffsetLookup) Retention(topic string) (time.Duration, error) {
+	return l.retention, nil
+}
+
+func TestOffsetsForTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	hourAgo := now.Add(-time.Hour)
+	lookup := func(retention time.Duration) *fakeOffsetLookup {
+		return &fakeOffsetLookup{retention: retention, offsets: map[string]int64{
+			fmt.Sprintf("0@%d", hourAgo.UnixMilli()): 120,
+			// Nothing on partition 1 is that recent
+			fmt.Sprintf("1@%d", hourAgo.UnixMilli()): -1,
+			fmt.Sprintf("1@%d", sarama.OffsetNewest): 75,
+		}}
+	}
+	claims := map[string][]int32{testTopic: {0, 1}}
+
+	tests := []struct {
+		name      string
+		lookup    *fakeOffsetLookup
+		at        time.Time
+		want      []PartitionOffset
+		wantError error
+	}{
+		{
+			name:   "within retention",
+			lookup: lookup(24 * time.Hour),
+			at:     hourAgo,
+			want:   []PartitionOffset{{testTopic, 0, 120}, {testTopic, 1, 75}},
+		},
+		{
+			name:   "kept forever",
+			lookup: lookup(0),
+			at:     hourAgo,
+			want:   []PartitionOffset{{testTopic, 0, 120}, {testTopic, 1, 75}},
+		},
+		{
+			name:      "older than retention",
+			lookup:    lookup(30 * time.Minute),
+			at:        hourAgo,
+			wantError: ErrOutsideRetention,
+		},
+		{
+			name:      "in the future",
+			lookup:    lookup(24 * time.Hour),
+			at:        now.Add(time.Minute),
+			wantError: ErrOutsideRetention,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offsets, err := offsetsForTime(tt.lookup, claims, tt.at, now)
+			if tt.wantError != nil {
+				if !errors.Is(err, tt.wantError) {
+					t.Fatalf("offsetsForTime error = %v, want %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("offsetsForTime: %v", err)
+			}
+			if !slices.Equal(offsets, tt.want) {
+				t.Errorf("offsets = %v, want %v", offsets, tt.want)
+			}
+		})
+	}
+}
+
+// resetSession is a session holding claims that records offset resets
+type resetSession struct {
+	sarama.ConsumerGroupSession
+	claims  map[string][]int32
+	resets  []PartitionOffset
+	commits int
+}
+
+func (s *resetSession) Claims() map[string][]int32 { return s.claims }
+
+func (s *resetSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	s.resets = append(s.resets, PartitionOffset{topic, partition, offset})
+}
+
+func (s *resetSession) Commit() { s.commits++ }
+
+func TestActiveSessionResetCommitsAndEndsSession(t *testing.T) {
+	active := &activeSession{}
+	ctx := active.begin(context.Background())
+	if active.claims() != nil {
+		t.Fatal("claims before setup, want none")
+	}
+
+	session := &resetSession{claims: map[string][]int32{testTopic: {0}}}
+	active.setup(session)
+	if claims := active.claims(); len(claims[testTopic]) != 1 {
+		t.Fatalf("claims = %v, want the session's", claims)
+	}
+
+	offsets := []PartitionOffset{{testTopic, 0, 120}}
+	active.reset(offsets)
+
+	if !slices.Equal(session.resets, offsets) || session.commits != 1 {
+		t.Errorf("reset %v with %d commits, want %v committed once", session.resets, session.commits, offsets)
+	}
+	select {
+	case <-ctx.Done():
+	default:
+		t.Error("reset left the session running")
+	}
+
+	active.cleanup()
+	if active.claims() != nil {
+		t.Error("claims after cleanup, want none")
+	}
+}
+
+func TestResetOffsetsNeedsPausedConsumerWithSession(t *testing.T) {
+	c := &KafkaConsumer{pause: &pauseGate{}, session: &activeSession{}, logger: logger.NewLogger("json")}
+	at := time.Now().Add(-time.Hour)
+
+	if _, err := c.ResetOffsets(context.Background(), at, true); err != ErrNotPaused {
+		t.Errorf("ResetOffsets while running = %v, want ErrNotPaused", err)
+	}
+	c.pause.pause()
+	if _, err := c.ResetOffsets(context.Background(), at, true); err != ErrNoSession {
+		t.Errorf("ResetOffsets without a session = %v, want ErrNoSession", err)
+	}
+}
@@ -0,0 +1,65 @@
+package consumers
+
+import (
+	"context"
+	"sync"
+)
+
+// pauseGate holds message processing while the consumer is paused for
+// maintenance. Pausing the consumer group stops fetching, but messages
+// already fetched would still be processed; the gate stops those too,
+// without leaving the group.
+type pauseGate struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+// pause closes the gate. It reports false if it was already closed.
+func (g *pauseGate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return false
+	}
+	g.paused = true
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// resume opens the gate, releasing anything waiting on it. It reports false
+// if it was already open.
+func (g *pauseGate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+	g.paused = false
+	close(g.resumed)
+	return true
+}
+
+func (g *pauseGate) isPaused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// wait blocks while the gate is closed. It reports false if ctx was done
+// first, in which case the message must not be processed.
+func (g *pauseGate) wait(ctx context.Context) bool {
+	g.mu.Lock()
+	paused, resumed := g.paused, g.resumed
+	g.mu.Unlock()
+	if !paused {
+		return true
+	}
+
+	select {
+	case <-resumed:
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package consumers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+func TestPauseGate(t *testing.T) {
+	gate := &pauseGate{}
+	if !gate.wait(context.Background()) {
+		t.Fatal("an open gate held a message")
+	}
+	if !gate.pause() || gate.pause() {
+		t.Fatal("pause should report true, then false once already paused")
+	}
+
+	released := make(chan bool)
+	go func() { released <- gate.wait(context.Background()) }()
+	select {
+	case <-released:
+		t.Fatal("a closed gate let a message through")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !gate.resume() || gate.resume() {
+		t.Fatal("resume should report true, then false once already resumed")
+	}
+	if !<-released {
+		t.Error("resume didn't release the waiting message")
+	}
+
+	gate.pause()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if gate.wait(ctx) {
+		t.Error("wait reported true after its context ended")
+	}
+}
+
+func TestKafkaConsumerPauseAndResume(t *testing.T) {
+	ctx := context.Background()
+	group := &pauseRecorder{}
+	c := &KafkaConsumer{consumer: group, pause: &pauseGate{}, logger: logger.NewLogger("json"), status: "running"}
+
+	if !c.Pause(ctx) || c.Pause(ctx) {
+		t.Fatal("Pause should report true, then false once already paused")
+	}
+	if !c.Paused() || group.pauses != 1 {
+		t.Fatalf("Paused() = %v with %d PauseAll calls, want paused once", c.Paused(), group.pauses)
+	}
+
+	changed, err := c.Resume(ctx)
+	if err != nil || !changed {
+		t.Fatalf("Resume() = %v, %v; want true", changed, err)
+	}
+	if changed, _ := c.Resume(ctx); changed {
+		t.Error("Resume of a running consumer reported a change")
+	}
+	if c.Paused() || group.resumes != 1 {
+		t.Errorf("Paused() = %v with %d ResumeAll calls, want resumed once", c.Paused(), group.resumes)
+	}
+
+	c.Pause(ctx)
+	c.setStatus("halted")
+	if _, err := c.Resume(ctx); err != ErrConsumerHalted {
+		t.Errorf("Resume of a halted consumer = %v, want ErrConsumerHalted", err)
+	}
+	if !c.Paused() {
+		t.Error("a halted consumer was resumed")
+	}
+}
+
+func TestPausedHandlerHoldsMessages(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		repo := mocks.NewRepository()
+		h := newTestHandler(repo, workers)
+		h.pause.pause()
+
+		// The session ends during the pause: nothing is written or marked
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		session := &fakeSession{ctx: ctx}
+		if err := h.ConsumeClaim(session, newFakeClaim(changeMessage(0, "c", "7", "Books"))); err != nil {
+			t.Fatalf("ConsumeClaim: %v", err)
+		}
+		if len(repo.Calls()) != 0 || len(session.marked) != 0 {
+			t.Errorf("%d workers: paused handler made calls %v and marked %v", workers, repo.Calls(), session.marked)
+		}
+
+		// Resuming releases the held message
+		session = &fakeSession{ctx: context.Background()}
+		done := make(chan error)
+		go func() {
+			done <- h.ConsumeClaim(session, newFakeClaim(changeMessage(1, "c", "8", "Comics")))
+		}()
+		h.pause.resume()
+		if err := <-done; err != nil {
+			t.Fatalf("ConsumeClaim: %v", err)
+		}
+		if calls := repo.CallsTo("Index"); len(calls) != 1 || calls[0].ID != "8" {
+			t.Errorf("%d workers: Index calls = %+v, want category 8 once resumed", workers, calls)
+		}
+	}
+}
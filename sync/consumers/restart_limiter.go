@@ -0,0 +1,71 @@
+package consumers
+
+import (
+	"sync"
+	"time"
+)
+
+// restartLimiter bounds how often the consumer may restart itself after a
+// Consume error, so a persistent failure backs off inside the process
+// instead of crash-looping under the orchestrator.
+type restartLimiter struct {
+	maxRestarts int
+	window      time.Duration
+	baseBackoff time.Duration
+
+	mu       sync.Mutex
+	restarts []time.Time
+}
+
+func newRestartLimiter(maxRestarts int, window, baseBackoff time.Duration) *restartLimiter {
+	if maxRestarts < 0 {
+		maxRestarts = 0
+	}
+	if window <= 0 {
+		window = 10 * time.Minute
+	}
+	if baseBackoff <= 0 {
+		baseBackoff = 5 * time.Second
+	}
+	return &restartLimiter{
+		maxRestarts: maxRestarts,
+		window:      window,
+		baseBackoff: baseBackoff,
+	}
+}
+
+// Allow records a restart attempt at now and reports whether it fits in the
+// budget, along with how long to wait before restarting.
+func (l *restartLimiter) Allow(now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop restarts that have fallen out of the window
+	cutoff := now.Add(-l.window)
+	kept := l.restarts[:0]
+	for _, t := range l.restarts {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	l.restarts = kept
+
+	if len(l.restarts) >= l.maxRestarts {
+		return false, 0
+	}
+	l.restarts = append(l.restarts, now)
+
+	// Exponential backoff based on recent restarts, capped at the window
+	backoff := l.baseBackoff << (len(l.restarts) - 1)
+	if backoff <= 0 || backoff > l.window {
+		backoff = l.window
+	}
+	return true, backoff
+}
+
+// Recent returns the number of restarts within the current window
+func (l *restartLimiter) Recent() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.restarts)
+}
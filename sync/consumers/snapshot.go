@@ -0,0 +1,108 @@
+package consumers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
+)
+
+// Snapshot states reported by the readiness check
+const (
+	SnapshotStateUnknown      = "unknown"
+	SnapshotStateSnapshotting = "snapshotting"
+	SnapshotStateCaughtUp     = "caught_up"
+)
+
+// snapshotMarker is Debezium's source.snapshot field. Current versions send
+// "true", "last", "false" or "incremental"; older ones send a boolean.
+type snapshotMarker string
+
+func (m *snapshotMarker) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		if b {
+			*m = "true"
+		} else {
+			*m = "false"
+		}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*m = snapshotMarker(s)
+	return nil
+}
+
+// snapshotTracker follows Debezium's move from the initial snapshot to
+// streaming. The index is caught up once the last snapshot record arrives,
+// or once a streaming record arrives, which covers restarting after the
+// snapshot finished. A connector restarted mid-snapshot starts it over, so
+// snapshot records seen again simply keep the state at snapshotting.
+type snapshotTracker struct {
+	logger  logger.Logger
+	metrics *metrics.MetricsCollector
+
+	mu          sync.RWMutex
+	state       string
+	completedAt time.Time
+}
+
+func newSnapshotTracker(logger logger.Logger, metrics *metrics.MetricsCollector) *snapshotTracker {
+	return &snapshotTracker{
+		logger:  logger,
+		metrics: metrics,
+		state:   SnapshotStateUnknown,
+	}
+}
+
+// Observe records the snapshot marker of a consumed event
+func (t *snapshotTracker) Observe(ctx context.Context, marker snapshotMarker, topic string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	if t.state == SnapshotStateCaughtUp {
+		t.mu.Unlock()
+		return
+	}
+
+	switch marker {
+	case "true":
+		t.state = SnapshotStateSnapshotting
+		t.mu.Unlock()
+		return
+	case "incremental":
+		// Incremental snapshots run alongside streaming and don't gate
+		// the initial load
+		t.mu.Unlock()
+		return
+	}
+
+	// "last", or a streaming record
+	previous := t.state
+	t.state = SnapshotStateCaughtUp
+	t.completedAt = time.Now()
+	t.mu.Unlock()
+
+	t.metrics.RecordSnapshotComplete()
+	t.logger.Info(ctx, "Snapshot complete, index caught up", map[string]interface{}{
+		"event":          "snapshot_complete",
+		"topic":          topic,
+		"marker":         string(marker),
+		"previous_state": previous,
+	})
+}
+
+// State returns the current snapshot state
+func (t *snapshotTracker) State() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.state
+}
@@ -0,0 +1,74 @@
+package consumers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
+)
+
+func TestSnapshotMarkerAcceptsBooleans(t *testing.T) {
+	tests := map[string]snapshotMarker{
+		`true`:          "true",
+		`false`:         "false",
+		`"last"`:        "last",
+		`"incremental"`: "incremental",
+	}
+	for data, want := range tests {
+		var got snapshotMarker
+		if err := json.Unmarshal([]byte(data), &got); err != nil || got != want {
+			t.Errorf("unmarshal %s = %q, %v, want %q", data, got, err, want)
+		}
+	}
+}
+
+func TestSnapshotTracker(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		markers []snapshotMarker
+		want    string
+	}{
+		{"nothing consumed", nil, SnapshotStateUnknown},
+		{"mid snapshot", []snapshotMarker{"true", "true"}, SnapshotStateSnapshotting},
+		{"last snapshot record", []snapshotMarker{"true", "last"}, SnapshotStateCaughtUp},
+		// Restarting after the snapshot finished goes straight to streaming
+		{"restarted while streaming", []snapshotMarker{"false"}, SnapshotStateCaughtUp},
+		// A connector restarted mid-snapshot sends the snapshot again
+		{"restarted mid snapshot", []snapshotMarker{"true", "true", "true", "last"}, SnapshotStateCaughtUp},
+		{"incremental snapshot", []snapshotMarker{"incremental"}, SnapshotStateUnknown},
+		{"caught up stays caught up", []snapshotMarker{"last", "true"}, SnapshotStateCaughtUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &recordingLogger{Logger: logger.NewLogger("json")}
+			tracker := newSnapshotTracker(audit, metrics.NewMetricsCollector())
+			for _, marker := range tt.markers {
+				tracker.Observe(ctx, marker, testTopic)
+			}
+
+			if got := tracker.State(); got != tt.want {
+				t.Errorf("State() = %q, want %q", got, tt.want)
+			}
+
+			// The signal is sent once, however many records follow
+			completions := 0
+			for _, fields := range audit.entries {
+				if fields["event"] == "snapshot_complete" {
+					completions++
+				}
+			}
+			want := 0
+			if tt.want == SnapshotStateCaughtUp {
+				want = 1
+			}
+			if completions != want {
+				t.Errorf("%d snapshot_complete events, want %d", completions, want)
+			}
+		})
+	}
+}
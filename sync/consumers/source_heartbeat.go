@@ -0,0 +1,80 @@
+package consumers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
+)
+
+// sourceHeartbeats reads Debezium's heartbeat topic. The connector emits a
+// heartbeat every heartbeat.interval.ms even when no rows change, so the
+// latest one shows how fresh the source is: if it stops advancing, Postgres
+// or the connector has stopped producing. Heartbeats carry no row and are
+// acknowledged without a write.
+type sourceHeartbeats struct {
+	topic   string
+	values  Deserializer
+	logger  logger.Logger
+	metrics *metrics.MetricsCollector
+}
+
+func newSourceHeartbeats(topic string, values Deserializer, logger logger.Logger, metrics *metrics.MetricsCollector) *sourceHeartbeats {
+	return &sourceHeartbeats{
+		topic:   topic,
+		values:  values,
+		logger:  logger,
+		metrics: metrics,
+	}
+}
+
+// Matches reports whether topic is the heartbeat topic
+func (s *sourceHeartbeats) Matches(topic string) bool {
+	return s.topic != "" && topic == s.topic
+}
+
+// Observe records the heartbeat's timestamp as the source's freshness. A
+// heartbeat whose value can't be read falls back to the message timestamp,
+// since a heartbeat that arrived still shows the connector is alive.
+func (s *sourceHeartbeats) Observe(ctx context.Context, message *sarama.ConsumerMessage) {
+	at := message.Timestamp
+	if ts, ok := s.timestamp(message.Value); ok {
+		at = time.UnixMilli(ts)
+	} else {
+		s.logger.Info(ctx, "Unreadable Debezium heartbeat, using message timestamp", map[string]interface{}{
+			"topic":     message.Topic,
+			"partition": message.Partition,
+			"offset":    message.Offset,
+		})
+	}
+	s.metrics.RecordSourceHeartbeat(at)
+}
+
+// timestamp reads ts_ms from a heartbeat value, with or without the schema
+// envelope
+func (s *sourceHeartbeats) timestamp(value []byte) (int64, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+	data, err := s.values.JSON(value)
+	if err != nil {
+		return 0, false
+	}
+
+	var heartbeat struct {
+		TsMs    int64 `json:"ts_ms"`
+		Payload struct {
+			TsMs int64 `json:"ts_ms"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &heartbeat); err != nil {
+		return 0, false
+	}
+	if heartbeat.Payload.TsMs != 0 {
+		return heartbeat.Payload.TsMs, true
+	}
+	return heartbeat.TsMs, heartbeat.TsMs != 0
+}
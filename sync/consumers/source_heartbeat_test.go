@@ -0,0 +1,94 @@
+package consumers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
+)
+
+const heartbeatTopic = "__debezium-heartbeat.dbserver1"
+
+// sourceHeartbeatSeconds returns the source heartbeat gauge in registry
+func sourceHeartbeatSeconds(t *testing.T, registry *prometheus.Registry) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == "sync_source_heartbeat_timestamp_seconds" {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatal("sync_source_heartbeat_timestamp_seconds not registered")
+	return 0
+}
+
+func TestSourceHeartbeatsRecordFreshness(t *testing.T) {
+	received := time.UnixMilli(1700000009000)
+
+	tests := []struct {
+		name  string
+		value string
+		want  float64
+	}{
+		{"envelope", `{"schema":{},"payload":{"ts_ms":1700000001000}}`, 1700000001},
+		{"without schema", `{"ts_ms":1700000002000}`, 1700000002},
+		{"unreadable falls back to message time", `not json`, 1700000009},
+		{"empty falls back to message time", ``, 1700000009},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			heartbeats := newSourceHeartbeats(heartbeatTopic, jsonDeserializer{}, logger.NewLogger("json"),
+				metrics.NewMetricsCollectorWithRegistry(registry))
+
+			heartbeats.Observe(context.Background(), &sarama.ConsumerMessage{
+				Topic:     heartbeatTopic,
+				Value:     []byte(tt.value),
+				Timestamp: received,
+			})
+
+			if got := sourceHeartbeatSeconds(t, registry); got != tt.want {
+				t.Errorf("source heartbeat is %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceHeartbeatsMatchOnlyTheirTopic(t *testing.T) {
+	heartbeats := newSourceHeartbeats(heartbeatTopic, jsonDeserializer{}, logger.NewLogger("json"), nil)
+	if !heartbeats.Matches(heartbeatTopic) {
+		t.Error("the heartbeat topic didn't match")
+	}
+	if heartbeats.Matches(testTopic) {
+		t.Error("a change topic matched the heartbeat topic")
+	}
+	if newSourceHeartbeats("", jsonDeserializer{}, logger.NewLogger("json"), nil).Matches("") {
+		t.Error("an unconfigured heartbeat topic matched")
+	}
+}
+
+func TestHandlerAcknowledgesHeartbeatsWithoutWriting(t *testing.T) {
+	repo := mocks.NewRepository()
+	h := newTestHandler(repo, 1)
+	h.heartbeats = newSourceHeartbeats(heartbeatTopic, jsonDeserializer{}, logger.NewLogger("json"), h.syncService.Metrics())
+
+	err := h.processMessage(context.Background(), &sarama.ConsumerMessage{
+		Topic: heartbeatTopic,
+		Value: []byte(`{"payload":{"ts_ms":1700000001000}}`),
+	})
+	if err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if calls := repo.Calls(); len(calls) != 0 {
+		t.Errorf("a heartbeat made calls %v", calls)
+	}
+}
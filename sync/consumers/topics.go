@@ -0,0 +1,49 @@
+package consumers
+
+import (
+	"fmt"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+)
+
+// topicRouter maps the topics the consumer subscribes to onto the entities
+// their operations are written to. Rows are still decoded as categories, so
+// a mapped table must share the categories shape.
+type topicRouter struct {
+	topics   []string
+	entities map[string]string
+}
+
+func newTopicRouter(prefix string, mappings []config.TopicMapping) *topicRouter {
+	r := &topicRouter{
+		topics:   make([]string, 0, len(mappings)),
+		entities: make(map[string]string, len(mappings)),
+	}
+	for _, m := range mappings {
+		topic := fmt.Sprintf("%s.%s", prefix, m.Suffix)
+		r.topics = append(r.topics, topic)
+		r.entities[topic] = m.Entity
+	}
+	return r
+}
+
+// Topics returns the topics to subscribe to
+func (r *topicRouter) Topics() []string {
+	return r.topics
+}
+
+// Entity returns the entity messages from topic are written to
+func (r *topicRouter) Entity(topic string) (string, error) {
+	entity, ok := r.entities[topic]
+	if !ok {
+		return "", utils.NewSyncError(
+			utils.ErrCodeSchemaInvalid,
+			fmt.Sprintf("No entity is mapped to topic %q", topic),
+			nil,
+			"ROUTE",
+			"message",
+		)
+	}
+	return entity, nil
+}
@@ -0,0 +1,137 @@
+package consumers
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+)
+
+// consumeSharded processes a claim with h.workers goroutines. Each message
+// goes to the worker picked by hashing its key, and Debezium keys messages
+// by primary key, so changes to the same category are still applied in
+// order. Offsets are marked in partition order through orderedCommitter.
+func (h *ConsumerHandler) consumeSharded(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	commits := newOrderedCommitter(func(message *sarama.ConsumerMessage) {
+		session.MarkMessage(message, "")
+		h.offsets.Marked(session.Context(), message, claim.HighWaterMarkOffset())
+	})
+
+	// Once the partition is halted, nothing past the halting message is
+	// marked, so consumption resumes from it
+	var halted atomic.Bool
+
+	shards := make([]chan *sarama.ConsumerMessage, h.workers)
+	var wg sync.WaitGroup
+	for i := range shards {
+		shards[i] = make(chan *sarama.ConsumerMessage, 1)
+		wg.Add(1)
+		go func(messages <-chan *sarama.ConsumerMessage) {
+			defer wg.Done()
+			for message := range messages {
+				if halted.Load() || !h.pause.wait(session.Context()) {
+					continue
+				}
+				_, halt := h.handleMessage(session, message)
+				if halt {
+					halted.Store(true)
+					continue
+				}
+				// As in the serial path, a failed message that isn't held
+				// back by the malformed policy is committed past
+				commits.Done(message)
+			}
+		}(shards[i])
+	}
+
+	defer func() {
+		for _, shard := range shards {
+			close(shard)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if halted.Load() {
+				continue
+			}
+
+			commits.Track(message.Offset)
+			select {
+			case shards[shardFor(message.Key, len(shards))] <- message:
+			case <-session.Context().Done():
+				return nil
+			}
+
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// shardFor maps a message key to a worker. Messages without a key all go to
+// the first worker, which keeps them in order.
+func shardFor(key []byte, shards int) int {
+	if len(key) == 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(shards))
+}
+
+// orderedCommitter marks offsets only once every earlier offset of the
+// partition has finished, since marking an offset commits everything
+// before it.
+type orderedCommitter struct {
+	mark func(*sarama.ConsumerMessage)
+
+	mu      sync.Mutex
+	pending []int64
+	done    map[int64]*sarama.ConsumerMessage
+}
+
+func newOrderedCommitter(mark func(*sarama.ConsumerMessage)) *orderedCommitter {
+	return &orderedCommitter{
+		mark: mark,
+		done: make(map[int64]*sarama.ConsumerMessage),
+	}
+}
+
+// Track registers an offset as dispatched. Offsets must be tracked in the
+// order they were consumed.
+func (c *orderedCommitter) Track(offset int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, offset)
+}
+
+// Done records that message finished and marks the highest offset whose
+// predecessors have all finished.
+func (c *orderedCommitter) Done(message *sarama.ConsumerMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done[message.Offset] = message
+
+	var last *sarama.ConsumerMessage
+	for len(c.pending) > 0 {
+		next, ok := c.done[c.pending[0]]
+		if !ok {
+			break
+		}
+		delete(c.done, c.pending[0])
+		c.pending = c.pending[1:]
+		last = next
+	}
+
+	if last != nil {
+		c.mark(last)
+	}
+}
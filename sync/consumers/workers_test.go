@@ -0,0 +1,46 @@
+package consumers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+)
+
+func TestConsumeShardedKeepsPerKeyOrder(t *testing.T) {
+	const ids, versions = 8, 20
+
+	// Changes to every category interleaved, in version order per category
+	var messages []*sarama.ConsumerMessage
+	for v := 0; v < versions; v++ {
+		for id := 0; id < ids; id++ {
+			offset := int64(len(messages))
+			messages = append(messages, changeMessage(offset, "u", fmt.Sprint(id), fmt.Sprintf("v%02d", v)))
+		}
+	}
+
+	repo := mocks.NewRepository()
+	h := newTestHandler(repo, 4)
+	session := &fakeSession{ctx: context.Background()}
+	if err := h.ConsumeClaim(session, newFakeClaim(messages...)); err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+
+	applied := make(map[string][]string)
+	for _, call := range repo.CallsTo("Update") {
+		applied[call.ID] = append(applied[call.ID], docName(t, call))
+	}
+	for id := 0; id < ids; id++ {
+		names := applied[fmt.Sprint(id)]
+		if len(names) != versions || !slices.IsSorted(names) {
+			t.Errorf("category %d updated in order %v, want v00 to v%02d in order", id, names, versions-1)
+		}
+	}
+
+	if !slices.IsSorted(session.marked) || len(session.marked) == 0 || session.marked[len(session.marked)-1] != int64(len(messages)-1) {
+		t.Errorf("marked offsets %v, want increasing up to %d", session.marked, len(messages)-1)
+	}
+}
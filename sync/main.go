@@ -3,22 +3,25 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rendyspratama/digital-discovery/buildinfo"
 	"github.com/rendyspratama/digital-discovery/sync/config"
 	"github.com/rendyspratama/digital-discovery/sync/consumers"
 	"github.com/rendyspratama/digital-discovery/sync/middleware"
 	"github.com/rendyspratama/digital-discovery/sync/models"
 	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch"
 	"github.com/rendyspratama/digital-discovery/sync/services"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
 	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
 	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
 )
@@ -30,21 +33,55 @@ type App struct {
 	syncService  *services.SyncService
 	retryService *services.RetryService
 	consumer     *consumers.KafkaConsumer
+	monitor      *services.ConnectorMonitor
 	httpServer   *http.Server
 	metrics      *metrics.MetricsCollector
 }
 
-// Add health check handler
+// handleHealthCheck is the liveness check: it fails when the consumer has
+// stopped making progress, so the orchestrator restarts the process
 func (a *App) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	status := map[string]interface{}{
 		"status":    "UP",
 		"timestamp": time.Now().Format(time.RFC3339),
 	}
+
+	code := http.StatusOK
+	if timeout := a.cfg.Monitoring.LivenessTimeout; timeout > 0 && a.consumer != nil {
+		if stalled, last := a.consumer.Stalled(timeout); stalled {
+			status["status"] = "DOWN"
+			status["last_progress"] = last.Format(time.RFC3339)
+			code = http.StatusServiceUnavailable
+			a.logger.Info(r.Context(), "Consumer has stopped making progress", map[string]interface{}{
+				"last_progress": last,
+				"timeout":       timeout.String(),
+			})
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(status)
 }
 
-// Add readiness check handler
+// handleVersion reports which build is running
+func (a *App) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, buildinfo.Get(a.cfg.App.Version))
+}
+
+// checkElasticsearch runs the readiness check monitoring.readiness_check
+// selects
+func (a *App) checkElasticsearch(ctx context.Context) error {
+	if a.cfg.Monitoring.ReadinessCheck == config.ReadinessCheckPing {
+		return a.esClient.Ping(ctx)
+	}
+	return a.esClient.CheckHealth(ctx)
+}
+
 func (a *App) handleReadinessCheck(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	status := map[string]interface{}{
@@ -54,8 +91,8 @@ func (a *App) handleReadinessCheck(w http.ResponseWriter, r *http.Request) {
 		"kafka":         "UP",
 	}
 
-	// Check Elasticsearch using repository method
-	if err := a.esClient.CheckHealth(ctx); err != nil {
+	// Check Elasticsearch
+	if err := a.checkElasticsearch(ctx); err != nil {
 		status["elasticsearch"] = "DOWN"
 		status["status"] = "DOWN"
 		a.logger.WithError(ctx, err, "Elasticsearch health check failed", map[string]interface{}{
@@ -72,6 +109,40 @@ func (a *App) handleReadinessCheck(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	// A consumer paused for maintenance isn't syncing, so it isn't ready
+	if a.consumer.Paused() {
+		status["paused"] = true
+		status["status"] = "DOWN"
+	}
+
+	// In kafka-connect mode the sink connector does the work, so the
+	// service is only ready while it and all its tasks are running
+	if a.cfg.Sync.Mode == config.ModeKafkaConnect {
+		status["kafka_connect"] = "UP"
+		connector, err := a.monitor.Status(ctx)
+		switch {
+		case err != nil:
+			status["kafka_connect"] = "DOWN"
+			status["status"] = "DOWN"
+			a.logger.WithError(ctx, err, "Kafka Connect health check failed", map[string]interface{}{
+				"component": "kafka_connect",
+			})
+		case !connector.Running():
+			status["kafka_connect"] = "DOWN"
+			status["status"] = "DOWN"
+			status["connector_state"] = connector.Connector.State
+			status["failed_tasks"] = len(connector.FailedTasks())
+		}
+	}
+
+	// Report whether the initial snapshot has been consumed, and optionally
+	// hold readiness until it has
+	snapshot := a.consumer.SnapshotState()
+	status["snapshot"] = snapshot
+	if a.cfg.Kafka.RequireSnapshotComplete && snapshot != consumers.SnapshotStateCaughtUp {
+		status["status"] = "DOWN"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if status["status"] == "DOWN" {
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -94,7 +165,6 @@ func main() {
 		logger.WithError(context.Background(), err, "Failed to initialize application", nil)
 		os.Exit(1)
 	}
-	defer app.cleanup()
 
 	// Initialize context with cancellation for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -120,12 +190,8 @@ func main() {
 		"signal": sig.String(),
 	})
 
-	// Create shutdown context with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
-
-	// Perform graceful shutdown
-	if err := app.Stop(shutdownCtx); err != nil {
+	// Perform graceful shutdown, bounded by shutdown.timeout
+	if err := app.Stop(context.Background()); err != nil {
 		logger.Error(ctx, "Shutdown error", map[string]interface{}{
 			"error": err.Error(),
 		})
@@ -136,17 +202,26 @@ func main() {
 	})
 }
 
-func initializeApp(appLogger logger.Logger) (*App, error) {
-	ctx := context.Background()
-
-	// Load configuration
+// loadConfig loads the configuration and sets appLogger's level from it.
+// The resolved settings are logged at debug level, so nothing is written at
+// other levels.
+func loadConfig(ctx context.Context, appLogger logger.Logger) (*config.Config, error) {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
+	appLogger.SetLevel(cfg.App.LogLevel)
+	appLogger.Debug(ctx, "Configuration loaded", cfg.Summary())
+	return cfg, nil
+}
 
-	// Initialize metrics collector
-	// metricsCollector := metrics.NewMetricsCollector()
+func initializeApp(appLogger logger.Logger) (*App, error) {
+	ctx := context.Background()
+
+	cfg, err := loadConfig(ctx, appLogger)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize Elasticsearch repository
 	esConfig := &elasticsearch.Config{
@@ -158,7 +233,33 @@ func initializeApp(appLogger logger.Logger) (*App, error) {
 		EnableRetry:    cfg.ES.EnableRetry,
 		MaxConns:       cfg.ES.MaxConns,
 		RequestTimeout: cfg.ES.RequestTimeout,
+		BulkTimeout:    cfg.ES.BulkTimeout,
+		SearchTimeout:  cfg.ES.SearchTimeout,
+		IndexExistsTTL: cfg.ES.IndexExistsTTL,
+		Refresh:        cfg.ES.Refresh,
+		BulkRefresh:    cfg.ES.BulkRefresh,
 		GzipEnabled:    cfg.ES.GzipEnabled,
+
+		IndexTemplatePath: cfg.ES.IndexTemplate,
+		Environment:       cfg.App.Environment,
+		IndexPrefix:       cfg.ES.IndexPrefix,
+		IndexSeparator:    cfg.ES.IndexSeparator,
+		Entities:          cfg.Kafka.Entities(),
+		ShardCount:        cfg.ES.ShardCount,
+		ReplicaCount:      cfg.ES.ReplicaCount,
+
+		Startup: utils.WaitPolicy{
+			MaxAttempts: cfg.Startup.MaxAttempts,
+			Backoff:     cfg.Startup.Backoff,
+			MaxBackoff:  cfg.Startup.MaxBackoff,
+		},
+		OnStartupRetry: func(attempt int, wait time.Duration, err error) {
+			appLogger.WithError(ctx, err, "Elasticsearch not reachable, retrying", map[string]interface{}{
+				"attempt":      attempt,
+				"max_attempts": cfg.Startup.MaxAttempts,
+				"backoff":      wait.String(),
+			})
+		},
 	}
 
 	// Use NewRepository instead of NewClient
@@ -169,13 +270,20 @@ func initializeApp(appLogger logger.Logger) (*App, error) {
 
 	// Initialize services with repository
 	syncService := services.NewSyncService(esClient, cfg, appLogger)
-	retryService := services.NewRetryService(syncService, cfg, appLogger)
+	retryStore, err := services.NewFileRetryStore(cfg.Sync.Custom.RetryStorePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open retry store: %w", err)
+	}
+	retryService := services.NewRetryService(syncService, retryStore, cfg, appLogger)
 
 	// Initialize Kafka consumer
 	consumer, err := consumers.NewKafkaConsumer(cfg, syncService, appLogger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kafka consumer: %w", err)
 	}
+	retryService.OnExhausted(consumer.HandleExhausted)
+
+	connect := services.NewConnectClient(cfg.Sync.KafkaConnect, syncService.Metrics(), appLogger)
 
 	app := &App{
 		cfg:          cfg,
@@ -184,6 +292,7 @@ func initializeApp(appLogger logger.Logger) (*App, error) {
 		syncService:  syncService,
 		retryService: retryService,
 		consumer:     consumer,
+		monitor:      services.NewConnectorMonitor(connect, cfg.Sync.KafkaConnect, appLogger),
 		// metrics:      metricsCollector,
 	}
 
@@ -217,12 +326,12 @@ func (a *App) Start(ctx context.Context) error {
 
 	// Start sync based on mode
 	switch a.cfg.Sync.Mode {
-	case "custom":
+	case config.ModeCustom:
 		if !a.cfg.Sync.Custom.Enabled {
 			return fmt.Errorf("custom sync is not enabled")
 		}
 		return a.startCustomSync(ctx)
-	case "kafka-connect":
+	case config.ModeKafkaConnect:
 		if !a.cfg.Sync.KafkaConnect.Enabled {
 			return fmt.Errorf("kafka connect is not enabled")
 		}
@@ -236,6 +345,9 @@ func (a *App) startCustomSync(ctx context.Context) error {
 	a.logger.Info(ctx, "Starting custom sync mode", map[string]interface{}{
 		"mode": "custom",
 	})
+	if err := a.retryService.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start retry queue: %w", err)
+	}
 	return a.consumer.Start(ctx)
 }
 
@@ -254,72 +366,82 @@ func (a *App) monitorKafkaConnect(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-a.monitor.Stopped():
+			return nil
 		case <-ticker.C:
-			status, err := a.checkConnectorStatus()
+			status, err := a.monitor.Check(ctx, time.Now())
+			if status != nil && status.Failed() {
+				a.logConnectorFailure(ctx, status)
+			}
 			if err != nil {
 				a.logger.WithError(ctx, err, "Failed to check connector status", map[string]interface{}{
 					"mode": "kafka-connect",
 				})
 				continue
 			}
+			if status.Failed() {
+				continue
+			}
 			a.logger.Info(ctx, "Connector status", map[string]interface{}{
-				"status": status,
+				"status": status.Connector.State,
+				"tasks":  len(status.Tasks),
 			})
 		}
 	}
 }
 
-func (a *App) checkConnectorStatus() (string, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/connectors/%s/status",
-		a.cfg.Sync.KafkaConnect.SinkConnector.URL,
-		a.cfg.Sync.KafkaConnect.SinkConnector.Name))
-	if err != nil {
-		return "", err
+// logConnectorFailure reports a failed connector or tasks, with the
+// exception each failed with
+func (a *App) logConnectorFailure(ctx context.Context, status *services.ConnectorStatus) {
+	failed := status.FailedTasks()
+	tasks := make([]map[string]interface{}, 0, len(failed))
+	for _, task := range failed {
+		tasks = append(tasks, map[string]interface{}{
+			"id":        task.ID,
+			"worker_id": task.WorkerID,
+			"error":     services.TraceSummary(task.Trace),
+		})
 	}
-	defer resp.Body.Close()
 
-	var status struct {
-		Connector struct {
-			State string `json:"state"`
-		} `json:"connector"`
+	fields := map[string]interface{}{
+		"connector":    status.Name,
+		"status":       status.Connector.State,
+		"failed_tasks": tasks,
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-		return "", err
+	if status.Connector.Trace != "" {
+		fields["error"] = services.TraceSummary(status.Connector.Trace)
 	}
-	return status.Connector.State, nil
+	a.logger.Error(ctx, "Kafka Connect connector has failed", fields)
 }
 
 func (a *App) setupElasticsearch(ctx context.Context) error {
+	// Create lifecycle policy first so the template's lifecycle settings
+	// resolve when the bootstrap index is created
+	policy := a.syncService.IndexNames().LifecyclePolicy()
+	if err := a.esClient.CreateLifecyclePolicy(ctx, policy); err != nil {
+		return fmt.Errorf("failed to create lifecycle policy: %w", err)
+	}
+
 	// Create index template using repository
 	if err := a.esClient.CreateTemplate(ctx); err != nil {
 		return fmt.Errorf("failed to create index template: %w", err)
 	}
 
-	// Create lifecycle policy using repository
-	if err := a.esClient.CreateLifecyclePolicy(ctx, "digital-discovery-policy"); err != nil {
-		return fmt.Errorf("failed to create lifecycle policy: %w", err)
-	}
-
 	// Verify setup using repository
 	if err := a.esClient.VerifySetup(ctx); err != nil {
 		return fmt.Errorf("failed to verify elasticsearch setup: %w", err)
 	}
 
 	a.logger.Info(ctx, "Elasticsearch setup completed", map[string]interface{}{
-		"templates": []string{"categories-template"},
-		"policies":  []string{"digital-discovery-policy"},
-		"status":    "success",
+		"entities": a.cfg.Kafka.Entities(),
+		"policies": []string{policy},
+		"status":   "success",
 	})
 
 	return nil
 }
 
 func (a *App) initMetrics() error {
-	// Initialize Prometheus metrics
-	if err := metrics.InitPrometheus(a.cfg.Monitoring.MetricsPort, a.cfg.Monitoring.PrometheusPath); err != nil {
-		return fmt.Errorf("failed to initialize Prometheus metrics: %w", err)
-	}
-
 	// Initialize OpenTelemetry if enabled
 	if a.cfg.Monitoring.TracingEnabled {
 		if err := metrics.InitTracing(a.cfg.App.ServiceName, a.cfg.Monitoring.OtelCollector); err != nil {
@@ -340,14 +462,22 @@ func (a *App) initHTTPServer() error {
 	mux.HandleFunc("/health", a.handleHealthCheck)
 
 	// Add metrics endpoint
-	mux.Handle("/metrics", promhttp.Handler())
+	metrics.InitPrometheus(mux, a.cfg.Monitoring.PrometheusPath)
 
 	// Add readiness check endpoint
 	mux.HandleFunc("/ready", a.handleReadinessCheck)
+	mux.HandleFunc("/version", a.handleVersion)
 
 	// Add API endpoints
 	mux.HandleFunc("/api/v1/categories", a.handleCategories)
 	mux.HandleFunc("/api/v1/category", a.handleCategory)
+	mux.HandleFunc("/api/v1/maintenance/purge", a.handlePurge)
+	mux.HandleFunc("/api/v1/dlq/replay", a.handleDLQReplay)
+	mux.HandleFunc("/api/v1/buffer", a.handleBuffer)
+	mux.HandleFunc("/api/v1/buffer/flush", a.handleBufferFlush)
+	mux.HandleFunc("/api/v1/consumer/pause", a.handleConsumerPause)
+	mux.HandleFunc("/api/v1/consumer/resume", a.handleConsumerResume)
+	mux.HandleFunc("/api/v1/consumer/offsets/reset", a.handleOffsetReset)
 
 	a.httpServer = &http.Server{
 		Addr:         ":8082", // API server port
@@ -364,12 +494,29 @@ func (a *App) handleCategories(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	switch r.Method {
 	case http.MethodGet:
-		categories, err := a.syncService.ListCategories(ctx)
+		limit, offset, err := a.listPagination(r)
 		if err != nil {
-			a.respondWithError(w, http.StatusInternalServerError, err.Error())
+			a.respondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		a.respondWithJSON(w, http.StatusOK, categories)
+		categories, total, stale, err := a.syncService.ListCategories(ctx, limit, offset)
+		if err != nil {
+			a.respondWithError(w, utils.HTTPStatus(err), err.Error())
+			return
+		}
+		metadata := map[string]interface{}{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		}
+		if stale {
+			markStale(w)
+			metadata["stale"] = true
+		}
+		a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":     categories,
+			"metadata": metadata,
+		})
 	case http.MethodPost:
 		var category models.Category
 		if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
@@ -398,7 +545,7 @@ func (a *App) handleCategories(w http.ResponseWriter, r *http.Request) {
 
 		// Create category
 		if err := a.syncService.CreateCategory(ctx, category); err != nil {
-			a.respondWithError(w, http.StatusInternalServerError, err.Error())
+			a.respondWithError(w, utils.HTTPStatus(err), err.Error())
 			return
 		}
 
@@ -408,6 +555,46 @@ func (a *App) handleCategories(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// markStale flags a response served from the stale read cache
+func markStale(w http.ResponseWriter) {
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+}
+
+// listPagination reads limit and offset from the query string, falling
+// back to the configured default limit and capping at the maximum
+func (a *App) listPagination(r *http.Request) (int, int, error) {
+	limit := a.cfg.Sync.ListLimit
+	if limit <= 0 {
+		limit = 50
+	}
+	maxLimit := a.cfg.Sync.ListMaxLimit
+	if maxLimit <= 0 {
+		maxLimit = 500
+	}
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer")
+		}
+		limit = n
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
+
 func (a *App) handleCategory(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
@@ -417,26 +604,56 @@ func (a *App) handleCategory(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		category, err := a.syncService.GetCategory(r.Context(), id)
+		category, stale, err := a.syncService.GetCategory(r.Context(), id)
 		if err != nil {
-			a.respondWithError(w, http.StatusInternalServerError, err.Error())
+			a.respondWithError(w, utils.HTTPStatus(err), err.Error())
 			return
 		}
-		a.respondWithJSON(w, http.StatusOK, category)
+		if stale {
+			markStale(w)
+		}
+		a.respondWithJSON(w, http.StatusOK, struct {
+			*models.Category
+			Stale bool `json:"stale,omitempty"`
+		}{category, stale})
 	case http.MethodPut:
 		var category models.Category
 		if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+			if errors.Is(err, io.EOF) {
+				a.respondWithError(w, http.StatusBadRequest, "Request body is required")
+				return
+			}
 			a.respondWithError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
-		if err := a.syncService.UpdateCategory(r.Context(), category); err != nil {
-			a.respondWithError(w, http.StatusInternalServerError, err.Error())
+		// The URL identifies the resource; a body id may only repeat it
+		if category.ID != "" && category.ID != id {
+			a.respondWithError(w, http.StatusBadRequest, "Category ID in body does not match URL")
 			return
 		}
-		a.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Category updated successfully"})
+		category.ID = id
+		version, err := a.syncService.UpdateCategory(r.Context(), category)
+		if err != nil {
+			var conflict *utils.VersionConflictError
+			if errors.As(err, &conflict) {
+				a.respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+					"status":          "error",
+					"message":         "Category was modified by another request",
+					"current_version": conflict.Current,
+					"request_id":      uuid.New().String(),
+				})
+				return
+			}
+			a.respondWithError(w, utils.HTTPStatus(err), err.Error())
+			return
+		}
+		a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message": "Category updated successfully",
+			"version": version,
+		})
 	case http.MethodDelete:
 		if err := a.syncService.DeleteCategory(r.Context(), id); err != nil {
-			a.respondWithError(w, http.StatusInternalServerError, err.Error())
+			a.respondWithError(w, utils.HTTPStatus(err), err.Error())
 			return
 		}
 		a.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Category deleted successfully"})
@@ -445,129 +662,280 @@ func (a *App) handleCategory(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Helper methods for consistent responses
-func (a *App) respondWithError(w http.ResponseWriter, code int, message string) {
-	a.respondWithJSON(w, code, map[string]interface{}{
-		"status":     "error",
-		"message":    message,
-		"request_id": uuid.New().String(),
+// handlePurge deletes orphaned category documents, see
+// SyncService.PurgeCategories for the accepted criteria
+func (a *App) handlePurge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var criteria services.PurgeCriteria
+	if err := json.NewDecoder(r.Body).Decode(&criteria); err != nil {
+		a.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if criteria.OlderThan.IsZero() && len(criteria.IDs) == 0 {
+		a.respondWithError(w, http.StatusBadRequest, "older_than or ids is required")
+		return
+	}
+
+	deleted, err := a.syncService.PurgeCategories(r.Context(), criteria)
+	if err != nil {
+		a.respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":     "error",
+			"message":    err.Error(),
+			"deleted":    deleted,
+			"request_id": uuid.New().String(),
+		})
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Purge completed",
+		"deleted": deleted,
 	})
 }
 
-func (a *App) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, err := json.Marshal(payload)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+// Limits on how many dead-lettered messages one replay request handles
+const (
+	defaultReplayMax = 100
+	maxReplayMax     = 1000
+)
+
+// handleDLQReplay reprocesses dead-lettered messages. max limits how many
+// are read and dry_run reports them without replaying anything.
+func (a *App) handleDLQReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(response)
+	replayer := a.consumer.DLQReplayer()
+	if replayer == nil {
+		a.respondWithError(w, http.StatusBadRequest, "No dead letter topic is configured")
+		return
+	}
+
+	limit := defaultReplayMax
+	if v := r.URL.Query().Get("max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			a.respondWithError(w, http.StatusBadRequest, "max must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	if limit > maxReplayMax {
+		limit = maxReplayMax
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			a.respondWithError(w, http.StatusBadRequest, "dry_run must be a boolean")
+			return
+		}
+		dryRun = b
+	}
+
+	report, err := replayer.Replay(r.Context(), limit, dryRun)
+	if err != nil {
+		if errors.Is(err, consumers.ErrReplayInProgress) {
+			a.respondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		a.respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":     "error",
+			"message":    err.Error(),
+			"report":     report,
+			"request_id": uuid.New().String(),
+		})
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, report)
 }
 
-func (a *App) cleanup() {
-	startTime := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// handleBuffer reports what is waiting in the bulk buffer
+func (a *App) handleBuffer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, a.syncService.BulkBufferStats())
+}
 
-	cleanupInfo := map[string]interface{}{
-		"event":     "cleanup_started",
-		"timestamp": time.Now().Format(time.RFC3339),
-		"service":   a.cfg.App.ServiceName,
-		"components": []string{
-			"http_server",
-			"kafka_consumer",
-			"elasticsearch_client",
-			"metrics_collector",
-		},
+// handleBufferFlush writes out the bulk buffer now instead of waiting for
+// it to fill
+func (a *App) handleBufferFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
 
-	jsonBytes, _ := json.MarshalIndent(cleanupInfo, "", "  ")
-	a.logger.Info(ctx, "Starting cleanup", map[string]interface{}{
-		"cleanup_info": string(jsonBytes),
+	flushed, err := a.syncService.DrainBulkBuffer(r.Context())
+	if err != nil {
+		a.respondWithJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":     "error",
+			"message":    err.Error(),
+			"flushed":    flushed,
+			"request_id": uuid.New().String(),
+		})
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"flushed": flushed,
 	})
+}
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, 4) // Buffer for all cleanup operations
-
-	// Cleanup HTTP server
-	if a.httpServer != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if err := a.httpServer.Shutdown(ctx); err != nil {
-				errChan <- fmt.Errorf("http server shutdown: %w", err)
-			}
-		}()
+// handleConsumerPause stops consuming for a maintenance window, keeping the
+// consumer in its group
+func (a *App) handleConsumerPause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
 
-	// Cleanup Kafka consumer
-	if a.consumer != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if err := a.consumer.Close(); err != nil {
-				errChan <- fmt.Errorf("kafka consumer cleanup: %w", err)
-			}
-		}()
+	changed := a.consumer.Pause(r.Context())
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"paused":  true,
+		"changed": changed,
+	})
+}
+
+// handleConsumerResume undoes handleConsumerPause
+func (a *App) handleConsumerResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
 
-	// Cleanup Elasticsearch client
-	if a.esClient != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if err := a.esClient.Close(); err != nil {
-				errChan <- fmt.Errorf("elasticsearch cleanup: %w", err)
-			}
-		}()
+	changed, err := a.consumer.Resume(r.Context())
+	if err != nil {
+		a.respondWithError(w, http.StatusConflict, err.Error())
+		return
 	}
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"paused":  false,
+		"changed": changed,
+	})
+}
 
-	// Cleanup metrics
-	if a.metrics != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.metrics.Cleanup()
-		}()
+// handleOffsetReset moves the consumer's committed offsets to a point in
+// time, given as timestamp (RFC 3339) or since (a duration before now, e.g.
+// 1h). The consumer must be paused first; dry_run only reports the offsets.
+func (a *App) handleOffsetReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
 
-	// Wait for all cleanup operations
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
+	var at time.Time
+	query := r.URL.Query()
+	switch {
+	case query.Get("timestamp") != "":
+		t, err := time.Parse(time.RFC3339, query.Get("timestamp"))
+		if err != nil {
+			a.respondWithError(w, http.StatusBadRequest, "timestamp must be RFC 3339, e.g. 2024-01-02T15:04:05Z")
+			return
+		}
+		at = t
+	case query.Get("since") != "":
+		d, err := time.ParseDuration(query.Get("since"))
+		if err != nil || d <= 0 {
+			a.respondWithError(w, http.StatusBadRequest, "since must be a positive duration, e.g. 1h")
+			return
+		}
+		at = time.Now().Add(-d)
+	default:
+		a.respondWithError(w, http.StatusBadRequest, "timestamp or since is required")
+		return
+	}
 
-	// Wait for cleanup or timeout
-	select {
-	case <-done:
-		// Check for any errors
-		close(errChan)
-		for err := range errChan {
-			a.logger.WithError(ctx, err, "Cleanup error", nil)
+	dryRun := false
+	if v := query.Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			a.respondWithError(w, http.StatusBadRequest, "dry_run must be a boolean")
+			return
 		}
-	case <-ctx.Done():
-		a.logger.WithError(ctx, ctx.Err(), "Cleanup timeout", nil)
+		dryRun = b
 	}
 
-	cleanupCompleteInfo := map[string]interface{}{
-		"event":       "cleanup_completed",
-		"timestamp":   time.Now().Format(time.RFC3339),
-		"service":     a.cfg.App.ServiceName,
-		"duration_ms": time.Since(startTime).Milliseconds(),
+	offsets, err := a.consumer.ResetOffsets(r.Context(), at, dryRun)
+	switch {
+	case errors.Is(err, consumers.ErrNotPaused), errors.Is(err, consumers.ErrNoSession):
+		a.respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, consumers.ErrOutsideRetention):
+		a.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		a.respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"dry_run":   dryRun,
+		"timestamp": at.UTC().Format(time.RFC3339),
+		"offsets":   offsets,
+	})
+}
 
-	jsonBytes, _ = json.MarshalIndent(cleanupCompleteInfo, "", "  ")
-	a.logger.Info(ctx, "Cleanup completed", map[string]interface{}{
-		"cleanup_info": string(jsonBytes),
+// Helper methods for consistent responses
+func (a *App) respondWithError(w http.ResponseWriter, code int, message string) {
+	a.respondWithJSON(w, code, map[string]interface{}{
+		"status":     "error",
+		"message":    message,
+		"request_id": uuid.New().String(),
 	})
 }
 
+func (a *App) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
+
+// flushBulkBuffer writes out operations still waiting in the bulk buffer.
+// It must run after the consumer has stopped and before the Elasticsearch
+// client is closed.
+func (a *App) flushBulkBuffer(ctx context.Context) error {
+	if a.syncService == nil {
+		return nil
+	}
+
+	pending := a.syncService.BulkBufferSize()
+	if pending == 0 {
+		return nil
+	}
+
+	// The flush gets whatever is left of the shutdown timeout
+	fields := map[string]interface{}{
+		"buffer_size": pending,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		fields["time_left"] = time.Until(deadline).String()
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("no time left to flush %d buffered operations: %w", pending, err)
+	}
+	a.logger.Info(ctx, "Flushing bulk buffer", fields)
+	return a.syncService.FlushBulkBuffer(ctx)
+}
+
 func (a *App) initializeServices(ctx context.Context) error {
-	// Setup Elasticsearch
-	if err := a.setupElasticsearch(ctx); err != nil {
+	// Setup Elasticsearch, which dry run must leave untouched
+	if a.cfg.Sync.Custom.DryRun {
+		a.logger.Info(ctx, "DRY RUN: operations are validated and logged but nothing is written to Elasticsearch", map[string]interface{}{
+			"dry_run": true,
+		})
+	} else if err := a.setupElasticsearch(ctx); err != nil {
 		return fmt.Errorf("failed to setup elasticsearch: %w", err)
 	}
 
@@ -579,28 +947,61 @@ func (a *App) initializeServices(ctx context.Context) error {
 	return nil
 }
 
+// Stop shuts the service down within shutdown.timeout, or sooner if ctx
+// ends first
 func (a *App) Stop(ctx context.Context) error {
-	var err error
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Shutdown.Timeout)
+	defer cancel()
+
+	// Every step runs even if an earlier one failed, and every failure is
+	// returned
+	var errs []error
 	// Shutdown HTTP server
 	if a.httpServer != nil {
-		if err = a.httpServer.Shutdown(ctx); err != nil {
+		if err := a.httpServer.Shutdown(ctx); err != nil {
 			a.logger.WithError(ctx, err, "Failed to shutdown HTTP server", nil)
+			errs = append(errs, err)
 		}
 	}
 
+	// Stop checking the connector
+	if a.monitor != nil {
+		a.monitor.Stop()
+	}
+
 	// Close Kafka consumer
 	if a.consumer != nil {
-		if err = a.consumer.Close(); err != nil {
+		if err := a.consumer.Close(); err != nil {
 			a.logger.WithError(ctx, err, "Failed to close Kafka consumer", nil)
+			errs = append(errs, err)
+		}
+	}
+
+	// Stop retrying before the flush, so nothing reaches the buffer or
+	// Elasticsearch after it
+	if a.retryService != nil {
+		if err := a.retryService.Stop(ctx); err != nil {
+			a.logger.WithError(ctx, err, "Failed to stop retry queue", nil)
+			errs = append(errs, err)
 		}
 	}
 
+	// Flush buffered operations before the Elasticsearch client closes
+	if err := a.flushBulkBuffer(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
 	// Close Elasticsearch client
 	if a.esClient != nil {
-		if err = a.esClient.Close(); err != nil {
+		if err := a.esClient.Close(); err != nil {
 			a.logger.WithError(ctx, err, "Failed to close Elasticsearch client", nil)
+			errs = append(errs, err)
 		}
 	}
 
-	return err
+	if a.metrics != nil {
+		a.metrics.Cleanup()
+	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/services"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// captureStdout returns what fn writes to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestLoadConfigLogsOnlyAtDebug(t *testing.T) {
+	// LoadConfig reads sync/config/config.yaml relative to the repository root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, level := range []string{"info", "debug"} {
+		t.Run(level, func(t *testing.T) {
+			t.Setenv("DD_APP_LOG_LEVEL", level)
+			appLogger := logger.NewPrettyLogger("test")
+
+			out := captureStdout(t, func() {
+				if _, err := loadConfig(context.Background(), appLogger); err != nil {
+					t.Fatalf("loadConfig: %v", err)
+				}
+			})
+
+			if level == "info" && out != "" {
+				t.Errorf("loading config at info level wrote to stdout:\n%s", out)
+			}
+			if level == "debug" && !strings.Contains(out, "Configuration loaded") {
+				t.Errorf("loading config at debug level didn't log the summary; stdout:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestStopReturnsEveryError(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Shutdown.Timeout = 5 * time.Second
+	cfg.Sync.Custom.BatchSize = 100
+	log := logger.NewLogger("json")
+
+	flushErr := errors.New("bulk rejected")
+	closeErr := errors.New("close failed")
+	repo := mocks.NewRepository()
+	repo.Errors["Bulk"] = flushErr
+	repo.Errors["BulkItems"] = flushErr
+	repo.Errors["Close"] = closeErr
+
+	syncService := services.NewSyncService(repo, cfg, log)
+	if err := syncService.AddToBulkBuffer(models.CategoryOperation{
+		Operation: models.OperationDelete,
+		Payload:   models.Category{ID: "1"},
+	}); err != nil {
+		t.Fatalf("AddToBulkBuffer: %v", err)
+	}
+
+	app := &App{cfg: cfg, logger: log, esClient: repo, syncService: syncService}
+	err := app.Stop(context.Background())
+
+	if !errors.Is(err, flushErr) {
+		t.Errorf("Stop() = %v, want the flush error", err)
+	}
+	if !errors.Is(err, closeErr) {
+		t.Errorf("Stop() = %v, want the close error", err)
+	}
+}
+
+func TestStopFlushesBufferBeforeClose(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Shutdown.Timeout = 5 * time.Second
+	cfg.Sync.Custom.BatchSize = 100
+	cfg.Sync.Custom.RetryPollInterval = 5 * time.Millisecond
+	log := logger.NewLogger("json")
+	repo := mocks.NewRepository()
+
+	syncService := services.NewSyncService(repo, cfg, log)
+	store, err := services.NewFileRetryStore(filepath.Join(t.TempDir(), "retries.json"))
+	if err != nil {
+		t.Fatalf("NewFileRetryStore: %v", err)
+	}
+	retryService := services.NewRetryService(syncService, store, cfg, log)
+	if err := retryService.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := syncService.AddToBulkBuffer(models.CategoryOperation{
+		Operation: models.OperationDelete,
+		Payload:   models.Category{ID: "1"},
+	}); err != nil {
+		t.Fatalf("AddToBulkBuffer: %v", err)
+	}
+
+	app := &App{cfg: cfg, logger: log, esClient: repo, syncService: syncService, retryService: retryService}
+	if err := app.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+
+	// The buffered delete is written, and Close is the last call made
+	calls := repo.Calls()
+	flushed := false
+	for _, call := range calls {
+		if strings.HasPrefix(call.Method, "Bulk") && strings.Contains(call.Body, `"1"`) {
+			flushed = true
+		}
+	}
+	if !flushed {
+		t.Errorf("calls = %+v, want a bulk write of category 1", calls)
+	}
+	if len(calls) == 0 || calls[len(calls)-1].Method != "Close" {
+		t.Errorf("calls = %+v, want Close last", calls)
+	}
+	if syncService.BulkBufferSize() != 0 {
+		t.Errorf("%d operations left in the buffer", syncService.BulkBufferSize())
+	}
+}
+
+func TestVersionReportsBuild(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.Version = "2.1.0"
+	app := &App{cfg: cfg, logger: logger.NewLogger("json")}
+
+	rec := httptest.NewRecorder()
+	app.handleVersion(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	for _, key := range []string{"version", "commit", "build_date", "go_version"} {
+		if body[key] == "" {
+			t.Errorf("%s missing from %v", key, body)
+		}
+	}
+	if body["version"] != "2.1.0" {
+		t.Errorf("version = %q, want the configured 2.1.0", body["version"])
+	}
+}
+
+func TestGetMissingCategoryReturnsNotFound(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.ES.IndexPrefix = "digital-discovery"
+	cfg.ES.IndexSeparator = "-"
+	cfg.Sync.Custom.BatchSize = 100
+	log := logger.NewLogger("json")
+	repo := mocks.NewRepository()
+	app := &App{cfg: cfg, logger: log, esClient: repo, syncService: services.NewSyncService(repo, cfg, log)}
+
+	rec := httptest.NewRecorder()
+	app.handleCategory(rec, httptest.NewRequest(http.MethodGet, "/api/v1/category?id=42", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	var body struct {
+		Status    string `json:"status"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+	}
+	if body.Status != "error" || !strings.Contains(body.Message, "category 42 not found") || body.RequestID == "" {
+		t.Errorf("body = %+v, want the error envelope for category 42", body)
+	}
+}
+
+// newTestApp returns an App serving categories from repo
+func newTestApp(repo *mocks.Repository) *App {
+	cfg := &config.Config{}
+	cfg.ES.IndexPrefix = "digital-discovery"
+	cfg.ES.IndexSeparator = "-"
+	cfg.Sync.Custom.BatchSize = 100
+	log := logger.NewLogger("json")
+	return &App{cfg: cfg, logger: log, esClient: repo, syncService: services.NewSyncService(repo, cfg, log)}
+}
+
+func TestPutCategoryTakesIDFromURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		message string
+	}{
+		{"empty body", "", http.StatusBadRequest, "Request body is required"},
+		{"mismatched id", `{"id":"7","name":"Books"}`, http.StatusBadRequest, "Category ID in body does not match URL"},
+		{"matching id", `{"id":"42","name":"Books"}`, http.StatusOK, ""},
+		{"no id", `{"name":"Books"}`, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewRepository()
+			app := newTestApp(repo)
+
+			rec := httptest.NewRecorder()
+			app.handleCategory(rec, httptest.NewRequest(http.MethodPut, "/api/v1/category?id=42", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.message != "" && !strings.Contains(rec.Body.String(), tt.message) {
+				t.Errorf("body = %s, want %q", rec.Body.String(), tt.message)
+			}
+			wrote := false
+			for _, call := range repo.Calls() {
+				if call.ID != "" && call.ID != "42" {
+					t.Errorf("%s wrote category %s, want only the URL's 42", call.Method, call.ID)
+				}
+				wrote = wrote || call.ID == "42"
+			}
+			if wrote != (tt.status == http.StatusOK) {
+				t.Errorf("calls = %+v, want category 42 written only for an accepted body", repo.Calls())
+			}
+		})
+	}
+}
+
+func TestLivenessIgnoresDependencies(t *testing.T) {
+	repo := mocks.NewRepository()
+	repo.Errors["CheckHealth"] = errors.New("cluster red")
+	repo.Errors["Ping"] = errors.New("connection refused")
+	app := newTestApp(repo)
+	app.cfg.Monitoring.LivenessTimeout = 30 * time.Second
+
+	// Restarting the process doesn't fix Elasticsearch, so only readiness
+	// reports it; liveness only fails on a stalled consumer
+	rec := httptest.NewRecorder()
+	app.handleHealthCheck(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusOK || body["status"] != "UP" {
+		t.Errorf("GET /health = %d %v, want 200 UP", rec.Code, body)
+	}
+}
+
+func TestStopIsBoundedByShutdownTimeout(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Shutdown.Timeout = time.Nanosecond
+	cfg.Sync.Custom.BatchSize = 100
+	log := logger.NewLogger("json")
+	repo := mocks.NewRepository()
+
+	syncService := services.NewSyncService(repo, cfg, log)
+	if err := syncService.AddToBulkBuffer(models.CategoryOperation{
+		Operation: models.OperationDelete,
+		Payload:   models.Category{ID: "1"},
+	}); err != nil {
+		t.Fatalf("AddToBulkBuffer: %v", err)
+	}
+
+	app := &App{cfg: cfg, logger: log, esClient: repo, syncService: syncService}
+	err := app.Stop(context.Background())
+
+	// Out of time, the flush is abandoned but the client is still closed
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "no time left to flush 1") {
+		t.Errorf("Stop() = %v, want the flush abandoned at the deadline", err)
+	}
+	calls := repo.Calls()
+	if len(calls) != 1 || calls[0].Method != "Close" {
+		t.Errorf("calls = %+v, want only Close", calls)
+	}
+}
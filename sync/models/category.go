@@ -10,17 +10,38 @@ type Category struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	Status      int64      `json:"status"`
+	StatusLabel string     `json:"status_label,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 	Version     int64      `json:"version"`
 	SyncStatus  SyncStatus `json:"sync_status"`
 	LastSync    time.Time  `json:"last_sync"`
+
+	// SourceTimestamp is when the change was committed at the source, in
+	// epoch milliseconds; the timestamp conflict mode orders writes by it
+	SourceTimestamp int64 `json:"source_ts_ms,omitempty"`
 }
 
 type CategoryOperation struct {
-	Operation string    `json:"operation"`
-	Payload   Category  `json:"payload"`
-	Timestamp time.Time `json:"timestamp"`
+	Operation string     `json:"operation"`
+	Payload   Category   `json:"payload"`
+	Timestamp time.Time  `json:"timestamp"`
+	Source    SourceInfo `json:"source,omitempty"`
+
+	// Entity picks the index the operation is written to; empty means
+	// categories
+	Entity string `json:"entity,omitempty"`
+
+	// Changed lists the fields an update changed, or is nil when the
+	// event had no before image to compare against
+	Changed []string `json:"changed,omitempty"`
+}
+
+// SourceInfo identifies the Debezium source table an operation came from;
+// it is empty for operations made through the REST API
+type SourceInfo struct {
+	Schema string `json:"schema,omitempty"`
+	Table  string `json:"table,omitempty"`
 }
 
 // Validate checks if the category data is valid
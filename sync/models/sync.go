@@ -30,6 +30,9 @@ type SyncRecord struct {
 	NextRetry    *time.Time `json:"next_retry,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Payload is the operation to retry, for records in the retry queue
+	Payload *CategoryOperation `json:"payload,omitempty"`
 }
 
 // Add validation method
@@ -0,0 +1,132 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// BulkItem is the outcome of one action of a bulk request
+type BulkItem struct {
+	Action string
+	Index  string
+	ID     string
+	// Status is the HTTP status of the action, e.g. 404 for deleting a
+	// document that doesn't exist
+	Status int
+	// Error is the reason the action failed, empty if it succeeded
+	Error string
+}
+
+// NotFound reports whether the action found no document, as when deleting
+// a document that doesn't exist
+func (i BulkItem) NotFound() bool {
+	return i.Status == http.StatusNotFound && i.Error == ""
+}
+
+// Failed reports whether the action failed. A delete that found no
+// document didn't fail, since there is nothing left to delete.
+func (i BulkItem) Failed() bool {
+	return i.Error != "" || (i.Status >= 300 && !i.NotFound())
+}
+
+// BulkItemsError is returned by Bulk when Elasticsearch accepted a bulk
+// request but failed some of its actions
+type BulkItemsError struct {
+	Failed []BulkItem
+	Total  int
+}
+
+func (e *BulkItemsError) Error() string {
+	first := e.Failed[0]
+	return fmt.Sprintf("%d of %d bulk actions failed, first %s %s/%s: status=%d %s",
+		len(e.Failed), e.Total, first.Action, first.Index, first.ID, first.Status, first.Error)
+}
+
+// FailedBulkItems returns the BulkItemsError for the failed items among
+// items, or nil if none failed
+func FailedBulkItems(items []BulkItem) error {
+	var failed []BulkItem
+	for _, item := range items {
+		if item.Failed() {
+			failed = append(failed, item)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &BulkItemsError{Failed: failed, Total: len(items)}
+}
+
+// Bulk runs a bulk request and fails with a BulkItemsError if any of its
+// actions failed
+func (r *esRepository) Bulk(ctx context.Context, body io.Reader) error {
+	items, err := r.BulkItems(ctx, body)
+	if err != nil {
+		return err
+	}
+	return FailedBulkItems(items)
+}
+
+// bulkResponse is the body of a bulk response; each item maps its action
+// to the outcome
+type bulkResponse struct {
+	Items []map[string]struct {
+		Index  string `json:"_index"`
+		ID     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// BulkItems runs a bulk request and returns the outcome of each action, in
+// request order. Failed actions don't fail the request; it
+// fails only if Elasticsearch rejects it as a whole.
+func (r *esRepository) BulkItems(ctx context.Context, body io.Reader) ([]BulkItem, error) {
+	req := esapi.BulkRequest{
+		Body:    body,
+		Refresh: r.config.BulkRefresh,
+		Timeout: r.config.BulkTimeout,
+	}
+
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute bulk request: %w", err)
+	}
+	defer res.Body.Close()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bulk response: %w", err)
+	}
+	if res.IsError() {
+		if blockErr := clusterBlockError(res.StatusCode, "", bodyBytes); blockErr != nil {
+			return nil, blockErr
+		}
+		return nil, fmt.Errorf("bulk error: status=%s body=%s", res.Status(), string(bodyBytes))
+	}
+
+	var response bulkResponse
+	if err := json.Unmarshal(bodyBytes, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+
+	items := make([]BulkItem, 0, len(response.Items))
+	for _, entry := range response.Items {
+		for action, outcome := range entry {
+			item := BulkItem{Action: action, Index: outcome.Index, ID: outcome.ID, Status: outcome.Status}
+			if outcome.Error != nil {
+				item.Error = fmt.Sprintf("%s: %s", outcome.Error.Type, outcome.Error.Reason)
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,70 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBulkReportsFailedItems(t *testing.T) {
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"took":3,"errors":true,"items":[` +
+			`{"index":{"_index":"categories-000001","_id":"1","status":201}},` +
+			`{"delete":{"_index":"categories-000001","_id":"2","status":404,"result":"not_found"}},` +
+			`{"update":{"_index":"categories-000001","_id":"3","status":400,` +
+			`"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [status]"}}}]}`))
+	})
+
+	err := repo.Bulk(context.Background(), strings.NewReader("{}\n"))
+
+	var itemsErr *BulkItemsError
+	if !errors.As(err, &itemsErr) {
+		t.Fatalf("Bulk error = %v, want a BulkItemsError", err)
+	}
+	if itemsErr.Total != 3 || len(itemsErr.Failed) != 1 {
+		t.Fatalf("BulkItemsError = %+v, want 1 of 3 failed", itemsErr)
+	}
+	failed := itemsErr.Failed[0]
+	if failed.ID != "3" || failed.Action != "update" || failed.Error != "mapper_parsing_exception: failed to parse field [status]" {
+		t.Errorf("failed item = %+v, want the update of 3", failed)
+	}
+}
+
+func TestBulkSucceedsWhenNoItemFailed(t *testing.T) {
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"took":3,"errors":false,"items":[` +
+			`{"index":{"_index":"categories-000001","_id":"1","status":201}},` +
+			`{"delete":{"_index":"categories-000001","_id":"2","status":404,"result":"not_found"}}]}`))
+	})
+
+	if err := repo.Bulk(context.Background(), strings.NewReader("{}\n")); err != nil {
+		t.Fatalf("Bulk: %v", err)
+	}
+}
+
+func TestBulkItemOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     BulkItem
+		notFound bool
+		failed   bool
+	}{
+		{"created", BulkItem{Action: "index", Status: 201}, false, false},
+		{"delete of missing document", BulkItem{Action: "delete", Status: 404}, true, false},
+		{"missing index", BulkItem{Action: "index", Status: 404, Error: "index_not_found_exception: no such index"}, false, true},
+		{"rejected", BulkItem{Action: "update", Status: 429, Error: "es_rejected_execution_exception: queue full"}, false, true},
+		{"server error without reason", BulkItem{Action: "delete", Status: 500}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.item.NotFound(); got != tt.notFound {
+				t.Errorf("NotFound() = %v, want %v", got, tt.notFound)
+			}
+			if got := tt.item.Failed(); got != tt.failed {
+				t.Errorf("Failed() = %v, want %v", got, tt.failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Kinds of ClusterBlockError
+const (
+	BlockClosed   = "closed"
+	BlockReadOnly = "read_only"
+	BlockOther    = "blocked"
+)
+
+// ClusterBlockError is returned for a write refused because the index is
+// closed or blocked, e.g. made read-only once the disk passes the flood
+// stage watermark. The block stays until an operator lifts it, so retrying
+// the write doesn't help.
+type ClusterBlockError struct {
+	// Kind is BlockClosed, BlockReadOnly or BlockOther
+	Kind   string
+	Index  string
+	Reason string
+	Status int
+}
+
+func (e *ClusterBlockError) Error() string {
+	return fmt.Sprintf("index %s is %s: status=%d reason=%s", e.Index, e.Kind, e.Status, e.Reason)
+}
+
+// clusterBlockError returns the ClusterBlockError an error response body
+// describes, or nil if it describes some other error
+func clusterBlockError(status int, index string, body []byte) error {
+	var response struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+			Index  string `json:"index"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil
+	}
+
+	blockErr := &ClusterBlockError{
+		Index:  index,
+		Reason: response.Error.Reason,
+		Status: status,
+	}
+	if response.Error.Index != "" {
+		blockErr.Index = response.Error.Index
+	}
+
+	switch response.Error.Type {
+	case "index_closed_exception":
+		blockErr.Kind = BlockClosed
+	case "cluster_block_exception":
+		reason := strings.ToLower(response.Error.Reason)
+		switch {
+		case strings.Contains(reason, "read-only") || strings.Contains(reason, "read_only"):
+			blockErr.Kind = BlockReadOnly
+		case strings.Contains(reason, "closed"):
+			blockErr.Kind = BlockClosed
+		default:
+			blockErr.Kind = BlockOther
+		}
+	default:
+		return nil
+	}
+	return blockErr
+}
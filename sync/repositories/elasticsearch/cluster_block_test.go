@@ -0,0 +1,75 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const readOnlyBody = `{"error":{"type":"cluster_block_exception",` +
+	`"reason":"index [categories-000001] blocked by: [TOO_MANY_REQUESTS/12/disk usage exceeded flood-stage watermark, index has read-only-allow-delete block];"},` +
+	`"status":429}`
+
+func TestClusterBlockError(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantKind string
+		wantIdx  string
+	}{
+		{"read only", readOnlyBody, BlockReadOnly, "categories-write"},
+		{
+			"closed",
+			`{"error":{"type":"index_closed_exception","reason":"closed","index":"categories-000001"},"status":400}`,
+			BlockClosed, "categories-000001",
+		},
+		{
+			"other block",
+			`{"error":{"type":"cluster_block_exception","reason":"blocked by: [FORBIDDEN/8/index write (api)];"},"status":403}`,
+			BlockOther, "categories-write",
+		},
+		{"not a block", `{"error":{"type":"mapper_parsing_exception","reason":"failed to parse"},"status":400}`, "", ""},
+		{"not json", `bad gateway`, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := clusterBlockError(400, "categories-write", []byte(tt.body))
+			if tt.wantKind == "" {
+				if err != nil {
+					t.Fatalf("clusterBlockError = %v, want nil", err)
+				}
+				return
+			}
+			var blockErr *ClusterBlockError
+			if !errors.As(err, &blockErr) {
+				t.Fatalf("clusterBlockError = %v, want a ClusterBlockError", err)
+			}
+			if blockErr.Kind != tt.wantKind || blockErr.Index != tt.wantIdx {
+				t.Errorf("got kind %s index %s, want kind %s index %s", blockErr.Kind, blockErr.Index, tt.wantKind, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func TestWritesReportClusterBlocks(t *testing.T) {
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(readOnlyBody))
+	})
+	ctx := context.Background()
+
+	writes := map[string]func() error{
+		"Index":  func() error { return repo.Index(ctx, "categories-write", "1", strings.NewReader(`{}`)) },
+		"Update": func() error { return repo.Update(ctx, "categories-write", "1", strings.NewReader(`{}`)) },
+		"Delete": func() error { return repo.Delete(ctx, "categories-write", "1") },
+	}
+	for name, write := range writes {
+		var blockErr *ClusterBlockError
+		if err := write(); !errors.As(err, &blockErr) || blockErr.Kind != BlockReadOnly || blockErr.Status != http.StatusTooManyRequests {
+			t.Errorf("%s error = %v, want a read-only ClusterBlockError with status 429", name, err)
+		}
+	}
+}
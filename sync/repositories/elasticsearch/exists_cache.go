@@ -0,0 +1,58 @@
+package elasticsearch
+
+import (
+	"sync"
+	"time"
+)
+
+// existsCache remembers IndexExists results for a short TTL, so frequent
+// readiness checks don't send an exists request to the cluster every time.
+// A zero TTL disables it.
+type existsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]existsEntry
+}
+
+type existsEntry struct {
+	exists  bool
+	expires time.Time
+}
+
+func newExistsCache(ttl time.Duration) *existsCache {
+	return &existsCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]existsEntry),
+	}
+}
+
+// get returns the cached result for index, and whether there was one
+func (c *existsCache) get(index string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[index]
+	if !ok || !c.now().Before(entry.expires) {
+		return false, false
+	}
+	return entry.exists, true
+}
+
+func (c *existsCache) set(index string, exists bool) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[index] = existsEntry{exists: exists, expires: c.now().Add(c.ttl)}
+}
+
+// clear drops every cached result. Index names are resolved through
+// aliases, so a new index can change the answer for names other than its
+// own.
+func (c *existsCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]existsEntry)
+}
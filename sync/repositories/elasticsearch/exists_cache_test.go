@@ -0,0 +1,73 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestExistsCacheExpires(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := newExistsCache(5 * time.Second)
+	cache.now = func() time.Time { return now }
+
+	if _, ok := cache.get("categories"); ok {
+		t.Fatal("an empty cache had a result")
+	}
+	cache.set("categories", true)
+	if exists, ok := cache.get("categories"); !ok || !exists {
+		t.Fatalf("get = %v, %v; want true, true", exists, ok)
+	}
+
+	now = now.Add(5 * time.Second)
+	if _, ok := cache.get("categories"); ok {
+		t.Error("a result was served after its TTL")
+	}
+
+	cache.set("categories", false)
+	cache.clear()
+	if _, ok := cache.get("categories"); ok {
+		t.Error("a result was served after clear")
+	}
+}
+
+func TestExistsCacheWithZeroTTLCachesNothing(t *testing.T) {
+	cache := newExistsCache(0)
+	cache.set("categories", true)
+	if _, ok := cache.get("categories"); ok {
+		t.Error("a zero TTL cache served a result")
+	}
+}
+
+func TestIndexExistsAnswersFromCache(t *testing.T) {
+	requests := 0
+	r := newTestRepository(t, func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	r.exists = newExistsCache(time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if exists, err := r.IndexExists(ctx, "categories"); err != nil || !exists {
+			t.Fatalf("IndexExists(categories) = %v, %v; want true", exists, err)
+		}
+		if exists, err := r.IndexExists(ctx, "missing"); err != nil || exists {
+			t.Fatalf("IndexExists(missing) = %v, %v; want false", exists, err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("made %d exists requests, want one per index", requests)
+	}
+
+	r.exists.clear()
+	if _, err := r.IndexExists(ctx, "categories"); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Errorf("made %d exists requests, want a new one after clear", requests)
+	}
+}
@@ -0,0 +1,238 @@
+// Package mocks provides a test double for the Elasticsearch repository
+package mocks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch"
+)
+
+var _ elasticsearch.Repository = (*Repository)(nil)
+
+// Call is one recorded Repository call
+type Call struct {
+	Method string
+	Index  string
+	ID     string
+	// Body is the request body, or the query encoded as JSON
+	Body string
+}
+
+// Repository is an elasticsearch.Repository that records every call and
+// answers from its fields instead of a cluster
+type Repository struct {
+	mu    sync.Mutex
+	calls []Call
+
+	// Errors makes the named method, e.g. "Index", fail with the error
+	Errors map[string]error
+	// Docs are returned by Search and SearchPage
+	Docs []json.RawMessage
+	// Exists answers IndexExists
+	Exists bool
+	// Deleted is the count DeleteByQuery reports
+	Deleted int
+	// WriteIndexes answers WriteIndex by alias; an alias missing from it
+	// resolves to itself
+	WriteIndexes map[string]string
+	// BulkStatuses answers Bulk and BulkItems with the status of each
+	// action by document ID; an ID missing from it gets 200
+	BulkStatuses map[string]int
+	// BulkOmitted are document IDs left out of bulk responses
+	BulkOmitted map[string]bool
+}
+
+// NewRepository returns a mock whose calls all succeed
+func NewRepository() *Repository {
+	return &Repository{
+		Errors: make(map[string]error),
+		Exists: true,
+	}
+}
+
+// Calls returns every call made so far, oldest first
+func (m *Repository) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsTo returns the calls made to the named method, oldest first
+func (m *Repository) CallsTo(method string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var calls []Call
+	for _, call := range m.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls
+func (m *Repository) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+// record saves a call and returns the error configured for its method
+func (m *Repository) record(call Call) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+	return m.Errors[call.Method]
+}
+
+func readBody(body io.Reader) string {
+	if body == nil {
+		return ""
+	}
+	data, _ := io.ReadAll(body)
+	return string(data)
+}
+
+func encodeQuery(query interface{}) string {
+	data, _ := json.Marshal(query)
+	return string(data)
+}
+
+func (m *Repository) Index(ctx context.Context, index, id string, body io.Reader) error {
+	return m.record(Call{Method: "Index", Index: index, ID: id, Body: readBody(body)})
+}
+
+func (m *Repository) Update(ctx context.Context, index, id string, body io.Reader) error {
+	return m.record(Call{Method: "Update", Index: index, ID: id, Body: readBody(body)})
+}
+
+func (m *Repository) Delete(ctx context.Context, index, id string) error {
+	return m.record(Call{Method: "Delete", Index: index, ID: id})
+}
+
+func (m *Repository) DeleteByQuery(ctx context.Context, index string, query interface{}) (int, error) {
+	if err := m.record(Call{Method: "DeleteByQuery", Index: index, Body: encodeQuery(query)}); err != nil {
+		return 0, err
+	}
+	return m.Deleted, nil
+}
+
+func (m *Repository) Search(ctx context.Context, index string, query interface{}) ([]json.RawMessage, error) {
+	if err := m.record(Call{Method: "Search", Index: index, Body: encodeQuery(query)}); err != nil {
+		return nil, err
+	}
+	return m.Docs, nil
+}
+
+func (m *Repository) SearchPage(ctx context.Context, index string, query interface{}) (*elasticsearch.SearchResult, error) {
+	if err := m.record(Call{Method: "SearchPage", Index: index, Body: encodeQuery(query)}); err != nil {
+		return nil, err
+	}
+	return &elasticsearch.SearchResult{Total: int64(len(m.Docs)), Docs: m.Docs}, nil
+}
+
+func (m *Repository) Bulk(ctx context.Context, body io.Reader) error {
+	data := readBody(body)
+	if err := m.record(Call{Method: "Bulk", Body: data}); err != nil {
+		return err
+	}
+	items, err := m.bulkItems(data)
+	if err != nil {
+		return err
+	}
+	return elasticsearch.FailedBulkItems(items)
+}
+
+func (m *Repository) BulkItems(ctx context.Context, body io.Reader) ([]elasticsearch.BulkItem, error) {
+	data := readBody(body)
+	if err := m.record(Call{Method: "BulkItems", Body: data}); err != nil {
+		return nil, err
+	}
+	return m.bulkItems(data)
+}
+
+// bulkItems answers each action line of a bulk request body from
+// BulkStatuses and BulkOmitted. Source lines don't decode into an action
+// with an ID and are skipped.
+func (m *Repository) bulkItems(data string) ([]elasticsearch.BulkItem, error) {
+	var items []elasticsearch.BulkItem
+	decoder := json.NewDecoder(strings.NewReader(data))
+	for decoder.More() {
+		var line map[string]json.RawMessage
+		if err := decoder.Decode(&line); err != nil {
+			return nil, err
+		}
+		for action, raw := range line {
+			var meta struct {
+				Index string `json:"_index"`
+				ID    string `json:"_id"`
+			}
+			if json.Unmarshal(raw, &meta) != nil || meta.ID == "" || m.BulkOmitted[meta.ID] {
+				continue
+			}
+			status, ok := m.BulkStatuses[meta.ID]
+			if !ok {
+				status = 200
+			}
+			item := elasticsearch.BulkItem{Action: action, Index: meta.Index, ID: meta.ID, Status: status}
+			if status >= 300 && status != 404 {
+				item.Error = "mock_exception: failed"
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (m *Repository) Ping(ctx context.Context) error {
+	return m.record(Call{Method: "Ping"})
+}
+
+func (m *Repository) IndexExists(ctx context.Context, index string) (bool, error) {
+	if err := m.record(Call{Method: "IndexExists", Index: index}); err != nil {
+		return false, err
+	}
+	return m.Exists, nil
+}
+
+func (m *Repository) WriteIndex(ctx context.Context, alias string) (string, error) {
+	if err := m.record(Call{Method: "WriteIndex", Index: alias}); err != nil {
+		return "", err
+	}
+	if index, ok := m.WriteIndexes[alias]; ok {
+		return index, nil
+	}
+	return alias, nil
+}
+
+func (m *Repository) CheckHealth(ctx context.Context) error {
+	return m.record(Call{Method: "CheckHealth"})
+}
+
+func (m *Repository) CreateTemplate(ctx context.Context) error {
+	return m.record(Call{Method: "CreateTemplate"})
+}
+
+func (m *Repository) CreateLifecyclePolicy(ctx context.Context, name string) error {
+	return m.record(Call{Method: "CreateLifecyclePolicy", ID: name})
+}
+
+func (m *Repository) VerifySetup(ctx context.Context) error {
+	return m.record(Call{Method: "VerifySetup"})
+}
+
+func (m *Repository) SwapAlias(ctx context.Context, from, to string) error {
+	return m.record(Call{Method: "SwapAlias", Index: to, ID: from})
+}
+
+func (m *Repository) Rollover(ctx context.Context, alias string) error {
+	return m.record(Call{Method: "Rollover", Index: alias})
+}
+
+func (m *Repository) Close() error {
+	return m.record(Call{Method: "Close"})
+}
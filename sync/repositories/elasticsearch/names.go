@@ -0,0 +1,52 @@
+package elasticsearch
+
+import "strings"
+
+// IndexNames builds the names of an environment's indices, aliases and
+// lifecycle policy from es.index_prefix, joining their parts with
+// es.index_separator
+type IndexNames struct {
+	Environment string
+	Prefix      string
+	Separator   string
+}
+
+func (n IndexNames) join(parts ...string) string {
+	return strings.Join(parts, n.Separator)
+}
+
+// Alias returns the name entity's indices and aliases start with
+func (n IndexNames) Alias(entity string) string {
+	return n.join(n.Prefix, entity)
+}
+
+// WriteAlias returns the alias entity's writes always go through. It points
+// at exactly one index.
+func (n IndexNames) WriteAlias(entity string) string {
+	return n.join(n.Alias(entity), "write")
+}
+
+// ReadAlias returns the alias entity's reads go through. It may span several
+// indices while a reindex or rollover is in progress.
+func (n IndexNames) ReadAlias(entity string) string {
+	return n.join(n.Alias(entity), "read")
+}
+
+// IndexPattern matches every index of entity in the environment; the
+// template applies only to these, so environments sharing a cluster never
+// pick up each other's settings.
+func (n IndexNames) IndexPattern(entity string) string {
+	return n.join(n.Environment, n.Alias(entity)) + "-*"
+}
+
+// BootstrapIndex is the first index behind entity's write alias. ILM
+// rollover only works on indices whose names end in "-" and an incrementing
+// number, so the counter is appended with "-" whatever the separator.
+func (n IndexNames) BootstrapIndex(entity string) string {
+	return n.join(n.Environment, n.Alias(entity)) + "-000001"
+}
+
+// LifecyclePolicy returns the ILM policy attached to the prefix's indices
+func (n IndexNames) LifecyclePolicy() string {
+	return n.join(n.Prefix, "policy")
+}
@@ -13,11 +13,21 @@ import (
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
 )
 
 // ErrInvalidConfig represents a configuration error
 var ErrInvalidConfig = fmt.Errorf("invalid elasticsearch configuration")
 
+// categoriesEntity is the entity aliases are swapped for and the default
+// of Config.Entities
+const categoriesEntity = "categories"
+
+// templateName is the name of entity's index template
+func templateName(entity string) string {
+	return entity + "-template"
+}
+
 // Config holds Elasticsearch client configuration
 type Config struct {
 	Addresses      []string
@@ -29,6 +39,49 @@ type Config struct {
 	MaxConns       int
 	RequestTimeout time.Duration
 	GzipEnabled    bool
+
+	// BulkTimeout and SearchTimeout override RequestTimeout for bulk
+	// requests, including delete by query, and for searches. Zero falls
+	// back to RequestTimeout.
+	BulkTimeout   time.Duration
+	SearchTimeout time.Duration
+
+	// IndexExistsTTL is how long IndexExists and WriteIndex results are
+	// cached; zero disables the caches
+	IndexExistsTTL time.Duration
+
+	// Refresh is the refresh policy of index, update and delete requests
+	// and BulkRefresh that of bulk requests: "true", "false" or
+	// "wait_for". Empty defaults to wait_for and false, since refreshing
+	// after every bulk request throttles ingestion.
+	Refresh     string
+	BulkRefresh string
+
+	// IndexTemplatePath points at the index template JSON; empty uses the
+	// embedded default
+	IndexTemplatePath string
+
+	// Environment prefixes index names and the template's index pattern
+	Environment string
+	// IndexPrefix follows the environment in index names and starts alias
+	// names, e.g. digital-discovery-categories-write
+	IndexPrefix string
+	// IndexSeparator joins the parts of index, alias and policy names
+	IndexSeparator string
+	// Entities are the entities written to; each gets its own template,
+	// aliases and bootstrap index. Empty means categories alone.
+	Entities []string
+
+	// ShardCount and ReplicaCount set the template's index settings. Zero
+	// shards means the default of 1; zero replicas is honoured so single
+	// node clusters can report green.
+	ShardCount   int
+	ReplicaCount int
+
+	// Startup is how long NewRepository waits for the cluster to become
+	// reachable, and OnStartupRetry is told about each failed attempt
+	Startup        utils.WaitPolicy
+	OnStartupRetry func(attempt int, wait time.Duration, err error)
 }
 
 // Validate checks if the configuration is valid
@@ -45,30 +98,85 @@ func (c *Config) Validate() error {
 	if c.RequestTimeout == 0 {
 		c.RequestTimeout = 30 * time.Second // default timeout
 	}
+	if c.BulkTimeout <= 0 {
+		c.BulkTimeout = c.RequestTimeout
+	}
+	if c.SearchTimeout <= 0 {
+		c.SearchTimeout = c.RequestTimeout
+	}
+	if c.Refresh == "" {
+		c.Refresh = "wait_for"
+	}
+	if c.BulkRefresh == "" {
+		c.BulkRefresh = "false"
+	}
+	for _, refresh := range []string{c.Refresh, c.BulkRefresh} {
+		switch refresh {
+		case "true", "false", "wait_for":
+		default:
+			return fmt.Errorf("%w: invalid refresh policy %q", ErrInvalidConfig, refresh)
+		}
+	}
+	if c.ShardCount <= 0 {
+		c.ShardCount = 1
+	}
+	if c.ReplicaCount < 0 {
+		return fmt.Errorf("%w: replica count cannot be negative", ErrInvalidConfig)
+	}
+	if c.Environment == "" {
+		c.Environment = "development"
+	}
+	if c.IndexPrefix == "" {
+		c.IndexPrefix = "digital-discovery"
+	}
+	if c.IndexSeparator == "" {
+		c.IndexSeparator = "-"
+	}
+	if len(c.Entities) == 0 {
+		c.Entities = []string{categoriesEntity}
+	}
 	return nil
 }
 
+// Names returns the names of the configured environment's indices, aliases
+// and lifecycle policy
+func (c *Config) Names() IndexNames {
+	return IndexNames{Environment: c.Environment, Prefix: c.IndexPrefix, Separator: c.IndexSeparator}
+}
+
 // Repository defines the interface for Elasticsearch operations
 type Repository interface {
 	// Index operations
 	Index(ctx context.Context, index, id string, body io.Reader) error
 	Update(ctx context.Context, index, id string, body io.Reader) error
 	Delete(ctx context.Context, index, id string) error
+	DeleteByQuery(ctx context.Context, index string, query interface{}) (int, error)
 	Search(ctx context.Context, index string, query interface{}) ([]json.RawMessage, error)
+	SearchPage(ctx context.Context, index string, query interface{}) (*SearchResult, error)
 	Bulk(ctx context.Context, body io.Reader) error
+	BulkItems(ctx context.Context, body io.Reader) ([]BulkItem, error)
 	Ping(ctx context.Context) error
 	IndexExists(ctx context.Context, index string) (bool, error)
+	WriteIndex(ctx context.Context, alias string) (string, error)
 
 	// Setup and maintenance
 	CheckHealth(ctx context.Context) error
 	CreateTemplate(ctx context.Context) error
 	CreateLifecyclePolicy(ctx context.Context, name string) error
 	VerifySetup(ctx context.Context) error
+	SwapAlias(ctx context.Context, from, to string) error
+	Rollover(ctx context.Context, alias string) error
 
 	// Cleanup
 	Close() error
 }
 
+// SearchResult is a page of search hits along with the total match count
+type SearchResult struct {
+	Total int64
+	Docs  []json.RawMessage
+}
+
 // Operation represents a bulk operation
 type Operation struct {
 	Action string
@@ -79,8 +187,11 @@ type Operation struct {
 
 // esRepository implements the Repository interface
 type esRepository struct {
-	client *elasticsearch.Client
-	config *Config
+	client   *elasticsearch.Client
+	config   *Config
+	template map[string]interface{}
+	exists   *existsCache
+	writes   *writeIndexCache
 }
 
 // NewRepository creates a new Elasticsearch repository
@@ -93,6 +204,11 @@ func NewRepository(cfg *Config) (Repository, error) {
 		return nil, err
 	}
 
+	template, err := LoadIndexTemplate(cfg.IndexTemplatePath)
+	if err != nil {
+		return nil, err
+	}
+
 	transport := &http.Transport{
 		MaxIdleConnsPerHost: cfg.MaxConns,
 		IdleConnTimeout:     90 * time.Second,
@@ -120,15 +236,20 @@ func NewRepository(cfg *Config) (Repository, error) {
 	}
 
 	repo := &esRepository{
-		client: client,
-		config: cfg,
-	}
-
-	// Verify connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := repo.CheckHealth(ctx); err != nil {
+		client:   client,
+		config:   cfg,
+		template: template,
+		exists:   newExistsCache(cfg.IndexExistsTTL),
+		writes:   newWriteIndexCache(cfg.IndexExistsTTL),
+	}
+
+	// Verify connection, waiting for a cluster that is still starting
+	err = utils.WaitFor(context.Background(), cfg.Startup, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return repo.CheckHealth(ctx)
+	}, cfg.OnStartupRetry)
+	if err != nil {
 		return nil, fmt.Errorf("failed to connect to elasticsearch: %w", err)
 	}
 
@@ -144,7 +265,7 @@ func (r *esRepository) Index(ctx context.Context, index, id string, body io.Read
 		Index:      index,
 		DocumentID: id,
 		Body:       body,
-		Refresh:    "true",
+		Refresh:    r.config.Refresh,
 		Timeout:    r.config.RequestTimeout,
 	}
 
@@ -156,6 +277,9 @@ func (r *esRepository) Index(ctx context.Context, index, id string, body io.Read
 
 	if res.IsError() {
 		bodyBytes, _ := io.ReadAll(res.Body)
+		if blockErr := clusterBlockError(res.StatusCode, index, bodyBytes); blockErr != nil {
+			return blockErr
+		}
 		return fmt.Errorf("index error: status=%s body=%s", res.Status(), string(bodyBytes))
 	}
 	return nil
@@ -166,6 +290,7 @@ func (r *esRepository) Update(ctx context.Context, index, id string, body io.Rea
 		Index:      index,
 		DocumentID: id,
 		Body:       body,
+		Refresh:    r.config.Refresh,
 		Timeout:    r.config.RequestTimeout,
 	}
 
@@ -176,7 +301,11 @@ func (r *esRepository) Update(ctx context.Context, index, id string, body io.Rea
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("update error: %s", res.String())
+		bodyBytes, _ := io.ReadAll(res.Body)
+		if blockErr := clusterBlockError(res.StatusCode, index, bodyBytes); blockErr != nil {
+			return blockErr
+		}
+		return fmt.Errorf("update error: status=%s body=%s", res.Status(), string(bodyBytes))
 	}
 	return nil
 }
@@ -185,6 +314,7 @@ func (r *esRepository) Delete(ctx context.Context, index, id string) error {
 	req := esapi.DeleteRequest{
 		Index:      index,
 		DocumentID: id,
+		Refresh:    r.config.Refresh,
 		Timeout:    r.config.RequestTimeout,
 	}
 
@@ -195,28 +325,57 @@ func (r *esRepository) Delete(ctx context.Context, index, id string) error {
 	defer res.Body.Close()
 
 	if res.IsError() && res.StatusCode != 404 {
-		return fmt.Errorf("delete error: %s", res.String())
+		bodyBytes, _ := io.ReadAll(res.Body)
+		if blockErr := clusterBlockError(res.StatusCode, index, bodyBytes); blockErr != nil {
+			return blockErr
+		}
+		return fmt.Errorf("delete error: status=%s body=%s", res.Status(), string(bodyBytes))
 	}
 	return nil
 }
 
-func (r *esRepository) Bulk(ctx context.Context, body io.Reader) error {
-	req := esapi.BulkRequest{
-		Body:    body,
-		Refresh: "true",
-		Timeout: r.config.RequestTimeout,
+// DeleteByQuery deletes every document in index matching query and returns
+// how many were deleted. Documents changed while the request runs are
+// skipped rather than aborting it, so a concurrent sync always wins.
+func (r *esRepository) DeleteByQuery(ctx context.Context, index string, query interface{}) (int, error) {
+	queryBody, err := json.Marshal(query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	refresh := true
+	req := esapi.DeleteByQueryRequest{
+		Index:     []string{index},
+		Body:      bytes.NewReader(queryBody),
+		Conflicts: "proceed",
+		Refresh:   &refresh,
+		Timeout:   r.config.BulkTimeout,
 	}
 
 	res, err := req.Do(ctx, r.client)
 	if err != nil {
-		return fmt.Errorf("failed to execute bulk request: %w", err)
+		return 0, fmt.Errorf("failed to execute delete by query request: %w", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("bulk error: %s", res.String())
+		return 0, fmt.Errorf("delete by query error: %s", res.String())
 	}
-	return nil
+
+	var result struct {
+		Deleted          int               `json:"deleted"`
+		VersionConflicts int               `json:"version_conflicts"`
+		Failures         []json.RawMessage `json:"failures"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to parse delete by query response: %w", err)
+	}
+
+	if len(result.Failures) > 0 {
+		return result.Deleted, fmt.Errorf("delete by query partially failed: %d failures, first: %s",
+			len(result.Failures), result.Failures[0])
+	}
+	return result.Deleted, nil
 }
 
 func (r *esRepository) CheckHealth(ctx context.Context) error {
@@ -235,61 +394,23 @@ func (r *esRepository) CheckHealth(ctx context.Context) error {
 	return nil
 }
 
+// CreateTemplate puts the index template of every configured entity and
+// bootstraps the entity's write alias
 func (r *esRepository) CreateTemplate(ctx context.Context) error {
-	template := map[string]interface{}{
-		"index_patterns": []string{"development-digital-discovery-categories-*"},
-		"priority":       500, // Add high priority to avoid conflicts
-		"template": map[string]interface{}{
-			"settings": map[string]interface{}{
-				"number_of_shards":   1,
-				"number_of_replicas": 1,
-			},
-			"mappings": map[string]interface{}{
-				"properties": map[string]interface{}{
-					"id": map[string]interface{}{
-						"type": "keyword",
-					},
-					"name": map[string]interface{}{
-						"type": "text",
-						"fields": map[string]interface{}{
-							"keyword": map[string]interface{}{
-								"type":         "keyword",
-								"ignore_above": 256,
-							},
-						},
-					},
-					"description": map[string]interface{}{
-						"type": "text",
-					},
-					"status": map[string]interface{}{
-						"type": "keyword",
-					},
-					"sync_status": map[string]interface{}{
-						"type": "keyword",
-					},
-					"last_sync": map[string]interface{}{
-						"type": "date",
-					},
-					"created_at": map[string]interface{}{
-						"type": "date",
-					},
-					"updated_at": map[string]interface{}{
-						"type": "date",
-					},
-				},
-			},
-		},
-		// Add metadata
-		"version": 1,
-		"_meta": map[string]interface{}{
-			"description": "Template for digital discovery categories",
-			"application": "digital-discovery",
-		},
+	for _, entity := range r.config.Entities {
+		if err := r.createTemplate(ctx, entity); err != nil {
+			return fmt.Errorf("%s: %w", entity, err)
+		}
 	}
+	return nil
+}
+
+func (r *esRepository) createTemplate(ctx context.Context, entity string) error {
+	template := renderTemplate(r.template, r.config, entity)
 
 	// Delete existing template if it exists
 	deleteRes, err := r.client.Indices.DeleteIndexTemplate(
-		"categories-template",
+		templateName(entity),
 		r.client.Indices.DeleteIndexTemplate.WithContext(ctx),
 	)
 	if err != nil && !strings.Contains(err.Error(), "404") {
@@ -301,7 +422,7 @@ func (r *esRepository) CreateTemplate(ctx context.Context) error {
 
 	// Create new template
 	res, err := r.client.Indices.PutIndexTemplate(
-		"categories-template",
+		templateName(entity),
 		esutil.NewJSONReader(template),
 		r.client.Indices.PutIndexTemplate.WithContext(ctx),
 	)
@@ -315,24 +436,41 @@ func (r *esRepository) CreateTemplate(ctx context.Context) error {
 		return fmt.Errorf("template creation failed: status=%s body=%s", res.Status(), body)
 	}
 
-	// Create initial index
-	initialIndex := fmt.Sprintf("development-digital-discovery-categories-%s", time.Now().Format("2006-01"))
-	if err := r.createInitialIndex(ctx, initialIndex); err != nil {
-		return fmt.Errorf("failed to create initial index: %w", err)
-	}
+	return r.bootstrap(ctx, entity)
+}
 
-	// Create alias
-	if err := r.createAlias(ctx, initialIndex); err != nil {
-		return fmt.Errorf("failed to create alias: %w", err)
+// bootstrap creates entity's first rollover index unless its write alias
+// already exists, in which case ILM owns the index sequence from here on
+func (r *esRepository) bootstrap(ctx context.Context, entity string) error {
+	exists, err := r.aliasExists(ctx, r.config.Names().WriteAlias(entity))
+	if err != nil {
+		return fmt.Errorf("failed to check write alias: %w", err)
+	}
+	if !exists {
+		if err := r.createInitialIndex(ctx, entity); err != nil {
+			return fmt.Errorf("failed to create initial index: %w", err)
+		}
 	}
-
 	return nil
 }
 
-// Helper function to create initial index
-func (r *esRepository) createInitialIndex(ctx context.Context, indexName string) error {
+// Helper function to create entity's initial rollover index with both
+// aliases attached, the write alias flagged as the write index
+func (r *esRepository) createInitialIndex(ctx context.Context, entity string) error {
+	names := r.config.Names()
+	indexName := names.BootstrapIndex(entity)
+	body := map[string]interface{}{
+		"aliases": map[string]interface{}{
+			names.WriteAlias(entity): map[string]interface{}{
+				"is_write_index": true,
+			},
+			names.ReadAlias(entity): map[string]interface{}{},
+		},
+	}
+
 	createRes, err := r.client.Indices.Create(
 		indexName,
+		r.client.Indices.Create.WithBody(esutil.NewJSONReader(body)),
 		r.client.Indices.Create.WithContext(ctx),
 	)
 	if err != nil {
@@ -340,32 +478,120 @@ func (r *esRepository) createInitialIndex(ctx context.Context, indexName string)
 	}
 	defer createRes.Body.Close()
 
-	// If index already exists (400 error), that's fine
-	if createRes.IsError() && createRes.StatusCode != 400 {
-		body, _ := io.ReadAll(createRes.Body)
-		return fmt.Errorf("index creation failed: status=%s body=%s", createRes.Status(), body)
+	// If index already exists (400 error), attach the aliases to it instead
+	if createRes.IsError() {
+		if createRes.StatusCode != 400 {
+			body, _ := io.ReadAll(createRes.Body)
+			return fmt.Errorf("index creation failed: status=%s body=%s", createRes.Status(), body)
+		}
+		if err := r.createAlias(ctx, entity, indexName); err != nil {
+			return fmt.Errorf("failed to create alias: %w", err)
+		}
+	}
+
+	r.exists.clear()
+	return r.waitForIndex(ctx, indexName)
+}
+
+// waitForIndex waits until index's primary shards are allocated, so writes
+// through its aliases don't fail right after it was created
+func (r *esRepository) waitForIndex(ctx context.Context, index string) error {
+	res, err := r.client.Cluster.Health(
+		r.client.Cluster.Health.WithContext(ctx),
+		r.client.Cluster.Health.WithIndex(index),
+		r.client.Cluster.Health.WithWaitForStatus("yellow"),
+		r.client.Cluster.Health.WithTimeout(r.config.RequestTimeout),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to wait for index %s: %w", index, err)
 	}
+	defer res.Body.Close()
 
-	// Wait for index to be ready
-	time.Sleep(2 * time.Second)
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("index %s not ready: status=%s body=%s", index, res.Status(), body)
+	}
+
+	var health struct {
+		Status   string `json:"status"`
+		TimedOut bool   `json:"timed_out"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		return fmt.Errorf("failed to decode cluster health: %w", err)
+	}
+	if health.TimedOut {
+		return fmt.Errorf("index %s not ready after %s: status=%s", index, r.config.RequestTimeout, health.Status)
+	}
 	return nil
 }
 
-// Helper function to create alias
-func (r *esRepository) createAlias(ctx context.Context, indexName string) error {
-	aliasBody := map[string]interface{}{
-		"actions": []map[string]interface{}{
-			{
-				"add": map[string]interface{}{
-					"index": indexName,
-					"alias": "digital-discovery-categories",
-				},
-			},
-		},
+// aliasExists reports whether an alias currently points at any index
+func (r *esRepository) aliasExists(ctx context.Context, alias string) (bool, error) {
+	res, err := r.client.Indices.ExistsAlias(
+		[]string{alias},
+		r.client.Indices.ExistsAlias.WithContext(ctx),
+	)
+	if err != nil {
+		return false, err
 	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case 200:
+		return true, nil
+	case 404:
+		return false, nil
+	default:
+		return false, fmt.Errorf("alias check failed: %s", res.Status())
+	}
+}
+
+// Helper function to create entity's write and read aliases
+func (r *esRepository) createAlias(ctx context.Context, entity, indexName string) error {
+	return r.updateAliases(ctx, initialAliasActions(r.config.Names(), entity, indexName), "alias creation")
+}
+
+// SwapAlias atomically moves the write alias from one index to another and
+// adds the new index to the read alias, so readers see both indices while
+// the old one is drained or reindexed.
+func (r *esRepository) SwapAlias(ctx context.Context, from, to string) error {
+	if from == "" || to == "" {
+		return fmt.Errorf("source and target index cannot be empty")
+	}
+	if from == to {
+		return fmt.Errorf("source and target index must differ: %s", from)
+	}
+	return r.updateAliases(ctx, swapAliasActions(r.config.Names(), categoriesEntity, from, to), "alias swap")
+}
+
+// Rollover manually rolls the given write alias over to a new index,
+// without waiting for the ILM conditions to be met.
+func (r *esRepository) Rollover(ctx context.Context, alias string) error {
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+
+	res, err := r.client.Indices.Rollover(
+		alias,
+		r.client.Indices.Rollover.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to execute rollover request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("rollover failed: status=%s body=%s", res.Status(), body)
+	}
+	r.exists.clear()
+	r.writes.clear()
+	return nil
+}
 
+func (r *esRepository) updateAliases(ctx context.Context, body map[string]interface{}, action string) error {
 	aliasRes, err := r.client.Indices.UpdateAliases(
-		esutil.NewJSONReader(aliasBody),
+		esutil.NewJSONReader(body),
 		r.client.Indices.UpdateAliases.WithContext(ctx),
 	)
 	if err != nil {
@@ -375,12 +601,63 @@ func (r *esRepository) createAlias(ctx context.Context, indexName string) error
 
 	if aliasRes.IsError() {
 		body, _ := io.ReadAll(aliasRes.Body)
-		return fmt.Errorf("alias creation failed: status=%s body=%s", aliasRes.Status(), body)
+		return fmt.Errorf("%s failed: status=%s body=%s", action, aliasRes.Status(), body)
 	}
 
+	r.writes.clear()
 	return nil
 }
 
+// initialAliasActions points both of entity's write and read aliases at a
+// freshly created index.
+func initialAliasActions(names IndexNames, entity, indexName string) map[string]interface{} {
+	return map[string]interface{}{
+		"actions": []map[string]interface{}{
+			{
+				"add": map[string]interface{}{
+					"index":          indexName,
+					"alias":          names.WriteAlias(entity),
+					"is_write_index": true,
+				},
+			},
+			{
+				"add": map[string]interface{}{
+					"index": indexName,
+					"alias": names.ReadAlias(entity),
+				},
+			},
+		},
+	}
+}
+
+// swapAliasActions builds the _aliases request that moves entity's write
+// alias from one index to another in a single atomic call.
+func swapAliasActions(names IndexNames, entity, from, to string) map[string]interface{} {
+	return map[string]interface{}{
+		"actions": []map[string]interface{}{
+			{
+				"remove": map[string]interface{}{
+					"index": from,
+					"alias": names.WriteAlias(entity),
+				},
+			},
+			{
+				"add": map[string]interface{}{
+					"index":          to,
+					"alias":          names.WriteAlias(entity),
+					"is_write_index": true,
+				},
+			},
+			{
+				"add": map[string]interface{}{
+					"index": to,
+					"alias": names.ReadAlias(entity),
+				},
+			},
+		},
+	}
+}
+
 func (r *esRepository) CreateLifecyclePolicy(ctx context.Context, name string) error {
 	// First check if policy exists
 	existsRes, err := r.client.ILM.GetLifecycle(
@@ -444,9 +721,19 @@ func (r *esRepository) VerifySetup(ctx context.Context) error {
 		return fmt.Errorf("cluster is not healthy: %s", healthRes.Status())
 	}
 
-	// Check template
+	for _, entity := range r.config.Entities {
+		if err := r.verifyEntity(ctx, entity); err != nil {
+			return fmt.Errorf("%s: %w", entity, err)
+		}
+	}
+	return nil
+}
+
+// verifyEntity checks entity's template exists and bootstraps its rollover
+// index if the write alias doesn't exist yet
+func (r *esRepository) verifyEntity(ctx context.Context, entity string) error {
 	templateRes, err := r.client.Indices.GetIndexTemplate(
-		r.client.Indices.GetIndexTemplate.WithName("categories-template"),
+		r.client.Indices.GetIndexTemplate.WithName(templateName(entity)),
 		r.client.Indices.GetIndexTemplate.WithContext(ctx),
 	)
 	if err != nil {
@@ -458,68 +745,7 @@ func (r *esRepository) VerifySetup(ctx context.Context) error {
 		return fmt.Errorf("template verification failed: %s", templateRes.Status())
 	}
 
-	// Check if current month's index exists
-	currentMonth := time.Now().Format("2006-01")
-	currentIndex := fmt.Sprintf("development-digital-discovery-categories-%s", currentMonth)
-
-	// Try to create the index if it doesn't exist
-	createRes, err := r.client.Indices.Create(
-		currentIndex,
-		r.client.Indices.Create.WithContext(ctx),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create index: %w", err)
-	}
-	defer createRes.Body.Close()
-
-	// If index already exists (400 error), that's fine
-	if createRes.IsError() && createRes.StatusCode != 400 {
-		body, _ := io.ReadAll(createRes.Body)
-		return fmt.Errorf("index creation failed: status=%s body=%s", createRes.Status(), body)
-	}
-
-	// Wait for index to be ready
-	time.Sleep(2 * time.Second)
-
-	// Check if alias exists
-	aliasRes, err := r.client.Indices.GetAlias(
-		r.client.Indices.GetAlias.WithName("digital-discovery-categories"),
-		r.client.Indices.GetAlias.WithContext(ctx),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to verify alias: %w", err)
-	}
-	defer aliasRes.Body.Close()
-
-	if aliasRes.IsError() {
-		// Try to create the alias if it doesn't exist
-		aliasBody := map[string]interface{}{
-			"actions": []map[string]interface{}{
-				{
-					"add": map[string]interface{}{
-						"index": currentIndex,
-						"alias": "digital-discovery-categories",
-					},
-				},
-			},
-		}
-
-		aliasCreateRes, err := r.client.Indices.UpdateAliases(
-			esutil.NewJSONReader(aliasBody),
-			r.client.Indices.UpdateAliases.WithContext(ctx),
-		)
-		if err != nil {
-			return fmt.Errorf("failed to create alias: %w", err)
-		}
-		defer aliasCreateRes.Body.Close()
-
-		if aliasCreateRes.IsError() {
-			body, _ := io.ReadAll(aliasCreateRes.Body)
-			return fmt.Errorf("alias creation failed: status=%s body=%s", aliasCreateRes.Status(), body)
-		}
-	}
-
-	return nil
+	return r.bootstrap(ctx, entity)
 }
 
 func (r *esRepository) Close() error {
@@ -529,6 +755,16 @@ func (r *esRepository) Close() error {
 
 // Search executes a search query in Elasticsearch
 func (r *esRepository) Search(ctx context.Context, index string, query interface{}) ([]json.RawMessage, error) {
+	result, err := r.SearchPage(ctx, index, query)
+	if err != nil {
+		return nil, err
+	}
+	return result.Docs, nil
+}
+
+// SearchPage runs query and also returns the total number of matches, for
+// callers paginating with from/size
+func (r *esRepository) SearchPage(ctx context.Context, index string, query interface{}) (*SearchResult, error) {
 	// Convert query to JSON
 	queryBody, err := json.Marshal(query)
 	if err != nil {
@@ -538,7 +774,7 @@ func (r *esRepository) Search(ctx context.Context, index string, query interface
 	req := esapi.SearchRequest{
 		Index:   []string{index},
 		Body:    bytes.NewReader(queryBody),
-		Timeout: r.config.RequestTimeout,
+		Timeout: r.config.SearchTimeout,
 	}
 
 	res, err := req.Do(ctx, r.client)
@@ -554,6 +790,9 @@ func (r *esRepository) Search(ctx context.Context, index string, query interface
 	// Parse response
 	var result struct {
 		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
 			Hits []struct {
 				Source json.RawMessage `json:"_source"`
 			} `json:"hits"`
@@ -570,7 +809,7 @@ func (r *esRepository) Search(ctx context.Context, index string, query interface
 		docs = append(docs, hit.Source)
 	}
 
-	return docs, nil
+	return &SearchResult{Total: result.Hits.Total.Value, Docs: docs}, nil
 }
 
 func (r *esRepository) Ping(ctx context.Context) error {
@@ -588,10 +827,31 @@ func (r *esRepository) Ping(ctx context.Context) error {
 	return nil
 }
 
+// IndexExists reports whether an index or alias exists, answering from the
+// exists cache while its result is fresh
 func (r *esRepository) IndexExists(ctx context.Context, index string) (bool, error) {
-	res, err := r.client.Indices.Exists([]string{index})
+	if exists, ok := r.exists.get(index); ok {
+		return exists, nil
+	}
+
+	res, err := r.client.Indices.Exists(
+		[]string{index},
+		r.client.Indices.Exists.WithContext(ctx),
+	)
 	if err != nil {
 		return false, err
 	}
-	return res.StatusCode != 404, nil
+	defer res.Body.Close()
+
+	var exists bool
+	switch res.StatusCode {
+	case 200:
+		exists = true
+	case 404:
+		exists = false
+	default:
+		return false, fmt.Errorf("index exists check failed: %s", res.Status())
+	}
+	r.exists.set(index, exists)
+	return exists, nil
 }
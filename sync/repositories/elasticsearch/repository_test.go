@@ -0,0 +1,334 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// newTestRepository returns a repository talking to a cluster served by
+// handler
+func newTestRepository(t *testing.T, handler http.HandlerFunc) *esRepository {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The client refuses responses without the product header
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Addresses: []string{server.URL}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return &esRepository{
+		client: client,
+		config: cfg,
+		exists: newExistsCache(0),
+		writes: newWriteIndexCache(0),
+	}
+}
+
+func TestAliasesAndTemplateUseIndexPrefix(t *testing.T) {
+	cfg := &Config{Environment: "staging", IndexPrefix: "acme", IndexSeparator: "-", ShardCount: 1}
+
+	rendered := renderTemplate(map[string]interface{}{}, cfg, categoriesEntity)
+	patterns, _ := rendered["index_patterns"].([]string)
+	if len(patterns) != 1 || patterns[0] != "staging-acme-categories-*" {
+		t.Errorf("index_patterns = %v, want [staging-acme-categories-*]", patterns)
+	}
+	settings := rendered["template"].(map[string]interface{})["settings"].(map[string]interface{})
+	if got := settings["index.lifecycle.rollover_alias"]; got != "acme-categories-write" {
+		t.Errorf("rollover_alias = %v, want acme-categories-write", got)
+	}
+
+	if got := cfg.Names().BootstrapIndex(categoriesEntity); got != "staging-acme-categories-000001" {
+		t.Errorf("bootstrap index = %s, want staging-acme-categories-000001", got)
+	}
+
+	body, err := json.Marshal(initialAliasActions(cfg.Names(), categoriesEntity, "staging-acme-categories-000001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"actions":[` +
+		`{"add":{"alias":"acme-categories-write","index":"staging-acme-categories-000001","is_write_index":true}},` +
+		`{"add":{"alias":"acme-categories-read","index":"staging-acme-categories-000001"}}]}`
+	if string(body) != want {
+		t.Errorf("initial alias actions =\n%s\nwant\n%s", body, want)
+	}
+}
+
+func TestSwapAliasActions(t *testing.T) {
+	names := IndexNames{Prefix: "digital-discovery", Separator: "-"}
+	body, err := json.Marshal(swapAliasActions(names, categoriesEntity, "old-000001", "new-000002"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One request, so the write alias never points at zero or two indices,
+	// and the old index stays behind the read alias
+	want := `{"actions":[` +
+		`{"remove":{"alias":"digital-discovery-categories-write","index":"old-000001"}},` +
+		`{"add":{"alias":"digital-discovery-categories-write","index":"new-000002","is_write_index":true}},` +
+		`{"add":{"alias":"digital-discovery-categories-read","index":"new-000002"}}]}`
+	if string(body) != want {
+		t.Errorf("swap actions =\n%s\nwant\n%s", body, want)
+	}
+}
+
+func TestNamesUseIndexSeparator(t *testing.T) {
+	cfg := &Config{Environment: "staging", IndexPrefix: "acme", IndexSeparator: "_", ShardCount: 1}
+	names := cfg.Names()
+
+	want := map[string]string{
+		"write alias":      "acme_categories_write",
+		"read alias":       "acme_categories_read",
+		"index pattern":    "staging_acme_categories-*",
+		"bootstrap index":  "staging_acme_categories-000001",
+		"lifecycle policy": "acme_policy",
+	}
+	got := map[string]string{
+		"write alias":      names.WriteAlias(categoriesEntity),
+		"read alias":       names.ReadAlias(categoriesEntity),
+		"index pattern":    names.IndexPattern(categoriesEntity),
+		"bootstrap index":  names.BootstrapIndex(categoriesEntity),
+		"lifecycle policy": names.LifecyclePolicy(),
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s = %s, want %s", name, got[name], w)
+		}
+	}
+
+	rendered := renderTemplate(map[string]interface{}{}, cfg, categoriesEntity)
+	if patterns, _ := rendered["index_patterns"].([]string); len(patterns) != 1 || patterns[0] != want["index pattern"] {
+		t.Errorf("index_patterns = %v, want [%s]", patterns, want["index pattern"])
+	}
+	settings := rendered["template"].(map[string]interface{})["settings"].(map[string]interface{})
+	if settings["index.lifecycle.name"] != want["lifecycle policy"] {
+		t.Errorf("lifecycle name = %v, want %s", settings["index.lifecycle.name"], want["lifecycle policy"])
+	}
+	if settings["index.lifecycle.rollover_alias"] != want["write alias"] {
+		t.Errorf("rollover_alias = %v, want %s", settings["index.lifecycle.rollover_alias"], want["write alias"])
+	}
+}
+
+func TestTemplateAttachesLifecyclePolicy(t *testing.T) {
+	cfg := &Config{Environment: "staging", IndexPrefix: "digital-discovery", IndexSeparator: "-", ShardCount: 1}
+
+	rendered := renderTemplate(map[string]interface{}{}, cfg, categoriesEntity)
+
+	settings := rendered["template"].(map[string]interface{})["settings"].(map[string]interface{})
+	if got := settings["index.lifecycle.name"]; got != "digital-discovery-policy" {
+		t.Errorf("index.lifecycle.name = %v, want digital-discovery-policy", got)
+	}
+	if got := settings["index.lifecycle.rollover_alias"]; got != "digital-discovery-categories-write" {
+		t.Errorf("index.lifecycle.rollover_alias = %v, want digital-discovery-categories-write", got)
+	}
+}
+
+func TestCreateInitialIndexWaitsForHealth(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	var createBody string
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPut:
+			createBody = string(body)
+			w.Write([]byte(`{"acknowledged":true}`))
+		case strings.HasPrefix(r.URL.Path, "/_cluster/health/"):
+			if r.URL.Query().Get("wait_for_status") != "yellow" {
+				t.Errorf("health query = %s, want wait_for_status=yellow", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"status":"yellow","timed_out":false}`))
+		}
+	})
+
+	if err := repo.createInitialIndex(context.Background(), categoriesEntity); err != nil {
+		t.Fatalf("createInitialIndex: %v", err)
+	}
+
+	want := []string{
+		"PUT /development-digital-discovery-categories-000001",
+		"GET /_cluster/health/development-digital-discovery-categories-000001",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+	wantBody := `{"aliases":{"digital-discovery-categories-read":{},"digital-discovery-categories-write":{"is_write_index":true}}}`
+	if strings.TrimSpace(createBody) != wantBody {
+		t.Errorf("create body = %s, want %s", createBody, wantBody)
+	}
+}
+
+func TestCreateInitialIndexFailsWhenHealthTimesOut(t *testing.T) {
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/_cluster/health/") {
+			w.WriteHeader(http.StatusRequestTimeout)
+			w.Write([]byte(`{"status":"red","timed_out":true}`))
+			return
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	})
+
+	err := repo.createInitialIndex(context.Background(), categoriesEntity)
+
+	if err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Errorf("createInitialIndex error = %v, want the index reported not ready", err)
+	}
+}
+
+func TestCreateTemplateBootstrapsEveryEntity(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodHead:
+			// No write alias exists yet
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasPrefix(r.URL.Path, "/_cluster/health/"):
+			w.Write([]byte(`{"status":"green","timed_out":false}`))
+		default:
+			w.Write([]byte(`{"acknowledged":true}`))
+		}
+	})
+	repo.config.Entities = []string{"categories", "products"}
+
+	if err := repo.CreateTemplate(context.Background()); err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+
+	for _, want := range []string{
+		"PUT /_index_template/categories-template",
+		"PUT /development-digital-discovery-categories-000001",
+		"PUT /_index_template/products-template",
+		"PUT /development-digital-discovery-products-000001",
+	} {
+		found := false
+		for _, request := range requests {
+			found = found || request == want
+		}
+		if !found {
+			t.Errorf("no %s among %v", want, requests)
+		}
+	}
+}
+
+func TestRequestsUseTheirOperationTimeout(t *testing.T) {
+	timeouts := make(map[string]string)
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		timeouts[r.URL.Path] = r.URL.Query().Get("timeout")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_bulk"):
+			w.Write([]byte(`{"errors":false,"items":[]}`))
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			w.Write([]byte(`{"hits":{"total":{"value":0},"hits":[]}}`))
+		case strings.HasSuffix(r.URL.Path, "/_delete_by_query"):
+			w.Write([]byte(`{"deleted":0}`))
+		default:
+			w.Write([]byte(`{"result":"created"}`))
+		}
+	})
+	repo.config.RequestTimeout = time.Second
+	repo.config.BulkTimeout = 2 * time.Minute
+	repo.config.SearchTimeout = 5 * time.Second
+
+	ctx := context.Background()
+	if err := repo.Index(ctx, "categories-write", "1", strings.NewReader(`{}`)); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if err := repo.Bulk(ctx, strings.NewReader("{\"delete\":{\"_id\":\"1\"}}\n")); err != nil {
+		t.Fatalf("Bulk: %v", err)
+	}
+	if _, err := repo.DeleteByQuery(ctx, "categories-read", map[string]interface{}{}); err != nil {
+		t.Fatalf("DeleteByQuery: %v", err)
+	}
+	if _, err := repo.SearchPage(ctx, "categories-read", map[string]interface{}{}); err != nil {
+		t.Fatalf("SearchPage: %v", err)
+	}
+
+	want := map[string]string{
+		"/categories-write/_doc/1":          "1000ms",
+		"/_bulk":                            "120000ms",
+		"/categories-read/_delete_by_query": "120000ms",
+		"/categories-read/_search":          "5000ms",
+	}
+	if !reflect.DeepEqual(timeouts, want) {
+		t.Errorf("timeouts by path = %v, want %v", timeouts, want)
+	}
+}
+
+func TestRequestsUseTheirRefreshPolicy(t *testing.T) {
+	refresh := make(map[string]string)
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		refresh[r.Method+" "+r.URL.Path] = r.URL.Query().Get("refresh")
+		if strings.HasSuffix(r.URL.Path, "/_bulk") {
+			w.Write([]byte(`{"errors":false,"items":[]}`))
+			return
+		}
+		w.Write([]byte(`{"result":"created"}`))
+	})
+	repo.config.Refresh = "true"
+	repo.config.BulkRefresh = "wait_for"
+
+	ctx := context.Background()
+	if err := repo.Index(ctx, "categories-write", "1", strings.NewReader(`{}`)); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if err := repo.Update(ctx, "categories-write", "1", strings.NewReader(`{"doc":{}}`)); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := repo.Delete(ctx, "categories-write", "1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.BulkItems(ctx, strings.NewReader("{\"delete\":{\"_id\":\"1\"}}\n")); err != nil {
+		t.Fatalf("BulkItems: %v", err)
+	}
+
+	want := map[string]string{
+		"PUT /categories-write/_doc/1":     "true",
+		"POST /categories-write/_update/1": "true",
+		"DELETE /categories-write/_doc/1":  "true",
+		"POST /_bulk":                      "wait_for",
+	}
+	if !reflect.DeepEqual(refresh, want) {
+		t.Errorf("refresh by request = %v, want %v", refresh, want)
+	}
+}
+
+func TestConfigRefreshDefaults(t *testing.T) {
+	cfg := &Config{Addresses: []string{"http://localhost:9200"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if cfg.Refresh != "wait_for" || cfg.BulkRefresh != "false" {
+		t.Errorf("refresh = %q and bulk refresh = %q, want wait_for and false", cfg.Refresh, cfg.BulkRefresh)
+	}
+
+	cfg = &Config{Addresses: []string{"http://localhost:9200"}, BulkRefresh: "always"}
+	if err := cfg.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Validate() with bulk refresh always = %v, want ErrInvalidConfig", err)
+	}
+}
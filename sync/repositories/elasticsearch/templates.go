@@ -1,94 +1,91 @@
 package elasticsearch
 
 import (
+	_ "embed"
+	"encoding/json"
 	"fmt"
-	"strings"
-
-	"github.com/elastic/go-elasticsearch/v8"
+	"os"
 )
 
-type IndexTemplate struct {
-	client *elasticsearch.Client
+// defaultCategoryTemplate is used when no template file is configured
+//
+//go:embed templates/categories.json
+var defaultCategoryTemplate []byte
+
+// LoadIndexTemplate reads an index template from path, or the embedded
+// default when path is empty, and validates its shape.
+func LoadIndexTemplate(path string) (map[string]interface{}, error) {
+	raw := defaultCategoryTemplate
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read index template %s: %v", ErrInvalidConfig, path, err)
+		}
+		raw = data
+	}
+
+	var template map[string]interface{}
+	if err := json.Unmarshal(raw, &template); err != nil {
+		return nil, fmt.Errorf("%w: index template is not valid JSON: %v", ErrInvalidConfig, err)
+	}
+
+	if err := validateIndexTemplate(template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
 }
 
-func NewIndexTemplate(client *elasticsearch.Client) *IndexTemplate {
-	return &IndexTemplate{client: client}
+// validateIndexTemplate checks the fields CreateTemplate relies on. The
+// index pattern is not read from the file; it is derived from the
+// configured environment.
+func validateIndexTemplate(template map[string]interface{}) error {
+	body, ok := template["template"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: index template must define a template section", ErrInvalidConfig)
+	}
+
+	mappings, ok := body["mappings"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: index template must define mappings", ErrInvalidConfig)
+	}
+	if _, ok := mappings["properties"].(map[string]interface{}); !ok {
+		return fmt.Errorf("%w: index template mappings must define properties", ErrInvalidConfig)
+	}
+
+	return nil
 }
 
-func (it *IndexTemplate) CreateCategoryTemplate() error {
-	template := `{
-        "index_patterns": ["*-digital-discovery-categories-*"],
-        "template": {
-            "settings": {
-                "number_of_shards": 3,
-                "number_of_replicas": 1,
-                "refresh_interval": "1s",
-                "analysis": {
-                    "analyzer": {
-                        "custom_analyzer": {
-                            "type": "custom",
-                            "tokenizer": "standard",
-                            "filter": ["lowercase", "asciifolding"]
-                        }
-                    }
-                }
-            },
-            "mappings": {
-                "properties": {
-                    "id": {
-                        "type": "keyword"
-                    },
-                    "name": {
-                        "type": "text",
-                        "analyzer": "custom_analyzer",
-                        "fields": {
-                            "keyword": {
-                                "type": "keyword",
-                                "ignore_above": 256
-                            }
-                        }
-                    },
-                    "description": {
-                        "type": "text",
-                        "analyzer": "custom_analyzer"
-                    },
-                    "created_at": {
-                        "type": "date"
-                    },
-                    "updated_at": {
-                        "type": "date"
-                    },
-                    "version": {
-                        "type": "long"
-                    },
-                    "sync_status": {
-                        "type": "keyword"
-                    },
-                    "last_sync": {
-                        "type": "date"
-                    }
-                }
-            }
-        },
-        "priority": 100,
-        "version": 1,
-        "_meta": {
-            "description": "Template for category indices",
-            "service": "digital-discovery"
-        }
-    }`
+// renderTemplate returns a copy of the loaded template for entity with the
+// parts that come from configuration filled in: the entity's index pattern
+// in the environment, the shard and replica counts, and the ILM policy and
+// rollover alias.
+func renderTemplate(template map[string]interface{}, cfg *Config, entity string) map[string]interface{} {
+	result := make(map[string]interface{}, len(template))
+	for k, v := range template {
+		result[k] = v
+	}
+	result["index_patterns"] = []string{cfg.Names().IndexPattern(entity)}
 
-	resp, err := it.client.Indices.PutIndexTemplate(
-		"categories-template",
-		strings.NewReader(template),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create template: %w", err)
+	body := make(map[string]interface{})
+	if existing, ok := template["template"].(map[string]interface{}); ok {
+		for k, v := range existing {
+			body[k] = v
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.IsError() {
-		return fmt.Errorf("error creating template: %s", resp.String())
+	settings := make(map[string]interface{})
+	if existing, ok := body["settings"].(map[string]interface{}); ok {
+		for k, v := range existing {
+			settings[k] = v
+		}
 	}
-	return nil
+	settings["number_of_shards"] = cfg.ShardCount
+	settings["number_of_replicas"] = cfg.ReplicaCount
+	settings["index.lifecycle.name"] = cfg.Names().LifecyclePolicy()
+	settings["index.lifecycle.rollover_alias"] = cfg.Names().WriteAlias(entity)
+
+	body["settings"] = settings
+	result["template"] = body
+	return result
 }
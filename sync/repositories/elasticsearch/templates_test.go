@@ -0,0 +1,139 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIndexTemplate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "embedded default", path: ""},
+		{name: "file", path: write("valid.json", `{"template":{"mappings":{"properties":{"name":{"type":"keyword"}}}}}`)},
+		{name: "missing file", path: filepath.Join(dir, "missing.json"), wantErr: true},
+		{name: "not JSON", path: write("broken.json", `{"template":`), wantErr: true},
+		{name: "no properties", path: write("empty.json", `{"template":{"mappings":{}}}`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadIndexTemplate(tt.path)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("LoadIndexTemplate error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("LoadIndexTemplate error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestCreateTemplatePutsLoadedTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "template.json")
+	content := `{"priority":200,"template":{"settings":{"refresh_interval":"5s"},` +
+		`"mappings":{"properties":{"name":{"type":"keyword"}}}}}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	template, err := LoadIndexTemplate(path)
+	if err != nil {
+		t.Fatalf("LoadIndexTemplate: %v", err)
+	}
+
+	var put []byte
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/_index_template/categories-template" {
+			put, _ = io.ReadAll(r.Body)
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	})
+	repo.template = template
+	repo.config.ShardCount = 3
+
+	if err := repo.createTemplate(context.Background(), categoriesEntity); err != nil {
+		t.Fatalf("createTemplate: %v", err)
+	}
+
+	var body struct {
+		Priority      int      `json:"priority"`
+		IndexPatterns []string `json:"index_patterns"`
+		Template      struct {
+			Settings map[string]interface{} `json:"settings"`
+			Mappings struct {
+				Properties map[string]map[string]string `json:"properties"`
+			} `json:"mappings"`
+		} `json:"template"`
+	}
+	if err := json.Unmarshal(put, &body); err != nil {
+		t.Fatalf("decode PUT body %q: %v", put, err)
+	}
+
+	// The file's own parts are kept; the configured ones are filled in
+	if body.Priority != 200 || body.Template.Mappings.Properties["name"]["type"] != "keyword" {
+		t.Errorf("PUT body %s lost the file's priority or mappings", put)
+	}
+	if body.Template.Settings["refresh_interval"] != "5s" || body.Template.Settings["number_of_shards"] != float64(3) {
+		t.Errorf("settings = %v, want the file's refresh_interval and 3 shards", body.Template.Settings)
+	}
+	if len(body.IndexPatterns) != 1 || body.IndexPatterns[0] != "development-digital-discovery-categories-*" {
+		t.Errorf("index_patterns = %v, want the configured pattern", body.IndexPatterns)
+	}
+}
+
+func TestDefaultTemplateTakesShardsFromConfig(t *testing.T) {
+	template, err := LoadIndexTemplate("")
+	if err != nil {
+		t.Fatalf("LoadIndexTemplate: %v", err)
+	}
+	cfg := &Config{Addresses: []string{"http://localhost:9200"}, Environment: "production", ShardCount: 3, ReplicaCount: 2}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := renderTemplate(template, cfg, categoriesEntity)
+
+	settings := rendered["template"].(map[string]interface{})["settings"].(map[string]interface{})
+	if settings["number_of_shards"] != 3 || settings["number_of_replicas"] != 2 {
+		t.Errorf("shards = %v and replicas = %v, want 3 and 2", settings["number_of_shards"], settings["number_of_replicas"])
+	}
+	patterns, _ := rendered["index_patterns"].([]string)
+	if len(patterns) != 1 || patterns[0] != "production-digital-discovery-categories-*" {
+		t.Errorf("index_patterns = %v, want [production-digital-discovery-categories-*]", patterns)
+	}
+	// Rendering leaves the loaded template as it was, for the next entity
+	if _, ok := template["index_patterns"]; ok {
+		t.Error("renderTemplate wrote index_patterns into the loaded template")
+	}
+}
+
+func TestZeroReplicasReachTheTemplate(t *testing.T) {
+	cfg := &Config{Addresses: []string{"http://localhost:9200"}, ReplicaCount: 0}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := renderTemplate(map[string]interface{}{}, cfg, categoriesEntity)
+
+	settings := rendered["template"].(map[string]interface{})["settings"].(map[string]interface{})
+	if settings["number_of_replicas"] != 0 || settings["number_of_shards"] != 1 {
+		t.Errorf("replicas = %v and shards = %v, want 0 and the default 1",
+			settings["number_of_replicas"], settings["number_of_shards"])
+	}
+}
@@ -0,0 +1,112 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// writeIndexCache remembers which index each write alias resolves to for
+// a short TTL. Rollover moves the alias only every so often, so resolving
+// it on every write would be wasted requests. It shares IndexExistsTTL
+// with existsCache; zero disables it.
+type writeIndexCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]writeIndexEntry
+}
+
+type writeIndexEntry struct {
+	index   string
+	expires time.Time
+}
+
+func newWriteIndexCache(ttl time.Duration) *writeIndexCache {
+	return &writeIndexCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]writeIndexEntry),
+	}
+}
+
+func (c *writeIndexCache) get(alias string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[alias]
+	if !ok || !c.now().Before(entry.expires) {
+		return "", false
+	}
+	return entry.index, true
+}
+
+func (c *writeIndexCache) set(alias, index string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[alias] = writeIndexEntry{index: index, expires: c.now().Add(c.ttl)}
+}
+
+// clear drops every cached alias, after a rollover or alias update
+func (c *writeIndexCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]writeIndexEntry)
+}
+
+// WriteIndex returns the index writes through alias go to: the one marked
+// as its write index, or the only index it points at. A name that is an
+// index rather than an alias is returned as is.
+func (r *esRepository) WriteIndex(ctx context.Context, alias string) (string, error) {
+	if index, ok := r.writes.get(alias); ok {
+		return index, nil
+	}
+
+	res, err := r.client.Indices.GetAlias(
+		r.client.Indices.GetAlias.WithName(alias),
+		r.client.Indices.GetAlias.WithContext(ctx),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute get alias request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		r.writes.set(alias, alias)
+		return alias, nil
+	}
+	if res.IsError() {
+		return "", fmt.Errorf("get alias failed: %s", res.Status())
+	}
+
+	var indices map[string]struct {
+		Aliases map[string]struct {
+			IsWriteIndex *bool `json:"is_write_index"`
+		} `json:"aliases"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return "", fmt.Errorf("failed to decode get alias response: %w", err)
+	}
+
+	var index string
+	for name, entry := range indices {
+		isWrite := entry.Aliases[alias].IsWriteIndex
+		if isWrite != nil && *isWrite {
+			index = name
+			break
+		}
+		if isWrite == nil && len(indices) == 1 {
+			index = name
+		}
+	}
+	if index == "" {
+		return "", fmt.Errorf("alias %s has no write index", alias)
+	}
+
+	r.writes.set(alias, index)
+	return index, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/rendyspratama/digital-discovery/sync/models"
+)
+
+// collapseOperations reduces a batch to at most one operation per document,
+// with the same end state as applying the batch in order:
+//
+//   - a create replaces whatever came before it
+//   - an update after a create or delete becomes a create of the updated
+//     row, since the row is written whole either way
+//   - consecutive updates keep the last row, writing the union of the
+//     fields each changed
+//   - a delete replaces whatever came before it. Even after a create it is
+//     still sent: creates include snapshot reads, which overwrite documents
+//     that already exist, so the document may predate the batch.
+//
+// Documents keep the order in which they first appear in the batch.
+func collapseOperations(ops []models.CategoryOperation) []models.CategoryOperation {
+	type key struct{ entity, id string }
+
+	order := make([]key, 0, len(ops))
+	last := make(map[key]models.CategoryOperation, len(ops))
+	for _, op := range ops {
+		k := key{entityOf(&op), op.Payload.ID}
+		prev, seen := last[k]
+		if !seen {
+			order = append(order, k)
+			last[k] = op
+			continue
+		}
+
+		if op.Operation == models.OperationUpdate {
+			if prev.Operation == models.OperationUpdate {
+				op.Changed = mergeChanged(prev.Changed, op.Changed)
+			} else {
+				op.Operation = models.OperationCreate
+				op.Changed = nil
+			}
+		}
+		last[k] = op
+	}
+
+	collapsed := make([]models.CategoryOperation, 0, len(order))
+	for _, k := range order {
+		collapsed = append(collapsed, last[k])
+	}
+	return collapsed
+}
+
+// mergeChanged returns the fields changed by either of two updates, or nil
+// if either wrote the whole document
+func mergeChanged(a, b []string) []string {
+	if a == nil || b == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := make([]string, 0, len(a)+len(b))
+	for _, fields := range [][]string{a, b} {
+		for _, field := range fields {
+			if !seen[field] {
+				seen[field] = true
+				merged = append(merged, field)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
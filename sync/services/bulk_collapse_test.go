@@ -0,0 +1,68 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/sync/models"
+)
+
+func collapseOp(operation, id, name string, changed ...string) models.CategoryOperation {
+	return models.CategoryOperation{
+		Operation: operation,
+		Payload:   models.Category{ID: id, Name: name},
+		Changed:   changed,
+	}
+}
+
+func TestCollapseOperations(t *testing.T) {
+	const create, update, del = models.OperationCreate, models.OperationUpdate, models.OperationDelete
+
+	tests := []struct {
+		name string
+		ops  []models.CategoryOperation
+		want []models.CategoryOperation
+	}{
+		{
+			name: "create then delete keeps the delete",
+			ops:  []models.CategoryOperation{collapseOp(create, "1", "a"), collapseOp(del, "1", "")},
+			want: []models.CategoryOperation{collapseOp(del, "1", "")},
+		},
+		{
+			name: "delete then create keeps the create",
+			ops:  []models.CategoryOperation{collapseOp(del, "1", ""), collapseOp(create, "1", "b")},
+			want: []models.CategoryOperation{collapseOp(create, "1", "b")},
+		},
+		{
+			name: "create, delete, create keeps the last create",
+			ops:  []models.CategoryOperation{collapseOp(create, "1", "a"), collapseOp(del, "1", ""), collapseOp(create, "1", "c")},
+			want: []models.CategoryOperation{collapseOp(create, "1", "c")},
+		},
+		{
+			name: "update after create becomes a create",
+			ops:  []models.CategoryOperation{collapseOp(create, "1", "a"), collapseOp(update, "1", "b", "name")},
+			want: []models.CategoryOperation{collapseOp(create, "1", "b")},
+		},
+		{
+			name: "updates merge their changed fields",
+			ops:  []models.CategoryOperation{collapseOp(update, "1", "a", "name"), collapseOp(update, "1", "b", "description")},
+			want: []models.CategoryOperation{collapseOp(update, "1", "b", "description", "name")},
+		},
+		{
+			name: "documents keep their first position",
+			ops:  []models.CategoryOperation{collapseOp(create, "1", "a"), collapseOp(create, "2", "b"), collapseOp(del, "1", "")},
+			want: []models.CategoryOperation{collapseOp(del, "1", ""), collapseOp(create, "2", "b")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collapseOperations(tt.ops)
+			if !slices.EqualFunc(got, tt.want, func(a, b models.CategoryOperation) bool {
+				return a.Operation == b.Operation && a.Payload == b.Payload && slices.Equal(a.Changed, b.Changed)
+			}) {
+				t.Errorf("collapseOperations = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
+)
+
+// ConnectClient talks to the Kafka Connect REST API. It is created once and
+// shared across monitor ticks so connections are reused, and every request
+// is bounded by a timeout and retried on transport errors and 5xx responses.
+type ConnectClient struct {
+	baseURL      string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	metrics      *metrics.MetricsCollector
+	logger       logger.Logger
+}
+
+func NewConnectClient(cfg config.KafkaConnectConfig, metrics *metrics.MetricsCollector, logger logger.Logger) *ConnectClient {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	backoff := cfg.RetryBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	return &ConnectClient{
+		baseURL:      cfg.SinkConnector.URL,
+		httpClient:   &http.Client{Timeout: timeout},
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: backoff,
+		metrics:      metrics,
+		logger:       logger,
+	}
+}
+
+// ErrConnectorNotFound is returned when Kafka Connect has no such connector
+var ErrConnectorNotFound = errors.New("kafka connect connector not found")
+
+// Connector and task states reported by Kafka Connect
+const (
+	ConnectorStateRunning = "RUNNING"
+	ConnectorStateFailed  = "FAILED"
+)
+
+// ConnectorStatus is a connector's state and the state of each of its tasks
+type ConnectorStatus struct {
+	Name      string `json:"name"`
+	Connector struct {
+		State    string `json:"state"`
+		WorkerID string `json:"worker_id"`
+		Trace    string `json:"trace,omitempty"`
+	} `json:"connector"`
+	Tasks []ConnectorTaskStatus `json:"tasks"`
+}
+
+type ConnectorTaskStatus struct {
+	ID       int    `json:"id"`
+	State    string `json:"state"`
+	WorkerID string `json:"worker_id"`
+	// Trace is the stack trace of a failed task
+	Trace string `json:"trace,omitempty"`
+}
+
+// FailedTasks returns the tasks in the FAILED state
+func (s *ConnectorStatus) FailedTasks() []ConnectorTaskStatus {
+	var failed []ConnectorTaskStatus
+	for _, task := range s.Tasks {
+		if task.State == ConnectorStateFailed {
+			failed = append(failed, task)
+		}
+	}
+	return failed
+}
+
+// Failed reports whether the connector or any of its tasks has failed
+func (s *ConnectorStatus) Failed() bool {
+	return s.Connector.State == ConnectorStateFailed || len(s.FailedTasks()) > 0
+}
+
+// Running reports whether the connector and every one of its tasks is
+// RUNNING. A paused or unassigned connector isn't failed, but isn't doing
+// any work either.
+func (s *ConnectorStatus) Running() bool {
+	if s.Connector.State != ConnectorStateRunning {
+		return false
+	}
+	for _, task := range s.Tasks {
+		if task.State != ConnectorStateRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// ConnectorStatus returns the state of the named connector and its tasks,
+// and records how many tasks have failed
+func (c *ConnectClient) ConnectorStatus(ctx context.Context, name string) (*ConnectorStatus, error) {
+	var status ConnectorStatus
+	path := fmt.Sprintf("/connectors/%s/status", name)
+	if err := c.getJSON(ctx, "connector_status", path, &status); err != nil {
+		return nil, err
+	}
+
+	c.metrics.RecordConnectFailedTasks(name, len(status.FailedTasks()))
+	return &status, nil
+}
+
+// TraceSummary returns the first line of a Kafka Connect stack trace, which
+// names the exception
+func TraceSummary(trace string) string {
+	if i := strings.IndexByte(trace, '\n'); i >= 0 {
+		return trace[:i]
+	}
+	return trace
+}
+
+// RestartConnector restarts the named connector together with its tasks
+func (c *ConnectClient) RestartConnector(ctx context.Context, name string) error {
+	path := fmt.Sprintf("/connectors/%s/restart?includeTasks=true", name)
+	return c.request(ctx, http.MethodPost, "connector_restart", path, nil, nil)
+}
+
+// CreateConnector creates a connector with the given configuration
+func (c *ConnectClient) CreateConnector(ctx context.Context, name string, cfg map[string]string) error {
+	spec := map[string]interface{}{
+		"name":   name,
+		"config": cfg,
+	}
+	return c.request(ctx, http.MethodPost, "connector_create", "/connectors", spec, nil)
+}
+
+// getJSON GETs path and decodes the response into out
+func (c *ConnectClient) getJSON(ctx context.Context, endpoint, path string, out interface{}) error {
+	return c.request(ctx, http.MethodGet, endpoint, path, nil, out)
+}
+
+// request sends in as the JSON body, if set, and decodes the response into
+// out, if set, retrying failed attempts with a linear backoff
+func (c *ConnectClient) request(ctx context.Context, method, endpoint, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode Kafka Connect request: %w", err)
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retryBackoff * time.Duration(attempt)):
+			}
+		}
+
+		retryable, err := c.do(ctx, method, endpoint, path, body, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+
+		c.logger.WithError(ctx, err, "Kafka Connect request failed", map[string]interface{}{
+			"endpoint": endpoint,
+			"attempt":  attempt + 1,
+		})
+	}
+	return lastErr
+}
+
+// do performs a single request and reports whether a failure is worth
+// retrying
+func (c *ConnectClient) do(ctx context.Context, method, endpoint, path string, body []byte, out interface{}) (bool, error) {
+	start := time.Now()
+	outcome := "success"
+	defer func() {
+		c.metrics.RecordConnectRequest(endpoint, outcome, time.Since(start))
+	}()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		outcome = "error"
+		return false, fmt.Errorf("failed to build Kafka Connect request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		outcome = "error"
+		return ctx.Err() == nil, fmt.Errorf("kafka connect request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		outcome = "http_404"
+		return false, ErrConnectorNotFound
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		outcome = fmt.Sprintf("http_%d", resp.StatusCode)
+		return resp.StatusCode >= 500, fmt.Errorf("kafka connect returned %s: %s", resp.Status, body)
+	}
+
+	if out == nil {
+		return false, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		outcome = "error"
+		return false, fmt.Errorf("failed to decode Kafka Connect response: %w", err)
+	}
+	return false, nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+	"github.com/rendyspratama/digital-discovery/sync/utils/metrics"
+)
+
+// newTestConnectClient returns a client for url that retries quickly
+func newTestConnectClient(url string, maxRetries int, timeout time.Duration) *ConnectClient {
+	cfg := config.KafkaConnectConfig{
+		Timeout:      timeout,
+		MaxRetries:   maxRetries,
+		RetryBackoff: time.Millisecond,
+	}
+	cfg.SinkConnector.URL = url
+	return NewConnectClient(cfg, metrics.NewMetricsCollector(), logger.NewLogger("json"))
+}
+
+func TestConnectClientRetriesServerErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			http.Error(w, "worker unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"name": "es-sink", "connector": {"state": "RUNNING"}, "tasks": [{"id": 0, "state": "RUNNING"}]}`))
+	}))
+	defer server.Close()
+
+	status, err := newTestConnectClient(server.URL, 2, time.Second).ConnectorStatus(context.Background(), "es-sink")
+	if err != nil {
+		t.Fatalf("ConnectorStatus: %v", err)
+	}
+	if !status.Running() {
+		t.Errorf("status = %+v, want running", status)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("%d requests, want 2 failures and a success", n)
+	}
+}
+
+func TestConnectClientGivesUp(t *testing.T) {
+	t.Run("after max retries", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.Error(w, "worker unavailable", http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		if _, err := newTestConnectClient(server.URL, 2, time.Second).ConnectorStatus(context.Background(), "es-sink"); err == nil {
+			t.Error("ConnectorStatus succeeded against a failing server")
+		}
+		if n := requests.Load(); n != 3 {
+			t.Errorf("%d requests, want the first and 2 retries", n)
+		}
+	})
+
+	t.Run("on not found", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.NotFound(w, r)
+		}))
+		defer server.Close()
+
+		_, err := newTestConnectClient(server.URL, 2, time.Second).ConnectorStatus(context.Background(), "es-sink")
+		if !errors.Is(err, ErrConnectorNotFound) {
+			t.Errorf("ConnectorStatus error = %v, want ErrConnectorNotFound", err)
+		}
+		if n := requests.Load(); n != 1 {
+			t.Errorf("%d requests, want a missing connector not retried", n)
+		}
+	})
+
+	t.Run("on timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		start := time.Now()
+		if _, err := newTestConnectClient(server.URL, 0, 50*time.Millisecond).ConnectorStatus(context.Background(), "es-sink"); err == nil {
+			t.Error("ConnectorStatus succeeded against a hanging server")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("ConnectorStatus returned after %v, want the 50ms timeout", elapsed)
+		}
+	})
+}
+
+func TestConnectClientReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "es-sink", "connector": {"state": "RUNNING"}, "tasks": []}`))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	// One client serves every monitor tick
+	client := newTestConnectClient(server.URL, 0, time.Second)
+	for i := 0; i < 5; i++ {
+		if _, err := client.ConnectorStatus(context.Background(), "es-sink"); err != nil {
+			t.Fatalf("ConnectorStatus #%d: %v", i+1, err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("%d connections for 5 checks, want 1 kept alive", n)
+	}
+}
+
+func TestConnectorStatusReportsFailedTasks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "es-sink", "connector": {"state": "RUNNING", "worker_id": "w1"}, "tasks": [
+			{"id": 0, "state": "RUNNING", "worker_id": "w1"},
+			{"id": 1, "state": "FAILED", "worker_id": "w2", "trace": "org.apache.kafka.connect.errors.ConnectException: mapping conflict\n\tat Worker.run"}
+		]}`))
+	}))
+	defer server.Close()
+
+	status, err := newTestConnectClient(server.URL, 0, time.Second).ConnectorStatus(context.Background(), "es-sink")
+	if err != nil {
+		t.Fatalf("ConnectorStatus: %v", err)
+	}
+
+	if !status.Failed() || status.Running() {
+		t.Errorf("Failed() = %v, Running() = %v, want a failed task to fail the connector", status.Failed(), status.Running())
+	}
+	failed := status.FailedTasks()
+	if len(failed) != 1 || failed[0].ID != 1 || failed[0].WorkerID != "w2" {
+		t.Fatalf("FailedTasks() = %+v, want task 1 on w2", failed)
+	}
+	if got, want := TraceSummary(failed[0].Trace), "org.apache.kafka.connect.errors.ConnectException: mapping conflict"; got != want {
+		t.Errorf("TraceSummary = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// ConnectorMonitor keeps the sink connector running in kafka-connect mode.
+// A failed connector or task is restarted, waiting twice as long after each
+// restart that doesn't bring it back so a connector that keeps failing
+// doesn't hammer Connect. A missing connector is created when a config spec
+// is provided.
+type ConnectorMonitor struct {
+	client     *ConnectClient
+	name       string
+	spec       map[string]string
+	backoff    time.Duration
+	maxBackoff time.Duration
+	logger     logger.Logger
+
+	restarts    int
+	nextRestart time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewConnectorMonitor(client *ConnectClient, cfg config.KafkaConnectConfig, logger logger.Logger) *ConnectorMonitor {
+	return &ConnectorMonitor{
+		client:     client,
+		name:       cfg.SinkConnector.Name,
+		spec:       cfg.SinkConnector.Config,
+		backoff:    cfg.RestartBackoff,
+		maxBackoff: cfg.MaxRestartBackoff,
+		logger:     logger,
+		stop:       make(chan struct{}),
+	}
+}
+
+// Stop tells the loop checking the connector to end; Stopped is closed once
+// it has been called
+func (m *ConnectorMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// Stopped returns a channel that is closed once Stop has been called
+func (m *ConnectorMonitor) Stopped() <-chan struct{} {
+	return m.stop
+}
+
+// Check fetches the connector's status as of now, creating the connector if
+// it is missing and restarting it if it has failed and is due a restart
+func (m *ConnectorMonitor) Check(ctx context.Context, now time.Time) (*ConnectorStatus, error) {
+	status, err := m.client.ConnectorStatus(ctx, m.name)
+	if errors.Is(err, ErrConnectorNotFound) && len(m.spec) > 0 {
+		if err := m.client.CreateConnector(ctx, m.name, m.spec); err != nil {
+			return nil, fmt.Errorf("failed to create connector %s: %w", m.name, err)
+		}
+		m.logger.Info(ctx, "Created Kafka Connect connector", map[string]interface{}{
+			"connector": m.name,
+		})
+		return m.client.ConnectorStatus(ctx, m.name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !status.Failed() {
+		if m.restarts > 0 && status.Connector.State == ConnectorStateRunning {
+			m.logger.Info(ctx, "Kafka Connect connector recovered", map[string]interface{}{
+				"connector": m.name,
+				"restarts":  m.restarts,
+			})
+			m.restarts = 0
+			m.nextRestart = time.Time{}
+		}
+		return status, nil
+	}
+
+	if now.Before(m.nextRestart) {
+		return status, nil
+	}
+
+	delay := m.restartDelay()
+	m.restarts++
+	m.nextRestart = now.Add(delay)
+	if err := m.client.RestartConnector(ctx, m.name); err != nil {
+		return status, fmt.Errorf("failed to restart connector %s: %w", m.name, err)
+	}
+	m.logger.Info(ctx, "Restarted Kafka Connect connector", map[string]interface{}{
+		"connector":    m.name,
+		"restarts":     m.restarts,
+		"next_restart": m.nextRestart,
+	})
+	return status, nil
+}
+
+// Status fetches the connector's status without acting on it
+func (m *ConnectorMonitor) Status(ctx context.Context) (*ConnectorStatus, error) {
+	return m.client.ConnectorStatus(ctx, m.name)
+}
+
+// restartDelay returns how long to wait after the next restart before
+// restarting again
+func (m *ConnectorMonitor) restartDelay() time.Duration {
+	delay := m.backoff
+	for i := 0; i < m.restarts && delay < m.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > m.maxBackoff {
+		delay = m.maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// fakeConnect is a Kafka Connect API serving one connector, which comes
+// back up after healAfter restarts
+type fakeConnect struct {
+	mu        sync.Mutex
+	exists    bool
+	state     string
+	healAfter int
+	restarts  int
+	created   map[string]interface{}
+}
+
+func (c *fakeConnect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/connectors/es-sink/status":
+		if !c.exists {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"name": "es-sink", "connector": {"state": %q}, "tasks": [{"id": 0, "state": %q}]}`, c.state, c.state)
+	case r.Method == http.MethodPost && r.URL.Path == "/connectors/es-sink/restart":
+		if r.URL.Query().Get("includeTasks") != "true" {
+			http.Error(w, "tasks not restarted", http.StatusBadRequest)
+			return
+		}
+		c.restarts++
+		if c.restarts >= c.healAfter {
+			c.state = ConnectorStateRunning
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPost && r.URL.Path == "/connectors":
+		json.NewDecoder(r.Body).Decode(&c.created)
+		c.exists = true
+		c.state = ConnectorStateRunning
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestConnectorMonitor(url string, spec map[string]string) *ConnectorMonitor {
+	cfg := config.KafkaConnectConfig{RestartBackoff: time.Minute, MaxRestartBackoff: 4 * time.Minute}
+	cfg.SinkConnector.URL = url
+	cfg.SinkConnector.Name = "es-sink"
+	cfg.SinkConnector.Config = spec
+	return NewConnectorMonitor(newTestConnectClient(url, 0, time.Second), cfg, logger.NewLogger("json"))
+}
+
+func TestConnectorMonitorRestartsFailedConnector(t *testing.T) {
+	connect := &fakeConnect{exists: true, state: ConnectorStateFailed, healAfter: 3}
+	server := httptest.NewServer(connect)
+	defer server.Close()
+
+	ctx := context.Background()
+	monitor := newTestConnectorMonitor(server.URL, nil)
+	start := time.Now()
+
+	// Restarts back off 1m, 2m, 4m; checks in between leave it alone
+	checks := []struct {
+		at       time.Duration
+		restarts int
+	}{
+		{0, 1},
+		{30 * time.Second, 1},
+		{time.Minute, 2},
+		{2 * time.Minute, 2},
+		{3 * time.Minute, 3},
+	}
+	for _, check := range checks {
+		if _, err := monitor.Check(ctx, start.Add(check.at)); err != nil {
+			t.Fatalf("Check at %v: %v", check.at, err)
+		}
+		if connect.restarts != check.restarts {
+			t.Fatalf("after the check at %v: %d restarts, want %d", check.at, connect.restarts, check.restarts)
+		}
+	}
+
+	status, err := monitor.Check(ctx, start.Add(4*time.Minute))
+	if err != nil || !status.Running() {
+		t.Fatalf("Check after recovery = %+v, %v, want running", status, err)
+	}
+	if monitor.restarts != 0 {
+		t.Errorf("monitor restarts = %d after recovery, want the backoff reset", monitor.restarts)
+	}
+}
+
+func TestConnectorMonitorCreatesMissingConnector(t *testing.T) {
+	t.Run("with spec", func(t *testing.T) {
+		connect := &fakeConnect{}
+		server := httptest.NewServer(connect)
+		defer server.Close()
+
+		spec := map[string]string{"connector.class": "io.confluent.connect.elasticsearch.ElasticsearchSinkConnector"}
+		status, err := newTestConnectorMonitor(server.URL, spec).Check(context.Background(), time.Now())
+		if err != nil || !status.Running() {
+			t.Fatalf("Check = %+v, %v, want the created connector running", status, err)
+		}
+		created, _ := connect.created["config"].(map[string]interface{})
+		if connect.created["name"] != "es-sink" || created["connector.class"] != spec["connector.class"] {
+			t.Errorf("created %v, want es-sink with the configured spec", connect.created)
+		}
+	})
+
+	t.Run("without spec", func(t *testing.T) {
+		connect := &fakeConnect{}
+		server := httptest.NewServer(connect)
+		defer server.Close()
+
+		if _, err := newTestConnectorMonitor(server.URL, nil).Check(context.Background(), time.Now()); err == nil {
+			t.Error("Check succeeded for a missing connector with nothing to create it from")
+		}
+		if connect.exists {
+			t.Error("connector created without a spec")
+		}
+	})
+}
+
+func TestConnectorStatusRunning(t *testing.T) {
+	tests := []struct {
+		name      string
+		connector string
+		tasks     []string
+		want      bool
+	}{
+		{"running with running tasks", ConnectorStateRunning, []string{ConnectorStateRunning, ConnectorStateRunning}, true},
+		{"running without tasks", ConnectorStateRunning, nil, true},
+		{"paused", "PAUSED", []string{"PAUSED"}, false},
+		{"unassigned", "UNASSIGNED", nil, false},
+		{"failed task", ConnectorStateRunning, []string{ConnectorStateRunning, ConnectorStateFailed}, false},
+		{"failed", ConnectorStateFailed, []string{ConnectorStateRunning}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &ConnectorStatus{Name: "es-sink"}
+			status.Connector.State = tt.connector
+			for i, state := range tt.tasks {
+				status.Tasks = append(status.Tasks, ConnectorTaskStatus{ID: i, State: state})
+			}
+			if got := status.Running(); got != tt.want {
+				t.Errorf("Running() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectorMonitorStatusDoesNotRestart(t *testing.T) {
+	connect := &fakeConnect{exists: true, state: ConnectorStateFailed, healAfter: 1}
+	server := httptest.NewServer(connect)
+	defer server.Close()
+
+	monitor := newTestConnectorMonitor(server.URL, nil)
+	status, err := monitor.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Running() {
+		t.Error("a failed connector reported running")
+	}
+	if connect.restarts != 0 {
+		t.Errorf("Status restarted the connector %d times, want 0", connect.restarts)
+	}
+
+	connect.mu.Lock()
+	connect.exists = false
+	connect.mu.Unlock()
+	if _, err := monitor.Status(context.Background()); err == nil {
+		t.Error("Status of a missing connector succeeded")
+	}
+}
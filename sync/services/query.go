@@ -0,0 +1,63 @@
+package services
+
+import "time"
+
+// Builders for the Elasticsearch queries SyncService sends. Input from
+// requests only ever becomes the value of a term or ids query, which
+// Elasticsearch takes literally; query_string and simple_query_string
+// parse their input as query syntax, so there are no builders for them and
+// they must not be used with user input. Field names always come from code.
+
+// searchBody wraps a query clause into a request body
+func searchBody(query map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"query": query}
+}
+
+func matchAllQuery() map[string]interface{} {
+	return map[string]interface{}{
+		"match_all": map[string]interface{}{},
+	}
+}
+
+// termQuery matches documents whose field is exactly value
+func termQuery(field, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"term": map[string]interface{}{
+			field: map[string]interface{}{"value": value},
+		},
+	}
+}
+
+// idsQuery matches documents with any of ids
+func idsQuery(ids []string) map[string]interface{} {
+	return map[string]interface{}{
+		"ids": map[string]interface{}{
+			"values": ids,
+		},
+	}
+}
+
+// beforeQuery matches documents whose date field is before t
+func beforeQuery(field string, t time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			field: map[string]interface{}{
+				"lt": t.Format(time.RFC3339),
+			},
+		},
+	}
+}
+
+// anyQuery matches documents matching at least one of clauses
+func anyQuery(clauses ...map[string]interface{}) map[string]interface{} {
+	should := make([]interface{}, len(clauses))
+	for i, clause := range clauses {
+		should[i] = clause
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should":               should,
+			"minimum_should_match": 1,
+		},
+	}
+}
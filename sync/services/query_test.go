@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// hostileIDs are IDs that would change a query built by string
+// concatenation or parsed as query syntax
+var hostileIDs = []string{
+	`1" } }, "match_all": { "x": "`,
+	`* OR _id:*`,
+	`a\"b\\c`,
+	"{\"query\":{}}\n",
+	`<script>&'`,
+}
+
+func TestQueriesKeepInputAsValues(t *testing.T) {
+	repo := mocks.NewRepository()
+	s := NewSyncService(repo, testConfig(), logger.NewLogger("json"))
+	ctx := context.Background()
+
+	for _, id := range hostileIDs {
+		repo.Reset()
+		s.GetCategory(ctx, id)
+		if _, err := s.PurgeCategories(ctx, PurgeCriteria{IDs: []string{id, "2"}}); err != nil {
+			t.Fatalf("PurgeCategories(%q): %v", id, err)
+		}
+
+		search := repo.CallsTo("Search")
+		if len(search) != 1 {
+			t.Fatalf("Search calls = %+v, want one", search)
+		}
+		wantSearch := map[string]interface{}{
+			"query": map[string]interface{}{
+				"term": map[string]interface{}{"_id": map[string]interface{}{"value": id}},
+			},
+		}
+		assertQuery(t, search[0].Body, wantSearch)
+
+		purge := repo.CallsTo("DeleteByQuery")
+		if len(purge) != 1 {
+			t.Fatalf("DeleteByQuery calls = %+v, want one", purge)
+		}
+		wantPurge := map[string]interface{}{
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"should": []interface{}{
+						map[string]interface{}{"ids": map[string]interface{}{"values": []interface{}{id, "2"}}},
+					},
+					"minimum_should_match": float64(1),
+				},
+			},
+		}
+		assertQuery(t, purge[0].Body, wantPurge)
+	}
+}
+
+// assertQuery checks that body decodes to exactly want
+func assertQuery(t *testing.T, body string, want map[string]interface{}) {
+	t.Helper()
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("decode query %s: %v", body, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("query = %s, want %v", body, want)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/rendyspratama/digital-discovery/sync/config"
@@ -13,169 +15,357 @@ import (
 	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
 )
 
+// RetryService owns the retry queue. Operations that fail with a retryable
+// error are scheduled with a NextRetry time and persisted, and a background
+// scheduler reprocesses them once due, so the consumer never waits on a
+// backoff.
 type RetryService struct {
 	syncService *SyncService
+	store       RetryStore
 	config      *config.Config
 	logger      logger.Logger
-}
+	onExhausted func(ctx context.Context, record *models.SyncRecord, err error)
 
-type RetryAttempt struct {
-	Attempt   int
-	Error     error
-	Timestamp time.Time
-	NextRetry time.Time
-	Duration  time.Duration
-}
+	// stop ends the scheduler; done is closed once it has returned
+	stop     chan struct{}
+	stopOnce sync.Once
 
-type RetryHistory struct {
-	OperationID string
-	Entity      string
-	Operation   string
-	Attempts    []RetryAttempt
-	Status      string
+	// mu guards queue, rand, which isn't safe for concurrent use, and done
+	mu    sync.Mutex
+	queue map[string]*models.SyncRecord
+	rand  *rand.Rand
+	done  chan struct{}
 }
 
-func init() {
-	// Initialize random seed for jitter
-	rand.Seed(time.Now().UnixNano())
-}
-
-func NewRetryService(syncService *SyncService, config *config.Config, logger logger.Logger) *RetryService {
-	return &RetryService{
+// NewRetryService creates the retry queue and registers it with syncService,
+// whose RetryOperation schedules onto it
+func NewRetryService(syncService *SyncService, store RetryStore, config *config.Config, logger logger.Logger) *RetryService {
+	rs := &RetryService{
 		syncService: syncService,
+		store:       store,
 		config:      config,
 		logger:      logger,
+		stop:        make(chan struct{}),
+		queue:       make(map[string]*models.SyncRecord),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	syncService.retries = rs
+	return rs
 }
 
-func (rs *RetryService) calculateNextDelay(attempt int, baseDelay time.Duration) time.Duration {
-	// Calculate exponential delay
-	delay := float64(baseDelay) * math.Pow(rs.config.Sync.Custom.BackoffFactor, float64(attempt))
+// OnExhausted sets fn to be called with each record whose retries run out,
+// after it has been taken off the queue. fn runs without the queue locked,
+// so it may schedule further retries.
+func (rs *RetryService) OnExhausted(fn func(ctx context.Context, record *models.SyncRecord, err error)) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.onExhausted = fn
+}
+
+// calculateNextDelay returns the delay before retry attempt+1, given the
+// delay before the previous one. Callers hold rs.mu.
+func (rs *RetryService) calculateNextDelay(attempt int, previous time.Duration) time.Duration {
+	custom := rs.config.Sync.Custom
+	base := float64(custom.RetryDelay)
+	ceiling := float64(custom.MaxRetryDelay)
 
-	// Add jitter (±20%)
-	jitter := rand.Float64()*0.4 - 0.2
-	delay = delay * (1 + jitter)
+	// Calculate exponential delay
+	delay := math.Min(base*math.Pow(custom.BackoffFactor, float64(attempt)), ceiling)
 
-	// Ensure delay doesn't exceed max
-	if delay > float64(rs.config.Sync.Custom.MaxRetryDelay) {
-		delay = float64(rs.config.Sync.Custom.MaxRetryDelay)
+	switch custom.Jitter {
+	case config.JitterNone:
+	case config.JitterFull:
+		delay = rs.rand.Float64() * delay
+	case config.JitterDecorrelated:
+		upper := math.Max(float64(previous), base) * 3
+		delay = math.Min(base+rs.rand.Float64()*(upper-base), ceiling)
+	default:
+		delay = delay/2 + rs.rand.Float64()*delay/2
 	}
 
 	return time.Duration(delay)
 }
 
-func (rs *RetryService) RetryWithBackoff(ctx context.Context, operation *models.CategoryOperation) error {
-	history := &RetryHistory{
-		OperationID: operation.Payload.ID,
-		Entity:      "category",
-		Operation:   operation.Operation,
-		Attempts:    make([]RetryAttempt, 0),
-		Status:      "IN_PROGRESS",
+// lastDelay returns how long record waited before its last retry
+func lastDelay(record *models.SyncRecord) time.Duration {
+	if record.LastRetry == nil || record.NextRetry == nil {
+		return 0
 	}
+	return record.NextRetry.Sub(*record.LastRetry)
+}
 
-	defer rs.cleanup(ctx, history)
+// Start restores the persisted queue and runs the scheduler until ctx is
+// done or Stop is called. The queue is restored before Start returns, so
+// operations scheduled afterwards see the retries already pending for their
+// rows.
+func (rs *RetryService) Start(ctx context.Context) error {
+	records, err := rs.store.Load(ctx)
+	if err != nil {
+		return err
+	}
 
-	var lastErr error
-	attempt := 0
-	baseDelay := rs.config.Sync.Custom.RetryDelay
+	done := make(chan struct{})
+	rs.mu.Lock()
+	for _, record := range records {
+		rs.queue[record.ID] = record
+	}
+	rs.done = done
+	rs.mu.Unlock()
 
-	rs.logger.Info(ctx, "Starting retry sequence", map[string]interface{}{
-		"operation_id": operation.Payload.ID,
-		"operation":    operation.Operation,
-		"max_retries":  rs.config.Sync.Custom.MaxRetries,
+	rs.logger.Info(ctx, "Retry queue restored", map[string]interface{}{
+		"pending": len(records),
 	})
 
-	for attempt < rs.config.Sync.Custom.MaxRetries {
-		delay := rs.calculateNextDelay(attempt, baseDelay)
-		attemptStart := time.Now()
-		err := rs.syncService.ProcessCategoryOperation(ctx, operation)
+	go rs.run(ctx, done)
+	return nil
+}
 
-		retryAttempt := RetryAttempt{
-			Attempt:   attempt + 1,
-			Error:     err,
-			Timestamp: attemptStart,
-			Duration:  time.Since(attemptStart),
-		}
+// Stop ends the scheduler and waits, until ctx is done, for a retry in
+// flight to finish, so nothing is written through the sync service once
+// Stop returns. Queued retries stay persisted for the next Start.
+func (rs *RetryService) Stop(ctx context.Context) error {
+	rs.stopOnce.Do(func() { close(rs.stop) })
 
-		if err == nil {
-			// Success
-			history.Status = "SUCCESS"
-			history.Attempts = append(history.Attempts, retryAttempt)
-			rs.logRetryHistory(ctx, history)
-			return nil
-		}
+	rs.mu.Lock()
+	done := rs.done
+	rs.mu.Unlock()
+	if done == nil {
+		return nil
+	}
 
-		// Handle failure
-		lastErr = err
-		attempt++
-		nextRetry := time.Now().Add(delay)
-		retryAttempt.NextRetry = nextRetry
-		history.Attempts = append(history.Attempts, retryAttempt)
-
-		rs.logger.WithError(ctx, err, "Retry attempt failed", map[string]interface{}{
-			"operation_id": operation.Payload.ID,
-			"attempt":      attempt,
-			"next_retry":   nextRetry,
-			"delay":        delay.String(),
-		})
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("retry scheduler still running: %w", ctx.Err())
+	}
+}
+
+func (rs *RetryService) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
 
+	interval := rs.config.Sync.Custom.RetryPollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
 		select {
 		case <-ctx.Done():
-			history.Status = "CANCELLED"
-			return ctx.Err()
-		case <-time.After(delay):
-			continue
+			return
+		case <-rs.stop:
+			return
+		case now := <-ticker.C:
+			rs.processDue(ctx, now)
 		}
 	}
+}
 
-	// All retries failed
-	history.Status = "FAILED"
-	return utils.NewSyncError(
-		utils.ErrCodeRetryExhausted,
-		fmt.Sprintf("Max retries (%d) reached", rs.config.Sync.Custom.MaxRetries),
-		lastErr,
-		operation.Operation,
-		"category",
-	)
+// retryKey identifies the row an operation changes. IDs are only unique
+// within an entity, so the entity is part of the key.
+func retryKey(entity, id string) string {
+	return entity + "/" + id
 }
 
-func (rs *RetryService) logRetryHistory(ctx context.Context, history *RetryHistory) {
-	rs.logger.Info(ctx, "Retry sequence completed", map[string]interface{}{
-		"operation_id":   history.OperationID,
-		"entity":         history.Entity,
-		"operation":      history.Operation,
-		"status":         history.Status,
-		"total_attempts": len(history.Attempts),
-		"attempts":       history.Attempts,
+// Schedule queues operation for a retry after it failed with cause. When a
+// retry is already queued for the same row, operation replaces it and keeps
+// its place, so only the newest change is ever applied. A nil cause queues
+// an operation behind a pending retry without counting a failure.
+//
+// An error with code ErrCodeRetryPersist means operation is neither applied
+// nor queued, so the caller must not move past it.
+func (rs *RetryService) Schedule(ctx context.Context, operation *models.CategoryOperation, cause error) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	record := &models.SyncRecord{
+		ID:         retryKey(entityOf(operation), operation.Payload.ID),
+		EntityType: "category",
+		EntityID:   operation.Payload.ID,
+		Operation:  operation.Operation,
+		Status:     models.SyncStatusRetrying,
+		NextRetry:  &now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Payload:    operation,
+	}
+
+	if queued, ok := rs.queue[record.ID]; ok {
+		record.ErrorMessage = queued.ErrorMessage
+		record.RetryCount = queued.RetryCount
+		record.LastRetry = queued.LastRetry
+		record.NextRetry = queued.NextRetry
+		record.CreatedAt = queued.CreatedAt
+	}
+	if cause != nil {
+		record.ErrorMessage = cause.Error()
+		if record.RetryCount == 0 {
+			record.RetryCount = 1
+			nextRetry := now.Add(rs.calculateNextDelay(0, 0))
+			record.NextRetry = &nextRetry
+		}
+	}
+
+	if err := rs.store.Save(ctx, record); err != nil {
+		return utils.NewSyncError(
+			utils.ErrCodeRetryPersist,
+			"Failed to persist retry",
+			err,
+			operation.Operation,
+			"category",
+		)
+	}
+	rs.queue[record.ID] = record
+
+	rs.logger.Info(ctx, "Retry scheduled", map[string]interface{}{
+		"operation_id": record.ID,
+		"operation":    record.Operation,
+		"retry_count":  record.RetryCount,
+		"next_retry":   record.NextRetry,
 	})
+	return nil
 }
 
-func (rs *RetryService) recordFailedAttempt(ctx context.Context, history *RetryHistory) {
-	lastAttempt := history.Attempts[len(history.Attempts)-1]
+// Pending reports whether a retry is queued for the row of entity with id
+func (rs *RetryService) Pending(entity, id string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	_, ok := rs.queue[retryKey(entity, id)]
+	return ok
+}
 
-	record := &models.SyncRecord{
-		ID:           history.OperationID,
-		EntityType:   history.Entity,
-		EntityID:     history.OperationID,
-		Operation:    history.Operation,
-		Status:       models.SyncStatusFailed,
-		ErrorMessage: lastAttempt.Error.Error(),
-		RetryCount:   len(history.Attempts),
-		LastRetry:    &lastAttempt.Timestamp,
-		NextRetry:    &lastAttempt.NextRetry,
-		CreatedAt:    history.Attempts[0].Timestamp,
-		UpdatedAt:    lastAttempt.Timestamp,
-	}
-
-	rs.logger.Info(ctx, "Recording failed retry attempt", map[string]interface{}{
-		"sync_record": record,
-		"history":     history,
+// due returns the queued records whose NextRetry is at or before now,
+// oldest first
+func (rs *RetryService) due(now time.Time) []*models.SyncRecord {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	var records []*models.SyncRecord
+	for _, record := range rs.queue {
+		if !record.NextRetry.After(now) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].NextRetry.Before(*records[j].NextRetry)
+	})
+	return records
+}
+
+func (rs *RetryService) processDue(ctx context.Context, now time.Time) {
+	for _, record := range rs.due(now) {
+		if ctx.Err() != nil || rs.stopped() {
+			return
+		}
+		rs.retry(ctx, record)
+	}
+}
+
+// stopped reports whether Stop has been called
+func (rs *RetryService) stopped() bool {
+	select {
+	case <-rs.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// retry reprocesses one record, then removes it from the queue or pushes
+// its NextRetry back. The exhausted callback runs once the queue is
+// unlocked.
+func (rs *RetryService) retry(ctx context.Context, record *models.SyncRecord) {
+	metrics := rs.syncService.Metrics()
+	metrics.RecordRetryAttempt(record.Operation, record.EntityType)
+	err := rs.syncService.ProcessCategoryOperation(ctx, record.Payload)
+
+	rs.mu.Lock()
+	exhausted := rs.updateAfterRetry(ctx, record, err)
+	onExhausted := rs.onExhausted
+	rs.mu.Unlock()
+
+	if exhausted && onExhausted != nil {
+		onExhausted(ctx, record, err)
+	}
+}
+
+// updateAfterRetry applies the outcome err of retrying record to the
+// queue and reports whether its retries are now exhausted. Callers hold
+// rs.mu.
+func (rs *RetryService) updateAfterRetry(ctx context.Context, record *models.SyncRecord, err error) bool {
+	metrics := rs.syncService.Metrics()
+
+	// A newer operation for the row replaced this one while it ran; leave
+	// it queued
+	if rs.queue[record.ID] != record {
+		return false
+	}
+
+	if err == nil {
+		metrics.RecordRetryOutcome(record.Operation, record.EntityType, record.RetryCount, true)
+		delete(rs.queue, record.ID)
+		if removeErr := rs.store.Remove(ctx, record.ID); removeErr != nil {
+			rs.logger.WithError(ctx, removeErr, "Failed to remove completed retry", map[string]interface{}{
+				"operation_id": record.ID,
+			})
+		}
+		rs.logger.Info(ctx, "Retry succeeded", map[string]interface{}{
+			"operation_id": record.ID,
+			"operation":    record.Operation,
+			"retry_count":  record.RetryCount,
+		})
+		return false
+	}
+
+	if !rs.syncService.IsRetryable(err) || record.RetryCount >= rs.config.Sync.Custom.MaxRetries {
+		rs.recordFailedAttempt(ctx, record, err)
+		return true
+	}
+
+	record.MarkAsFailed(err, rs.calculateNextDelay(record.RetryCount, lastDelay(record)))
+	record.Status = models.SyncStatusRetrying
+	if saveErr := rs.store.Save(ctx, record); saveErr != nil {
+		rs.logger.WithError(ctx, saveErr, "Failed to persist retry", map[string]interface{}{
+			"operation_id": record.ID,
+		})
+	}
+
+	rs.logger.WithError(ctx, err, "Retry attempt failed", map[string]interface{}{
+		"operation_id": record.ID,
+		"attempt":      record.RetryCount,
+		"next_retry":   record.NextRetry,
 	})
+	return false
 }
 
-func (rs *RetryService) cleanup(ctx context.Context, history *RetryHistory) {
-	// Clean up any resources if needed
-	if history.Status == "FAILED" {
-		rs.recordFailedAttempt(ctx, history)
+// recordFailedAttempt takes a record off the queue and out of the store
+// once its retries are exhausted. Callers hold rs.mu.
+func (rs *RetryService) recordFailedAttempt(ctx context.Context, record *models.SyncRecord, err error) {
+	delete(rs.queue, record.ID)
+	rs.syncService.Metrics().RecordRetryOutcome(record.Operation, record.EntityType, record.RetryCount, false)
+
+	now := time.Now()
+	record.Status = models.SyncStatusFailed
+	record.ErrorMessage = err.Error()
+	record.LastRetry = &now
+	record.NextRetry = nil
+	record.UpdatedAt = now
+	if removeErr := rs.store.Remove(ctx, record.ID); removeErr != nil {
+		rs.logger.WithError(ctx, removeErr, "Failed to remove exhausted retry", map[string]interface{}{
+			"operation_id": record.ID,
+		})
 	}
+
+	rs.logger.WithError(ctx, utils.NewSyncError(
+		utils.ErrCodeRetryExhausted,
+		fmt.Sprintf("Max retries (%d) reached", rs.config.Sync.Custom.MaxRetries),
+		err,
+		record.Operation,
+		"category",
+	), "Retry abandoned", map[string]interface{}{
+		"sync_record": record,
+	})
 }
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+// testConfig returns the config the services tests start from
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.ES.IndexPrefix = "digital-discovery"
+	cfg.ES.IndexSeparator = "-"
+	cfg.Sync.Custom.BatchSize = 100
+	cfg.Sync.Custom.MaxRetries = 3
+	cfg.Sync.Custom.RetryDelay = time.Minute
+	cfg.Sync.Custom.MaxRetryDelay = time.Hour
+	cfg.Sync.Custom.BackoffFactor = 2
+	cfg.Sync.Custom.Jitter = config.JitterNone
+	cfg.Sync.Custom.ConflictMode = "last-write-wins"
+	cfg.Sync.Custom.RetryableCodes = utils.DefaultRetryableCodes
+	return cfg
+}
+
+func deleteOperation(entity, id string) *models.CategoryOperation {
+	return &models.CategoryOperation{
+		Operation: models.OperationDelete,
+		Payload:   models.Category{ID: id},
+		Entity:    entity,
+	}
+}
+
+// failingStore is a RetryStore whose Save always fails
+type failingStore struct{ RetryStore }
+
+func (failingStore) Save(ctx context.Context, record *models.SyncRecord) error {
+	return errors.New("disk full")
+}
+
+func newTestRetryService(t *testing.T, repo *mocks.Repository, cfg *config.Config) *RetryService {
+	t.Helper()
+	store, err := NewFileRetryStore(filepath.Join(t.TempDir(), "retries.json"))
+	if err != nil {
+		t.Fatalf("NewFileRetryStore: %v", err)
+	}
+	log := logger.NewLogger("json")
+	return NewRetryService(NewSyncService(repo, cfg, log), store, cfg, log)
+}
+
+func TestFileRetryStoreSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data", "retries.json")
+
+	store, err := NewFileRetryStore(path)
+	if err != nil {
+		t.Fatalf("NewFileRetryStore: %v", err)
+	}
+	next := time.Now().Add(time.Minute)
+	for _, id := range []string{"categories/1", "categories/2", "categories/3"} {
+		record := &models.SyncRecord{ID: id, NextRetry: &next, Payload: deleteOperation("categories", id)}
+		if err := store.Save(ctx, record); err != nil {
+			t.Fatalf("Save(%s): %v", id, err)
+		}
+	}
+	if err := store.Remove(ctx, "categories/2"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	// An exhausted record has no next retry and leaves the store
+	if err := store.Save(ctx, &models.SyncRecord{ID: "categories/3"}); err != nil {
+		t.Fatalf("Save exhausted: %v", err)
+	}
+
+	reopened, err := NewFileRetryStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	records, err := reopened.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "categories/1" {
+		t.Fatalf("Load = %+v, want only categories/1", records)
+	}
+}
+
+func TestScheduleKeysRetriesByEntityAndID(t *testing.T) {
+	ctx := context.Background()
+	rs := newTestRetryService(t, mocks.NewRepository(), testConfig())
+
+	if err := rs.Schedule(ctx, deleteOperation("categories", "1"), errors.New("es down")); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	if !rs.Pending("categories", "1") {
+		t.Error("categories/1 not pending")
+	}
+	if rs.Pending("products", "1") {
+		t.Error("products/1 pending; it shares only the ID with the scheduled row")
+	}
+}
+
+func TestScheduleReportsUnpersistedRetry(t *testing.T) {
+	cfg := testConfig()
+	log := logger.NewLogger("json")
+	rs := NewRetryService(NewSyncService(mocks.NewRepository(), cfg, log), failingStore{}, cfg, log)
+
+	err := rs.Schedule(context.Background(), deleteOperation("categories", "1"), errors.New("es down"))
+
+	var syncErr *utils.SyncError
+	if !errors.As(err, &syncErr) || syncErr.Code != utils.ErrCodeRetryPersist {
+		t.Fatalf("Schedule error = %v, want code %s", err, utils.ErrCodeRetryPersist)
+	}
+	if rs.Pending("categories", "1") {
+		t.Error("retry pending although it wasn't persisted")
+	}
+}
+
+func TestExhaustedCallbackRunsOutsideLock(t *testing.T) {
+	ctx := context.Background()
+	repo := mocks.NewRepository()
+	repo.Errors["Delete"] = errors.New("es down")
+	cfg := testConfig()
+	cfg.Sync.Custom.MaxRetries = 1
+	rs := newTestRetryService(t, repo, cfg)
+
+	called := make(chan bool, 1)
+	rs.OnExhausted(func(ctx context.Context, record *models.SyncRecord, err error) {
+		// Would deadlock if the queue were still locked
+		called <- rs.Pending("categories", "1")
+	})
+	if err := rs.Schedule(ctx, deleteOperation("categories", "1"), errors.New("es down")); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		rs.processDue(ctx, time.Now().Add(24*time.Hour))
+		close(done)
+	}()
+
+	select {
+	case pending := <-called:
+		if pending {
+			t.Error("exhausted retry still pending in its callback")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("exhausted callback not called, or blocked on the queue lock")
+	}
+	<-done
+}
+
+func TestStopEndsScheduler(t *testing.T) {
+	ctx := context.Background()
+	repo := mocks.NewRepository()
+	cfg := testConfig()
+	cfg.Sync.Custom.RetryPollInterval = 5 * time.Millisecond
+	rs := newTestRetryService(t, repo, cfg)
+
+	if err := rs.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := rs.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Due at once, but the scheduler is gone
+	if err := rs.Schedule(ctx, deleteOperation("categories", "1"), nil); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	time.Sleep(10 * cfg.Sync.Custom.RetryPollInterval)
+
+	if calls := repo.CallsTo("Delete"); len(calls) != 0 {
+		t.Errorf("Delete calls after Stop = %+v, want none", calls)
+	}
+	if !rs.Pending("categories", "1") {
+		t.Error("retry dropped from the queue by Stop")
+	}
+}
+
+func TestRetryDelaysStayWithinBounds(t *testing.T) {
+	base, ceiling := time.Minute, time.Hour
+
+	tests := []struct {
+		jitter string
+		// bounds returns the range a delay may fall in, given the
+		// exponential delay and the previous delay
+		bounds func(exponential, previous time.Duration) (time.Duration, time.Duration)
+	}{
+		{config.JitterNone, func(exp, _ time.Duration) (time.Duration, time.Duration) { return exp, exp }},
+		{config.JitterEqual, func(exp, _ time.Duration) (time.Duration, time.Duration) { return exp / 2, exp }},
+		{config.JitterFull, func(exp, _ time.Duration) (time.Duration, time.Duration) { return 0, exp }},
+		{config.JitterDecorrelated, func(_, prev time.Duration) (time.Duration, time.Duration) {
+			return base, min(3*max(prev, base), ceiling)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.jitter, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Sync.Custom.Jitter = tt.jitter
+			rs := newTestRetryService(t, mocks.NewRepository(), cfg)
+			rs.rand = rand.New(rand.NewSource(1))
+
+			for run := 0; run < 200; run++ {
+				var previous time.Duration
+				for attempt := 0; attempt < 10; attempt++ {
+					exponential := min(base<<attempt, ceiling)
+					lo, hi := tt.bounds(exponential, previous)
+
+					delay := rs.calculateNextDelay(attempt, previous)
+					if delay < lo || delay > hi {
+						t.Fatalf("attempt %d after %v: delay %v outside [%v, %v]", attempt, previous, delay, lo, hi)
+					}
+					if delay > ceiling {
+						t.Fatalf("attempt %d: delay %v over MaxRetryDelay %v", attempt, delay, ceiling)
+					}
+					previous = delay
+				}
+			}
+		})
+	}
+}
+
+func TestRetryJitterSpreadsDelays(t *testing.T) {
+	// Operations failing together mustn't retry in lockstep
+	for _, jitter := range []string{config.JitterEqual, config.JitterFull, config.JitterDecorrelated} {
+		cfg := testConfig()
+		cfg.Sync.Custom.Jitter = jitter
+		rs := newTestRetryService(t, mocks.NewRepository(), cfg)
+
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 50; i++ {
+			seen[rs.calculateNextDelay(2, 0)] = true
+		}
+		if len(seen) < 40 {
+			t.Errorf("%s jitter gave %d distinct delays out of 50", jitter, len(seen))
+		}
+	}
+}
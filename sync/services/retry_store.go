@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/rendyspratama/digital-discovery/sync/models"
+)
+
+// RetryStore persists the retry queue so scheduled retries survive a restart
+type RetryStore interface {
+	// Save creates or replaces the record with the same ID
+	Save(ctx context.Context, record *models.SyncRecord) error
+	// Remove deletes a record; removing a missing record is not an error
+	Remove(ctx context.Context, id string) error
+	// Load returns every record still waiting for a retry
+	Load(ctx context.Context) ([]*models.SyncRecord, error)
+}
+
+// fileRetryStore keeps the retry queue in a local JSON file. The queue
+// fills up while Elasticsearch is failing, so it can't be kept there.
+// Every change rewrites the file through a temporary file renamed over it,
+// so a crash leaves either the old or the new queue. Records whose retries
+// are exhausted are dropped, the exhausted policy having dealt with them.
+type fileRetryStore struct {
+	path string
+
+	mu      sync.Mutex
+	records map[string]models.SyncRecord
+}
+
+// NewFileRetryStore opens the retry queue kept in the file at path,
+// creating its directory if needed
+func NewFileRetryStore(path string) (RetryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create retry store directory: %w", err)
+	}
+
+	store := &fileRetryStore{
+		path:    path,
+		records: make(map[string]models.SyncRecord),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retry store: %w", err)
+	}
+
+	var records []models.SyncRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode retry store %s: %w", path, err)
+	}
+	for _, record := range records {
+		store.records[record.ID] = record
+	}
+	return store, nil
+}
+
+func (s *fileRetryStore) Save(ctx context.Context, record *models.SyncRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.records[record.ID]
+	if record.NextRetry == nil {
+		delete(s.records, record.ID)
+	} else {
+		s.records[record.ID] = *record
+	}
+
+	if err := s.write(); err != nil {
+		if existed {
+			s.records[record.ID] = previous
+		} else {
+			delete(s.records, record.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *fileRetryStore) Remove(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.records[id]
+	if !ok {
+		return nil
+	}
+	delete(s.records, id)
+
+	if err := s.write(); err != nil {
+		s.records[id] = previous
+		return err
+	}
+	return nil
+}
+
+func (s *fileRetryStore) Load(ctx context.Context) ([]*models.SyncRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]*models.SyncRecord, 0, len(s.records))
+	for _, record := range s.records {
+		if record.Payload == nil || record.NextRetry == nil {
+			continue
+		}
+		record := record
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// write replaces the file with the records held in memory. Callers hold
+// s.mu.
+func (s *fileRetryStore) write() error {
+	records := make([]models.SyncRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode retry queue: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write retry store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write retry store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync retry store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write retry store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace retry store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// staleCache keeps the last good result of each read so it can still be
+// served, flagged as stale, while Elasticsearch is failing. Entries are
+// refreshed by every successful read and expire after ttl; writes don't
+// touch them, so a stale result may predate changes made since. When full,
+// the oldest entry makes room. A zero ttl disables it.
+type staleCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	entries    map[string]staleEntry
+}
+
+type staleEntry struct {
+	value  interface{}
+	stored time.Time
+}
+
+func newStaleCache(ttl time.Duration, maxEntries int) *staleCache {
+	return &staleCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]staleEntry),
+	}
+}
+
+func (c *staleCache) set(key string, value interface{}) {
+	if c.ttl <= 0 || c.maxEntries <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evictOldest()
+	}
+	c.entries[key] = staleEntry{value: value, stored: c.now()}
+}
+
+// get returns the value stored under key if it is younger than ttl
+func (c *staleCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || c.now().Sub(entry.stored) >= c.ttl {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *staleCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// evictOldest drops the oldest entry; the caller holds mu
+func (c *staleCache) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if oldestKey == "" || entry.stored.Before(oldest) {
+			oldestKey, oldest = key, entry.stored
+		}
+	}
+	delete(c.entries, oldestKey)
+}
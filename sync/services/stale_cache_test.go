@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+func TestStaleCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := newStaleCache(time.Minute, 2)
+	cache.now = func() time.Time { return now }
+
+	cache.set("a", 1)
+	now = now.Add(time.Second)
+	cache.set("b", 2)
+	now = now.Add(time.Second)
+
+	// Full, so the oldest entry makes room
+	cache.set("c", 3)
+	if _, ok := cache.get("a"); ok {
+		t.Error("the oldest entry wasn't evicted")
+	}
+	if v, ok := cache.get("c"); !ok || v != 3 {
+		t.Errorf("get(c) = %v, %v; want 3", v, ok)
+	}
+
+	cache.forget("b")
+	if _, ok := cache.get("b"); ok {
+		t.Error("a forgotten entry was served")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("c"); ok {
+		t.Error("an entry was served after its ttl")
+	}
+
+	disabled := newStaleCache(0, 10)
+	disabled.set("a", 1)
+	if _, ok := disabled.get("a"); ok {
+		t.Error("a zero ttl cache served an entry")
+	}
+}
+
+func TestGetCategoryServesStaleWhileElasticsearchFails(t *testing.T) {
+	repo := mocks.NewRepository()
+	repo.Docs = []json.RawMessage{json.RawMessage(`{"id":"1","name":"Books"}`)}
+	cfg := testConfig()
+	cfg.Sync.StaleReadTTL = time.Minute
+	cfg.Sync.StaleReadEntries = 10
+	s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+	ctx := context.Background()
+
+	if _, stale, err := s.GetCategory(ctx, "1"); err != nil || stale {
+		t.Fatalf("GetCategory = stale %v, %v; want a fresh read", stale, err)
+	}
+
+	repo.Errors["Search"] = errors.New("connection refused")
+	category, stale, err := s.GetCategory(ctx, "1")
+	if err != nil || !stale || category.Name != "Books" {
+		t.Fatalf("GetCategory while failing = %+v, stale %v, %v; want the last read, stale", category, stale, err)
+	}
+	if _, _, err := s.GetCategory(ctx, "2"); err == nil {
+		t.Error("GetCategory of an uncached category succeeded while failing")
+	}
+
+	// A category found missing is no longer served from the cache
+	delete(repo.Errors, "Search")
+	repo.Docs = nil
+	if _, _, err := s.GetCategory(ctx, "1"); !errors.Is(err, utils.ErrNotFound) {
+		t.Fatalf("GetCategory of a deleted category = %v, want not found", err)
+	}
+	repo.Errors["Search"] = errors.New("connection refused")
+	if _, _, err := s.GetCategory(ctx, "1"); err == nil {
+		t.Error("a category found missing was served stale")
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -17,23 +18,40 @@ import (
 )
 
 type SyncService struct {
-	esClient    elasticsearch.Repository
-	indexPrefix string
-	config      *config.Config
-	logger      logger.Logger
-	metrics     *metrics.MetricsCollector
-	mu          sync.RWMutex
-	bulkBuffer  []models.CategoryOperation
+	esClient   elasticsearch.Repository
+	names      elasticsearch.IndexNames
+	config     *config.Config
+	logger     logger.Logger
+	metrics    *metrics.MetricsCollector
+	mu         sync.RWMutex
+	bulkBuffer []models.CategoryOperation
+	retries    *RetryService
+	stale      *staleCache
+	retryable  utils.RetryableCodes
+
+	// writeIndexes labels write metrics with the index behind the alias
+	writeIndexes *writeIndexLabels
 }
 
 func NewSyncService(esClient elasticsearch.Repository, cfg *config.Config, logger logger.Logger) *SyncService {
+	collector := metrics.NewMetricsCollector()
+	collector.SetSourceTables(cfg.Monitoring.SourceTables)
+
 	return &SyncService{
-		esClient:    esClient,
-		indexPrefix: cfg.ES.IndexPrefix,
-		config:      cfg,
-		logger:      logger,
-		metrics:     metrics.NewMetricsCollector(),
-		bulkBuffer:  make([]models.CategoryOperation, 0, cfg.Sync.Custom.BatchSize),
+		esClient: esClient,
+		names: elasticsearch.IndexNames{
+			Environment: cfg.App.Environment,
+			Prefix:      cfg.ES.IndexPrefix,
+			Separator:   cfg.ES.IndexSeparator,
+		},
+		config:     cfg,
+		logger:     logger,
+		metrics:    collector,
+		bulkBuffer: make([]models.CategoryOperation, 0, cfg.Sync.Custom.BatchSize),
+		stale:      newStaleCache(cfg.Sync.StaleReadTTL, cfg.Sync.StaleReadEntries),
+		retryable:  utils.NewRetryableCodes(cfg.Sync.Custom.RetryableCodes),
+
+		writeIndexes: newWriteIndexLabels(writeIndexLabelTTL, esClient.WriteIndex),
 	}
 }
 
@@ -70,6 +88,10 @@ func (s *SyncService) ProcessCategoryOperation(ctx context.Context, operation *m
 		Status:      "IN_PROGRESS",
 		PayloadSize: 0,
 		ErrorCount:  0,
+
+		SourceSchema:    operation.Source.Schema,
+		SourceTable:     operation.Source.Table,
+		SourceTimestamp: operation.Timestamp,
 	}
 
 	defer func() {
@@ -86,7 +108,7 @@ func (s *SyncService) ProcessCategoryOperation(ctx context.Context, operation *m
 		"timestamp":   operation.Timestamp,
 	})
 
-	indexName := s.getCurrentIndexName("categories")
+	indexName := s.getWriteAlias(entityOf(operation))
 	opMetrics.IndexName = indexName
 
 	// Safe JSON marshaling
@@ -96,9 +118,27 @@ func (s *SyncService) ProcessCategoryOperation(ctx context.Context, operation *m
 		s.logger.WithError(ctx, err, "Failed to marshal payload for metrics", nil)
 	}
 
+	if limit := s.config.Sync.Custom.MaxPayloadBytes; limit > 0 && opMetrics.PayloadSize > limit {
+		opMetrics.Status = "FAILED"
+		opMetrics.ErrorCount++
+		s.metrics.RecordOversizedPayload(operation.Operation, "category")
+		return utils.NewPayloadTooLargeError(operation.Operation, "category", opMetrics.PayloadSize, limit)
+	}
+
 	var err error
 	switch operation.Operation {
 	case models.OperationCreate, models.OperationUpdate, models.OperationDelete:
+		if s.config.Sync.Custom.DryRun {
+			opMetrics.Status = "DRY_RUN"
+			s.metrics.RecordDryRunOperation(operation.Operation, "category")
+			s.logger.Info(ctx, "Dry run: skipping Elasticsearch write", map[string]interface{}{
+				"operation":   operation.Operation,
+				"category_id": operation.Payload.ID,
+				"index":       indexName,
+				"payload":     operation.Payload,
+			})
+			return nil
+		}
 		err = s.processOperation(ctx, indexName, operation)
 	default:
 		opMetrics.Status = "FAILED"
@@ -125,6 +165,16 @@ func (s *SyncService) ProcessCategoryOperation(ctx context.Context, operation *m
 	}
 
 	opMetrics.Status = "SUCCESS"
+	// Count the write against the index the alias points at, so writes
+	// can be told apart across rollovers
+	index, err := s.writeIndexes.label(ctx, indexName)
+	if err != nil {
+		s.logger.Debug(ctx, "Failed to resolve write index; labelling with the alias", map[string]interface{}{
+			"alias": indexName,
+			"error": err.Error(),
+		})
+	}
+	opMetrics.IndexName = index
 	s.logger.Info(ctx, "Operation completed successfully", map[string]interface{}{
 		"operation":   operation.Operation,
 		"category_id": operation.Payload.ID,
@@ -136,11 +186,12 @@ func (s *SyncService) ProcessCategoryOperation(ctx context.Context, operation *m
 }
 
 func (s *SyncService) processOperation(ctx context.Context, indexName string, operation *models.CategoryOperation) error {
+	category := sourceStamped(operation)
 	switch operation.Operation {
 	case models.OperationCreate:
-		return s.createCategory(ctx, indexName, operation.Payload)
+		return s.createCategory(ctx, indexName, category)
 	case models.OperationUpdate:
-		return s.updateCategory(ctx, indexName, operation.Payload)
+		return s.updateCategory(ctx, indexName, category, s.changedFields(operation), s.config.Sync.Custom.ConflictMode)
 	case models.OperationDelete:
 		return s.deleteCategory(ctx, indexName, operation.Payload.ID)
 	default:
@@ -205,42 +256,179 @@ func (s *SyncService) createCategory(ctx context.Context, indexName string, cate
 	body := strings.NewReader(mustJSON(category))
 	err := s.esClient.Index(ctx, indexName, category.ID, body)
 	if err != nil {
-		return utils.NewESIndexError("Failed to index category", err)
+		return s.writeError(ctx, "Failed to index category", err)
 	}
 	return nil
 }
 
-func (s *SyncService) updateCategory(ctx context.Context, indexName string, category models.Category) error {
+// updateCategory upserts category, writing only the changed fields of an
+// existing document unless changed is nil, and dropping the update under
+// conflictMode if the stored document is newer
+func (s *SyncService) updateCategory(ctx context.Context, indexName string, category models.Category, changed []string, conflictMode string) error {
 	category.SyncStatus = models.SyncStatusSuccess
 	category.LastSync = time.Now()
 
-	updateBody := map[string]interface{}{
-		"doc":           category,
-		"doc_as_upsert": true,
-	}
-
-	body := strings.NewReader(mustJSON(updateBody))
+	body := strings.NewReader(mustJSON(updateBody(category, changed, conflictMode)))
 	err := s.esClient.Update(ctx, indexName, category.ID, body)
 	if err != nil {
-		return utils.NewESIndexError("Failed to update category", err)
+		return s.writeError(ctx, "Failed to update category", err)
 	}
 	return nil
 }
 
+// conflictScript applies an update unless the stored document's
+// params.field is greater than the incoming params.value, in which case the
+// update is a noop. The check runs inside Elasticsearch, so a concurrent
+// write can't slip between reading the document and overwriting it.
+const conflictScript = `def stored = ctx._source[params.field];
+if (stored != null && stored > params.value) {
+  ctx.op = 'noop';
+} else {
+  ctx._source.putAll(params.doc);
+}`
+
+// syncFields are written with every partial update, along with the fields
+// that changed
+var syncFields = []string{"id", "sync_status", "last_sync", "source_ts_ms"}
+
+// updateBody builds the upsert request for category. With changed set, an
+// existing document gets only those fields and the sync fields, and a
+// missing one is created from the whole category. Timestamp and version
+// modes guard the update with conflictScript, comparing source_ts_ms or
+// version; last-write-wins, and a timestamp-mode update without a source
+// timestamp, overwrite unconditionally.
+func updateBody(category models.Category, changed []string, conflictMode string) map[string]interface{} {
+	var field string
+	var value int64
+	switch conflictMode {
+	case config.ConflictTimestamp:
+		field, value = "source_ts_ms", category.SourceTimestamp
+	case config.ConflictVersion:
+		field, value = "version", category.Version
+	}
+	guarded := field != "" && (field != "source_ts_ms" || value != 0)
+	if !guarded && changed == nil {
+		return map[string]interface{}{
+			"doc":           category,
+			"doc_as_upsert": true,
+		}
+	}
+
+	var doc map[string]interface{}
+	_ = json.Unmarshal([]byte(mustJSON(category)), &doc)
+	update := doc
+	if changed != nil {
+		update = make(map[string]interface{}, len(changed)+len(syncFields))
+		for _, fields := range [][]string{changed, syncFields} {
+			for _, name := range fields {
+				if v, ok := doc[name]; ok {
+					update[name] = v
+				}
+			}
+		}
+	}
+
+	if !guarded {
+		return map[string]interface{}{
+			"doc":    update,
+			"upsert": doc,
+		}
+	}
+	return map[string]interface{}{
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": conflictScript,
+			"params": map[string]interface{}{
+				"field": field,
+				"value": value,
+				"doc":   update,
+			},
+		},
+		"upsert": doc,
+	}
+}
+
+// changedFields returns the fields to write for an update, or nil to write
+// the whole document
+func (s *SyncService) changedFields(operation *models.CategoryOperation) []string {
+	if !s.config.Sync.Custom.PartialUpdates {
+		return nil
+	}
+	return operation.Changed
+}
+
+// sourceStamped returns the operation's category stamped with when the
+// change was committed at the source, if known
+func sourceStamped(operation *models.CategoryOperation) models.Category {
+	category := operation.Payload
+	if !operation.Timestamp.IsZero() {
+		category.SourceTimestamp = operation.Timestamp.UnixMilli()
+	}
+	return category
+}
+
 func (s *SyncService) deleteCategory(ctx context.Context, indexName string, id string) error {
 	err := s.esClient.Delete(ctx, indexName, id)
 	if err != nil {
-		return utils.NewESIndexError("Failed to delete category", err)
+		return s.writeError(ctx, "Failed to delete category", err)
 	}
 	return nil
 }
 
+// IsRetryable reports whether err's code is one of
+// sync.custom.retryable_codes
+func (s *SyncService) IsRetryable(err error) bool {
+	return s.retryable.IsRetryable(err)
+}
+
+// writeError wraps an error from an Elasticsearch write. A write refused by
+// a closed or read-only index is counted and logged as an error and isn't
+// retried, since the block lasts until an operator lifts it.
+func (s *SyncService) writeError(ctx context.Context, msg string, err error) error {
+	var blockErr *elasticsearch.ClusterBlockError
+	if !errors.As(err, &blockErr) {
+		return utils.NewESIndexError(msg, err)
+	}
+
+	s.metrics.RecordClusterBlocked(blockErr.Index, blockErr.Kind)
+	s.logger.Error(ctx, "Elasticsearch index is blocked for writes", map[string]interface{}{
+		"index":  blockErr.Index,
+		"block":  blockErr.Kind,
+		"reason": blockErr.Reason,
+	})
+	return utils.NewESError(utils.ErrCodeESBlocked, msg, err, "index", blockErr.Index)
+}
+
+// getCurrentIndexName returns this month's index name for entity:
+// environment, es.index_prefix, entity and month, joined by
+// es.index_separator
 func (s *SyncService) getCurrentIndexName(entity string) string {
-	return fmt.Sprintf("%s-%s-%s-%s",
-		s.config.App.Environment,
-		"digital-discovery",
+	return strings.Join([]string{
+		s.names.Environment,
+		s.names.Prefix,
 		entity,
-		time.Now().Format("2006-01"))
+		time.Now().Format("2006-01"),
+	}, s.names.Separator)
+}
+
+// getWriteAlias returns the alias all writes for an entity go through. It
+// always resolves to a single index, so reindexing only needs an alias swap.
+func (s *SyncService) getWriteAlias(entity string) string {
+	return s.names.WriteAlias(entity)
+}
+
+// getReadAlias returns the alias reads for an entity go through. It may span
+// the old and new index while a reindex is in progress.
+func (s *SyncService) getReadAlias(entity string) string {
+	return s.names.ReadAlias(entity)
+}
+
+// entityOf returns the entity whose index operation is written to
+func entityOf(operation *models.CategoryOperation) string {
+	if operation.Entity == "" {
+		return "categories"
+	}
+	return operation.Entity
 }
 
 func mustJSON(v interface{}) string {
@@ -295,7 +483,8 @@ func (s *SyncService) recordOperationResult(ctx context.Context, operation *mode
 			fmt.Errorf("operation failed with %d errors", metrics.ErrorCount),
 			s.config.Sync.Custom.RetryDelay,
 		)
-		s.metrics.RecordError(operation.Operation, "category", metrics.ErrorCount)
+		s.metrics.RecordError(operation.Operation, "category",
+			operation.Source.Schema, operation.Source.Table, metrics.ErrorCount)
 	} else {
 		record.MarkAsSuccess()
 	}
@@ -306,18 +495,34 @@ func (s *SyncService) recordOperationResult(ctx context.Context, operation *mode
 	})
 }
 
-func (s *SyncService) processBulkOperations(ctx context.Context) error {
+// processBulkOperations writes the bulk buffer in one request and returns
+// how many operations it held
+func (s *SyncService) processBulkOperations(ctx context.Context) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if len(s.bulkBuffer) == 0 {
-		return nil
+		return 0, nil
 	}
 
 	bufferSize := len(s.bulkBuffer)
+	if s.config.Sync.Custom.DryRun {
+		for _, op := range s.bulkBuffer {
+			s.metrics.RecordDryRunOperation(op.Operation, "category")
+		}
+		s.logger.Info(ctx, "Dry run: skipping Elasticsearch bulk write", map[string]interface{}{
+			"buffer_size": bufferSize,
+		})
+		s.bulkBuffer = s.bulkBuffer[:0]
+		return bufferSize, nil
+	}
+
+	// Only the last state of each document needs writing
+	ops := collapseOperations(s.bulkBuffer)
+
 	var buf strings.Builder
 
-	for _, op := range s.bulkBuffer {
+	for _, op := range ops {
 		// Add action line
 		var action string
 		switch op.Operation {
@@ -333,30 +538,27 @@ func (s *SyncService) processBulkOperations(ctx context.Context) error {
 
 		actionLine := map[string]interface{}{
 			action: map[string]interface{}{
-				"_index": s.getCurrentIndexName("categories"),
+				"_index": s.getWriteAlias(entityOf(&op)),
 				"_id":    op.Payload.ID,
 			},
 		}
 		if err := json.NewEncoder(&buf).Encode(actionLine); err != nil {
 			s.metrics.RecordBulkOperation("category", bufferSize, true)
-			return fmt.Errorf("failed to encode action line: %w", err)
+			return 0, fmt.Errorf("failed to encode action line: %w", err)
 		}
 
 		// Add payload line for non-delete operations
 		if op.Operation != models.OperationDelete {
 			var payload interface{}
 			if op.Operation == models.OperationUpdate {
-				payload = map[string]interface{}{
-					"doc":           op.Payload,
-					"doc_as_upsert": true,
-				}
+				payload = updateBody(sourceStamped(&op), s.changedFields(&op), s.config.Sync.Custom.ConflictMode)
 			} else {
-				payload = op.Payload
+				payload = sourceStamped(&op)
 			}
 
 			if err := json.NewEncoder(&buf).Encode(payload); err != nil {
 				s.metrics.RecordBulkOperation("category", bufferSize, true)
-				return fmt.Errorf("failed to encode payload: %w", err)
+				return 0, fmt.Errorf("failed to encode payload: %w", err)
 			}
 		}
 	}
@@ -364,12 +566,18 @@ func (s *SyncService) processBulkOperations(ctx context.Context) error {
 	err := s.esClient.Bulk(ctx, strings.NewReader(buf.String()))
 	if err != nil {
 		s.metrics.RecordBulkOperation("category", bufferSize, true)
-		return utils.NewESIndexError("Bulk operation failed", err)
+		return 0, s.writeError(ctx, "Bulk operation failed", err)
 	}
 
 	s.metrics.RecordBulkOperation("category", bufferSize, false)
+	written := time.Now()
+	for _, op := range s.bulkBuffer {
+		if !op.Timestamp.IsZero() {
+			s.metrics.RecordE2ELag(op.Operation, "category", written.Sub(op.Timestamp))
+		}
+	}
 	s.bulkBuffer = s.bulkBuffer[:0]
-	return nil
+	return bufferSize, nil
 }
 
 // Add method to check if operation can be bulked
@@ -379,26 +587,81 @@ func (s *SyncService) canBulkOperation(operation *models.CategoryOperation) bool
 
 // Add context to FlushBulkBuffer
 func (s *SyncService) FlushBulkBuffer(ctx context.Context) error {
+	_, err := s.DrainBulkBuffer(ctx)
+	return err
+}
+
+// DrainBulkBuffer flushes the bulk buffer like FlushBulkBuffer and returns
+// how many operations were written
+func (s *SyncService) DrainBulkBuffer(ctx context.Context) (int, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	if err := s.processBulkOperations(ctx); err != nil {
+	flushed, err := s.processBulkOperations(ctx)
+	if err != nil {
 		s.logger.WithError(ctx, err, "Failed to flush bulk buffer", map[string]interface{}{
-			"buffer_size": len(s.bulkBuffer),
+			"buffer_size": s.BulkBufferSize(),
 		})
-		return err
+		return 0, err
 	}
 
-	return nil
+	return flushed, nil
+}
+
+// BulkBufferSize returns the number of operations waiting to be flushed
+func (s *SyncService) BulkBufferSize() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.bulkBuffer)
+}
+
+// BulkBufferStats describes the operations waiting in the bulk buffer
+type BulkBufferStats struct {
+	Size int `json:"size"`
+	// OldestTimestamp is the earliest operation timestamp in the buffer,
+	// unset when the buffer is empty or no operation carries one
+	OldestTimestamp *time.Time `json:"oldest_timestamp,omitempty"`
+}
+
+// BulkBufferStats returns the size of the bulk buffer and its oldest
+// operation's timestamp
+func (s *SyncService) BulkBufferStats() BulkBufferStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := BulkBufferStats{Size: len(s.bulkBuffer)}
+	for i := range s.bulkBuffer {
+		ts := s.bulkBuffer[i].Timestamp
+		if ts.IsZero() {
+			continue
+		}
+		if stats.OldestTimestamp == nil || ts.Before(*stats.OldestTimestamp) {
+			stats.OldestTimestamp = &ts
+		}
+	}
+	return stats
+}
+
+// RetryOperation queues operation for a retry after it failed with cause and
+// returns without waiting for it. Without a retry queue it returns cause.
+func (s *SyncService) RetryOperation(ctx context.Context, operation *models.CategoryOperation, cause error) error {
+	if s.retries == nil {
+		return cause
+	}
+	return s.retries.Schedule(ctx, operation, cause)
 }
 
-// Update RetryOperation method to pass the logger interface directly
-func (s *SyncService) RetryOperation(ctx context.Context, operation *models.CategoryOperation) error {
-	retryService := NewRetryService(s, s.config, s.logger)
-	return retryService.RetryWithBackoff(ctx, operation)
+// RetryPending reports whether a retry is queued for the row of entity with
+// id. Later changes to that row must queue behind it to stay in order.
+func (s *SyncService) RetryPending(entity, id string) bool {
+	return s.retries != nil && s.retries.Pending(entity, id)
 }
 
-// Update addToBulkBuffer to be exported for use in bulk operations
+// AddToBulkBuffer queues operation for the next bulk request, flushing once
+// sync.custom.batch_size operations are waiting. The Kafka consumer doesn't
+// use the buffer: it writes each operation on its own and marks the offset
+// once the write is done, and buffering would mean holding offsets back
+// until the flush. Shutdown and rebalances still flush whatever is queued.
 func (s *SyncService) AddToBulkBuffer(operation models.CategoryOperation) error {
 	if !s.canBulkOperation(&operation) {
 		return utils.NewSyncError(
@@ -411,11 +674,12 @@ func (s *SyncService) AddToBulkBuffer(operation models.CategoryOperation) error
 	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.bulkBuffer = append(s.bulkBuffer, operation)
+	full := len(s.bulkBuffer) >= s.config.Sync.Custom.BatchSize
+	s.mu.Unlock()
 
-	// Auto-flush if buffer is full
-	if len(s.bulkBuffer) >= s.config.Sync.Custom.BatchSize {
+	// Auto-flush if buffer is full; the flush takes s.mu itself
+	if full {
 		return s.FlushBulkBuffer(context.Background())
 	}
 
@@ -424,43 +688,179 @@ func (s *SyncService) AddToBulkBuffer(operation models.CategoryOperation) error
 
 // CreateCategory creates a new category in Elasticsearch
 func (s *SyncService) CreateCategory(ctx context.Context, category models.Category) error {
-	indexName := s.getCurrentIndexName("categories")
+	indexName := s.getWriteAlias("categories")
 	return s.createCategory(ctx, indexName, category)
 }
 
 // UpdateCategory updates an existing category in Elasticsearch
-func (s *SyncService) UpdateCategory(ctx context.Context, category models.Category) error {
-	indexName := s.getCurrentIndexName("categories")
-	return s.updateCategory(ctx, indexName, category)
+func (s *SyncService) UpdateCategory(ctx context.Context, category models.Category) (int64, error) {
+	indexName := s.getWriteAlias("categories")
+
+	current, err := s.findCategory(ctx, category.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Optimistic concurrency: the caller must send the version it read.
+	// The check and the write are separate requests, so this narrows the
+	// lost-update window rather than closing it.
+	var currentVersion int64
+	if current != nil {
+		currentVersion = current.Version
+		if category.Version != currentVersion && s.config.Sync.UpdateConflict != config.UpdateConflictOverwrite {
+			return 0, utils.NewVersionConflictError("category", currentVersion)
+		}
+	}
+
+	// The version check above already decided the conflict
+	category.Version = currentVersion + 1
+	if err := s.updateCategory(ctx, indexName, category, nil, config.ConflictLastWriteWins); err != nil {
+		return 0, err
+	}
+	return category.Version, nil
 }
 
 // DeleteCategory deletes a category from Elasticsearch
 func (s *SyncService) DeleteCategory(ctx context.Context, id string) error {
-	indexName := s.getCurrentIndexName("categories")
+	indexName := s.getWriteAlias("categories")
 	return s.deleteCategory(ctx, indexName, id)
 }
 
-// GetCategory retrieves a category from Elasticsearch
-func (s *SyncService) GetCategory(ctx context.Context, id string) (*models.Category, error) {
-	indexName := s.getCurrentIndexName("categories")
+// Outcomes of DeleteMany for one ID
+const (
+	DeleteStatusDeleted  = "deleted"
+	DeleteStatusNotFound = "not_found"
+	DeleteStatusFailed   = "failed"
+)
 
-	// Create a search query to find the document
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"term": map[string]interface{}{
-				"_id": id,
-			},
-		},
+// DeleteResult is what happened to one ID of DeleteMany
+type DeleteResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DeleteMany deletes categories with bulk requests of up to
+// sync.custom.batch_size deletes, and reports the outcome per ID in the
+// order given. A failed delete doesn't stop the others; an error is only
+// returned if a whole request fails, along with the results so far.
+func (s *SyncService) DeleteMany(ctx context.Context, ids []string) ([]DeleteResult, error) {
+	for _, id := range ids {
+		if id == "" {
+			return nil, utils.NewDataError(utils.ErrCodeValidationFailed, "Category ID cannot be empty", nil, "category")
+		}
 	}
 
+	batchSize := s.config.Sync.Custom.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(ids)
+	}
+	indexName := s.getWriteAlias("categories")
+
+	results := make([]DeleteResult, 0, len(ids))
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+
+		var buf strings.Builder
+		for _, id := range batch {
+			actionLine := map[string]interface{}{
+				"delete": map[string]interface{}{
+					"_index": indexName,
+					"_id":    id,
+				},
+			}
+			if err := json.NewEncoder(&buf).Encode(actionLine); err != nil {
+				return results, fmt.Errorf("failed to encode action line: %w", err)
+			}
+		}
+
+		items, err := s.esClient.BulkItems(ctx, strings.NewReader(buf.String()))
+		if err != nil {
+			s.metrics.RecordBulkOperation("category", len(batch), true)
+			return results, s.writeError(ctx, "Bulk delete failed", err)
+		}
+
+		outcomes := make(map[string]elasticsearch.BulkItem, len(items))
+		for _, item := range items {
+			outcomes[item.ID] = item
+		}
+
+		var failed bool
+		for _, id := range batch {
+			result := DeleteResult{ID: id, Status: DeleteStatusDeleted}
+			item, ok := outcomes[id]
+			switch {
+			case !ok:
+				// A delete the response doesn't mention may not have happened
+				result.Status = DeleteStatusFailed
+				result.Error = "no result in the bulk response"
+				failed = true
+			case item.NotFound():
+				result.Status = DeleteStatusNotFound
+			case item.Failed():
+				result.Status = DeleteStatusFailed
+				result.Error = item.Error
+				failed = true
+			}
+			results = append(results, result)
+		}
+		s.metrics.RecordBulkOperation("category", len(batch), failed)
+	}
+
+	s.logger.Info(ctx, "Bulk deleted categories", map[string]interface{}{
+		"ids": len(ids),
+	})
+	return results, nil
+}
+
+// GetCategory retrieves a category from Elasticsearch. If Elasticsearch fails, the last good result younger than
+// sync.stale_read_ttl is returned instead, with stale set. A missing category fails with an error matching
+// utils.ErrNotFound.
+func (s *SyncService) GetCategory(ctx context.Context, id string) (category *models.Category, stale bool, err error) {
+	key := "category:" + id
+	category, err = s.findCategory(ctx, id)
+	if err != nil {
+		if cached, ok := s.stale.get(key); ok {
+			s.logStaleRead(ctx, key, err)
+			c := cached.(models.Category)
+			return &c, true, nil
+		}
+		return nil, false, err
+	}
+	if category == nil {
+		s.stale.forget(key)
+		return nil, false, utils.NewNotFoundError("category", id)
+	}
+	s.stale.set(key, *category)
+	return category, false, nil
+}
+
+// staleList is a cached ListCategories page
+type staleList struct {
+	categories []models.Category
+	total      int64
+}
+
+// logStaleRead records that key was answered from the stale cache because
+// of err
+func (s *SyncService) logStaleRead(ctx context.Context, key string, err error) {
+	s.logger.WithError(ctx, err, "Elasticsearch read failed, serving stale result", map[string]interface{}{
+		"key": key,
+	})
+}
+
+// findCategory looks a category up by ID, returning nil when it doesn't exist
+func (s *SyncService) findCategory(ctx context.Context, id string) (*models.Category, error) {
+	indexName := s.getReadAlias("categories")
+
 	// Execute search
-	docs, err := s.esClient.Search(ctx, indexName, query)
+	docs, err := s.esClient.Search(ctx, indexName, searchBody(termQuery("_id", id)))
 	if err != nil {
 		return nil, utils.NewESIndexError("Failed to search category", err)
 	}
 
 	if len(docs) == 0 {
-		return nil, utils.NewESIndexError("Category not found", nil)
+		return nil, nil
 	}
 
 	// Parse document into Category struct
@@ -472,34 +872,129 @@ func (s *SyncService) GetCategory(ctx context.Context, id string) (*models.Categ
 	return &category, nil
 }
 
-// ListCategories retrieves all categories from Elasticsearch
-func (s *SyncService) ListCategories(ctx context.Context) ([]models.Category, error) {
-	indexName := s.getCurrentIndexName("categories")
-
-	// Create a search query to find all documents
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"match_all": map[string]interface{}{},
-		},
+// ListCategories retrieves one page of categories from Elasticsearch along
+// with the total number of categories. Like GetCategory, it falls back to
+// the last good page, with stale set, while Elasticsearch fails.
+func (s *SyncService) ListCategories(ctx context.Context, limit, offset int) ([]models.Category, int64, bool, error) {
+	key := fmt.Sprintf("list:%d:%d", limit, offset)
+	categories, total, err := s.listCategories(ctx, limit, offset)
+	if err != nil {
+		if cached, ok := s.stale.get(key); ok {
+			s.logStaleRead(ctx, key, err)
+			page := cached.(staleList)
+			return page.categories, page.total, true, nil
+		}
+		return nil, 0, false, err
 	}
+	s.stale.set(key, staleList{categories: categories, total: total})
+	return categories, total, false, nil
+}
+
+func (s *SyncService) listCategories(ctx context.Context, limit, offset int) ([]models.Category, int64, error) {
+	indexName := s.getReadAlias("categories")
+
+	// Page through the documents in a stable order
+	query := searchBody(matchAllQuery())
+	query["from"] = offset
+	query["size"] = limit
+	query["sort"] = []interface{}{map[string]interface{}{"id": "asc"}}
+	query["track_total_hits"] = true
 
 	// Execute search
-	docs, err := s.esClient.Search(ctx, indexName, query)
+	result, err := s.esClient.SearchPage(ctx, indexName, query)
 	if err != nil {
-		return nil, utils.NewESIndexError("Failed to search categories", err)
+		return nil, 0, utils.NewESIndexError("Failed to search categories", err)
 	}
 
 	// Parse documents into Category structs
-	var categories []models.Category
-	for _, doc := range docs {
+	categories := make([]models.Category, 0, len(result.Docs))
+	for _, doc := range result.Docs {
 		var category models.Category
 		if err := json.Unmarshal(doc, &category); err != nil {
-			return nil, utils.NewESIndexError("Failed to parse category", err)
+			return nil, 0, utils.NewESIndexError("Failed to parse category", err)
 		}
 		categories = append(categories, category)
 	}
 
-	return categories, nil
+	return categories, result.Total, nil
+}
+
+// PurgeCriteria selects orphaned category documents to delete. A document
+// matches if it was last synced before OlderThan or its ID is in IDs.
+type PurgeCriteria struct {
+	OlderThan time.Time `json:"older_than"`
+	IDs       []string  `json:"ids"`
+}
+
+// PurgeCategories deletes category documents whose source rows are gone but
+// whose delete event never arrived, and returns how many were deleted.
+func (s *SyncService) PurgeCategories(ctx context.Context, criteria PurgeCriteria) (int, error) {
+	var should []map[string]interface{}
+	if !criteria.OlderThan.IsZero() {
+		should = append(should, beforeQuery("last_sync", criteria.OlderThan))
+	}
+	if len(criteria.IDs) > 0 {
+		should = append(should, idsQuery(criteria.IDs))
+	}
+	if len(should) == 0 {
+		return 0, utils.NewDataError(
+			utils.ErrCodeValidationFailed,
+			"Purge requires older_than or ids",
+			nil,
+			"category",
+		)
+	}
+
+	deleted, err := s.esClient.DeleteByQuery(ctx, s.getReadAlias("categories"), searchBody(anyQuery(should...)))
+	if err != nil {
+		s.logger.WithError(ctx, err, "Category purge failed", map[string]interface{}{
+			"deleted": deleted,
+		})
+		return deleted, utils.NewESError(utils.ErrCodeDeleteFailed, "Failed to purge categories", err, "purge", "categories")
+	}
+
+	s.logger.Info(ctx, "Purged orphaned categories", map[string]interface{}{
+		"deleted":    deleted,
+		"older_than": criteria.OlderThan,
+		"ids":        len(criteria.IDs),
+	})
+	return deleted, nil
+}
+
+// TruncateEntity deletes every document of entity after its source table
+// was truncated, returning how many were deleted. Unless
+// Sync.Custom.ApplyTruncates is set, or in a dry run, the truncate is only
+// logged.
+func (s *SyncService) TruncateEntity(ctx context.Context, entity string, source models.SourceInfo) (int, error) {
+	fields := map[string]interface{}{
+		"entity": entity,
+		"schema": source.Schema,
+		"table":  source.Table,
+	}
+	if !s.config.Sync.Custom.ApplyTruncates || s.config.Sync.Custom.DryRun {
+		s.logger.Info(ctx, "Source table truncated; documents left in place", fields)
+		return 0, nil
+	}
+
+	deleted, err := s.esClient.DeleteByQuery(ctx, s.getReadAlias(entity), searchBody(matchAllQuery()))
+	fields["deleted"] = deleted
+	if err != nil {
+		s.logger.WithError(ctx, err, "Truncate failed", fields)
+		return deleted, utils.NewESError(utils.ErrCodeDeleteFailed, "Failed to truncate documents", err, "truncate", entity)
+	}
+
+	s.logger.Info(ctx, "Source table truncated; documents deleted", fields)
+	return deleted, nil
+}
+
+func (s *SyncService) Metrics() *metrics.MetricsCollector {
+	return s.metrics
+}
+
+// IndexNames returns the names of the configured indices, aliases and
+// lifecycle policy
+func (s *SyncService) IndexNames() elasticsearch.IndexNames {
+	return s.names
 }
 
 func (s *SyncService) GetCurrentIndexName(entity string) string {
@@ -518,14 +1013,15 @@ func (s *SyncService) HealthCheck() error {
 		return fmt.Errorf("elasticsearch health check failed: %w", err)
 	}
 
-	// Check current index exists using Search with size 0 if IndexExists is not available
-	indexName := s.getCurrentIndexName("categories")
-	_, err = s.esClient.Search(ctx, indexName, map[string]interface{}{
-		"size": 0,
-	})
+	// Check the write alias resolves; the repository caches the answer
+	indexName := s.getWriteAlias("categories")
+	exists, err := s.esClient.IndexExists(ctx, indexName)
 	if err != nil {
 		return fmt.Errorf("failed to check index existence: %w", err)
 	}
+	if !exists {
+		return fmt.Errorf("index %s does not exist", indexName)
+	}
 
 	// Check bulk buffer status using default size if not configured
 	s.mu.RLock()
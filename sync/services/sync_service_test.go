@@ -0,0 +1,565 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/config"
+	"github.com/rendyspratama/digital-discovery/sync/models"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch"
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+func TestAliasesUseIndexPrefixAndSeparator(t *testing.T) {
+	ctx := context.Background()
+	repo := mocks.NewRepository()
+	cfg := testConfig()
+	cfg.ES.IndexPrefix = "acme"
+	cfg.ES.IndexSeparator = "_"
+	s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+	if err := s.ProcessCategoryOperation(ctx, deleteOperation("categories", "1")); err != nil {
+		t.Fatalf("ProcessCategoryOperation: %v", err)
+	}
+	if _, _, err := s.GetCategory(ctx, "1"); err == nil {
+		t.Fatal("GetCategory found a category in an empty index")
+	}
+
+	want := map[string]string{
+		"Delete": "acme_categories_write",
+		"Search": "acme_categories_read",
+	}
+	for method, index := range want {
+		calls := repo.CallsTo(method)
+		if len(calls) == 0 {
+			t.Errorf("no %s call", method)
+			continue
+		}
+		for _, call := range calls {
+			if call.Index != index {
+				t.Errorf("%s went to %s, want %s", method, call.Index, index)
+			}
+		}
+	}
+}
+
+func TestProcessCategoryOperation(t *testing.T) {
+	category := models.Category{ID: "1", Name: "Books", Description: "Printed books"}
+	blocked := &elasticsearch.ClusterBlockError{Kind: elasticsearch.BlockReadOnly, Index: "categories-000001", Status: 403}
+
+	tests := []struct {
+		name      string
+		operation *models.CategoryOperation
+		esErrors  map[string]error
+		// wantCall is the write expected on the write alias, empty for none
+		wantCall      string
+		wantCode      string
+		wantRetryable bool
+	}{
+		{
+			name:      "create",
+			operation: &models.CategoryOperation{Operation: models.OperationCreate, Payload: category},
+			wantCall:  "Index",
+		},
+		{
+			name:      "update",
+			operation: &models.CategoryOperation{Operation: models.OperationUpdate, Payload: category},
+			wantCall:  "Update",
+		},
+		{
+			name:      "delete",
+			operation: &models.CategoryOperation{Operation: models.OperationDelete, Payload: models.Category{ID: "1"}},
+			wantCall:  "Delete",
+		},
+		{
+			name:      "missing id",
+			operation: &models.CategoryOperation{Operation: models.OperationCreate, Payload: models.Category{Name: "Books", Description: "Printed books"}},
+			wantCode:  utils.ErrCodeInvalidPayload,
+		},
+		{
+			name:      "missing name",
+			operation: &models.CategoryOperation{Operation: models.OperationUpdate, Payload: models.Category{ID: "1", Description: "Printed books"}},
+			wantCode:  utils.ErrCodeInvalidPayload,
+		},
+		{
+			name:      "unknown operation",
+			operation: &models.CategoryOperation{Operation: "UPSERT", Payload: category},
+			wantCode:  utils.ErrCodeInvalidPayload,
+		},
+		{
+			name:          "index failure is retried",
+			operation:     &models.CategoryOperation{Operation: models.OperationCreate, Payload: category},
+			esErrors:      map[string]error{"Index": errors.New("connection reset")},
+			wantCall:      "Index",
+			wantCode:      utils.ErrCodeESIndex,
+			wantRetryable: true,
+		},
+		{
+			name:      "blocked index is not retried",
+			operation: &models.CategoryOperation{Operation: models.OperationDelete, Payload: models.Category{ID: "1"}},
+			esErrors:  map[string]error{"Delete": blocked},
+			wantCall:  "Delete",
+			wantCode:  utils.ErrCodeESBlocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewRepository()
+			for method, err := range tt.esErrors {
+				repo.Errors[method] = err
+			}
+			s := NewSyncService(repo, testConfig(), logger.NewLogger("json"))
+
+			err := s.ProcessCategoryOperation(context.Background(), tt.operation)
+
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("ProcessCategoryOperation: %v", err)
+				}
+			} else {
+				var syncErr *utils.SyncError
+				if !errors.As(err, &syncErr) || syncErr.Code != tt.wantCode {
+					t.Fatalf("ProcessCategoryOperation error = %v, want code %s", err, tt.wantCode)
+				}
+				if got := s.IsRetryable(err); got != tt.wantRetryable {
+					t.Errorf("IsRetryable = %v, want %v", got, tt.wantRetryable)
+				}
+			}
+
+			var writes []mocks.Call
+			for _, method := range []string{"Index", "Update", "Delete"} {
+				writes = append(writes, repo.CallsTo(method)...)
+			}
+			if tt.wantCall == "" {
+				if len(writes) != 0 {
+					t.Errorf("writes = %+v, want none", writes)
+				}
+				return
+			}
+			if len(writes) != 1 || writes[0].Method != tt.wantCall {
+				t.Fatalf("writes = %+v, want one %s", writes, tt.wantCall)
+			}
+			if writes[0].Index != "digital-discovery-categories-write" || writes[0].ID != "1" {
+				t.Errorf("%s went to %s/%s, want digital-discovery-categories-write/1", tt.wantCall, writes[0].Index, writes[0].ID)
+			}
+		})
+	}
+}
+
+func TestDeleteManyReportsEachID(t *testing.T) {
+	repo := mocks.NewRepository()
+	repo.BulkStatuses = map[string]int{"2": 404, "3": 500}
+	repo.BulkOmitted = map[string]bool{"4": true}
+	cfg := testConfig()
+	cfg.Sync.Custom.BatchSize = 2
+	s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+	results, err := s.DeleteMany(context.Background(), []string{"1", "2", "3", "4", "5"})
+	if err != nil {
+		t.Fatalf("DeleteMany: %v", err)
+	}
+
+	want := []DeleteResult{
+		{ID: "1", Status: DeleteStatusDeleted},
+		{ID: "2", Status: DeleteStatusNotFound},
+		{ID: "3", Status: DeleteStatusFailed, Error: "mock_exception: failed"},
+		{ID: "4", Status: DeleteStatusFailed, Error: "no result in the bulk response"},
+		{ID: "5", Status: DeleteStatusDeleted},
+	}
+	if !slices.Equal(results, want) {
+		t.Errorf("results = %+v\nwant %+v", results, want)
+	}
+	if calls := repo.CallsTo("BulkItems"); len(calls) != 3 {
+		t.Errorf("%d bulk requests, want 3 of at most 2 deletes", len(calls))
+	}
+}
+
+func TestFlushBulkBufferFailsOnFailedItem(t *testing.T) {
+	repo := mocks.NewRepository()
+	repo.BulkStatuses = map[string]int{"2": 429}
+	s := NewSyncService(repo, testConfig(), logger.NewLogger("json"))
+	for _, id := range []string{"1", "2"} {
+		if err := s.AddToBulkBuffer(*deleteOperation("categories", id)); err != nil {
+			t.Fatalf("AddToBulkBuffer: %v", err)
+		}
+	}
+
+	err := s.FlushBulkBuffer(context.Background())
+
+	var itemsErr *elasticsearch.BulkItemsError
+	if !errors.As(err, &itemsErr) || len(itemsErr.Failed) != 1 || itemsErr.Failed[0].ID != "2" {
+		t.Fatalf("FlushBulkBuffer error = %v, want the failed delete of 2", err)
+	}
+	if !s.IsRetryable(err) {
+		t.Error("partially failed bulk request not retryable")
+	}
+}
+
+func TestPurgeCategories(t *testing.T) {
+	ctx := context.Background()
+	cutoff := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("older than", func(t *testing.T) {
+		repo := mocks.NewRepository()
+		repo.Deleted = 12
+		s := NewSyncService(repo, testConfig(), logger.NewLogger("json"))
+
+		deleted, err := s.PurgeCategories(ctx, PurgeCriteria{OlderThan: cutoff})
+		if err != nil || deleted != 12 {
+			t.Fatalf("PurgeCategories = %d, %v, want 12 deleted", deleted, err)
+		}
+		calls := repo.CallsTo("DeleteByQuery")
+		if len(calls) != 1 || calls[0].Index != "digital-discovery-categories-read" {
+			t.Fatalf("DeleteByQuery calls = %+v, want one against the read alias", calls)
+		}
+		assertQuery(t, calls[0].Body, map[string]interface{}{
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"should": []interface{}{
+						map[string]interface{}{"range": map[string]interface{}{
+							"last_sync": map[string]interface{}{"lt": "2026-03-01T00:00:00Z"},
+						}},
+					},
+					"minimum_should_match": float64(1),
+				},
+			},
+		})
+	})
+
+	t.Run("no criteria", func(t *testing.T) {
+		repo := mocks.NewRepository()
+		s := NewSyncService(repo, testConfig(), logger.NewLogger("json"))
+
+		_, err := s.PurgeCategories(ctx, PurgeCriteria{})
+		var syncErr *utils.SyncError
+		if !errors.As(err, &syncErr) || syncErr.Code != utils.ErrCodeValidationFailed {
+			t.Errorf("PurgeCategories error = %v, want code %s", err, utils.ErrCodeValidationFailed)
+		}
+		// Without criteria nothing may be deleted, let alone everything
+		if calls := repo.Calls(); len(calls) != 0 {
+			t.Errorf("calls = %+v, want none", calls)
+		}
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		repo := mocks.NewRepository()
+		repo.Errors["DeleteByQuery"] = errors.New("shard failure")
+		s := NewSyncService(repo, testConfig(), logger.NewLogger("json"))
+
+		_, err := s.PurgeCategories(ctx, PurgeCriteria{IDs: []string{"1"}})
+		var syncErr *utils.SyncError
+		if !errors.As(err, &syncErr) || syncErr.Code != utils.ErrCodeDeleteFailed {
+			t.Errorf("PurgeCategories error = %v, want code %s", err, utils.ErrCodeDeleteFailed)
+		}
+	})
+}
+
+func TestDryRunMakesNoElasticsearchCalls(t *testing.T) {
+	ctx := context.Background()
+	repo := mocks.NewRepository()
+	cfg := testConfig()
+	cfg.Sync.Custom.DryRun = true
+	s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+	category := models.Category{ID: "1", Name: "Books", Description: "Printed books"}
+	for _, op := range []string{models.OperationCreate, models.OperationUpdate, models.OperationDelete} {
+		operation := &models.CategoryOperation{Operation: op, Payload: category}
+		if err := s.ProcessCategoryOperation(ctx, operation); err != nil {
+			t.Errorf("ProcessCategoryOperation(%s) in dry run: %v", op, err)
+		}
+		if err := s.AddToBulkBuffer(*operation); err != nil {
+			t.Errorf("AddToBulkBuffer(%s) in dry run: %v", op, err)
+		}
+	}
+
+	flushed, err := s.DrainBulkBuffer(ctx)
+	if err != nil || flushed != 3 {
+		t.Errorf("DrainBulkBuffer = %d, %v, want the 3 buffered operations counted", flushed, err)
+	}
+	if calls := repo.Calls(); len(calls) != 0 {
+		t.Errorf("dry run called Elasticsearch: %+v", calls)
+	}
+}
+
+// applyUpdate does to stored what Elasticsearch does with an update request
+// body: it runs conflictScript, or merges the partial doc, upserting a
+// missing document
+func applyUpdate(t *testing.T, stored map[string]interface{}, body string) map[string]interface{} {
+	t.Helper()
+	var update struct {
+		Doc         map[string]interface{} `json:"doc"`
+		DocAsUpsert bool                   `json:"doc_as_upsert"`
+		Upsert      map[string]interface{} `json:"upsert"`
+		Script      *struct {
+			Source string `json:"source"`
+			Params struct {
+				Field string                 `json:"field"`
+				Value float64                `json:"value"`
+				Doc   map[string]interface{} `json:"doc"`
+			} `json:"params"`
+		} `json:"script"`
+	}
+	if err := json.Unmarshal([]byte(body), &update); err != nil {
+		t.Fatalf("decoding update body %s: %v", body, err)
+	}
+
+	if stored == nil {
+		if update.DocAsUpsert {
+			return update.Doc
+		}
+		return update.Upsert
+	}
+	doc := update.Doc
+	if update.Script != nil {
+		if update.Script.Source != conflictScript {
+			t.Fatalf("script is %q, want conflictScript", update.Script.Source)
+		}
+		if v, ok := stored[update.Script.Params.Field].(float64); ok && v > update.Script.Params.Value {
+			return stored
+		}
+		doc = update.Script.Params.Doc
+	}
+	merged := make(map[string]interface{}, len(stored))
+	for k, v := range stored {
+		merged[k] = v
+	}
+	for k, v := range doc {
+		merged[k] = v
+	}
+	return merged
+}
+
+func TestConflictModesDropOlderUpdates(t *testing.T) {
+	newer := &models.CategoryOperation{
+		Operation: models.OperationUpdate,
+		Payload:   models.Category{ID: "1", Name: "Books", Description: "Printed books", Version: 2},
+		Timestamp: time.UnixMilli(1700000002000),
+	}
+	older := &models.CategoryOperation{
+		Operation: models.OperationUpdate,
+		Payload:   models.Category{ID: "1", Name: "Old books", Description: "Printed books", Version: 1},
+		Timestamp: time.UnixMilli(1700000001000),
+	}
+	unstamped := func(op *models.CategoryOperation) *models.CategoryOperation {
+		c := *op
+		c.Timestamp = time.Time{}
+		return &c
+	}
+
+	tests := []struct {
+		name       string
+		mode       string
+		operations []*models.CategoryOperation
+		wantName   string
+	}{
+		{"timestamp keeps the newer event", config.ConflictTimestamp, []*models.CategoryOperation{newer, older}, "Books"},
+		{"timestamp applies events in order", config.ConflictTimestamp, []*models.CategoryOperation{older, newer}, "Books"},
+		{"timestamp without source time overwrites", config.ConflictTimestamp, []*models.CategoryOperation{unstamped(newer), unstamped(older)}, "Old books"},
+		{"version keeps the higher version", config.ConflictVersion, []*models.CategoryOperation{newer, older}, "Books"},
+		{"version applies events in order", config.ConflictVersion, []*models.CategoryOperation{older, newer}, "Books"},
+		{"last write wins", config.ConflictLastWriteWins, []*models.CategoryOperation{newer, older}, "Old books"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewRepository()
+			cfg := testConfig()
+			cfg.Sync.Custom.ConflictMode = tt.mode
+			s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+			var stored map[string]interface{}
+			for _, op := range tt.operations {
+				if err := s.ProcessCategoryOperation(context.Background(), op); err != nil {
+					t.Fatalf("ProcessCategoryOperation: %v", err)
+				}
+				calls := repo.CallsTo("Update")
+				stored = applyUpdate(t, stored, calls[len(calls)-1].Body)
+			}
+
+			if stored["name"] != tt.wantName {
+				t.Errorf("stored name is %v, want %q", stored["name"], tt.wantName)
+			}
+		})
+	}
+}
+
+func TestPartialUpdateWritesOnlyChangedFields(t *testing.T) {
+	operation := &models.CategoryOperation{
+		Operation: models.OperationUpdate,
+		Payload:   models.Category{ID: "1", Name: "Books", Description: "Printed books", Version: 2},
+		Timestamp: time.UnixMilli(1700000002000),
+		Changed:   []string{"name"},
+	}
+
+	tests := []struct {
+		name     string
+		partial  bool
+		wantDoc  []string
+		upserted bool
+	}{
+		{
+			name:     "partial",
+			partial:  true,
+			wantDoc:  []string{"id", "last_sync", "name", "source_ts_ms", "sync_status"},
+			upserted: true,
+		},
+		{
+			name:    "whole document",
+			partial: false,
+			wantDoc: []string{"created_at", "description", "id", "last_sync", "name", "source_ts_ms", "status", "sync_status", "updated_at", "version"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewRepository()
+			cfg := testConfig()
+			cfg.Sync.Custom.PartialUpdates = tt.partial
+			s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+			if err := s.ProcessCategoryOperation(context.Background(), operation); err != nil {
+				t.Fatalf("ProcessCategoryOperation: %v", err)
+			}
+			calls := repo.CallsTo("Update")
+			if len(calls) != 1 {
+				t.Fatalf("got %d Update calls, want 1", len(calls))
+			}
+			var body struct {
+				Doc    map[string]interface{} `json:"doc"`
+				Upsert map[string]interface{} `json:"upsert"`
+			}
+			if err := json.Unmarshal([]byte(calls[0].Body), &body); err != nil {
+				t.Fatalf("decoding update body: %v", err)
+			}
+
+			var fields []string
+			for name := range body.Doc {
+				fields = append(fields, name)
+			}
+			slices.Sort(fields)
+			if !slices.Equal(fields, tt.wantDoc) {
+				t.Errorf("doc fields are %v, want %v", fields, tt.wantDoc)
+			}
+			if body.Doc["name"] != "Books" {
+				t.Errorf("doc name is %v, want Books", body.Doc["name"])
+			}
+			if got := body.Upsert != nil; got != tt.upserted {
+				t.Errorf("upsert present is %v, want %v", got, tt.upserted)
+			}
+			if tt.upserted && body.Upsert["description"] != "Printed books" {
+				t.Errorf("upsert is %v, want the whole category", body.Upsert)
+			}
+		})
+	}
+}
+
+func TestMaxPayloadBytesRejectsLargerOperations(t *testing.T) {
+	operation := &models.CategoryOperation{
+		Operation: models.OperationCreate,
+		Payload:   models.Category{ID: "1", Name: "Books", Description: strings.Repeat("x", 2048)},
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		wantLarge bool
+	}{
+		{"under the limit", 4096, false},
+		{"no limit", 0, false},
+		{"over the limit", 1024, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewRepository()
+			cfg := testConfig()
+			cfg.Sync.Custom.MaxPayloadBytes = tt.limit
+			s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+			err := s.ProcessCategoryOperation(context.Background(), operation)
+
+			if !tt.wantLarge {
+				if err != nil {
+					t.Fatalf("ProcessCategoryOperation: %v", err)
+				}
+				if len(repo.CallsTo("Index")) != 1 {
+					t.Errorf("calls are %v, want one Index", repo.Calls())
+				}
+				return
+			}
+			large, ok := err.(*utils.PayloadTooLargeError)
+			if !ok {
+				t.Fatalf("error is %T %v, want *utils.PayloadTooLargeError", err, err)
+			}
+			if large.Limit != tt.limit || large.Size <= tt.limit {
+				t.Errorf("error reports %d bytes over a %d byte limit, want over %d", large.Size, large.Limit, tt.limit)
+			}
+			if calls := repo.Calls(); len(calls) != 0 {
+				t.Errorf("an oversized operation made calls %v", calls)
+			}
+		})
+	}
+}
+
+func TestTruncateEntity(t *testing.T) {
+	source := models.SourceInfo{Schema: "public", Table: "categories"}
+
+	tests := []struct {
+		name      string
+		apply     bool
+		dryRun    bool
+		esErr     error
+		wantQuery bool
+		wantCode  string
+	}{
+		{name: "logged only by default"},
+		{name: "dry run", apply: true, dryRun: true},
+		{name: "applied", apply: true, wantQuery: true},
+		{name: "failed", apply: true, esErr: errors.New("connection reset"), wantQuery: true, wantCode: utils.ErrCodeDeleteFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewRepository()
+			if tt.esErr != nil {
+				repo.Errors["DeleteByQuery"] = tt.esErr
+			}
+			cfg := testConfig()
+			cfg.Sync.Custom.ApplyTruncates = tt.apply
+			cfg.Sync.Custom.DryRun = tt.dryRun
+			s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+			_, err := s.TruncateEntity(context.Background(), "categories", source)
+
+			if tt.wantCode == "" && err != nil {
+				t.Fatalf("TruncateEntity: %v", err)
+			}
+			if tt.wantCode != "" {
+				if syncErr, ok := err.(*utils.SyncError); !ok || syncErr.Code != tt.wantCode {
+					t.Fatalf("TruncateEntity error = %v, want %s", err, tt.wantCode)
+				}
+			}
+			calls := repo.Calls()
+			if !tt.wantQuery {
+				if len(calls) != 0 {
+					t.Errorf("calls = %+v, want none", calls)
+				}
+				return
+			}
+			if len(calls) != 1 || calls[0].Method != "DeleteByQuery" || calls[0].Index != "digital-discovery-categories-read" {
+				t.Fatalf("calls = %+v, want one DeleteByQuery on the read alias", calls)
+			}
+			assertQuery(t, calls[0].Body, map[string]interface{}{
+				"query": map[string]interface{}{"match_all": map[string]interface{}{}},
+			})
+		})
+	}
+}
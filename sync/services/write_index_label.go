@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// writeIndexLabelTTL is how long the index a write alias points at is
+// reused to label write metrics. It doesn't follow es.index_exists_ttl,
+// which may be zero, since the hot path would then make an alias lookup
+// per event.
+const writeIndexLabelTTL = 30 * time.Second
+
+// writeIndexLabels resolves write aliases to the index they point at, for
+// labelling write metrics. Writes are counted against the new index within
+// ttl of a rollover. A failed lookup labels writes with the alias itself
+// and is only retried once ttl has passed.
+type writeIndexLabels struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	resolve func(ctx context.Context, alias string) (string, error)
+	entries map[string]writeIndexLabel
+}
+
+type writeIndexLabel struct {
+	index    string
+	resolved time.Time
+}
+
+func newWriteIndexLabels(ttl time.Duration, resolve func(ctx context.Context, alias string) (string, error)) *writeIndexLabels {
+	return &writeIndexLabels{
+		ttl:     ttl,
+		now:     time.Now,
+		resolve: resolve,
+		entries: make(map[string]writeIndexLabel),
+	}
+}
+
+// label returns the index to label a write through alias with, and the
+// error of the lookup if one was made and failed
+func (l *writeIndexLabels) label(ctx context.Context, alias string) (string, error) {
+	l.mu.Lock()
+	entry, ok := l.entries[alias]
+	if ok && l.now().Sub(entry.resolved) < l.ttl {
+		l.mu.Unlock()
+		return entry.index, nil
+	}
+	// Claim the refresh so concurrent writes keep the current label
+	// instead of all looking the alias up
+	if !ok {
+		entry.index = alias
+	}
+	l.entries[alias] = writeIndexLabel{index: entry.index, resolved: l.now()}
+	l.mu.Unlock()
+
+	index, err := l.resolve(ctx, alias)
+	if err != nil {
+		return entry.index, err
+	}
+
+	l.mu.Lock()
+	l.entries[alias] = writeIndexLabel{index: index, resolved: l.now()}
+	l.mu.Unlock()
+	return index, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rendyspratama/digital-discovery/sync/repositories/elasticsearch/mocks"
+	"github.com/rendyspratama/digital-discovery/sync/utils/logger"
+)
+
+func TestWritesResolveWriteIndexOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := mocks.NewRepository()
+	repo.WriteIndexes = map[string]string{"digital-discovery-categories-write": "development-digital-discovery-categories-000001"}
+	cfg := testConfig()
+	cfg.ES.IndexExistsTTL = 0
+	s := NewSyncService(repo, cfg, logger.NewLogger("json"))
+
+	for _, id := range []string{"1", "2", "3"} {
+		if err := s.ProcessCategoryOperation(ctx, deleteOperation("categories", id)); err != nil {
+			t.Fatalf("ProcessCategoryOperation(%s): %v", id, err)
+		}
+	}
+
+	if calls := repo.CallsTo("WriteIndex"); len(calls) != 1 {
+		t.Errorf("WriteIndex called %d times for 3 writes, want 1", len(calls))
+	}
+}
+
+func TestWriteIndexLabelRefreshesAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	index, lookupErr := "categories-000001", error(nil)
+	lookups := 0
+	labels := newWriteIndexLabels(time.Minute, func(ctx context.Context, alias string) (string, error) {
+		lookups++
+		return index, lookupErr
+	})
+	labels.now = func() time.Time { return now }
+
+	if got, _ := labels.label(ctx, "categories-write"); got != "categories-000001" {
+		t.Fatalf("label = %s, want categories-000001", got)
+	}
+
+	// A rollover shows up once the TTL has passed
+	index = "categories-000002"
+	now = now.Add(30 * time.Second)
+	if got, _ := labels.label(ctx, "categories-write"); got != "categories-000001" {
+		t.Errorf("label within TTL = %s, want categories-000001", got)
+	}
+	now = now.Add(time.Minute)
+	if got, _ := labels.label(ctx, "categories-write"); got != "categories-000002" {
+		t.Errorf("label after TTL = %s, want categories-000002", got)
+	}
+
+	// A failed lookup keeps the last label and isn't retried within the TTL
+	lookupErr = errors.New("es down")
+	now = now.Add(time.Minute)
+	if got, err := labels.label(ctx, "categories-write"); got != "categories-000002" || err == nil {
+		t.Errorf("label on failure = %s, %v; want categories-000002 and the error", got, err)
+	}
+	if got, err := labels.label(ctx, "categories-write"); got != "categories-000002" || err != nil {
+		t.Errorf("label after failure = %s, %v; want categories-000002 without a lookup", got, err)
+	}
+	if lookups != 3 {
+		t.Errorf("%d lookups, want 3", lookups)
+	}
+
+	// An alias never resolved is labelled with itself
+	if got, _ := labels.label(ctx, "products-write"); got != "products-write" {
+		t.Errorf("label of unresolvable alias = %s, want products-write", got)
+	}
+}
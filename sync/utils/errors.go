@@ -1,6 +1,11 @@
 package utils
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
 
 type SyncError struct {
 	Code       string
@@ -20,6 +25,21 @@ func (e *SyncError) Error() string {
 		e.Code, e.Message, e.Operation, e.Entity)
 }
 
+// Unwrap returns the cause, so errors.Is and errors.As see through a
+// SyncError
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches a SyncError against the sentinel for its code, so callers can
+// check errors.Is(err, ErrNotFound) without comparing codes
+func (e *SyncError) Is(target error) bool {
+	return target == ErrNotFound && e.Code == ErrCodeNotFound
+}
+
+// ErrNotFound matches errors for a document that doesn't exist
+var ErrNotFound = errors.New("not found")
+
 // Error codes with categories
 const (
 	// Kafka related errors
@@ -36,6 +56,8 @@ const (
 	ErrCodeESQuery      = "SYNC_ES_005"
 	ErrCodeESConflict   = "SYNC_ES_006"
 	ErrCodeESTimeout    = "SYNC_ES_007"
+	ErrCodeNotFound     = "SYNC_ES_008"
+	ErrCodeESBlocked    = "SYNC_ES_009"
 
 	// Data related errors
 	ErrCodeInvalidPayload = "SYNC_DATA_001"
@@ -47,6 +69,7 @@ const (
 	ErrCodeRetryExhausted = "SYNC_RETRY_001"
 	ErrCodeRetryTimeout   = "SYNC_RETRY_002"
 	ErrCodeRetryCircuit   = "SYNC_RETRY_003"
+	ErrCodeRetryPersist   = "SYNC_RETRY_004"
 
 	// System errors
 	ErrCodeSystemConfig   = "SYNC_SYS_001"
@@ -131,6 +154,63 @@ func NewESIndexError(msg string, err error) *SyncError {
 	}
 }
 
+// NewNotFoundError reports that the requested document does not exist. It
+// matches ErrNotFound.
+func NewNotFoundError(entity string, id string) *SyncError {
+	return &SyncError{
+		Code:       ErrCodeNotFound,
+		Message:    fmt.Sprintf("%s %s not found", entity, id),
+		StatusCode: 404,
+		Operation:  "get",
+		Entity:     entity,
+	}
+}
+
+// NewVersionConflictError reports an update made against a stale version;
+// Current carries the version the caller should re-read and retry with.
+func NewVersionConflictError(entity string, current int64) *VersionConflictError {
+	return &VersionConflictError{
+		SyncError: SyncError{
+			Code:       ErrCodeVersionConflict,
+			Message:    fmt.Sprintf("Version conflict, current version is %d", current),
+			StatusCode: 409,
+			Operation:  "update",
+			Entity:     entity,
+		},
+		Current: current,
+	}
+}
+
+// VersionConflictError is a SyncError that also carries the stored version
+type VersionConflictError struct {
+	SyncError
+	Current int64
+}
+
+// NewPayloadTooLargeError reports an operation whose payload exceeds the
+// configured limit
+func NewPayloadTooLargeError(operation string, entity string, size, limit int) *PayloadTooLargeError {
+	return &PayloadTooLargeError{
+		SyncError: SyncError{
+			Code:       ErrCodeInvalidPayload,
+			Message:    fmt.Sprintf("Payload is %d bytes, over the %d byte limit", size, limit),
+			StatusCode: 413,
+			Operation:  operation,
+			Entity:     entity,
+		},
+		Size:  size,
+		Limit: limit,
+	}
+}
+
+// PayloadTooLargeError is a SyncError that also carries the payload size
+// and the limit it exceeded
+type PayloadTooLargeError struct {
+	SyncError
+	Size  int
+	Limit int
+}
+
 // Add Kafka-specific error constructor
 func NewKafkaConsumerError(msg string, err error, operation string) *SyncError {
 	return &SyncError{
@@ -143,15 +223,72 @@ func NewKafkaConsumerError(msg string, err error, operation string) *SyncError {
 	}
 }
 
-// Add IsRetryableError function to determine if an error should be retried
-func IsRetryableError(err error) bool {
-	if syncErr, ok := err.(*SyncError); ok {
-		switch syncErr.Code {
-		case ErrCodeESIndex, ErrCodeESConnection, ErrCodeKafkaDeserialize:
-			return true
-		default:
-			return false
-		}
+// DefaultRetryableCodes are the codes of the errors retried unless
+// sync.custom.retryable_codes says otherwise
+var DefaultRetryableCodes = []string{ErrCodeESConnection, ErrCodeESIndex}
+
+// RetryableCodes is the set of SyncError codes worth retrying
+type RetryableCodes map[string]bool
+
+// NewRetryableCodes returns the set of codes
+func NewRetryableCodes(codes []string) RetryableCodes {
+	set := make(RetryableCodes, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+// IsRetryable reports whether err is a SyncError whose code is in the set.
+// Deserialization errors shouldn't be: a malformed message stays
+// malformed, and the consumer's deserialize error policy handles it.
+func (c RetryableCodes) IsRetryable(err error) bool {
+	var syncErr *SyncError
+	if errors.As(err, &syncErr) {
+		return c[syncErr.Code]
 	}
 	return false
 }
+
+var defaultRetryableCodes = NewRetryableCodes(DefaultRetryableCodes)
+
+// IsRetryableError reports whether err should be retried under
+// DefaultRetryableCodes
+func IsRetryableError(err error) bool {
+	return defaultRetryableCodes.IsRetryable(err)
+}
+
+// HTTPStatus maps an error to the HTTP status a handler should answer with.
+// Errors that aren't SyncErrors are treated as internal errors.
+func HTTPStatus(err error) int {
+	var conflict *VersionConflictError
+	if errors.As(err, &conflict) {
+		return http.StatusConflict
+	}
+
+	if errors.Is(err, ErrNotFound) {
+		return http.StatusNotFound
+	}
+
+	var syncErr *SyncError
+	if !errors.As(err, &syncErr) {
+		return http.StatusInternalServerError
+	}
+
+	switch syncErr.Code {
+	case ErrCodeVersionConflict, ErrCodeDataConflict, ErrCodeESConflict:
+		return http.StatusConflict
+	case ErrCodeRetryCircuit, ErrCodeESConnection, ErrCodeESBlocked, ErrCodeConnectionFailed, ErrCodeKafkaConnection:
+		return http.StatusServiceUnavailable
+	case ErrCodeESTimeout, ErrCodeRetryTimeout, ErrCodeTimeout:
+		return http.StatusGatewayTimeout
+	}
+
+	if strings.HasPrefix(syncErr.Code, "SYNC_DATA_") || strings.HasPrefix(syncErr.Code, "SYNC_VAL_") {
+		return http.StatusBadRequest
+	}
+	if syncErr.StatusCode != 0 {
+		return syncErr.StatusCode
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSyncErrorIsAndAs(t *testing.T) {
+	timeout := NewESIndexError("Failed to index category", fmt.Errorf("request: %w", context.DeadlineExceeded))
+	notFound := fmt.Errorf("get category: %w", NewNotFoundError("category", "1"))
+
+	if !errors.Is(timeout, context.DeadlineExceeded) {
+		t.Error("errors.Is doesn't find the cause of a SyncError")
+	}
+
+	var syncErr *SyncError
+	if !errors.As(notFound, &syncErr) || syncErr.Code != ErrCodeNotFound {
+		t.Errorf("errors.As through fmt.Errorf = %v, want the not found SyncError", syncErr)
+	}
+
+	if !errors.Is(notFound, ErrNotFound) {
+		t.Error("wrapped not found SyncError doesn't match ErrNotFound")
+	}
+	if errors.Is(timeout, ErrNotFound) {
+		t.Error("SyncError with another code matches ErrNotFound")
+	}
+
+	if got := HTTPStatus(notFound); got != http.StatusNotFound {
+		t.Errorf("HTTPStatus(wrapped not found) = %d, want 404", got)
+	}
+}
+
+func TestRetryableCodesSeeThroughWrapping(t *testing.T) {
+	codes := NewRetryableCodes(DefaultRetryableCodes)
+	wrapped := fmt.Errorf("retry: %w", NewESIndexError("Failed to index category", errors.New("reset")))
+
+	if !codes.IsRetryable(wrapped) {
+		t.Error("wrapped SYNC_ES_002 not retryable")
+	}
+	if codes.IsRetryable(fmt.Errorf("get: %w", NewNotFoundError("category", "1"))) {
+		t.Error("not found error retryable")
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain error", errors.New("boom"), http.StatusInternalServerError},
+		{"not found", NewNotFoundError("category", "1"), http.StatusNotFound},
+		{"version conflict", NewVersionConflictError("category", 3), http.StatusConflict},
+		{"invalid payload", NewDataError(ErrCodeInvalidPayload, "bad json", nil, "category"), http.StatusBadRequest},
+		{"validation", NewDataError(ErrCodeValidationFailed, "no criteria", nil, "category"), http.StatusBadRequest},
+		{"data conflict", NewDataError(ErrCodeDataConflict, "stale", nil, "category"), http.StatusConflict},
+		{"circuit open", NewSyncError(ErrCodeRetryCircuit, "circuit open", nil, "retry", "category"), http.StatusServiceUnavailable},
+		{"es connection", NewESError(ErrCodeESConnection, "unreachable", nil, "index", "categories"), http.StatusServiceUnavailable},
+		{"es timeout", NewESError(ErrCodeESTimeout, "slow", nil, "index", "categories"), http.StatusGatewayTimeout},
+		{"wrapped circuit", fmt.Errorf("sync: %w", NewSyncError(ErrCodeRetryCircuit, "circuit open", nil, "retry", "category")), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatus(tt.err); got != tt.want {
+				t.Errorf("HTTPStatus(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
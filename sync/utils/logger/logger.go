@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,13 +24,22 @@ const (
 )
 
 type Logger interface {
+	// Debug logs only once SetLevel enabled the debug level
+	Debug(ctx context.Context, msg string, fields map[string]interface{})
 	Info(ctx context.Context, msg string, fields map[string]interface{})
 	Error(ctx context.Context, msg string, fields map[string]interface{})
 	WithError(ctx context.Context, err error, msg string, fields map[string]interface{})
+	// SetLevel sets the lowest level logged from app.log_level; "debug"
+	// enables Debug, anything else leaves it silent
+	SetLevel(level string)
 }
 
+// LevelDebug is the app.log_level that enables Debug
+const LevelDebug = "debug"
+
 type logger struct {
 	format string
+	debug  atomic.Bool
 }
 
 func NewLogger(format string) Logger {
@@ -38,6 +48,16 @@ func NewLogger(format string) Logger {
 	}
 }
 
+func (l *logger) SetLevel(level string) {
+	l.debug.Store(level == LevelDebug)
+}
+
+func (l *logger) Debug(ctx context.Context, msg string, fields map[string]interface{}) {
+	if l.debug.Load() {
+		l.log(ctx, "DEBUG", cyan, msg, fields)
+	}
+}
+
 func (l *logger) Info(ctx context.Context, msg string, fields map[string]interface{}) {
 	l.log(ctx, "INFO", green, msg, fields)
 }
@@ -89,6 +109,7 @@ func (l *logger) log(ctx context.Context, level, colorCode string, msg string, f
 
 type PrettyLogger struct {
 	serviceName string
+	debug       atomic.Bool
 }
 
 func NewPrettyLogger(serviceName string) *PrettyLogger {
@@ -99,6 +120,22 @@ func NewPrettyLogger(serviceName string) *PrettyLogger {
 	}
 }
 
+func (l *PrettyLogger) SetLevel(level string) {
+	l.debug.Store(level == LevelDebug)
+}
+
+func (l *PrettyLogger) Debug(ctx context.Context, message string, fields map[string]interface{}) {
+	if !l.debug.Load() {
+		return
+	}
+	logEntry := l.formatLogEntry(ctx, "DEBUG", message, fields)
+	fmt.Printf("· %s\n", message)
+	if len(fields) > 0 {
+		prettyJSON, _ := json.MarshalIndent(logEntry, "", "  ")
+		fmt.Printf("\n%s\n\n", string(prettyJSON))
+	}
+}
+
 func (l *PrettyLogger) Info(ctx context.Context, message string, fields map[string]interface{}) {
 	logEntry := l.formatLogEntry(ctx, "INFO", message, fields)
 	fmt.Printf("▶ %s\n", message)
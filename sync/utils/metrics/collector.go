@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"errors"
+	"strconv"
 	"sync"
 	"time"
 
@@ -18,27 +20,87 @@ type OperationMetrics struct {
 	IndexName   string
 	PayloadSize int
 	ErrorCount  int
+
+	// Debezium source of the operation, empty for REST operations
+	SourceSchema string
+	SourceTable  string
+	// SourceTimestamp is when the change was committed in Postgres, zero
+	// for REST operations
+	SourceTimestamp time.Time
 }
 
 type MetricsCollector struct {
 	mu sync.RWMutex
 
+	// registry is where the collector's metrics are registered
+	registry prometheus.Registerer
+
+	// sourceTables bounds the source_schema/source_table label values;
+	// keys are "schema.table"
+	sourceTables map[string]bool
+
 	// Operation metrics
 	operationDuration *prometheus.HistogramVec
 	operationTotal    *prometheus.CounterVec
 	operationErrors   *prometheus.CounterVec
 	payloadSize       *prometheus.HistogramVec
+	dryRunOperations  *prometheus.CounterVec
+	oversizedPayloads *prometheus.CounterVec
+	e2eLag            *prometheus.HistogramVec
+	documentsIndexed  *prometheus.CounterVec
+	clusterBlocked    *prometheus.CounterVec
+
+	// Retry queue metrics
+	retryAttempts  *prometheus.CounterVec
+	retryExhausted *prometheus.CounterVec
+	retryCount     *prometheus.HistogramVec
 
 	// Bulk operation metrics
 	bulkOperations *prometheus.HistogramVec
+
+	// Consumer metrics
+	consumerRestarts *prometheus.CounterVec
+	consumerErrors   *prometheus.CounterVec
+	markedOffset     *prometheus.GaugeVec
+	consumerLag      *prometheus.GaugeVec
+	snapshotComplete prometheus.Gauge
+	sourceHeartbeat  prometheus.Gauge
+
+	// Kafka Connect API metrics
+	connectRequestDuration *prometheus.HistogramVec
+	connectFailedTasks     *prometheus.GaugeVec
 }
 
+// NewMetricsCollector creates a collector registered with the default
+// Prometheus registry, which the /metrics endpoint serves
 func NewMetricsCollector() *MetricsCollector {
-	mc := &MetricsCollector{}
+	return NewMetricsCollectorWithRegistry(prometheus.DefaultRegisterer)
+}
+
+// NewMetricsCollectorWithRegistry creates a collector registered with
+// registry. Collectors sharing a registry share its metrics instead of
+// panicking on duplicate registration.
+func NewMetricsCollectorWithRegistry(registry prometheus.Registerer) *MetricsCollector {
+	mc := &MetricsCollector{registry: registry}
 	mc.initMetrics()
 	return mc
 }
 
+// register registers c with registry, returning the collector already
+// registered under the same descriptors if there is one
+func register[T prometheus.Collector](registry prometheus.Registerer, c T) T {
+	if err := registry.Register(c); err != nil {
+		var existing prometheus.AlreadyRegisteredError
+		if errors.As(err, &existing) {
+			if prev, ok := existing.ExistingCollector.(T); ok {
+				return prev
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
 func (mc *MetricsCollector) initMetrics() {
 	mc.operationDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -46,9 +108,9 @@ func (mc *MetricsCollector) initMetrics() {
 			Name:      "operation_duration_seconds",
 			Help:      "Duration of sync operations",
 		},
-		[]string{"operation", "entity", "status"},
+		[]string{"operation", "entity", "status", "source_schema", "source_table"},
 	)
-	prometheus.MustRegister(mc.operationDuration)
+	mc.operationDuration = register(mc.registry, mc.operationDuration)
 
 	mc.operationTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -56,9 +118,41 @@ func (mc *MetricsCollector) initMetrics() {
 			Name:      "operations_total",
 			Help:      "Total number of sync operations",
 		},
-		[]string{"operation", "entity", "status"},
+		[]string{"operation", "entity", "status", "source_schema", "source_table"},
+	)
+	mc.operationTotal = register(mc.registry, mc.operationTotal)
+
+	// Labelled by the concrete index behind the write alias, which only
+	// changes on rollover, so its cardinality stays low
+	mc.documentsIndexed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync",
+			Name:      "documents_indexed_total",
+			Help:      "Total number of documents written, by the index they were written to",
+		},
+		[]string{"index", "operation"},
+	)
+	mc.documentsIndexed = register(mc.registry, mc.documentsIndexed)
+
+	mc.clusterBlocked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync",
+			Name:      "es_cluster_blocked_total",
+			Help:      "Total number of writes refused because the index is closed or read-only",
+		},
+		[]string{"index", "block"},
 	)
-	prometheus.MustRegister(mc.operationTotal)
+	mc.clusterBlocked = register(mc.registry, mc.clusterBlocked)
+
+	mc.dryRunOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync",
+			Name:      "dry_run_operations_total",
+			Help:      "Total number of operations skipped by dry run",
+		},
+		[]string{"operation", "entity"},
+	)
+	mc.dryRunOperations = register(mc.registry, mc.dryRunOperations)
 
 	mc.operationErrors = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -66,9 +160,9 @@ func (mc *MetricsCollector) initMetrics() {
 			Name:      "operation_errors_total",
 			Help:      "Total number of sync operation errors",
 		},
-		[]string{"operation", "entity"},
+		[]string{"operation", "entity", "source_schema", "source_table"},
 	)
-	prometheus.MustRegister(mc.operationErrors)
+	mc.operationErrors = register(mc.registry, mc.operationErrors)
 
 	mc.payloadSize = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -79,7 +173,59 @@ func (mc *MetricsCollector) initMetrics() {
 		},
 		[]string{"operation", "entity"},
 	)
-	prometheus.MustRegister(mc.payloadSize)
+	mc.payloadSize = register(mc.registry, mc.payloadSize)
+
+	mc.oversizedPayloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync",
+			Name:      "oversized_payloads_total",
+			Help:      "Total number of operations rejected for exceeding the payload size limit",
+		},
+		[]string{"operation", "entity"},
+	)
+	mc.oversizedPayloads = register(mc.registry, mc.oversizedPayloads)
+
+	mc.e2eLag = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "sync",
+			Name:      "e2e_lag_seconds",
+			Help:      "Time from a change's commit in Postgres to it being written to Elasticsearch",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 15),
+		},
+		[]string{"operation", "entity"},
+	)
+	mc.e2eLag = register(mc.registry, mc.e2eLag)
+
+	mc.retryAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync",
+			Name:      "retry_attempts_total",
+			Help:      "Total number of retry attempts made by the retry queue",
+		},
+		[]string{"operation", "entity"},
+	)
+	mc.retryAttempts = register(mc.registry, mc.retryAttempts)
+
+	mc.retryExhausted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync",
+			Name:      "retry_exhausted_total",
+			Help:      "Total number of operations abandoned by the retry queue",
+		},
+		[]string{"operation", "entity"},
+	)
+	mc.retryExhausted = register(mc.registry, mc.retryExhausted)
+
+	mc.retryCount = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "sync",
+			Name:      "retry_count",
+			Help:      "Number of retries an operation took before it succeeded or was abandoned",
+			Buckets:   prometheus.LinearBuckets(1, 1, 10),
+		},
+		[]string{"operation", "outcome"},
+	)
+	mc.retryCount = register(mc.registry, mc.retryCount)
 
 	mc.bulkOperations = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -89,36 +235,207 @@ func (mc *MetricsCollector) initMetrics() {
 		},
 		[]string{"entity", "status"},
 	)
-	prometheus.MustRegister(mc.bulkOperations)
+	mc.bulkOperations = register(mc.registry, mc.bulkOperations)
+
+	mc.consumerRestarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync",
+			Name:      "consumer_restarts_total",
+			Help:      "Total number of Kafka consumer restart attempts",
+		},
+		[]string{"outcome"},
+	)
+	mc.consumerRestarts = register(mc.registry, mc.consumerRestarts)
+
+	mc.consumerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "sync",
+			Name:      "kafka_consumer_errors_total",
+			Help:      "Total number of errors reported by the Kafka consumer group, by type",
+		},
+		[]string{"type"},
+	)
+	mc.consumerErrors = register(mc.registry, mc.consumerErrors)
+
+	mc.markedOffset = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "sync",
+			Name:      "consumer_marked_offset",
+			Help:      "Last offset the consumer marked for commit per partition",
+		},
+		[]string{"topic", "partition"},
+	)
+	mc.markedOffset = register(mc.registry, mc.markedOffset)
+
+	mc.consumerLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "sync",
+			Name:      "consumer_lag_messages",
+			Help:      "High-water mark minus marked offset per partition",
+		},
+		[]string{"topic", "partition"},
+	)
+	mc.consumerLag = register(mc.registry, mc.consumerLag)
+
+	mc.snapshotComplete = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "sync",
+			Name:      "snapshot_complete",
+			Help:      "1 once the initial Debezium snapshot has been consumed",
+		},
+	)
+	mc.snapshotComplete = register(mc.registry, mc.snapshotComplete)
+
+	mc.sourceHeartbeat = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "sync",
+			Name:      "source_heartbeat_timestamp_seconds",
+			Help:      "Time of the latest Debezium heartbeat; time() minus this is how stale the source is",
+		},
+	)
+	mc.sourceHeartbeat = register(mc.registry, mc.sourceHeartbeat)
+
+	mc.connectRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "sync",
+			Name:      "connect_request_duration_seconds",
+			Help:      "Latency of Kafka Connect REST API requests",
+		},
+		[]string{"endpoint", "outcome"},
+	)
+	mc.connectRequestDuration = register(mc.registry, mc.connectRequestDuration)
+
+	mc.connectFailedTasks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "sync",
+			Name:      "connect_failed_tasks",
+			Help:      "Number of a Kafka Connect connector's tasks in the FAILED state",
+		},
+		[]string{"connector"},
+	)
+	mc.connectFailedTasks = register(mc.registry, mc.connectFailedTasks)
 }
 
 func (mc *MetricsCollector) RecordOperation(metrics *OperationMetrics) {
 	mc.mu.RLock()
 	defer mc.mu.RUnlock()
 
+	schema, table := mc.sourceLabels(metrics.SourceSchema, metrics.SourceTable)
+
 	mc.operationDuration.WithLabelValues(
 		metrics.Operation,
 		metrics.Entity,
 		metrics.Status,
+		schema,
+		table,
 	).Observe(metrics.Duration.Seconds())
 
 	mc.operationTotal.WithLabelValues(
 		metrics.Operation,
 		metrics.Entity,
 		metrics.Status,
+		schema,
+		table,
 	).Inc()
 
 	mc.payloadSize.WithLabelValues(
 		metrics.Operation,
 		metrics.Entity,
 	).Observe(float64(metrics.PayloadSize))
+
+	if metrics.Status == "SUCCESS" && metrics.IndexName != "" {
+		mc.documentsIndexed.WithLabelValues(metrics.IndexName, metrics.Operation).Inc()
+	}
+
+	if metrics.Status == "SUCCESS" && !metrics.SourceTimestamp.IsZero() {
+		mc.e2eLag.WithLabelValues(
+			metrics.Operation,
+			metrics.Entity,
+		).Observe(metrics.EndTime.Sub(metrics.SourceTimestamp).Seconds())
+	}
+}
+
+// RecordE2ELag records how long after its commit in Postgres a change was
+// written to Elasticsearch
+func (mc *MetricsCollector) RecordE2ELag(operation, entity string, lag time.Duration) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	mc.e2eLag.WithLabelValues(operation, entity).Observe(lag.Seconds())
+}
+
+// RecordDryRunOperation counts an operation whose write dry run skipped
+func (mc *MetricsCollector) RecordDryRunOperation(operation, entity string) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	mc.dryRunOperations.WithLabelValues(operation, entity).Inc()
 }
 
-func (mc *MetricsCollector) RecordError(operation, entity string, count int) {
+// RecordClusterBlocked counts a write refused by a block on index; block is
+// "closed", "read_only" or "blocked"
+func (mc *MetricsCollector) RecordClusterBlocked(index, block string) {
 	mc.mu.RLock()
 	defer mc.mu.RUnlock()
+	mc.clusterBlocked.WithLabelValues(index, block).Inc()
+}
+
+// RecordOversizedPayload counts an operation rejected for its payload size
+func (mc *MetricsCollector) RecordOversizedPayload(operation, entity string) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	mc.oversizedPayloads.WithLabelValues(operation, entity).Inc()
+}
+
+// RecordRetryAttempt counts one attempt of the retry queue
+func (mc *MetricsCollector) RecordRetryAttempt(operation, entity string) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	mc.retryAttempts.WithLabelValues(operation, entity).Inc()
+}
+
+// RecordRetryOutcome records how many retries an operation took once the
+// retry queue is done with it. A failed outcome means it was abandoned.
+func (mc *MetricsCollector) RecordRetryOutcome(operation, entity string, retries int, succeeded bool) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	outcome := "success"
+	if !succeeded {
+		outcome = "exhausted"
+		mc.retryExhausted.WithLabelValues(operation, entity).Inc()
+	}
+	mc.retryCount.WithLabelValues(operation, outcome).Observe(float64(retries))
+}
+
+func (mc *MetricsCollector) RecordError(operation, entity, sourceSchema, sourceTable string, count int) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	schema, table := mc.sourceLabels(sourceSchema, sourceTable)
+	mc.operationErrors.WithLabelValues(operation, entity, schema, table).Add(float64(count))
+}
+
+// SetSourceTables sets the "schema.table" names allowed as source labels
+func (mc *MetricsCollector) SetSourceTables(tables []string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
 
-	mc.operationErrors.WithLabelValues(operation, entity).Add(float64(count))
+	mc.sourceTables = make(map[string]bool, len(tables))
+	for _, t := range tables {
+		mc.sourceTables[t] = true
+	}
+}
+
+// sourceLabels keeps label cardinality bounded: operations without a source
+// are labelled "none", and sources outside the allow-list "other". Callers
+// must hold mc.mu.
+func (mc *MetricsCollector) sourceLabels(schema, table string) (string, string) {
+	if schema == "" && table == "" {
+		return "none", "none"
+	}
+	if !mc.sourceTables[schema+"."+table] {
+		return "other", "other"
+	}
+	return schema, table
 }
 
 func (mc *MetricsCollector) RecordBulkOperation(entity string, size int, hasError bool) {
@@ -132,14 +449,92 @@ func (mc *MetricsCollector) RecordBulkOperation(entity string, size int, hasErro
 	mc.bulkOperations.WithLabelValues(entity, status).Observe(float64(size))
 }
 
+// RecordConsumerRestart counts a consumer restart; outcome is "restarted"
+// or "exhausted" once the restart budget is used up.
+func (mc *MetricsCollector) RecordConsumerRestart(outcome string) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	mc.consumerRestarts.WithLabelValues(outcome).Inc()
+}
+
+// RecordConsumerError counts an error from the consumer group; errorType is
+// "rebalance", "broker_unreachable", "auth" or "other"
+func (mc *MetricsCollector) RecordConsumerError(errorType string) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	mc.consumerErrors.WithLabelValues(errorType).Inc()
+}
+
+// RecordOffsetMarked sets the offset marked for commit and the lag for a
+// partition
+func (mc *MetricsCollector) RecordOffsetMarked(topic string, partition int32, offset, lag int64) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	p := strconv.Itoa(int(partition))
+	mc.markedOffset.WithLabelValues(topic, p).Set(float64(offset))
+	mc.consumerLag.WithLabelValues(topic, p).Set(float64(lag))
+}
+
+// RecordSnapshotComplete flags the initial snapshot as consumed
+func (mc *MetricsCollector) RecordSnapshotComplete() {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	mc.snapshotComplete.Set(1)
+}
+
+// RecordSourceHeartbeat records the time of a Debezium heartbeat
+func (mc *MetricsCollector) RecordSourceHeartbeat(at time.Time) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	mc.sourceHeartbeat.Set(float64(at.UnixMilli()) / 1000)
+}
+
+// RecordConnectRequest observes a Kafka Connect API call; outcome is
+// "success", "error" or "http_<status>"
+func (mc *MetricsCollector) RecordConnectRequest(endpoint, outcome string, duration time.Duration) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	mc.connectRequestDuration.WithLabelValues(endpoint, outcome).Observe(duration.Seconds())
+}
+
+// RecordConnectFailedTasks sets how many of connector's tasks have failed
+func (mc *MetricsCollector) RecordConnectFailedTasks(connector string, failed int) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	mc.connectFailedTasks.WithLabelValues(connector).Set(float64(failed))
+}
+
 func (mc *MetricsCollector) Cleanup() {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 
 	// Unregister all metrics
-	prometheus.Unregister(mc.operationDuration)
-	prometheus.Unregister(mc.operationTotal)
-	prometheus.Unregister(mc.operationErrors)
-	prometheus.Unregister(mc.payloadSize)
-	prometheus.Unregister(mc.bulkOperations)
+	mc.registry.Unregister(mc.operationDuration)
+	mc.registry.Unregister(mc.operationTotal)
+	mc.registry.Unregister(mc.documentsIndexed)
+	mc.registry.Unregister(mc.clusterBlocked)
+	mc.registry.Unregister(mc.operationErrors)
+	mc.registry.Unregister(mc.payloadSize)
+	mc.registry.Unregister(mc.dryRunOperations)
+	mc.registry.Unregister(mc.oversizedPayloads)
+	mc.registry.Unregister(mc.e2eLag)
+	mc.registry.Unregister(mc.retryAttempts)
+	mc.registry.Unregister(mc.retryExhausted)
+	mc.registry.Unregister(mc.retryCount)
+	mc.registry.Unregister(mc.bulkOperations)
+	mc.registry.Unregister(mc.consumerRestarts)
+	mc.registry.Unregister(mc.consumerErrors)
+	mc.registry.Unregister(mc.markedOffset)
+	mc.registry.Unregister(mc.consumerLag)
+	mc.registry.Unregister(mc.snapshotComplete)
+	mc.registry.Unregister(mc.sourceHeartbeat)
+	mc.registry.Unregister(mc.connectRequestDuration)
+	mc.registry.Unregister(mc.connectFailedTasks)
 }
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// counterTotal sums the samples of the counter name in registry
+func counterTotal(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var total float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			total += metric.GetCounter().GetValue()
+		}
+	}
+	return total
+}
+
+func TestTwoCollectorsDoNotPanic(t *testing.T) {
+	// Both register with the default registry, as NewSyncService does
+	NewMetricsCollector()
+	NewMetricsCollector()
+
+	registry := prometheus.NewRegistry()
+	first := NewMetricsCollectorWithRegistry(registry)
+	second := NewMetricsCollectorWithRegistry(registry)
+
+	// Collectors sharing a registry share its metrics
+	first.RecordConsumerRestart("restarted")
+	second.RecordConsumerRestart("restarted")
+	if got := counterTotal(t, registry, "sync_consumer_restarts_total"); got != 2 {
+		t.Errorf("sync_consumer_restarts_total = %v, want both collectors counted", got)
+	}
+
+	other := prometheus.NewRegistry()
+	NewMetricsCollectorWithRegistry(other).RecordConsumerRestart("restarted")
+	if got := counterTotal(t, other, "sync_consumer_restarts_total"); got != 1 {
+		t.Errorf("sync_consumer_restarts_total on a separate registry = %v, want 1", got)
+	}
+}
+
+// histogramTotals returns the sample count and sum of the histogram name in
+// registry, across its label values
+func histogramTotals(t *testing.T, registry *prometheus.Registry, name string) (uint64, float64) {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var count uint64
+	var sum float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			count += metric.GetHistogram().GetSampleCount()
+			sum += metric.GetHistogram().GetSampleSum()
+		}
+	}
+	return count, sum
+}
+
+func TestRecordOperationObservesE2ELag(t *testing.T) {
+	committed := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	written := committed.Add(3 * time.Second)
+
+	tests := []struct {
+		name      string
+		status    string
+		source    time.Time
+		wantCount uint64
+	}{
+		{"success with source timestamp", "SUCCESS", committed, 1},
+		{"rest operation without source timestamp", "SUCCESS", time.Time{}, 0},
+		{"failure", "ERROR", committed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			mc := NewMetricsCollectorWithRegistry(registry)
+
+			mc.RecordOperation(&OperationMetrics{
+				StartTime:       written.Add(-time.Millisecond),
+				EndTime:         written,
+				Operation:       "UPDATE",
+				Entity:          "category",
+				Status:          tt.status,
+				SourceTimestamp: tt.source,
+			})
+
+			count, sum := histogramTotals(t, registry, "sync_e2e_lag_seconds")
+			if count != tt.wantCount {
+				t.Fatalf("sync_e2e_lag_seconds has %d samples, want %d", count, tt.wantCount)
+			}
+			if count == 1 && sum != 3 {
+				t.Errorf("sync_e2e_lag_seconds observed %vs, want 3s", sum)
+			}
+		})
+	}
+}
+
+func TestRecordE2ELag(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mc := NewMetricsCollectorWithRegistry(registry)
+
+	mc.RecordE2ELag("CREATE", "category", 1500*time.Millisecond)
+	mc.RecordE2ELag("DELETE", "category", 500*time.Millisecond)
+
+	count, sum := histogramTotals(t, registry, "sync_e2e_lag_seconds")
+	if count != 2 || sum != 2 {
+		t.Errorf("sync_e2e_lag_seconds has %d samples summing to %vs, want 2 summing to 2s", count, sum)
+	}
+}
+
+func TestRetryMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mc := NewMetricsCollectorWithRegistry(registry)
+
+	// One operation succeeds on its second retry, another is abandoned
+	// after five
+	for i := 0; i < 2; i++ {
+		mc.RecordRetryAttempt("UPDATE", "category")
+	}
+	mc.RecordRetryOutcome("UPDATE", "category", 2, true)
+	for i := 0; i < 5; i++ {
+		mc.RecordRetryAttempt("DELETE", "category")
+	}
+	mc.RecordRetryOutcome("DELETE", "category", 5, false)
+
+	if got := counterTotal(t, registry, "sync_retry_attempts_total"); got != 7 {
+		t.Errorf("sync_retry_attempts_total = %v, want 7", got)
+	}
+	if got := counterTotal(t, registry, "sync_retry_exhausted_total"); got != 1 {
+		t.Errorf("sync_retry_exhausted_total = %v, want 1", got)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	retries := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "sync_retry_count" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "outcome" {
+					retries[label.GetValue()] += metric.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+	if retries["success"] != 2 || retries["exhausted"] != 5 {
+		t.Errorf("sync_retry_count by outcome = %v, want success 2 and exhausted 5", retries)
+	}
+}
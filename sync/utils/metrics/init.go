@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
@@ -11,15 +12,17 @@ import (
 	"go.opentelemetry.io/otel/sdk/trace"
 )
 
-func InitPrometheus(port int, path string) error {
-	http.Handle(path, promhttp.Handler())
-	go func() {
-		addr := fmt.Sprintf(":%d", port)
-		if err := http.ListenAndServe(addr, nil); err != nil {
-			panic(fmt.Sprintf("Failed to start metrics server: %v", err))
-		}
-	}()
-	return nil
+// InitPrometheus serves Prometheus metrics at path on mux, the server's
+// own mux rather than http.DefaultServeMux. Registering a path that is
+// already registered is a no-op, so it is safe to call more than once.
+func InitPrometheus(mux *http.ServeMux, path string) {
+	if path == "" {
+		path = "/metrics"
+	}
+	if _, pattern := mux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}}); pattern == path {
+		return
+	}
+	mux.Handle(path, promhttp.Handler())
 }
 
 func InitTracing(serviceName, collectorURL string) error {
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInitPrometheusTwiceDoesNotPanic(t *testing.T) {
+	mux := http.NewServeMux()
+	InitPrometheus(mux, "/metrics")
+	InitPrometheus(mux, "")
+	InitPrometheus(mux, "/internal/metrics")
+
+	NewMetricsCollector().RecordConsumerRestart("restarted")
+
+	for _, path := range []string{"/metrics", "/internal/metrics"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "sync_consumer_restarts_total") {
+			t.Errorf("GET %s = %d, want the collector's metrics", path, rec.Code)
+		}
+	}
+
+	// Only the mux it was given serves metrics
+	if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/metrics", nil)); pattern == "/metrics" {
+		t.Error("InitPrometheus registered on http.DefaultServeMux")
+	}
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// WaitPolicy bounds how long startup waits for a dependency to become
+// reachable: up to MaxAttempts checks, backing off exponentially from
+// Backoff to at most MaxBackoff between them
+type WaitPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// WaitFor calls check until it succeeds or the policy's attempts run out,
+// returning the last error. onRetry, if set, is called after each failed
+// attempt that will be retried.
+func WaitFor(ctx context.Context, policy WaitPolicy, check func(ctx context.Context) error,
+	onRetry func(attempt int, wait time.Duration, err error)) error {
+	attempts := policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	wait := policy.Backoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = check(ctx); err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return err
+		}
+
+		if onRetry != nil {
+			onRetry(attempt, wait, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+		if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+			wait = policy.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWaitForSucceedsOnThirdTry(t *testing.T) {
+	calls := 0
+	check := func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	var waits []time.Duration
+	policy := WaitPolicy{MaxAttempts: 5, Backoff: time.Millisecond, MaxBackoff: 3 * time.Millisecond}
+	err := WaitFor(context.Background(), policy, check, func(attempt int, wait time.Duration, err error) {
+		waits = append(waits, wait)
+	})
+
+	if err != nil {
+		t.Fatalf("WaitFor: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("%d checks, want 3", calls)
+	}
+	if want := []time.Duration{time.Millisecond, 2 * time.Millisecond}; !reflect.DeepEqual(waits, want) {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+}
+
+func TestWaitForGivesUp(t *testing.T) {
+	down := errors.New("connection refused")
+
+	t.Run("after max attempts", func(t *testing.T) {
+		calls := 0
+		var waits []time.Duration
+		policy := WaitPolicy{MaxAttempts: 4, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+		err := WaitFor(context.Background(), policy, func(ctx context.Context) error {
+			calls++
+			return down
+		}, func(attempt int, wait time.Duration, err error) {
+			waits = append(waits, wait)
+		})
+
+		if !errors.Is(err, down) || calls != 4 {
+			t.Errorf("WaitFor = %v after %d checks, want the check's error after 4", err, calls)
+		}
+		// Backoff doubles up to MaxBackoff, and the last failure isn't
+		// followed by a wait
+		if want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 2 * time.Millisecond}; !reflect.DeepEqual(waits, want) {
+			t.Errorf("waits = %v, want %v", waits, want)
+		}
+	})
+
+	t.Run("when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		policy := WaitPolicy{MaxAttempts: 10, Backoff: time.Hour}
+		err := WaitFor(ctx, policy, func(ctx context.Context) error { return down }, nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("WaitFor = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("without attempts", func(t *testing.T) {
+		calls := 0
+		err := WaitFor(context.Background(), WaitPolicy{}, func(ctx context.Context) error {
+			calls++
+			return down
+		}, nil)
+		if !errors.Is(err, down) || calls != 1 {
+			t.Errorf("WaitFor = %v after %d checks, want a single check", err, calls)
+		}
+	})
+}